@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// parseDSN turns a "type:location" string into a store type and the config
+// storage.NewStore expects for it. The location is a directory for jsonfile,
+// a file for sqlite and bolt, and a connection string for postgres:
+//
+//	jsonfile:./data/default
+//	sqlite:./olu.db
+//	bolt:./olu.bolt
+//	postgres:postgres://localhost:5432/olu?sslmode=disable
+func parseDSN(dsn string) (string, map[string]interface{}, error) {
+	storeType, location, ok := strings.Cut(dsn, ":")
+	if !ok || storeType == "" || location == "" {
+		return "", nil, fmt.Errorf("invalid store %q: expected type:location", dsn)
+	}
+
+	if !isRegistered(storeType) {
+		return "", nil, fmt.Errorf("unknown store type %q (available: %s)",
+			storeType, strings.Join(storage.ListStores(), ", "))
+	}
+
+	switch storeType {
+	case "jsonfile":
+		location = filepath.Clean(location)
+		return storeType, map[string]interface{}{
+			"base_dir": filepath.Dir(location),
+			"schema":   filepath.Base(location),
+		}, nil
+	case "sqlite":
+		return storeType, map[string]interface{}{"db_path": location}, nil
+	case "bolt":
+		return storeType, map[string]interface{}{"bolt_path": location}, nil
+	case "postgres":
+		// Accept a bare URL too: "postgres://host/db" splits into
+		// "postgres" and "//host/db"
+		if strings.HasPrefix(location, "//") {
+			location = dsn
+		}
+		return storeType, map[string]interface{}{"dsn": location}, nil
+	default:
+		return storeType, map[string]interface{}{"path": location}, nil
+	}
+}
+
+// openStore opens the store described by a "type:location" string
+func openStore(dsn string) (storage.Store, error) {
+	storeType, config, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewStore(storeType, config)
+}
+
+// localPath returns the file or directory a store config points at, or ""
+// for stores that are not local
+func localPath(config map[string]interface{}) string {
+	if dir, ok := config["base_dir"].(string); ok {
+		return filepath.Join(dir, config["schema"].(string))
+	}
+	for _, key := range []string{"db_path", "bolt_path"} {
+		if path, ok := config[key].(string); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+func isRegistered(storeType string) bool {
+	for _, name := range storage.ListStores() {
+		if name == storeType {
+			return true
+		}
+	}
+	return false
+}
@@ -2,142 +2,323 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"path/filepath"
+	"sort"
 
 	"github.com/ha1tch/olu/pkg/storage"
 )
 
+// options are the command-line settings of a migration
+type options struct {
+	From      string
+	To        string
+	Resume    bool
+	DryRun    bool
+	BatchSize int
+}
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: olu-migrate <source-dir> <target-db>")
-		fmt.Println("Example: olu-migrate ./data/default ./olu.db")
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 		os.Exit(1)
 	}
+}
 
-	sourceDir := os.Args[1]
-	targetDB := os.Args[2]
-
-	if err := migrate(sourceDir, targetDB); err != nil {
-		log.Fatal(err)
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "schema" {
+		return runSchema(ctx, args[1:], out)
 	}
 
-	fmt.Println("Migration completed successfully!")
-}
-
-func migrate(sourceDir, targetDB string) error {
-	ctx := context.Background()
+	flags := flag.NewFlagSet("olu-migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		fmt.Fprintln(out, "Usage: olu-migrate --from <type:location> --to <type:location> [flags]")
+		fmt.Fprintln(out, "       olu-migrate schema <up|status> <type:location>")
+		fmt.Fprintln(out, "Example: olu-migrate --from jsonfile:./data/default --to sqlite:./olu.db")
+		fmt.Fprintln(out)
+		flags.PrintDefaults()
+	}
 
-	// Check if source exists
-	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
-		return fmt.Errorf("source directory does not exist: %s", sourceDir)
+	var opts options
+	flags.StringVar(&opts.From, "from", "", "source store, e.g. jsonfile:./data/default")
+	flags.StringVar(&opts.To, "to", "", "target store, e.g. sqlite:./olu.db")
+	flags.BoolVar(&opts.Resume, "resume", false, "skip entities already in the target, continuing an interrupted migration")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "read the source and report what would be copied without touching the target")
+	flags.IntVar(&opts.BatchSize, "batch-size", 500, "entities written per batch")
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
 
-	// Check if target exists
-	if _, err := os.Stat(targetDB); err == nil {
-		return fmt.Errorf("target database already exists: %s (delete it first)", targetDB)
+	if opts.From == "" || opts.To == "" {
+		flags.Usage()
+		return errors.New("both --from and --to are required")
+	}
+	if opts.From == opts.To {
+		return errors.New("source and target are the same store")
+	}
+	if opts.BatchSize < 1 {
+		return errors.New("--batch-size must be at least 1")
 	}
 
-	// Create source store (JSONFile)
-	fmt.Println("Opening source (JSONFile)...")
-	baseDir := filepath.Dir(sourceDir)
-	schema := filepath.Base(sourceDir)
+	return migrate(ctx, opts, out)
+}
 
-	sourceStore, err := storage.NewStore("jsonfile", map[string]interface{}{
-		"base_dir": baseDir,
-		"schema":   schema,
-	})
+func migrate(ctx context.Context, opts options, out io.Writer) error {
+	// Opening a local store creates it, so check the source is really there
+	_, sourceConfig, err := parseDSN(opts.From)
 	if err != nil {
-		return fmt.Errorf("failed to open source: %w", err)
+		return fmt.Errorf("invalid source: %w", err)
+	}
+	if path := localPath(sourceConfig); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("source does not exist: %s", path)
+		}
 	}
-	defer sourceStore.Close()
 
-	// Create target store (SQLite)
-	fmt.Println("Creating target (SQLite)...")
-	targetStore, err := storage.NewStore("sqlite", map[string]interface{}{
-		"db_path": targetDB,
-	})
+	fmt.Fprintf(out, "Opening source %s...\n", opts.From)
+	source, err := openStore(opts.From)
 	if err != nil {
-		return fmt.Errorf("failed to create target: %w", err)
+		return fmt.Errorf("failed to open source: %w", err)
 	}
-	defer targetStore.Close()
+	defer source.Close()
 
-	// Get all entity types from source directory
-	entries, err := os.ReadDir(sourceDir)
+	entities, err := listEntities(ctx, source)
 	if err != nil {
-		return fmt.Errorf("failed to read source directory: %w", err)
+		return fmt.Errorf("failed to list source entity types: %w", err)
 	}
 
-	totalEntities := 0
-	totalEdges := 0
+	if opts.DryRun {
+		// Validate the target without opening it, since opening creates it
+		if _, _, err := parseDSN(opts.To); err != nil {
+			return fmt.Errorf("invalid target: %w", err)
+		}
+		return dryRun(ctx, source, entities, out)
+	}
 
-	// Migrate each entity type
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	fmt.Fprintf(out, "Opening target %s...\n", opts.To)
+	target, err := openStore(opts.To)
+	if err != nil {
+		return fmt.Errorf("failed to open target: %w", err)
+	}
+	defer target.Close()
+
+	if !opts.Resume {
+		for _, entity := range entities {
+			items, err := target.List(ctx, entity)
+			if err != nil {
+				return fmt.Errorf("failed to read target %s: %w", entity, err)
+			}
+			if len(items) > 0 {
+				return fmt.Errorf("target already holds %s entities (use --resume to continue an interrupted migration)", entity)
+			}
 		}
+	}
 
-		entityType := entry.Name()
-		fmt.Printf("Migrating %s...\n", entityType)
+	totalCopied, totalSkipped := 0, 0
+	for _, entity := range entities {
+		fmt.Fprintf(out, "Migrating %s...\n", entity)
+		items, err := sortedItems(ctx, source, entity, out)
+		if err != nil {
+			return err
+		}
 
-		// List all entities of this type
-		entities, err := sourceStore.List(ctx, entityType)
+		copied, skipped, err := copyEntities(ctx, target, entity, items, opts)
+		totalCopied += copied
+		totalSkipped += skipped
 		if err != nil {
-			fmt.Printf("  Warning: failed to list %s: %v\n", entityType, err)
-			continue
+			return fmt.Errorf("failed to migrate %s: %w", entity, err)
 		}
+		fmt.Fprintf(out, "  Copied %d, skipped %d\n", copied, skipped)
+	}
 
-		// Migrate each entity
-		for _, entity := range entities {
-			// Get ID
-			id, ok := entity["id"].(int)
-			if !ok {
-				if idFloat, ok := entity["id"].(float64); ok {
-					id = int(idFloat)
-				} else {
-					fmt.Printf("  Warning: entity without valid ID: %v\n", entity)
+	schemas, err := copySchemas(ctx, source, target)
+	if err != nil {
+		return fmt.Errorf("failed to migrate schemas: %w", err)
+	}
+
+	fmt.Fprintf(out, "\nMigration summary:\n")
+	fmt.Fprintf(out, "  Schemas: %d\n", schemas)
+	fmt.Fprintf(out, "  Entity types: %d\n", len(entities))
+	fmt.Fprintf(out, "  Copied: %d\n", totalCopied)
+	fmt.Fprintf(out, "  Skipped: %d\n", totalSkipped)
+
+	return verify(ctx, source, target, entities, out)
+}
+
+// copyEntities writes items to the target in batches under their source IDs,
+// returning how many were copied and how many skipped as already present
+func copyEntities(ctx context.Context, target storage.Store, entity string, items []map[string]interface{}, opts options) (int, int, error) {
+	copied, skipped := 0, 0
+	for start := 0; start < len(items); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		batch := items[start:end]
+		if opts.Resume {
+			pending := make([]map[string]interface{}, 0, len(batch))
+			for _, item := range batch {
+				id, _ := itemID(item)
+				if target.Exists(ctx, entity, id) {
+					skipped++
 					continue
 				}
+				pending = append(pending, item)
 			}
+			batch = pending
+		}
+		if len(batch) == 0 {
+			continue
+		}
 
-			// Save to target
-			if err := targetStore.Save(ctx, entityType, id, entity); err != nil {
-				return fmt.Errorf("failed to migrate %s:%d: %w", entityType, id, err)
-			}
+		if err := saveBatch(ctx, target, entity, batch); err != nil {
+			return copied, skipped, err
+		}
+		copied += len(batch)
+	}
+	return copied, skipped, nil
+}
 
-			totalEntities++
+// saveBatch saves a batch in one call when the target supports batches, and
+// otherwise item by item inside a transaction
+func saveBatch(ctx context.Context, target storage.Store, entity string, batch []map[string]interface{}) error {
+	if batcher, ok := target.(storage.Batcher); ok {
+		return batcher.BatchSave(ctx, entity, batch)
+	}
 
-			// Count REF fields (edges)
-			for _, value := range entity {
-				if valueMap, ok := value.(map[string]interface{}); ok {
-					if valueMap["type"] == "REF" {
-						totalEdges++
-					}
-				}
+	return storage.WithTransaction(ctx, target, func(tx storage.Transaction) error {
+		for _, item := range batch {
+			id, _ := itemID(item)
+			if err := tx.Save(ctx, entity, id, item); err != nil {
+				return fmt.Errorf("id %d: %w", id, err)
 			}
 		}
+		return nil
+	})
+}
 
-		fmt.Printf("  Migrated %d entities\n", len(entities))
+// copySchemas copies every JSON schema when both stores keep schemas,
+// replacing any the target already has for the same entity type
+func copySchemas(ctx context.Context, source, target storage.Store) (int, error) {
+	from, ok := source.(storage.SchemaStore)
+	if !ok {
+		return 0, nil
+	}
+	to, ok := target.(storage.SchemaStore)
+	if !ok {
+		return 0, nil
 	}
 
-	fmt.Printf("\nMigration summary:\n")
-	fmt.Printf("  Total entities: %d\n", totalEntities)
-	fmt.Printf("  Total edges: %d\n", totalEdges)
+	schemas, err := from.ListSchemas(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for entity, schema := range schemas {
+		if err := to.SaveSchema(ctx, entity, schema); err != nil {
+			return 0, fmt.Errorf("%s: %w", entity, err)
+		}
+	}
+	return len(schemas), nil
+}
 
-	// Verify graph integrity
-	fmt.Println("\nVerifying graph integrity...")
-	type IntegrityChecker interface {
-		VerifyGraphIntegrity(ctx context.Context) error
+// dryRun reports what a migration would copy
+func dryRun(ctx context.Context, source storage.Store, entities []string, out io.Writer) error {
+	total := 0
+	for _, entity := range entities {
+		items, err := sortedItems(ctx, source, entity, out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  %s: %d entities\n", entity, len(items))
+		total += len(items)
 	}
+	if schemaStore, ok := source.(storage.SchemaStore); ok {
+		schemas, err := schemaStore.ListSchemas(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  schemas: %d\n", len(schemas))
+	}
+	fmt.Fprintf(out, "\nDry run: would copy %d entities of %d types; target left untouched\n", total, len(entities))
+	return nil
+}
 
-	if checker, ok := targetStore.(IntegrityChecker); ok {
+// verify diffs the source against the target and checks the target's graph
+func verify(ctx context.Context, source, target storage.Store, entities []string, out io.Writer) error {
+	fmt.Fprintln(out, "\nVerifying...")
+	diffs, err := diffStores(ctx, source, target, entities)
+	if err != nil {
+		return err
+	}
+	if failed := printDiffs(out, diffs); failed > 0 {
+		return fmt.Errorf("verification failed: %d entity types differ", failed)
+	}
+	if err := diffSchemas(ctx, source, target, out); err != nil {
+		return err
+	}
+
+	if checker, ok := target.(storage.GraphIntegrity); ok {
 		if err := checker.VerifyGraphIntegrity(ctx); err != nil {
 			return fmt.Errorf("graph integrity check failed: %w", err)
 		}
-		fmt.Println("  Graph integrity verified ✓")
+		fmt.Fprintln(out, "  Graph integrity verified ✓")
 	}
 
+	fmt.Fprintln(out, "Migration completed successfully!")
 	return nil
 }
+
+// listEntities returns the entity types a store holds, sorted
+func listEntities(ctx context.Context, store storage.Store) ([]string, error) {
+	lister, ok := store.(storage.EntityLister)
+	if !ok {
+		return nil, errors.New("store cannot list its entity types")
+	}
+	entities, err := lister.ListEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(entities)
+	return entities, nil
+}
+
+// sortedItems lists an entity type in ID order, dropping items without an ID
+func sortedItems(ctx context.Context, store storage.Store, entity string, out io.Writer) ([]map[string]interface{}, error) {
+	all, err := store.List(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", entity, err)
+	}
+
+	items := make([]map[string]interface{}, 0, len(all))
+	for _, item := range all {
+		if _, ok := itemID(item); !ok {
+			fmt.Fprintf(out, "  Warning: %s entity without valid ID: %v\n", entity, item)
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, _ := itemID(items[i])
+		b, _ := itemID(items[j])
+		return a < b
+	})
+	return items, nil
+}
+
+// itemID returns an entity's ID, which is a float64 when decoded from JSON
+func itemID(item map[string]interface{}) (int, bool) {
+	switch id := item["id"].(type) {
+	case int:
+		return id, id > 0
+	case float64:
+		return int(id), id > 0
+	}
+	return 0, false
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// seedJSONFile creates a jsonfile store with users, and posts referencing them
+func seedJSONFile(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "default")
+	dsn := "jsonfile:" + dir
+	store, err := openStore(dsn)
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := store.Create(ctx, "users", map[string]interface{}{"name": name, "tags": []interface{}{"a", "b"}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Delete(ctx, "users", 2))
+	require.NoError(t, store.Save(ctx, "posts", 7, map[string]interface{}{
+		"title":  "hello",
+		"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1},
+		"meta":   map[string]interface{}{"score": 1.5, "reviewer": map[string]interface{}{"type": "REF", "entity": "users", "id": 3}},
+	}))
+	require.NoError(t, store.(storage.SchemaStore).SaveSchema(ctx, "users", map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+	}))
+	return dsn
+}
+
+func runMigrate(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := run(context.Background(), args, &out)
+	return out.String(), err
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn       string
+		storeType string
+		config    map[string]interface{}
+	}{
+		{"jsonfile:./data/default", "jsonfile", map[string]interface{}{"base_dir": "data", "schema": "default"}},
+		{"sqlite:./olu.db", "sqlite", map[string]interface{}{"db_path": "./olu.db"}},
+		{"bolt:/var/lib/olu.bolt", "bolt", map[string]interface{}{"bolt_path": "/var/lib/olu.bolt"}},
+		{"postgres:postgres://localhost/olu", "postgres", map[string]interface{}{"dsn": "postgres://localhost/olu"}},
+		{"postgres://localhost/olu", "postgres", map[string]interface{}{"dsn": "postgres://localhost/olu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			storeType, config, err := parseDSN(tt.dsn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.storeType, storeType)
+			assert.Equal(t, tt.config, config)
+		})
+	}
+
+	for _, dsn := range []string{"", "sqlite", "sqlite:", ":./olu.db", "mongo:./olu"} {
+		_, _, err := parseDSN(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestMigrateRoundTrip(t *testing.T) {
+	source := seedJSONFile(t)
+	tmp := t.TempDir()
+	hops := []string{
+		"sqlite:" + filepath.Join(tmp, "olu.db"),
+		"bolt:" + filepath.Join(tmp, "olu.bolt"),
+		"jsonfile:" + filepath.Join(tmp, "fixtures", "default"),
+	}
+
+	from := source
+	for _, to := range hops {
+		out, err := runMigrate(t, "--from", from, "--to", to, "--batch-size", "1")
+		require.NoError(t, err, out)
+		assert.Contains(t, out, "Migration completed successfully!")
+		from = to
+	}
+
+	// The JSON files written at the end hold the original documents and IDs
+	ctx := context.Background()
+	original, err := openStore(source)
+	require.NoError(t, err)
+	defer original.Close()
+	final, err := openStore(from)
+	require.NoError(t, err)
+	defer final.Close()
+
+	diffs, err := diffStores(ctx, original, final, []string{"posts", "users"})
+	require.NoError(t, err)
+	for _, diff := range diffs {
+		assert.True(t, diff.OK(), "%+v", diff)
+	}
+	assert.Equal(t, 2, diffs[0].SourceEdges)
+	assert.False(t, final.Exists(ctx, "users", 2))
+
+	schemas, err := final.(storage.SchemaStore).ListSchemas(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"name"}, schemas["users"]["required"])
+
+	// New entities continue after the migrated IDs
+	id, err := final.Create(ctx, "posts", map[string]interface{}{"title": "next"})
+	require.NoError(t, err)
+	assert.Greater(t, id, 7)
+}
+
+func TestMigrateResume(t *testing.T) {
+	source := seedJSONFile(t)
+	target := "sqlite:" + filepath.Join(t.TempDir(), "olu.db")
+
+	// Simulate an interrupted migration that copied one user
+	partial, err := openStore(target)
+	require.NoError(t, err)
+	require.NoError(t, partial.Save(context.Background(), "users", 1, map[string]interface{}{"name": "alice", "tags": []interface{}{"a", "b"}}))
+	require.NoError(t, partial.Close())
+
+	_, err = runMigrate(t, "--from", source, "--to", target)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--resume")
+
+	out, err := runMigrate(t, "--from", source, "--to", target, "--resume")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Copied: 2")
+	assert.Contains(t, out, "Skipped: 1")
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	source := seedJSONFile(t)
+	path := filepath.Join(t.TempDir(), "olu.db")
+
+	out, err := runMigrate(t, "--from", source, "--to", "sqlite:"+path, "--dry-run")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "would copy 3 entities of 2 types")
+	assert.NoFileExists(t, path)
+}
+
+func TestMigrateArguments(t *testing.T) {
+	target := "sqlite:" + filepath.Join(t.TempDir(), "olu.db")
+
+	_, err := runMigrate(t, "--to", target)
+	assert.Error(t, err)
+	_, err = runMigrate(t, "--from", target, "--to", target)
+	assert.Error(t, err)
+	_, err = runMigrate(t, "--from", "jsonfile:./missing", "--to", target, "--batch-size", "0")
+	assert.Error(t, err)
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, err = runMigrate(t, "--from", "jsonfile:"+missing, "--to", target)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+	assert.NoDirExists(t, missing)
+}
+
+func TestDiffStores(t *testing.T) {
+	source := seedJSONFile(t)
+	target := "sqlite:" + filepath.Join(t.TempDir(), "olu.db")
+	out, err := runMigrate(t, "--from", source, "--to", target)
+	require.NoError(t, err, out)
+
+	ctx := context.Background()
+	src, err := openStore(source)
+	require.NoError(t, err)
+	defer src.Close()
+	dst, err := openStore(target)
+	require.NoError(t, err)
+	defer dst.Close()
+
+	require.NoError(t, dst.Patch(ctx, "posts", 7, map[string]interface{}{
+		"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 3},
+	}))
+	require.NoError(t, dst.Delete(ctx, "users", 3))
+	_, err = dst.Create(ctx, "users", map[string]interface{}{"name": "mallory"})
+	require.NoError(t, err)
+
+	diffs, err := diffStores(ctx, src, dst, []string{"posts", "users"})
+	require.NoError(t, err)
+
+	posts, users := diffs[0], diffs[1]
+	assert.Equal(t, []int{7}, posts.Changed)
+	assert.Equal(t, []string{"posts:7.author -> users:1"}, posts.MissingEdges)
+	assert.Equal(t, []string{"posts:7.author -> users:3"}, posts.ExtraEdges)
+	assert.Equal(t, []int{3}, users.Missing)
+	assert.Len(t, users.Extra, 1)
+	assert.Equal(t, 2, users.SourceCount)
+	assert.Equal(t, 2, users.TargetCount)
+
+	var report bytes.Buffer
+	assert.Equal(t, 2, printDiffs(&report, diffs))
+	assert.Contains(t, report.String(), "content differs (1): 7")
+}
+
+func TestSchemaCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.db")
+	dsn := "sqlite:" + path
+
+	_, err := runMigrate(t, "schema", "status", dsn)
+	require.Error(t, err)
+	assert.NoFileExists(t, path)
+
+	out, err := runMigrate(t, "schema", "up", dsn)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Migrated schema from version 0 to")
+	assert.Contains(t, out, "0001 initial")
+
+	out, err = runMigrate(t, "schema", "up", dsn)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "Schema is up to date")
+
+	out, err = runMigrate(t, "schema", "status", dsn)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "applied")
+	assert.NotContains(t, out, "pending")
+
+	_, err = runMigrate(t, "schema", "status", "jsonfile:"+t.TempDir())
+	assert.Error(t, err)
+	_, err = runMigrate(t, "schema", "down", dsn)
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+const schemaUsage = "Usage: olu-migrate schema <up|status> <type:location>"
+
+// runSchema applies or reports a store's schema migrations
+func runSchema(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) != 2 || (args[0] != "up" && args[0] != "status") {
+		fmt.Fprintln(out, schemaUsage)
+		fmt.Fprintln(out, "Example: olu-migrate schema status sqlite:./olu.db")
+		return errors.New("expected schema up or schema status and a store")
+	}
+	command, dsn := args[0], args[1]
+
+	storeType, config, err := parseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	if command == "status" {
+		// Opening a local store creates it, which status must not do
+		if path := localPath(config); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("store does not exist: %s", path)
+			}
+		}
+	}
+
+	// Open without migrating so status reports the database as it is
+	config["skip_migrations"] = true
+	store, err := storage.NewStore(storeType, config)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer store.Close()
+
+	migrator, ok := store.(storage.Migrator)
+	if !ok {
+		return fmt.Errorf("%s store has no schema migrations", storeType)
+	}
+
+	if command == "up" {
+		before, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Migrate(ctx); err != nil {
+			return err
+		}
+		after, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if after == before {
+			fmt.Fprintf(out, "Schema is up to date at version %d\n", after)
+		} else {
+			fmt.Fprintf(out, "Migrated schema from version %d to %d\n", before, after)
+		}
+	}
+
+	return printSchemaStatus(ctx, store, migrator, out)
+}
+
+// printSchemaStatus lists each migration and its state, returning an error
+// if the database has drifted from this build's migrations
+func printSchemaStatus(ctx context.Context, store storage.Store, migrator storage.Migrator, out io.Writer) error {
+	lister, ok := store.(storage.MigrationLister)
+	if !ok {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Schema version: %d\n", version)
+		return nil
+	}
+
+	migrations, err := lister.Migrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	pending := 0
+	for _, m := range migrations {
+		state := "pending"
+		switch {
+		case m.Unknown:
+			state = "applied " + m.AppliedAt + ", unknown to this build"
+			drifted++
+		case m.Modified:
+			state = "applied " + m.AppliedAt + ", modified since"
+			drifted++
+		case m.Applied:
+			state = "applied " + m.AppliedAt
+		default:
+			pending++
+		}
+		fmt.Fprintf(out, "  %04d %-24s %s\n", m.Version, m.Name, state)
+	}
+
+	if drifted > 0 {
+		return fmt.Errorf("%d applied migrations do not match this build", drifted)
+	}
+	if pending > 0 {
+		fmt.Fprintf(out, "%d pending (run olu-migrate schema up)\n", pending)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// maxReported caps how many IDs or edges a diff prints per category
+const maxReported = 10
+
+// entityDiff describes how one entity type differs between two stores
+type entityDiff struct {
+	Entity       string
+	SourceCount  int
+	TargetCount  int
+	SourceEdges  int
+	TargetEdges  int
+	Missing      []int    // in the source but not the target
+	Extra        []int    // in the target but not the source
+	Changed      []int    // in both, with different content
+	MissingEdges []string // references held in the source but not the target
+	ExtraEdges   []string // references held in the target but not the source
+}
+
+// OK reports whether both stores hold the same entities and edges
+func (d entityDiff) OK() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0 &&
+		len(d.MissingEdges) == 0 && len(d.ExtraEdges) == 0
+}
+
+// snapshot is the content hash and outgoing edges of every entity of a type
+type snapshot struct {
+	hashes map[int]string
+	edges  map[string]bool
+}
+
+// diffStores compares each entity type in both stores by entity count,
+// per-entity content hash and the set of REF edges the entities hold
+func diffStores(ctx context.Context, source, target storage.Store, entities []string) ([]entityDiff, error) {
+	diffs := make([]entityDiff, 0, len(entities))
+	for _, entity := range entities {
+		src, err := takeSnapshot(ctx, source, entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source %s: %w", entity, err)
+		}
+		dst, err := takeSnapshot(ctx, target, entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read target %s: %w", entity, err)
+		}
+
+		diff := entityDiff{
+			Entity:      entity,
+			SourceCount: len(src.hashes),
+			TargetCount: len(dst.hashes),
+			SourceEdges: len(src.edges),
+			TargetEdges: len(dst.edges),
+		}
+		for id, hash := range src.hashes {
+			other, ok := dst.hashes[id]
+			switch {
+			case !ok:
+				diff.Missing = append(diff.Missing, id)
+			case other != hash:
+				diff.Changed = append(diff.Changed, id)
+			}
+		}
+		for id := range dst.hashes {
+			if _, ok := src.hashes[id]; !ok {
+				diff.Extra = append(diff.Extra, id)
+			}
+		}
+		diff.MissingEdges = subtract(src.edges, dst.edges)
+		diff.ExtraEdges = subtract(dst.edges, src.edges)
+
+		sort.Ints(diff.Missing)
+		sort.Ints(diff.Extra)
+		sort.Ints(diff.Changed)
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func takeSnapshot(ctx context.Context, store storage.Store, entity string) (snapshot, error) {
+	items, err := store.List(ctx, entity)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	snap := snapshot{hashes: make(map[int]string, len(items)), edges: make(map[string]bool)}
+	for _, item := range items {
+		id, ok := itemID(item)
+		if !ok {
+			continue
+		}
+		hash, err := contentHash(item)
+		if err != nil {
+			return snapshot{}, fmt.Errorf("%s %d: %w", entity, id, err)
+		}
+		snap.hashes[id] = hash
+		for _, ref := range models.FindReferences(item) {
+			edge := fmt.Sprintf("%s:%d.%s -> %s:%d", entity, id, ref.Path, ref.Entity, ref.ID)
+			snap.edges[edge] = true
+		}
+	}
+	return snap, nil
+}
+
+// contentHash hashes an entity's JSON encoding. encoding/json sorts object
+// keys and writes whole numbers the same whether they were decoded as int or
+// float64, so the same document hashes the same from any store.
+func contentHash(item map[string]interface{}) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// subtract returns the members of a missing from b, sorted
+func subtract(a, b map[string]bool) []string {
+	var result []string
+	for key := range a {
+		if !b[key] {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// diffSchemas checks that the target holds every source schema unchanged
+func diffSchemas(ctx context.Context, source, target storage.Store, out io.Writer) error {
+	from, ok := source.(storage.SchemaStore)
+	if !ok {
+		return nil
+	}
+	to, ok := target.(storage.SchemaStore)
+	if !ok {
+		return nil
+	}
+
+	want, err := from.ListSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	got, err := to.ListSchemas(ctx)
+	if err != nil {
+		return err
+	}
+
+	var differing []string
+	for entity, schema := range want {
+		a, err := contentHash(schema)
+		if err != nil {
+			return err
+		}
+		b, err := contentHash(got[entity])
+		if err != nil {
+			return err
+		}
+		if got[entity] == nil || a != b {
+			differing = append(differing, entity)
+		}
+	}
+	sort.Strings(differing)
+
+	if len(differing) > 0 {
+		fmt.Fprintf(out, "  ✗ schemas differ: %s\n", strings.Join(differing, ", "))
+		return fmt.Errorf("verification failed: %d schemas differ", len(differing))
+	}
+	fmt.Fprintf(out, "  ✓ %d schemas\n", len(want))
+	return nil
+}
+
+// printDiffs writes a per-type report and returns how many types differ
+func printDiffs(w io.Writer, diffs []entityDiff) int {
+	failed := 0
+	for _, diff := range diffs {
+		status := "✓"
+		if !diff.OK() {
+			status = "✗"
+			failed++
+		}
+		fmt.Fprintf(w, "  %s %s: %d/%d entities, %d/%d edges\n", status, diff.Entity,
+			diff.SourceCount, diff.TargetCount, diff.SourceEdges, diff.TargetEdges)
+		printIDs(w, "missing from target", diff.Missing)
+		printIDs(w, "only in target", diff.Extra)
+		printIDs(w, "content differs", diff.Changed)
+		printEdges(w, "edges missing from target", diff.MissingEdges)
+		printEdges(w, "edges only in target", diff.ExtraEdges)
+	}
+	return failed
+}
+
+func printIDs(w io.Writer, label string, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	shown := make([]string, 0, maxReported)
+	for i, id := range ids {
+		if i == maxReported {
+			break
+		}
+		shown = append(shown, fmt.Sprint(id))
+	}
+	fmt.Fprintf(w, "      %s (%d): %s%s\n", label, len(ids), strings.Join(shown, ", "), more(len(ids)))
+}
+
+func printEdges(w io.Writer, label string, edges []string) {
+	if len(edges) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "      %s (%d):\n", label, len(edges))
+	for i, edge := range edges {
+		if i == maxReported {
+			break
+		}
+		fmt.Fprintf(w, "        %s\n", edge)
+	}
+	if len(edges) > maxReported {
+		fmt.Fprintf(w, "        ...\n")
+	}
+}
+
+func more(n int) string {
+	if n > maxReported {
+		return ", ..."
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -40,17 +41,35 @@ func main() {
 		logger.Fatal().Err(err).Msg("Failed to create schema directory")
 	}
 	
+	// Webhook subscriptions live with the data unless given a full path
+	if cfg.WebhooksFile != "" && !filepath.IsAbs(cfg.WebhooksFile) {
+		cfg.WebhooksFile = filepath.Join(cfg.BaseDir, cfg.WebhooksFile)
+	}
+	
 	// Initialize storage
 	var storeConfig map[string]interface{}
 	
-	if cfg.StorageType == "sqlite" {
+	switch cfg.StorageType {
+	case "sqlite":
 		storeConfig = map[string]interface{}{
-			"db_path": cfg.DBPath,
+			"db_path":  cfg.DBPath,
+			"fulltext": cfg.FullTextEnabled,
 		}
-	} else {
+	case "bolt":
 		storeConfig = map[string]interface{}{
-			"base_dir": cfg.BaseDir,
-			"schema":   cfg.Schema,
+			"bolt_path": cfg.BoltPath,
+		}
+	case "postgres":
+		storeConfig = map[string]interface{}{
+			"dsn":      cfg.PostgresDSN,
+			"fulltext": cfg.FullTextEnabled,
+		}
+	default:
+		storeConfig = map[string]interface{}{
+			"base_dir":   cfg.BaseDir,
+			"schema":     cfg.Schema,
+			"schema_dir": cfg.SchemaDir,
+			"fulltext":   cfg.FullTextEnabled,
 		}
 	}
 	
@@ -69,6 +88,7 @@ func main() {
 			Bool("supports_search", info.SupportsSearch).
 			Bool("supports_batch", info.SupportsBatch).
 			Bool("supports_transaction", info.SupportsTransaction).
+			Bool("supports_fulltext", info.SupportsFullText).
 			Msg("Storage initialized")
 	}
 	
@@ -118,13 +138,36 @@ func main() {
 	
 	// Initialize validator
 	validator := validation.NewJSONSchemaValidator(cfg.SchemaDir)
-	if err := validator.LoadAllSchemas(); err != nil {
-		logger.Warn().Err(err).Msg("Failed to load schemas")
-	}
 	
 	// Create server
 	srv := server.New(cfg, store, cacheInstance, graphInstance, validator, logger)
 	
+	// Refuse a cycle detection policy that could not be enforced
+	if err := srv.CheckCycleDetection(); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid cycle detection settings")
+	}
+	
+	// Load the schemas persisted in the store
+	if count, err := srv.LoadSchemas(context.Background()); err != nil {
+		logger.Warn().Err(err).Msg("Failed to load schemas")
+	} else {
+		logger.Info().Int("count", count).Msg("Loaded schemas")
+	}
+	
+	// Load the webhook subscriptions saved by earlier runs
+	if count, err := srv.LoadWebhooks(); err != nil {
+		logger.Warn().Err(err).Msg("Failed to load webhooks")
+	} else {
+		logger.Info().Int("count", count).Msg("Loaded webhooks")
+	}
+	
+	// Until shutdown, purge entities kept in the trash past their retention
+	// and expired graph queries, and deliver webhooks
+	background, stopBackground := context.WithCancel(context.Background())
+	srv.StartTrashPurge(background)
+	srv.StartQueryPurge(background)
+	srv.StartWebhooks(background)
+	
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -132,6 +175,7 @@ func main() {
 	go func() {
 		<-sigChan
 		logger.Info().Msg("Shutting down gracefully...")
+		stopBackground()
 		
 		// Save graph
 		if graphInstance != nil && cfg.GraphEnabled {
@@ -194,6 +238,9 @@ func printBanner(cfg *config.Config, logger zerolog.Logger) {
 		fmt.Printf("  Mode: Enabled (%s)\n", cfg.GraphMode)
 		fmt.Printf("  Query TTL: %d seconds\n", cfg.GraphQueryTTL)
 		fmt.Printf("  Cycle Detection: %s\n", cfg.GraphCycleDetection)
+		if len(cfg.GraphCycleRelationships) > 0 {
+			fmt.Printf("  Cycle Relationships: %s\n", strings.Join(cfg.GraphCycleRelationships, ", "))
+		}
 	} else {
 		fmt.Println("  Mode: Disabled")
 	}
@@ -208,6 +255,12 @@ func printBanner(cfg *config.Config, logger zerolog.Logger) {
 	fmt.Println("Other Configuration:")
 	fmt.Printf("  Full-text search: %v\n", cfg.FullTextEnabled)
 	fmt.Printf("  Cascading delete: %v\n", cfg.CascadingDelete)
+	if cfg.SoftDelete {
+		fmt.Println("  Soft delete: all entities")
+	} else if len(cfg.SoftDeleteEntities) > 0 {
+		fmt.Printf("  Soft delete: %s\n", strings.Join(cfg.SoftDeleteEntities, ", "))
+	}
+	fmt.Printf("  Webhook attempts: %d\n", cfg.WebhookMaxAttempts)
 	fmt.Printf("  REF embed depth: %d\n", cfg.RefEmbedDepth)
 	fmt.Printf("  Patch null handling: %s\n", cfg.PatchNullBehavior)
 	fmt.Printf("  Max query depth: %d\n", cfg.MaxQueryDepth)
@@ -5,9 +5,12 @@ go 1.21
 require (
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/gorilla/websocket v1.5.3
 	github.com/hashicorp/golang-lru/v2 v2.0.7
+	github.com/lib/pq v1.10.9
 	github.com/rs/zerolog v1.31.0
 	github.com/stretchr/testify v1.8.4
+	go.etcd.io/bbolt v1.3.8
 	modernc.org/sqlite v1.28.0
 )
 
@@ -15,11 +15,13 @@ type Config struct {
 	Port int
 
 	// Storage configuration
-	StorageType string // "jsonfile" or "sqlite"
+	StorageType string // "jsonfile", "sqlite", "bolt" or "postgres"
 	BaseDir     string
 	SchemaDir   string
 	Schema      string
 	DBPath      string // SQLite database path
+	BoltPath    string // bbolt database path
+	PostgresDSN string // PostgreSQL connection string
 
 	// Cache configuration
 	CacheType      string // "memory" or "redis"
@@ -35,7 +37,10 @@ type Config struct {
 	GraphIndexFile     string
 	GraphQueryTTL      int
 	GraphResultTTL     int
+	GraphQueryTimeout  int // seconds a graph query may run, 0 means no limit
+	GraphMaxRunningQueries int // graph queries running at once, 0 means unlimited
 	GraphCycleDetection string // "warn", "error", "ignore"
+	GraphCycleRelationships []string // relationships checked for cycles; empty means all
 
 	// Full-text search
 	FullTextEnabled bool
@@ -49,12 +54,29 @@ type Config struct {
 	// Entity configuration
 	PatchNullBehavior string // "store" or "delete"
 	MaxEntitySize     int    // bytes
+	MaxBatchOperations int   // operations per batch request, 0 means unlimited
 	
 	// Cascade delete configuration
 	CascadingDelete     bool
 	MaxCascadeDeletions int
 	MaxCascadeWork      int
 
+	// Soft delete configuration
+	SoftDelete         bool     // Move every deleted entity to the trash
+	SoftDeleteEntities []string // Entity types moved to the trash when SoftDelete is off
+	TrashRetention     int      // Seconds a trashed entity is kept; 0 keeps it until purged
+
+	// Change feed configuration
+	ChangeFeedOrigins []string // Origins besides the server's own that may open the feed over a WebSocket; "*" allows any
+
+	// Webhook configuration
+	WebhooksFile        string // Where webhook subscriptions are kept; empty keeps them in memory
+	WebhookMaxAttempts  int    // Delivery attempts before a delivery is dead-lettered
+	WebhookRetryDelay   int    // Milliseconds before the first retry; doubles with each retry
+	WebhookTimeout      int    // Seconds to wait for a receiver to respond
+	WebhookMaxInFlight  int    // Delivery attempts made at once
+	WebhookBlockPrivate bool   // Refuse to deliver to loopback, private and link-local addresses
+
 	// Debug
 	Debug      bool
 	DebugLocks bool
@@ -70,6 +92,8 @@ func Default() *Config {
 		SchemaDir:           "schema",
 		Schema:              "default",
 		DBPath:              "olu.db",
+		BoltPath:            "olu.bolt",
+		PostgresDSN:         "postgres://localhost:5432/olu?sslmode=disable",
 		CacheType:           "memory",
 		CacheTTL:            300,
 		CacheSize:           1024,
@@ -81,6 +105,8 @@ func Default() *Config {
 		GraphIndexFile:      "graph.index",
 		GraphQueryTTL:       86400,
 		GraphResultTTL:      3600,
+		GraphQueryTimeout:   300,
+		GraphMaxRunningQueries: 16,
 		GraphCycleDetection: "warn",
 		FullTextEnabled:     false,
 		MaxQueryDepth:       10,
@@ -89,9 +115,18 @@ func Default() *Config {
 		DefaultPageSize:     10,
 		PatchNullBehavior:   "store",
 		MaxEntitySize:       1048576, // 1MB
+		MaxBatchOperations:  1000,
 		CascadingDelete:     false,
 		MaxCascadeDeletions: 10000,
 		MaxCascadeWork:      100000,
+		SoftDelete:          false,
+		TrashRetention:      2592000, // 30 days
+		WebhooksFile:        "webhooks.json",
+		WebhookMaxAttempts:  5,
+		WebhookRetryDelay:   1000,
+		WebhookTimeout:      10,
+		WebhookMaxInFlight:  10,
+		WebhookBlockPrivate: false,
 		Debug:               false,
 		DebugLocks:          false,
 	}
@@ -113,6 +148,12 @@ func LoadFromEnv(cfg *Config) {
 	if val := os.Getenv("DB_PATH"); val != "" {
 		cfg.DBPath = val
 	}
+	if val := os.Getenv("BOLT_PATH"); val != "" {
+		cfg.BoltPath = val
+	}
+	if val := os.Getenv("POSTGRES_DSN"); val != "" {
+		cfg.PostgresDSN = val
+	}
 	if val := os.Getenv("BASE_DIR"); val != "" {
 		cfg.BaseDir = val
 	}
@@ -145,12 +186,105 @@ func LoadFromEnv(cfg *Config) {
 	if val := os.Getenv("GRAPH_CYCLE_DETECTION"); val != "" {
 		cfg.GraphCycleDetection = val
 	}
+	if val := os.Getenv("GRAPH_CYCLE_RELATIONSHIPS"); val != "" {
+		cfg.GraphCycleRelationships = nil
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.GraphCycleRelationships = append(cfg.GraphCycleRelationships, name)
+			}
+		}
+	}
+	if val := os.Getenv("GRAPH_QUERY_TTL"); val != "" {
+		if ttl, err := strconv.Atoi(val); err == nil {
+			cfg.GraphQueryTTL = ttl
+		}
+	}
+	if val := os.Getenv("GRAPH_RESULT_TTL"); val != "" {
+		if ttl, err := strconv.Atoi(val); err == nil {
+			cfg.GraphResultTTL = ttl
+		}
+	}
+	if val := os.Getenv("GRAPH_QUERY_TIMEOUT"); val != "" {
+		if timeout, err := strconv.Atoi(val); err == nil {
+			cfg.GraphQueryTimeout = timeout
+		}
+	}
+	if val := os.Getenv("GRAPH_MAX_RUNNING_QUERIES"); val != "" {
+		if max, err := strconv.Atoi(val); err == nil {
+			cfg.GraphMaxRunningQueries = max
+		}
+	}
+	if val := os.Getenv("MAX_QUERY_DEPTH"); val != "" {
+		if depth, err := strconv.Atoi(val); err == nil {
+			cfg.MaxQueryDepth = depth
+		}
+	}
 	if val := os.Getenv("FULLTEXT_ENABLED"); val != "" {
 		cfg.FullTextEnabled = parseBool(val)
 	}
 	if val := os.Getenv("CASCADING_DELETE"); val != "" {
 		cfg.CascadingDelete = parseBool(val)
 	}
+	if val := os.Getenv("MAX_CASCADE_DELETIONS"); val != "" {
+		if max, err := strconv.Atoi(val); err == nil {
+			cfg.MaxCascadeDeletions = max
+		}
+	}
+	if val := os.Getenv("MAX_CASCADE_WORK"); val != "" {
+		if max, err := strconv.Atoi(val); err == nil {
+			cfg.MaxCascadeWork = max
+		}
+	}
+	if val := os.Getenv("SOFT_DELETE"); val != "" {
+		cfg.SoftDelete = parseBool(val)
+	}
+	if val := os.Getenv("SOFT_DELETE_ENTITIES"); val != "" {
+		cfg.SoftDeleteEntities = nil
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.SoftDeleteEntities = append(cfg.SoftDeleteEntities, name)
+			}
+		}
+	}
+	if val := os.Getenv("TRASH_RETENTION"); val != "" {
+		if retention, err := strconv.Atoi(val); err == nil {
+			cfg.TrashRetention = retention
+		}
+	}
+	if val := os.Getenv("CHANGE_FEED_ORIGINS"); val != "" {
+		cfg.ChangeFeedOrigins = nil
+		for _, origin := range strings.Split(val, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.ChangeFeedOrigins = append(cfg.ChangeFeedOrigins, origin)
+			}
+		}
+	}
+	if val := os.Getenv("WEBHOOKS_FILE"); val != "" {
+		cfg.WebhooksFile = val
+	}
+	if val := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); val != "" {
+		if attempts, err := strconv.Atoi(val); err == nil {
+			cfg.WebhookMaxAttempts = attempts
+		}
+	}
+	if val := os.Getenv("WEBHOOK_RETRY_DELAY"); val != "" {
+		if delay, err := strconv.Atoi(val); err == nil {
+			cfg.WebhookRetryDelay = delay
+		}
+	}
+	if val := os.Getenv("WEBHOOK_TIMEOUT"); val != "" {
+		if timeout, err := strconv.Atoi(val); err == nil {
+			cfg.WebhookTimeout = timeout
+		}
+	}
+	if val := os.Getenv("WEBHOOK_MAX_IN_FLIGHT"); val != "" {
+		if max, err := strconv.Atoi(val); err == nil {
+			cfg.WebhookMaxInFlight = max
+		}
+	}
+	if val := os.Getenv("WEBHOOK_BLOCK_PRIVATE"); val != "" {
+		cfg.WebhookBlockPrivate = parseBool(val)
+	}
 	if val := os.Getenv("DEBUG"); val != "" {
 		cfg.Debug = parseBool(val)
 	}
@@ -167,6 +301,11 @@ func LoadFromEnv(cfg *Config) {
 			cfg.MaxEntitySize = size
 		}
 	}
+	if val := os.Getenv("MAX_BATCH_OPERATIONS"); val != "" {
+		if max, err := strconv.Atoi(val); err == nil {
+			cfg.MaxBatchOperations = max
+		}
+	}
 	if val := os.Getenv("PATCH_NULL"); val != "" {
 		cfg.PatchNullBehavior = val
 	}
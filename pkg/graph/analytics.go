@@ -0,0 +1,405 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Analytics defines optional whole-graph algorithms
+// Graphs that can run analytics should implement this interface
+type Analytics interface {
+	Degrees(filter AnalyticsFilter) []NodeDegree
+	WeaklyConnectedComponents(filter AnalyticsFilter) [][]string
+	StronglyConnectedComponents(filter AnalyticsFilter) [][]string
+	PageRank(filter AnalyticsFilter, damping float64, iterations int) []NodeScore
+	Betweenness(filter AnalyticsFilter, samples int, seed int64) ([]NodeScore, int)
+	TopologicalSort(filter AnalyticsFilter) ([]string, error)
+}
+
+// AnalyticsFilter restricts an algorithm to part of the graph. Empty lists
+// match everything.
+type AnalyticsFilter struct {
+	EntityTypes   []string `json:"entity_types,omitempty"`  // node entity types to include
+	Relationships []string `json:"relationships,omitempty"` // edge relationships to include
+}
+
+// NodeDegree is the number of edges into and out of a node
+type NodeDegree struct {
+	Node string `json:"node"`
+	In   int    `json:"in"`
+	Out  int    `json:"out"`
+}
+
+// NodeScore is a per-node score such as PageRank or betweenness
+type NodeScore struct {
+	Node  string  `json:"node"`
+	Score float64 `json:"score"`
+}
+
+// snapshot is a read-only copy of the filtered graph with nodes numbered in
+// sorted order, so the algorithms can run without holding the lock. Parallel
+// edges appear once per edge.
+type snapshot struct {
+	nodes []string
+	out   [][]int
+	in    [][]int
+}
+
+// snapshot copies the part of the graph matching the filter
+func (g *IndexedGraph) snapshot(filter AnalyticsFilter) *snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	types := make(map[string]bool, len(filter.EntityTypes))
+	for _, t := range filter.EntityTypes {
+		types[t] = true
+	}
+	inScope := relationshipFilter(filter.Relationships)
+	
+	s := &snapshot{}
+	for _, node := range g.sortedNodeIDs() {
+		if len(types) == 0 || types[g.nodes[node].Type] {
+			s.nodes = append(s.nodes, node)
+		}
+	}
+	
+	index := make(map[string]int, len(s.nodes))
+	for i, node := range s.nodes {
+		index[node] = i
+	}
+	
+	s.out = make([][]int, len(s.nodes))
+	s.in = make([][]int, len(s.nodes))
+	for i, node := range s.nodes {
+		for _, edge := range g.outgoing[node] {
+			j, ok := index[edge.To]
+			if !ok || !inScope(edge.Relationship) {
+				continue
+			}
+			s.out[i] = append(s.out[i], j)
+			s.in[j] = append(s.in[j], i)
+		}
+	}
+	
+	return s
+}
+
+// Degrees returns the in and out degree of every node
+func (g *IndexedGraph) Degrees(filter AnalyticsFilter) []NodeDegree {
+	s := g.snapshot(filter)
+	
+	degrees := make([]NodeDegree, len(s.nodes))
+	for i, node := range s.nodes {
+		degrees[i] = NodeDegree{Node: node, In: len(s.in[i]), Out: len(s.out[i])}
+	}
+	return degrees
+}
+
+// WeaklyConnectedComponents returns the components of the graph when edge
+// direction is ignored, largest first
+func (g *IndexedGraph) WeaklyConnectedComponents(filter AnalyticsFilter) [][]string {
+	s := g.snapshot(filter)
+	
+	seen := make([]bool, len(s.nodes))
+	var components [][]string
+	
+	for start := range s.nodes {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		queue := []int{start}
+		var component []string
+		
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, s.nodes[current])
+			
+			for _, edges := range [][]int{s.out[current], s.in[current]} {
+				for _, neighbor := range edges {
+					if !seen[neighbor] {
+						seen[neighbor] = true
+						queue = append(queue, neighbor)
+					}
+				}
+			}
+		}
+		
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	
+	sortComponents(components)
+	return components
+}
+
+// StronglyConnectedComponents returns the strongly connected components of
+// the graph, largest first. Nodes outside any cycle form components of one.
+func (g *IndexedGraph) StronglyConnectedComponents(filter AnalyticsFilter) [][]string {
+	s := g.snapshot(filter)
+	
+	var components [][]string
+	for _, members := range s.tarjan() {
+		component := make([]string, len(members))
+		for i, member := range members {
+			component[i] = s.nodes[member]
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	
+	sortComponents(components)
+	return components
+}
+
+// tarjan returns the strongly connected components of the snapshot
+func (s *snapshot) tarjan() [][]int {
+	index := make([]int, len(s.nodes))
+	lowlink := make([]int, len(s.nodes))
+	onStack := make([]bool, len(s.nodes))
+	for i := range index {
+		index[i] = -1
+	}
+	
+	var stack []int
+	var components [][]int
+	next := 0
+	
+	var connect func(node int)
+	connect = func(node int) {
+		index[node] = next
+		lowlink[node] = next
+		next++
+		stack = append(stack, node)
+		onStack[node] = true
+		
+		for _, neighbor := range s.out[node] {
+			if index[neighbor] < 0 {
+				connect(neighbor)
+				if lowlink[neighbor] < lowlink[node] {
+					lowlink[node] = lowlink[neighbor]
+				}
+			} else if onStack[neighbor] && index[neighbor] < lowlink[node] {
+				lowlink[node] = index[neighbor]
+			}
+		}
+		
+		if lowlink[node] == index[node] {
+			var component []int
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	
+	for node := range s.nodes {
+		if index[node] < 0 {
+			connect(node)
+		}
+	}
+	return components
+}
+
+// PageRank scores nodes by the stationary distribution of a random walk that
+// follows edges with probability damping and jumps anywhere otherwise. Rank
+// held by nodes without outgoing edges is spread evenly. Iteration stops early
+// once the scores change by less than 1e-9 in total.
+func (g *IndexedGraph) PageRank(filter AnalyticsFilter, damping float64, iterations int) []NodeScore {
+	s := g.snapshot(filter)
+	n := len(s.nodes)
+	if n == 0 {
+		return []NodeScore{}
+	}
+	
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	
+	for iter := 0; iter < iterations; iter++ {
+		dangling := 0.0
+		for i := range s.nodes {
+			if len(s.out[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+		
+		next := make([]float64, n)
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i := range s.nodes {
+			if len(s.out[i]) == 0 {
+				continue
+			}
+			share := damping * rank[i] / float64(len(s.out[i]))
+			for _, j := range s.out[i] {
+				next[j] += share
+			}
+		}
+		
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank = next
+		if delta < 1e-9 {
+			break
+		}
+	}
+	
+	return rankScores(s.nodes, rank)
+}
+
+// Betweenness scores nodes by how many shortest paths pass through them,
+// using Brandes' algorithm on unweighted directed edges. When samples is
+// positive and smaller than the node count, only that many randomly chosen
+// source nodes are used and the scores are scaled up to estimate the total.
+// It also returns the number of source nodes used.
+func (g *IndexedGraph) Betweenness(filter AnalyticsFilter, samples int, seed int64) ([]NodeScore, int) {
+	s := g.snapshot(filter)
+	n := len(s.nodes)
+	
+	sources := make([]int, n)
+	for i := range sources {
+		sources[i] = i
+	}
+	scale := 1.0
+	if samples > 0 && samples < n {
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(n, func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+		sources = sources[:samples]
+		scale = float64(n) / float64(samples)
+	}
+	
+	centrality := make([]float64, n)
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	preds := make([][]int, n)
+	
+	for _, source := range sources {
+		for i := 0; i < n; i++ {
+			sigma[i] = 0
+			dist[i] = -1
+			delta[i] = 0
+			preds[i] = preds[i][:0]
+		}
+		sigma[source] = 1
+		dist[source] = 0
+		
+		order := []int{}
+		queue := []int{source}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			order = append(order, v)
+			
+			for _, w := range s.out[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+		
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != source {
+				centrality[w] += delta[w]
+			}
+		}
+	}
+	
+	for i := range centrality {
+		centrality[i] *= scale
+	}
+	return rankScores(s.nodes, centrality), len(sources)
+}
+
+// TopologicalSort orders the nodes so that every edge points from an earlier
+// node to a later one. Ties are broken by node ID. It fails if the filtered
+// graph has a cycle.
+func (g *IndexedGraph) TopologicalSort(filter AnalyticsFilter) ([]string, error) {
+	s := g.snapshot(filter)
+	
+	indegree := make([]int, len(s.nodes))
+	for i := range s.nodes {
+		indegree[i] = len(s.in[i])
+	}
+	
+	// Nodes are numbered in sorted order, so a min-ordered ready list keeps
+	// the result stable
+	var ready []int
+	for i, d := range indegree {
+		if d == 0 {
+			ready = append(ready, i)
+		}
+	}
+	
+	order := make([]string, 0, len(s.nodes))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		node := ready[0]
+		ready = ready[1:]
+		order = append(order, s.nodes[node])
+		
+		for _, neighbor := range s.out[node] {
+			indegree[neighbor]--
+			if indegree[neighbor] == 0 {
+				ready = append(ready, neighbor)
+			}
+		}
+	}
+	
+	if len(order) < len(s.nodes) {
+		var remaining []string
+		for i, d := range indegree {
+			if d > 0 {
+				remaining = append(remaining, s.nodes[i])
+			}
+		}
+		return nil, fmt.Errorf("graph has a cycle through %d nodes, e.g. %s", len(remaining), remaining[0])
+	}
+	return order, nil
+}
+
+// rankScores pairs nodes with scores, highest score first
+func rankScores(nodes []string, scores []float64) []NodeScore {
+	result := make([]NodeScore, len(nodes))
+	for i, node := range nodes {
+		result[i] = NodeScore{Node: node, Score: scores[i]}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+// sortComponents orders components largest first, then by first node
+func sortComponents(components [][]string) {
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+}
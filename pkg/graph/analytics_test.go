@@ -0,0 +1,120 @@
+package graph_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// taskGraph builds tasks depending on each other, with a parallel edge from
+// tasks:1 to tasks:2, a "blocks" edge closing a cycle and an owner
+func taskGraph() *graph.IndexedGraph {
+	g := graph.NewIndexedGraph()
+	g.PutEdge(models.GraphEdge{From: "tasks:1", To: "tasks:2", Relationship: "dep", Field: "deps[0]"})
+	g.PutEdge(models.GraphEdge{From: "tasks:1", To: "tasks:2", Relationship: "dep", Field: "deps[1]"})
+	g.PutEdge(models.GraphEdge{From: "tasks:2", To: "tasks:3", Relationship: "dep", Field: "deps[0]"})
+	g.PutEdge(models.GraphEdge{From: "tasks:3", To: "tasks:1", Relationship: "blocks", Field: "blocks"})
+	g.PutEdge(models.GraphEdge{From: "users:9", To: "tasks:1", Relationship: "owner", Field: "owner"})
+	g.AddNode("users:10", "")
+	return g
+}
+
+func TestDegrees(t *testing.T) {
+	g := taskGraph()
+
+	// Parallel edges count once each
+	want := []graph.NodeDegree{
+		{Node: "tasks:1", In: 2, Out: 2},
+		{Node: "tasks:2", In: 2, Out: 1},
+		{Node: "tasks:3", In: 1, Out: 1},
+		{Node: "users:10", In: 0, Out: 0},
+		{Node: "users:9", In: 0, Out: 1},
+	}
+	if got := g.Degrees(graph.AnalyticsFilter{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	want = []graph.NodeDegree{
+		{Node: "tasks:1", In: 0, Out: 2},
+		{Node: "tasks:2", In: 2, Out: 1},
+		{Node: "tasks:3", In: 1, Out: 0},
+	}
+	filter := graph.AnalyticsFilter{EntityTypes: []string{"tasks"}, Relationships: []string{"dep"}}
+	if got := g.Degrees(filter); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// Removing the edges between two nodes drops both parallel edges
+	g.RemoveEdge("tasks:1", "tasks:2")
+	if got := g.Degrees(filter); got[1].In != 0 {
+		t.Errorf("Expected no edges into tasks:2, got %+v", got)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	g := taskGraph()
+
+	weak := g.WeaklyConnectedComponents(graph.AnalyticsFilter{})
+	want := [][]string{{"tasks:1", "tasks:2", "tasks:3", "users:9"}, {"users:10"}}
+	if !reflect.DeepEqual(weak, want) {
+		t.Errorf("Expected weak components %v, got %v", want, weak)
+	}
+
+	strong := g.StronglyConnectedComponents(graph.AnalyticsFilter{})
+	want = [][]string{{"tasks:1", "tasks:2", "tasks:3"}, {"users:10"}, {"users:9"}}
+	if !reflect.DeepEqual(strong, want) {
+		t.Errorf("Expected strong components %v, got %v", want, strong)
+	}
+
+	// Without the blocks edge there is no cycle left
+	strong = g.StronglyConnectedComponents(graph.AnalyticsFilter{Relationships: []string{"dep", "owner"}})
+	if len(strong) != 5 {
+		t.Errorf("Expected every node on its own, got %v", strong)
+	}
+}
+
+func TestTopologicalSort(t *testing.T) {
+	g := taskGraph()
+
+	if _, err := g.TopologicalSort(graph.AnalyticsFilter{}); err == nil {
+		t.Error("Expected the cycle through the blocks edge to be refused")
+	}
+
+	order, err := g.TopologicalSort(graph.AnalyticsFilter{Relationships: []string{"dep", "owner"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"users:10", "users:9", "tasks:1", "tasks:2", "tasks:3"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected %v, got %v", want, order)
+	}
+}
+
+func TestPageRank(t *testing.T) {
+	g := taskGraph()
+
+	scores := g.PageRank(graph.AnalyticsFilter{}, 0.85, 100)
+	if len(scores) != 5 {
+		t.Fatalf("Expected 5 scores, got %+v", scores)
+	}
+	total := 0.0
+	for i, score := range scores {
+		total += score.Score
+		if i > 0 && score.Score > scores[i-1].Score {
+			t.Errorf("Expected scores highest first, got %+v", scores)
+		}
+	}
+	if math.Abs(total-1) > 1e-6 {
+		t.Errorf("Expected scores to sum to 1, got %v", total)
+	}
+	if scores[0].Node != "tasks:1" {
+		t.Errorf("Expected tasks:1 to rank highest, got %+v", scores)
+	}
+
+	if scores := g.PageRank(graph.AnalyticsFilter{EntityTypes: []string{"teams"}}, 0.85, 100); len(scores) != 0 {
+		t.Errorf("Expected no scores for an empty graph, got %+v", scores)
+	}
+}
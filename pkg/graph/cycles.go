@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"sort"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// relationshipFilter reports whether an edge's relationship is in scope.
+// An empty list puts every relationship in scope.
+func relationshipFilter(relationships []string) func(string) bool {
+	if len(relationships) == 0 {
+		return func(string) bool { return true }
+	}
+	allowed := make(map[string]bool, len(relationships))
+	for _, r := range relationships {
+		allowed[r] = true
+	}
+	return func(r string) bool { return allowed[r] }
+}
+
+// Cycles returns the strongly connected components of the graph that contain
+// a cycle, considering only edges with the given relationships (all when
+// empty). Each component lists its nodes and the edges between them.
+func (g *IndexedGraph) Cycles(relationships []string) []models.GraphCycle {
+	s := g.snapshot(AnalyticsFilter{Relationships: relationships})
+	components := s.tarjan()
+	
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	inScope := relationshipFilter(relationships)
+	cycles := []models.GraphCycle{}
+	
+	for _, members := range components {
+		cycle := models.GraphCycle{Nodes: make([]string, len(members))}
+		inComponent := make(map[string]bool, len(members))
+		for i, member := range members {
+			cycle.Nodes[i] = s.nodes[member]
+			inComponent[s.nodes[member]] = true
+		}
+		sort.Strings(cycle.Nodes)
+		
+		for _, node := range cycle.Nodes {
+			for _, edge := range g.outgoing[node] {
+				if inComponent[edge.To] && inScope(edge.Relationship) {
+					cycle.Edges = append(cycle.Edges, edge)
+				}
+			}
+		}
+		
+		// A single node is only a cycle if it references itself
+		if len(cycle.Edges) > 0 {
+			cycles = append(cycles, cycle)
+		}
+	}
+	
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Nodes[0] < cycles[j].Nodes[0]
+	})
+	return cycles
+}
+
+// EdgeFunc returns a node's outgoing edges
+type EdgeFunc func(nodeID string) ([]models.GraphEdge, error)
+
+// FindCycle reports the cycle that giving a node the outgoing edges would
+// close, considering only edges with the given relationships (all when empty).
+// It returns nil when no cycle would form. The node's current outgoing edges
+// are ignored, since they are being replaced.
+func (g *IndexedGraph) FindCycle(nodeID string, edges []models.GraphEdge, relationships []string) *models.GraphCycle {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	cycle, _ := FindCycleVia(nodeID, edges, relationships, func(node string) ([]models.GraphEdge, error) {
+		return g.outgoing[node], nil
+	})
+	return cycle
+}
+
+// FindCycleVia is FindCycle over a graph whose edges are read with outgoing,
+// such as the edges a store keeps. It fails if outgoing does.
+func FindCycleVia(nodeID string, edges []models.GraphEdge, relationships []string, outgoing EdgeFunc) (*models.GraphCycle, error) {
+	inScope := relationshipFilter(relationships)
+	
+	for _, first := range edges {
+		if !inScope(first.Relationship) {
+			continue
+		}
+		
+		if first.To == nodeID {
+			return &models.GraphCycle{Nodes: []string{nodeID}, Edges: []models.GraphEdge{first}}, nil
+		}
+		
+		// Breadth-first search from the target back to the node
+		via := map[string]models.GraphEdge{first.To: {}}
+		queue := []string{first.To}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			
+			if current == nodeID {
+				var back []models.GraphEdge
+				for node := nodeID; node != first.To; node = via[node].From {
+					back = append(back, via[node])
+				}
+				cycle := &models.GraphCycle{Nodes: []string{nodeID}, Edges: []models.GraphEdge{first}}
+				for i := len(back) - 1; i >= 0; i-- {
+					cycle.Nodes = append(cycle.Nodes, back[i].From)
+					cycle.Edges = append(cycle.Edges, back[i])
+				}
+				return cycle, nil
+			}
+			
+			next, err := outgoing(current)
+			if err != nil {
+				return nil, err
+			}
+			for _, edge := range next {
+				if _, seen := via[edge.To]; seen || !inScope(edge.Relationship) {
+					continue
+				}
+				via[edge.To] = edge
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+	
+	return nil, nil
+}
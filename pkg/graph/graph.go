@@ -2,9 +2,12 @@ package graph
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 
@@ -14,75 +17,205 @@ import (
 // Graph interface defines graph operations
 type Graph interface {
 	AddNode(nodeID string, nodeType string) error
+	GetNode(nodeID string) (models.GraphNode, bool)
 	RemoveNode(nodeID string) error
+	SuspendNode(nodeID string) error
+	ResumeNode(nodeID string) error
 	AddEdge(from, to, relationship string) error
+	PutEdge(edge models.GraphEdge) error
 	RemoveEdge(from, to string) error
-	GetNeighbors(nodeID string) (map[string]string, error)
-	GetIncomingEdges(nodeID string) (map[string]string, error)
+	GetNeighbors(nodeID string) ([]models.GraphEdge, error)
+	GetIncomingEdges(nodeID string) ([]models.GraphEdge, error)
 	FindPath(from, to string, maxDepth int) ([]string, error)
+	FindPathContext(ctx context.Context, from, to string, maxDepth int) ([]string, error)
+	Reachable(ctx context.Context, from string, maxDepth int, direction string) ([]string, error)
+	AllPaths(ctx context.Context, from, to string, maxDepth, limit int) ([]Path, error)
+	ShortestPaths(ctx context.Context, from, to string, k int, weight WeightFunc) ([]Path, error)
 	HasCycle() bool
+	Cycles(relationships []string) []models.GraphCycle
+	FindCycle(nodeID string, edges []models.GraphEdge, relationships []string) *models.GraphCycle
 	Save(filename string) error
 	Load(filename string) error
 	Clear() error
 	UpdateFromEntity(entity string, id int, data map[string]interface{}) error
 }
 
-// IndexedGraph implements an indexed graph with adjacency lists
+// graphFileVersion is the version of the graph file format written by Save
+const graphFileVersion = 2
+
+// graphFile is the on-disk form of the graph
+type graphFile struct {
+	Version int                `json:"version"`
+	Nodes   []models.GraphNode `json:"nodes"`
+	Edges   []models.GraphEdge `json:"edges"`
+	Dormant []dormantNode      `json:"dormant,omitempty"`
+}
+
+// dormantNode is a suspended node together with the edges other nodes held
+// to it when it was suspended
+type dormantNode struct {
+	Node  models.GraphNode   `json:"node"`
+	Edges []models.GraphEdge `json:"edges"`
+}
+
+// IndexedGraph implements an indexed graph of typed nodes joined by multi-edges.
+// Any number of edges may join the same pair of nodes; an edge is identified by
+// its source node and source field, so each REF field contributes one edge.
 type IndexedGraph struct {
-	adjacency map[string]map[string]string // node -> {neighbor -> relationship}
-	reverse   map[string]map[string]string // reverse edges for incoming queries
-	index     map[string][]string          // type/property index
-	mu        sync.RWMutex
+	nodes    map[string]*models.GraphNode   // node ID -> typed node
+	outgoing map[string][]models.GraphEdge // node -> edges from it, sorted by target then field
+	incoming map[string][]models.GraphEdge // node -> edges into it, sorted by source then field
+	index    map[string][]string           // type/property index
+	dormant  map[string]*dormantNode       // suspended node -> its node and incoming edges
+	mu       sync.RWMutex
 }
 
 // NewIndexedGraph creates a new indexed graph
 func NewIndexedGraph() *IndexedGraph {
 	return &IndexedGraph{
-		adjacency: make(map[string]map[string]string),
-		reverse:   make(map[string]map[string]string),
-		index:     make(map[string][]string),
+		nodes:    make(map[string]*models.GraphNode),
+		outgoing: make(map[string][]models.GraphEdge),
+		incoming: make(map[string][]models.GraphEdge),
+		index:    make(map[string][]string),
+		dormant:  make(map[string]*dormantNode),
 	}
 }
 
-// AddNode adds a node to the graph
+// NodeID returns the graph node ID for an entity instance
+func NodeID(entity string, id int) string {
+	return fmt.Sprintf("%s:%d", entity, id)
+}
+
+// AddNode adds a node to the graph. The node type defaults to the entity type
+// in the node ID.
 func (g *IndexedGraph) AddNode(nodeID string, nodeType string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	if _, exists := g.adjacency[nodeID]; !exists {
-		g.adjacency[nodeID] = make(map[string]string)
-		g.reverse[nodeID] = make(map[string]string)
+	g.ensureNode(nodeID, nodeType)
+	return nil
+}
+
+// ensureNode adds a node if it is missing and records its type. The caller
+// must hold the write lock.
+func (g *IndexedGraph) ensureNode(nodeID string, nodeType string) {
+	if nodeType == "" {
+		nodeType = nodeLabel(nodeID)
 	}
 	
-	// Index by type
-	if nodeType != "" {
+	node, exists := g.nodes[nodeID]
+	if !exists {
+		node = &models.GraphNode{ID: nodeID}
+		g.nodes[nodeID] = node
+	}
+	if node.Type != nodeType {
+		node.Type = nodeType
 		g.index[nodeType] = append(g.index[nodeType], nodeID)
 	}
+}
+
+// GetNode returns a node by ID
+func (g *IndexedGraph) GetNode(nodeID string) (models.GraphNode, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	
-	return nil
+	node, exists := g.nodes[nodeID]
+	if !exists {
+		return models.GraphNode{}, false
+	}
+	return *node, true
 }
 
-// RemoveNode removes a node and all its edges
+// RemoveNode removes a node and all its edges, including those kept while it
+// was suspended
 func (g *IndexedGraph) RemoveNode(nodeID string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	// Remove outgoing edges
-	delete(g.adjacency, nodeID)
+	g.removeNodeLocked(nodeID)
+	delete(g.dormant, nodeID)
+	g.dropDormantEdgesLocked(nodeID)
+	return nil
+}
+
+// SuspendNode takes a node out of the graph, as RemoveNode does, but keeps
+// it and the edges other nodes hold to it so ResumeNode can put them back.
+// While it is suspended, references to it are kept aside rather than adding
+// it back.
+func (g *IndexedGraph) SuspendNode(nodeID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	
+	node, exists := g.nodes[nodeID]
+	if !exists {
+		node = &models.GraphNode{ID: nodeID, Type: nodeLabel(nodeID)}
+	}
+	dormant := &dormantNode{Node: *node}
+	for _, edge := range g.incoming[nodeID] {
+		if edge.From != nodeID {
+			dormant.Edges = append(dormant.Edges, edge)
+		}
+	}
+	
+	g.removeNodeLocked(nodeID)
+	g.dormant[nodeID] = dormant
+	return nil
+}
+
+// ResumeNode puts a suspended node back along with the edges held to it by
+// nodes that are still in the graph. Its own edges come back when the entity
+// is next passed to UpdateFromEntity.
+func (g *IndexedGraph) ResumeNode(nodeID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	
-	// Remove incoming edges
-	delete(g.reverse, nodeID)
+	dormant, exists := g.dormant[nodeID]
+	if !exists {
+		return fmt.Errorf("node %s is not suspended", nodeID)
+	}
+	delete(g.dormant, nodeID)
 	
-	// Remove from all neighbors' adjacency lists
-	for node := range g.adjacency {
-		delete(g.adjacency[node], nodeID)
+	g.ensureNode(nodeID, dormant.Node.Type)
+	g.nodes[nodeID].Properties = dormant.Node.Properties
+	for _, edge := range dormant.Edges {
+		if _, live := g.nodes[edge.From]; live {
+			g.putEdgeLocked(edge)
+		}
+	}
+	return nil
+}
+
+// dropDormantEdgesLocked forgets the edges a node held to suspended nodes.
+// The caller must hold the write lock.
+func (g *IndexedGraph) dropDormantEdgesLocked(from string) {
+	for _, dormant := range g.dormant {
+		dormant.Edges = filterEdges(dormant.Edges, func(e models.GraphEdge) bool {
+			return e.From != from
+		})
+	}
+}
+
+// removeNodeLocked removes a node and all its edges. The caller must hold the
+// write lock.
+func (g *IndexedGraph) removeNodeLocked(nodeID string) {
+	// Remove outgoing edges from their targets
+	for _, edge := range g.outgoing[nodeID] {
+		g.incoming[edge.To] = filterEdges(g.incoming[edge.To], func(e models.GraphEdge) bool {
+			return e.From != nodeID
+		})
 	}
 	
-	// Remove from all neighbors' reverse lists
-	for node := range g.reverse {
-		delete(g.reverse[node], nodeID)
+	// Remove incoming edges from their sources
+	for _, edge := range g.incoming[nodeID] {
+		g.outgoing[edge.From] = filterEdges(g.outgoing[edge.From], func(e models.GraphEdge) bool {
+			return e.To != nodeID
+		})
 	}
 	
+	delete(g.nodes, nodeID)
+	delete(g.outgoing, nodeID)
+	delete(g.incoming, nodeID)
+	
 	// Remove from index
 	for key, nodes := range g.index {
 		filtered := make([]string, 0)
@@ -97,98 +230,115 @@ func (g *IndexedGraph) RemoveNode(nodeID string) error {
 			delete(g.index, key)
 		}
 	}
-	
-	return nil
 }
 
-// AddEdge adds a directed edge between nodes
+// AddEdge adds a directed edge between nodes, recorded as coming from the
+// source field named after the relationship
 func (g *IndexedGraph) AddEdge(from, to, relationship string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	
-	// Ensure nodes exist
-	if _, exists := g.adjacency[from]; !exists {
-		g.adjacency[from] = make(map[string]string)
-		g.reverse[from] = make(map[string]string)
+	return g.PutEdge(models.GraphEdge{From: from, To: to, Relationship: relationship})
+}
+
+// PutEdge adds a directed edge, replacing any edge from the same source field.
+// The field defaults to the relationship name.
+func (g *IndexedGraph) PutEdge(edge models.GraphEdge) error {
+	if edge.From == "" || edge.To == "" {
+		return fmt.Errorf("edge needs both ends")
 	}
-	if _, exists := g.adjacency[to]; !exists {
-		g.adjacency[to] = make(map[string]string)
-		g.reverse[to] = make(map[string]string)
+	if edge.Field == "" {
+		edge.Field = edge.Relationship
 	}
 	
-	g.adjacency[from][to] = relationship
-	g.reverse[to][from] = relationship
-	
-	// Index by relationship type
-	relKey := fmt.Sprintf("relationship:%s", relationship)
-	g.index[relKey] = append(g.index[relKey], from)
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	
+	g.putEdgeLocked(edge)
 	return nil
 }
 
-// RemoveEdge removes an edge between nodes
+// putEdgeLocked adds or replaces an edge. The caller must hold the write lock.
+func (g *IndexedGraph) putEdgeLocked(edge models.GraphEdge) {
+	g.ensureNode(edge.From, "")
+	g.ensureNode(edge.To, "")
+	
+	g.removeEdgesLocked(edge.From, func(e models.GraphEdge) bool {
+		return e.Field == edge.Field
+	})
+	
+	g.outgoing[edge.From] = insertEdge(g.outgoing[edge.From], edge, func(a, b models.GraphEdge) bool {
+		return a.To < b.To || (a.To == b.To && a.Field < b.Field)
+	})
+	g.incoming[edge.To] = insertEdge(g.incoming[edge.To], edge, func(a, b models.GraphEdge) bool {
+		return a.From < b.From || (a.From == b.From && a.Field < b.Field)
+	})
+	
+	// Index by relationship type
+	relKey := fmt.Sprintf("relationship:%s", edge.Relationship)
+	g.index[relKey] = append(g.index[relKey], edge.From)
+}
+
+// RemoveEdge removes every edge between two nodes
 func (g *IndexedGraph) RemoveEdge(from, to string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	if adj, exists := g.adjacency[from]; exists {
-		delete(adj, to)
-	}
-	if rev, exists := g.reverse[to]; exists {
-		delete(rev, from)
-	}
-	
+	g.removeEdgesLocked(from, func(e models.GraphEdge) bool {
+		return e.To == to
+	})
 	return nil
 }
 
-// GetNeighbors returns all outgoing neighbors of a node
-func (g *IndexedGraph) GetNeighbors(nodeID string) (map[string]string, error) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
+// removeEdgesLocked removes the outgoing edges of a node that match. The
+// caller must hold the write lock.
+func (g *IndexedGraph) removeEdgesLocked(from string, match func(models.GraphEdge) bool) {
+	var removed []models.GraphEdge
+	g.outgoing[from] = filterEdges(g.outgoing[from], func(e models.GraphEdge) bool {
+		if match(e) {
+			removed = append(removed, e)
+			return false
+		}
+		return true
+	})
 	
-	neighbors, exists := g.adjacency[nodeID]
-	if !exists {
-		return make(map[string]string), nil
+	for _, edge := range removed {
+		g.incoming[edge.To] = filterEdges(g.incoming[edge.To], func(e models.GraphEdge) bool {
+			return e.From != edge.From || e.Field != edge.Field
+		})
 	}
+}
+
+// GetNeighbors returns all outgoing edges of a node
+func (g *IndexedGraph) GetNeighbors(nodeID string) ([]models.GraphEdge, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	
 	// Return a copy to avoid concurrent modification
-	result := make(map[string]string)
-	for k, v := range neighbors {
-		result[k] = v
-	}
-	
-	return result, nil
+	return append([]models.GraphEdge{}, g.outgoing[nodeID]...), nil
 }
 
 // GetIncomingEdges returns all incoming edges to a node
-func (g *IndexedGraph) GetIncomingEdges(nodeID string) (map[string]string, error) {
+func (g *IndexedGraph) GetIncomingEdges(nodeID string) ([]models.GraphEdge, error) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
-	incoming, exists := g.reverse[nodeID]
-	if !exists {
-		return make(map[string]string), nil
-	}
-	
 	// Return a copy
-	result := make(map[string]string)
-	for k, v := range incoming {
-		result[k] = v
-	}
-	
-	return result, nil
+	return append([]models.GraphEdge{}, g.incoming[nodeID]...), nil
 }
 
 // FindPath finds a path between two nodes using BFS
 func (g *IndexedGraph) FindPath(from, to string, maxDepth int) ([]string, error) {
+	return g.FindPathContext(context.Background(), from, to, maxDepth)
+}
+
+// FindPathContext finds a path between two nodes using BFS, stopping early
+// when the context is cancelled. The read lock is only held while a node's
+// edges are read, so writes are not held up by a long search; a path found
+// while the graph changes may mix edges from before and after a write.
+func (g *IndexedGraph) FindPathContext(ctx context.Context, from, to string, maxDepth int) ([]string, error) {
 	g.mu.RLock()
-	defer g.mu.RUnlock()
-	
-	if _, exists := g.adjacency[from]; !exists {
-		return nil, fmt.Errorf("node %s not found", from)
-	}
-	if _, exists := g.adjacency[to]; !exists {
-		return nil, fmt.Errorf("node %s not found", to)
+	err := g.checkEndpoints(from, to)
+	g.mu.RUnlock()
+	if err != nil {
+		return nil, err
 	}
 	
 	queue := [][]string{{from}}
@@ -196,6 +346,10 @@ func (g *IndexedGraph) FindPath(from, to string, maxDepth int) ([]string, error)
 	visited[from] = true
 	
 	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
 		path := queue[0]
 		queue = queue[1:]
 		
@@ -209,12 +363,13 @@ func (g *IndexedGraph) FindPath(from, to string, maxDepth int) ([]string, error)
 			return path, nil
 		}
 		
-		for neighbor := range g.adjacency[current] {
-			if !visited[neighbor] {
-				visited[neighbor] = true
+		edges, _ := g.GetNeighbors(current)
+		for _, edge := range edges {
+			if !visited[edge.To] {
+				visited[edge.To] = true
 				newPath := make([]string, len(path))
 				copy(newPath, path)
-				newPath = append(newPath, neighbor)
+				newPath = append(newPath, edge.To)
 				queue = append(queue, newPath)
 			}
 		}
@@ -223,133 +378,175 @@ func (g *IndexedGraph) FindPath(from, to string, maxDepth int) ([]string, error)
 	return nil, fmt.Errorf("no path found")
 }
 
-// HasCycle checks if the graph has a cycle using DFS
-func (g *IndexedGraph) HasCycle() bool {
+// Reachable returns every node reachable from a node within maxDepth hops,
+// following edges "out", "in" or "both" ways. The start node is not included.
+// The read lock is taken once per level rather than for the whole traversal,
+// so writes can go ahead between levels.
+func (g *IndexedGraph) Reachable(ctx context.Context, from string, maxDepth int, direction string) ([]string, error) {
 	g.mu.RLock()
-	defer g.mu.RUnlock()
+	_, exists := g.nodes[from]
+	g.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("node %s not found", from)
+	}
+	if direction != "out" && direction != "in" && direction != "both" {
+		return nil, fmt.Errorf("invalid direction: %s (must be 'out', 'in' or 'both')", direction)
+	}
 	
-	visited := make(map[string]bool)
-	recStack := make(map[string]bool)
+	visited := map[string]bool{from: true}
+	frontier := []string{from}
+	result := []string{}
 	
-	var hasCycleFrom func(string) bool
-	hasCycleFrom = func(node string) bool {
-		visited[node] = true
-		recStack[node] = true
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		
-		for neighbor := range g.adjacency[node] {
-			if !visited[neighbor] {
-				if hasCycleFrom(neighbor) {
-					return true
+		var next []string
+		g.mu.RLock()
+		for _, node := range frontier {
+			var neighbors []string
+			if direction == "out" || direction == "both" {
+				for _, edge := range g.outgoing[node] {
+					neighbors = append(neighbors, edge.To)
 				}
-			} else if recStack[neighbor] {
-				return true
 			}
-		}
-		
-		recStack[node] = false
-		return false
-	}
-	
-	for node := range g.adjacency {
-		if !visited[node] {
-			if hasCycleFrom(node) {
-				return true
+			if direction == "in" || direction == "both" {
+				for _, edge := range g.incoming[node] {
+					neighbors = append(neighbors, edge.From)
+				}
+			}
+			
+			for _, neighbor := range neighbors {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					next = append(next, neighbor)
+					result = append(result, neighbor)
+				}
 			}
 		}
+		g.mu.RUnlock()
+		frontier = next
 	}
 	
-	return false
+	sort.Strings(result)
+	return result, nil
+}
+
+// HasCycle checks if the graph has a cycle
+func (g *IndexedGraph) HasCycle() bool {
+	return len(g.Cycles(nil)) > 0
 }
 
 // Save saves the graph to a file
 func (g *IndexedGraph) Save(filename string) error {
 	g.mu.RLock()
-	defer g.mu.RUnlock()
+	file := graphFile{
+		Version: graphFileVersion,
+		Nodes:   make([]models.GraphNode, 0, len(g.nodes)),
+		Edges:   []models.GraphEdge{},
+	}
+	for _, nodeID := range g.sortedNodeIDs() {
+		file.Nodes = append(file.Nodes, *g.nodes[nodeID])
+		file.Edges = append(file.Edges, g.outgoing[nodeID]...)
+	}
+	for _, nodeID := range g.sortedDormantIDs() {
+		file.Dormant = append(file.Dormant, *g.dormant[nodeID])
+	}
+	g.mu.RUnlock()
 	
-	tempFile := filename + ".tmp"
-	file, err := os.Create(tempFile)
+	data, err := json.Marshal(file)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 	
-	writer := bufio.NewWriter(file)
-	for nodeID, neighbors := range g.adjacency {
-		var parts []string
-		for neighbor, relType := range neighbors {
-			parts = append(parts, fmt.Sprintf("%s:%s", neighbor, relType))
-		}
-		line := fmt.Sprintf("%s:%s\n", nodeID, strings.Join(parts, " "))
-		if _, err := writer.WriteString(line); err != nil {
-			return err
-		}
-	}
-	
-	if err := writer.Flush(); err != nil {
+	tempFile := filename + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
 		return err
 	}
 	
 	return os.Rename(tempFile, filename)
 }
 
-// Load loads the graph from a file
+// Load loads the graph from a file. An empty file is an empty graph, and a
+// file in the line format written before version 2 is read as such; the next
+// Save rewrites it in the current format.
 func (g *IndexedGraph) Load(filename string) error {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // File doesn't exist yet, that's okay
 		}
 		return err
 	}
-	defer file.Close()
+	
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		return g.loadLegacy(trimmed)
+	}
+	
+	var file graphFile
+	if len(trimmed) > 0 {
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return fmt.Errorf("failed to parse graph file %s: %w", filename, err)
+		}
+		if file.Version != graphFileVersion {
+			return fmt.Errorf("unsupported graph file version %d in %s", file.Version, filename)
+		}
+	}
 	
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	g.adjacency = make(map[string]map[string]string)
-	g.reverse = make(map[string]map[string]string)
-	g.index = make(map[string][]string)
+	g.reset()
+	for _, node := range file.Nodes {
+		g.ensureNode(node.ID, node.Type)
+		g.nodes[node.ID].Properties = node.Properties
+	}
+	for _, edge := range file.Edges {
+		if edge.Field == "" {
+			edge.Field = edge.Relationship
+		}
+		g.putEdgeLocked(edge)
+	}
+	for _, dormant := range file.Dormant {
+		dormant := dormant
+		g.dormant[dormant.Node.ID] = &dormant
+	}
 	
-	scanner := bufio.NewScanner(file)
+	return nil
+}
+
+// loadLegacy loads a graph saved in the format used before version 2: one
+// line per node, "entity:id:" followed by its edges as space-separated
+// "entity:id:relationship". Malformed lines and edges are skipped.
+func (g *IndexedGraph) loadLegacy(data []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	
+	g.reset()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
 			continue
 		}
 		
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		
-		nodeID := parts[0]
-		if _, exists := g.adjacency[nodeID]; !exists {
-			g.adjacency[nodeID] = make(map[string]string)
-			g.reverse[nodeID] = make(map[string]string)
-		}
-		
-		if parts[1] == "" {
-			continue
-		}
+		nodeID := parts[0] + ":" + parts[1]
+		g.ensureNode(nodeID, parts[0])
 		
-		neighbors := strings.Split(parts[1], " ")
-		for _, neighbor := range neighbors {
-			if neighbor == "" {
+		for _, neighbor := range strings.Fields(parts[2]) {
+			target := strings.SplitN(neighbor, ":", 3)
+			if len(target) != 3 || target[0] == "" || target[1] == "" || target[2] == "" {
 				continue
 			}
-			neighborParts := strings.SplitN(neighbor, ":", 2)
-			if len(neighborParts) == 2 {
-				neighborID := neighborParts[0]
-				relType := neighborParts[1]
-				
-				if _, exists := g.adjacency[neighborID]; !exists {
-					g.adjacency[neighborID] = make(map[string]string)
-					g.reverse[neighborID] = make(map[string]string)
-				}
-				
-				g.adjacency[nodeID][neighborID] = relType
-				g.reverse[neighborID][nodeID] = relType
-			}
+			g.putEdgeLocked(models.GraphEdge{
+				From:         nodeID,
+				To:           target[0] + ":" + target[1],
+				Relationship: target[2],
+				Field:        target[2],
+			})
 		}
 	}
 	
@@ -361,13 +558,19 @@ func (g *IndexedGraph) Clear() error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	g.adjacency = make(map[string]map[string]string)
-	g.reverse = make(map[string]map[string]string)
-	g.index = make(map[string][]string)
-	
+	g.reset()
 	return nil
 }
 
+// reset empties the graph. The caller must hold the write lock.
+func (g *IndexedGraph) reset() {
+	g.nodes = make(map[string]*models.GraphNode)
+	g.outgoing = make(map[string][]models.GraphEdge)
+	g.incoming = make(map[string][]models.GraphEdge)
+	g.index = make(map[string][]string)
+	g.dormant = make(map[string]*dormantNode)
+}
+
 // SaveIndex saves the graph index to a file
 func (g *IndexedGraph) SaveIndex(filename string) error {
 	g.mu.RLock()
@@ -402,34 +605,54 @@ func (g *IndexedGraph) LoadIndex(filename string) error {
 	return json.Unmarshal(data, &g.index)
 }
 
-// UpdateFromEntity updates the graph based on entity data
+// UpdateFromEntity updates the graph based on entity data, replacing the
+// node's outgoing edges with those described by its REF fields
 func (g *IndexedGraph) UpdateFromEntity(entity string, id int, data map[string]interface{}) error {
-	nodeID := fmt.Sprintf("%s:%d", entity, id)
+	nodeID := NodeID(entity, id)
+	edges := ReferenceEdges(nodeID, data)
 	
-	// Add node
-	nodeType, _ := data["type"].(string)
-	if err := g.AddNode(nodeID, nodeType); err != nil {
-		return err
-	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	
-	// Process references
-	for key, value := range data {
-		if ref, isRef := models.IsReference(value); isRef {
-			targetNodeID := fmt.Sprintf("%s:%d", ref.Entity, ref.ID)
-			if err := g.AddEdge(nodeID, targetNodeID, key); err != nil {
-				return err
-			}
+	g.ensureNode(nodeID, entity)
+	
+	// Drop references the entity no longer holds
+	g.removeEdgesLocked(nodeID, func(models.GraphEdge) bool { return true })
+	g.dropDormantEdgesLocked(nodeID)
+	
+	// Process references, keeping those to suspended nodes aside
+	for _, edge := range edges {
+		if dormant, suspended := g.dormant[edge.To]; suspended {
+			dormant.Edges = append(dormant.Edges, edge)
+			continue
 		}
+		g.putEdgeLocked(edge)
 	}
 	
 	return nil
 }
 
+// ReferenceEdges returns the outgoing edges described by the references an
+// entity holds, one per reference, named by its JSON path and sorted by it
+func ReferenceEdges(nodeID string, data map[string]interface{}) []models.GraphEdge {
+	refs := models.FindReferences(data)
+	edges := make([]models.GraphEdge, 0, len(refs))
+	for _, ref := range refs {
+		edges = append(edges, models.GraphEdge{
+			From:         nodeID,
+			To:           NodeID(ref.Entity, ref.ID),
+			Relationship: ref.Path,
+			Field:        ref.Path,
+		})
+	}
+	return edges
+}
+
 // NodeCount returns the number of nodes in the graph
 func (g *IndexedGraph) NodeCount() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return len(g.adjacency)
+	return len(g.nodes)
 }
 
 // EdgeCount returns the number of edges in the graph
@@ -438,8 +661,49 @@ func (g *IndexedGraph) EdgeCount() int {
 	defer g.mu.RUnlock()
 	
 	count := 0
-	for _, neighbors := range g.adjacency {
-		count += len(neighbors)
+	for _, edges := range g.outgoing {
+		count += len(edges)
 	}
 	return count
 }
+
+// sortedNodeIDs returns every node ID in order. The caller must hold the lock.
+func (g *IndexedGraph) sortedNodeIDs() []string {
+	ids := make([]string, 0, len(g.nodes))
+	for id := range g.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedDormantIDs returns the IDs of the suspended nodes in order. The
+// caller must hold the lock.
+func (g *IndexedGraph) sortedDormantIDs() []string {
+	ids := make([]string, 0, len(g.dormant))
+	for id := range g.dormant {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// filterEdges returns the edges for which keep returns true
+func filterEdges(edges []models.GraphEdge, keep func(models.GraphEdge) bool) []models.GraphEdge {
+	var kept []models.GraphEdge
+	for _, edge := range edges {
+		if keep(edge) {
+			kept = append(kept, edge)
+		}
+	}
+	return kept
+}
+
+// insertEdge returns a copy of a slice kept sorted by less with the edge inserted
+func insertEdge(edges []models.GraphEdge, edge models.GraphEdge, less func(a, b models.GraphEdge) bool) []models.GraphEdge {
+	i := sort.Search(len(edges), func(i int) bool { return less(edge, edges[i]) })
+	result := make([]models.GraphEdge, 0, len(edges)+1)
+	result = append(result, edges[:i]...)
+	result = append(result, edge)
+	return append(result, edges[i:]...)
+}
@@ -0,0 +1,185 @@
+package graph_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// writeGraphFile writes a graph file into a temporary directory
+func writeGraphFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "graph.data")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	for name, content := range map[string]string{"empty": "", "blank": "\n  \n"} {
+		t.Run(name, func(t *testing.T) {
+			g := graph.NewIndexedGraph()
+			g.AddEdge("users:1", "users:2", "manager")
+
+			if err := g.Load(writeGraphFile(t, content)); err != nil {
+				t.Fatalf("Expected an empty file to load, got %v", err)
+			}
+			if g.NodeCount() != 0 || g.EdgeCount() != 0 {
+				t.Errorf("Expected an empty graph, got %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	g := graph.NewIndexedGraph()
+	if err := g.Load(filepath.Join(t.TempDir(), "missing.data")); err != nil {
+		t.Errorf("Expected a missing file to be no error, got %v", err)
+	}
+}
+
+func TestLoadLegacyFormat(t *testing.T) {
+	filename := writeGraphFile(t, "users:1:users:2:manager teams:7:team\n"+
+		"users:2:\n"+
+		"posts:3:users:1:author\n"+
+		"garbage\n")
+
+	g := graph.NewIndexedGraph()
+	if err := g.Load(filename); err != nil {
+		t.Fatalf("Failed to load legacy graph: %v", err)
+	}
+
+	if g.NodeCount() != 4 || g.EdgeCount() != 3 {
+		t.Errorf("Expected 4 nodes and 3 edges, got %d and %d", g.NodeCount(), g.EdgeCount())
+	}
+	if node, ok := g.GetNode("posts:3"); !ok || node.Type != "posts" {
+		t.Errorf("Expected posts:3 typed as posts, got %+v", node)
+	}
+	edges, _ := g.GetNeighbors("users:1")
+	if len(edges) != 2 || edges[0].To != "teams:7" || edges[0].Relationship != "team" || edges[1].Field != "manager" {
+		t.Errorf("Unexpected edges from users:1: %+v", edges)
+	}
+
+	// Saving migrates the file to the current format
+	if err := g.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := graph.NewIndexedGraph()
+	if err := reloaded.Load(filename); err != nil {
+		t.Fatalf("Failed to reload migrated graph: %v", err)
+	}
+	if reloaded.NodeCount() != 4 || reloaded.EdgeCount() != 3 {
+		t.Errorf("Expected the migrated graph to match, got %d nodes and %d edges", reloaded.NodeCount(), reloaded.EdgeCount())
+	}
+}
+
+func TestLoadUnsupportedVersion(t *testing.T) {
+	g := graph.NewIndexedGraph()
+	if err := g.Load(writeGraphFile(t, `{"version": 99, "nodes": []}`)); err == nil {
+		t.Error("Expected an unknown version to be refused")
+	}
+	if err := g.Load(writeGraphFile(t, `{"version": 2, "nodes": [`)); err == nil {
+		t.Error("Expected a truncated file to be refused")
+	}
+}
+
+// writingContext adds an edge to the graph every time the traversal checks
+// for cancellation, which deadlocks if the traversal still holds the lock
+type writingContext struct {
+	context.Context
+	g     *graph.IndexedGraph
+	count int
+}
+
+func (c *writingContext) Err() error {
+	c.count++
+	c.g.AddEdge(fmt.Sprintf("posts:%d", c.count), "users:1", "author")
+	return nil
+}
+
+func TestTraversalAllowsWrites(t *testing.T) {
+	g := graph.NewIndexedGraph()
+	g.AddEdge("users:1", "users:2", "manager")
+	g.AddEdge("users:2", "users:3", "manager")
+	g.AddEdge("users:3", "users:4", "manager")
+
+	done := make(chan error, 2)
+	go func() {
+		path, err := g.FindPathContext(&writingContext{Context: context.Background(), g: g}, "users:1", "users:4", 5)
+		if err == nil && len(path) != 4 {
+			err = fmt.Errorf("unexpected path %v", path)
+		}
+		done <- err
+	}()
+	go func() {
+		nodes, err := g.Reachable(&writingContext{Context: context.Background(), g: g}, "users:1", 5, "out")
+		if err == nil && len(nodes) != 3 {
+			err = fmt.Errorf("unexpected nodes %v", nodes)
+		}
+		done <- err
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Traversal blocked writes to the graph")
+		}
+	}
+}
+
+func TestMultiEdges(t *testing.T) {
+	g := graph.NewIndexedGraph()
+	g.PutEdge(models.GraphEdge{From: "users:1", To: "users:2", Relationship: "manager", Field: "manager"})
+	g.PutEdge(models.GraphEdge{From: "users:1", To: "users:2", Relationship: "mentor", Field: "mentors[0]"})
+	g.PutEdge(models.GraphEdge{From: "users:1", To: "users:3", Relationship: "mentor", Field: "mentors[1]"})
+
+	// Parallel edges from different fields are kept apart
+	if g.EdgeCount() != 3 {
+		t.Fatalf("Expected 3 edges, got %d", g.EdgeCount())
+	}
+	incoming, _ := g.GetIncomingEdges("users:2")
+	if len(incoming) != 2 || incoming[0].Field != "manager" || incoming[1].Field != "mentors[0]" {
+		t.Errorf("Expected both edges into users:2, got %+v", incoming)
+	}
+
+	// An edge from the same field replaces the old one
+	g.PutEdge(models.GraphEdge{From: "users:1", To: "users:4", Relationship: "manager", Field: "manager"})
+	if g.EdgeCount() != 3 {
+		t.Errorf("Expected the manager edge to be replaced, got %d edges", g.EdgeCount())
+	}
+	incoming, _ = g.GetIncomingEdges("users:2")
+	if len(incoming) != 1 || incoming[0].Field != "mentors[0]" {
+		t.Errorf("Expected only the mentor edge into users:2, got %+v", incoming)
+	}
+
+	// Removing the edges between two nodes leaves the others
+	g.PutEdge(models.GraphEdge{From: "users:1", To: "users:2", Relationship: "manager", Field: "manager"})
+	if err := g.RemoveEdge("users:1", "users:2"); err != nil {
+		t.Fatal(err)
+	}
+	edges, _ := g.GetNeighbors("users:1")
+	if len(edges) != 1 || edges[0].To != "users:3" {
+		t.Errorf("Expected only the edge to users:3, got %+v", edges)
+	}
+	if incoming, _ := g.GetIncomingEdges("users:2"); len(incoming) != 0 {
+		t.Errorf("Expected no edges into users:2, got %+v", incoming)
+	}
+	if incoming, _ := g.GetIncomingEdges("users:3"); len(incoming) != 1 {
+		t.Errorf("Expected the edge into users:3 to stay, got %+v", incoming)
+	}
+
+	if err := g.PutEdge(models.GraphEdge{From: "users:1"}); err == nil {
+		t.Error("Expected an edge without a target to be refused")
+	}
+}
@@ -0,0 +1,356 @@
+package graph
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// WeightFunc returns the weight of the edge from one node to another.
+// Weights must not be negative.
+type WeightFunc func(from, to, relationship string) (float64, error)
+
+// UnitWeight weighs every edge as 1, so the shortest path is the one with fewest hops
+func UnitWeight(from, to, relationship string) (float64, error) {
+	return 1, nil
+}
+
+// Path is a route through the graph. Edges[i] joins Nodes[i] and Nodes[i+1].
+type Path struct {
+	Nodes  []string
+	Edges  []models.GraphEdge
+	Weight float64
+}
+
+// Hops returns the number of edges in the path
+func (p Path) Hops() int {
+	return len(p.Edges)
+}
+
+// Info converts the path to its wire format, alternating nodes and edges
+func (p Path) Info() models.PathInfo {
+	info := models.PathInfo{
+		Length: p.Hops(),
+		Weight: p.Weight,
+		Path:   make([]interface{}, 0, len(p.Nodes)+len(p.Edges)),
+	}
+	if len(p.Nodes) > 0 {
+		info.From = p.Nodes[0]
+		info.To = p.Nodes[len(p.Nodes)-1]
+	}
+	
+	for i, node := range p.Nodes {
+		info.Path = append(info.Path, models.GraphNode{ID: node, Type: nodeLabel(node)})
+		if i < len(p.Edges) {
+			info.Path = append(info.Path, p.Edges[i])
+		}
+	}
+	return info
+}
+
+// AllPaths returns every simple path from one node to another with at most
+// maxDepth edges, shortest first, stopping after limit paths (0 means no limit)
+func (g *IndexedGraph) AllPaths(ctx context.Context, from, to string, maxDepth, limit int) ([]Path, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	if err := g.checkEndpoints(from, to); err != nil {
+		return nil, err
+	}
+	
+	var paths []Path
+	nodes := []string{from}
+	var edges []models.GraphEdge
+	onPath := map[string]bool{from: true}
+	
+	var visit func(node string) error
+	visit = func(node string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if node == to && len(edges) > 0 {
+			paths = append(paths, Path{
+				Nodes:  append([]string(nil), nodes...),
+				Edges:  append([]models.GraphEdge(nil), edges...),
+				Weight: float64(len(edges)),
+			})
+			return nil
+		}
+		if len(edges) >= maxDepth {
+			return nil
+		}
+		
+		for _, edge := range g.outgoing[node] {
+			if limit > 0 && len(paths) >= limit {
+				return nil
+			}
+			if onPath[edge.To] {
+				continue
+			}
+			onPath[edge.To] = true
+			nodes = append(nodes, edge.To)
+			edges = append(edges, edge)
+			
+			if err := visit(edge.To); err != nil {
+				return err
+			}
+			
+			nodes = nodes[:len(nodes)-1]
+			edges = edges[:len(edges)-1]
+			delete(onPath, edge.To)
+		}
+		return nil
+	}
+	
+	if err := visit(from); err != nil {
+		return nil, err
+	}
+	
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].Hops() < paths[j].Hops()
+	})
+	return paths, nil
+}
+
+// ShortestPaths returns up to k loopless paths from one node to another in
+// order of increasing total weight, using Yen's algorithm over Dijkstra.
+// Ties are broken by hop count and then by node IDs, so results are stable.
+func (g *IndexedGraph) ShortestPaths(ctx context.Context, from, to string, k int, weight WeightFunc) ([]Path, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	if err := g.checkEndpoints(from, to); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive")
+	}
+	if from == to {
+		return nil, fmt.Errorf("from and to must be different nodes")
+	}
+	
+	first, err := g.dijkstra(ctx, from, to, weight, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, fmt.Errorf("no path found")
+	}
+	
+	accepted := []Path{*first}
+	var candidates []Path
+	seen := map[string]bool{pathKey(*first): true}
+	
+	for len(accepted) < k {
+		previous := accepted[len(accepted)-1]
+		
+		for i := 0; i < len(previous.Nodes)-1; i++ {
+			spur := previous.Nodes[i]
+			root := previous.Nodes[:i+1]
+			
+			// Block the edges other accepted paths take out of the same root,
+			// and the root's own nodes, so the spur path is new and loopless
+			blockedEdges := make(map[string]bool)
+			for _, p := range accepted {
+				if len(p.Nodes) > i+1 && equalStrings(p.Nodes[:i+1], root) {
+					blockedEdges[edgeKey(p.Edges[i])] = true
+				}
+			}
+			blockedNodes := make(map[string]bool, i)
+			for _, node := range root[:i] {
+				blockedNodes[node] = true
+			}
+			
+			spurPath, err := g.dijkstra(ctx, spur, to, weight, blockedNodes, blockedEdges)
+			if err != nil {
+				return nil, err
+			}
+			if spurPath == nil {
+				continue
+			}
+			
+			candidate := Path{
+				Nodes: append(append([]string(nil), root[:i]...), spurPath.Nodes...),
+				Edges: append(append([]models.GraphEdge(nil), previous.Edges[:i]...), spurPath.Edges...),
+			}
+			for _, edge := range candidate.Edges {
+				w, err := weight(edge.From, edge.To, edge.Relationship)
+				if err != nil {
+					return nil, err
+				}
+				candidate.Weight += w
+			}
+			
+			if key := pathKey(candidate); !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, candidate)
+			}
+		}
+		
+		if len(candidates) == 0 {
+			break
+		}
+		
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return lessPath(candidates[i], candidates[j])
+		})
+		accepted = append(accepted, candidates[0])
+		candidates = candidates[1:]
+	}
+	
+	return accepted, nil
+}
+
+// dijkstra finds the lowest-weight path avoiding the blocked nodes and edges,
+// or nil if there is none. The caller must hold the read lock.
+func (g *IndexedGraph) dijkstra(ctx context.Context, from, to string, weight WeightFunc,
+	blockedNodes, blockedEdges map[string]bool) (*Path, error) {
+	dist := map[string]float64{from: 0}
+	hops := map[string]int{from: 0}
+	prev := make(map[string]models.GraphEdge)
+	done := make(map[string]bool)
+	
+	queue := &pathQueue{{node: from}}
+	for queue.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		item := heap.Pop(queue).(pathQueueItem)
+		if done[item.node] {
+			continue
+		}
+		done[item.node] = true
+		
+		if item.node == to {
+			break
+		}
+		
+		for _, edge := range g.outgoing[item.node] {
+			neighbor := edge.To
+			if done[neighbor] || blockedNodes[neighbor] || blockedEdges[edgeKey(edge)] {
+				continue
+			}
+			
+			w, err := weight(item.node, neighbor, edge.Relationship)
+			if err != nil {
+				return nil, err
+			}
+			if w < 0 {
+				return nil, fmt.Errorf("negative weight %v on edge %s -> %s", w, item.node, neighbor)
+			}
+			
+			d := dist[item.node] + w
+			h := hops[item.node] + 1
+			if current, seen := dist[neighbor]; !seen || d < current || (d == current && h < hops[neighbor]) {
+				dist[neighbor] = d
+				hops[neighbor] = h
+				prev[neighbor] = edge
+				heap.Push(queue, pathQueueItem{node: neighbor, dist: d, hops: h})
+			}
+		}
+	}
+	
+	if !done[to] {
+		return nil, nil
+	}
+	
+	path := &Path{Nodes: []string{to}, Weight: dist[to]}
+	for node := to; node != from; node = prev[node].From {
+		path.Nodes = append(path.Nodes, prev[node].From)
+		path.Edges = append(path.Edges, prev[node])
+	}
+	for i, j := 0, len(path.Nodes)-1; i < j; i, j = i+1, j-1 {
+		path.Nodes[i], path.Nodes[j] = path.Nodes[j], path.Nodes[i]
+	}
+	for i, j := 0, len(path.Edges)-1; i < j; i, j = i+1, j-1 {
+		path.Edges[i], path.Edges[j] = path.Edges[j], path.Edges[i]
+	}
+	
+	return path, nil
+}
+
+// checkEndpoints verifies that both nodes exist. The caller must hold the read lock.
+func (g *IndexedGraph) checkEndpoints(from, to string) error {
+	if _, exists := g.nodes[from]; !exists {
+		return fmt.Errorf("node %s not found", from)
+	}
+	if _, exists := g.nodes[to]; !exists {
+		return fmt.Errorf("node %s not found", to)
+	}
+	return nil
+}
+
+// pathQueueItem is a tentative distance in the Dijkstra priority queue
+type pathQueueItem struct {
+	node string
+	dist float64
+	hops int
+}
+
+// pathQueue is a min-heap ordered by distance, then hops, then node ID
+type pathQueue []pathQueueItem
+
+func (q pathQueue) Len() int { return len(q) }
+
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	if q[i].hops != q[j].hops {
+		return q[i].hops < q[j].hops
+	}
+	return q[i].node < q[j].node
+}
+
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathQueueItem)) }
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// lessPath orders paths by weight, then hops, then node IDs
+func lessPath(a, b Path) bool {
+	if a.Weight != b.Weight {
+		return a.Weight < b.Weight
+	}
+	if a.Hops() != b.Hops() {
+		return a.Hops() < b.Hops()
+	}
+	return pathKey(a) < pathKey(b)
+}
+
+// pathKey identifies a path by its edges, so paths through parallel edges differ
+func pathKey(p Path) string {
+	keys := make([]string, len(p.Edges))
+	for i, edge := range p.Edges {
+		keys[i] = edgeKey(edge)
+	}
+	return strings.Join(keys, "\x01")
+}
+
+// edgeKey identifies an edge by its source node and field
+func edgeKey(edge models.GraphEdge) string {
+	return edge.From + "\x00" + edge.Field + "\x00" + edge.To
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
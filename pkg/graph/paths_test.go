@@ -0,0 +1,169 @@
+package graph_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/graph"
+)
+
+// diamondGraph builds a -> b -> c -> d with a shortcut a -> d and a detour
+// b -> d
+func diamondGraph() *graph.IndexedGraph {
+	g := graph.NewIndexedGraph()
+	g.AddEdge("n:a", "n:b", "next")
+	g.AddEdge("n:b", "n:c", "next")
+	g.AddEdge("n:c", "n:d", "next")
+	g.AddEdge("n:b", "n:d", "detour")
+	g.AddEdge("n:a", "n:d", "skip")
+	return g
+}
+
+// pathNodes returns the nodes of each path
+func pathNodes(paths []graph.Path) [][]string {
+	var nodes [][]string
+	for _, p := range paths {
+		nodes = append(nodes, p.Nodes)
+	}
+	return nodes
+}
+
+func TestAllPathsDepth(t *testing.T) {
+	g := diamondGraph()
+	ctx := context.Background()
+
+	tests := []struct {
+		depth int
+		want  [][]string
+	}{
+		{0, nil},
+		{1, [][]string{{"n:a", "n:d"}}},
+		{2, [][]string{{"n:a", "n:d"}, {"n:a", "n:b", "n:d"}}},
+		{3, [][]string{{"n:a", "n:d"}, {"n:a", "n:b", "n:d"}, {"n:a", "n:b", "n:c", "n:d"}}},
+		{10, [][]string{{"n:a", "n:d"}, {"n:a", "n:b", "n:d"}, {"n:a", "n:b", "n:c", "n:d"}}},
+	}
+	for _, tt := range tests {
+		paths, err := g.AllPaths(ctx, "n:a", "n:d", tt.depth, 0)
+		if err != nil {
+			t.Fatalf("depth %d: %v", tt.depth, err)
+		}
+		if got := pathNodes(paths); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("depth %d: expected %v, got %v", tt.depth, tt.want, got)
+		}
+		for _, p := range paths {
+			if p.Hops() > tt.depth || len(p.Edges) != len(p.Nodes)-1 {
+				t.Errorf("depth %d: malformed path %+v", tt.depth, p)
+			}
+		}
+	}
+
+	paths, err := g.AllPaths(ctx, "n:a", "n:d", 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Expected the limit to stop at 2 paths, got %d", len(paths))
+	}
+
+	if _, err := g.AllPaths(ctx, "n:a", "n:missing", 3, 0); err == nil {
+		t.Error("Expected a missing endpoint to be refused")
+	}
+}
+
+func TestAllPathsCycle(t *testing.T) {
+	g := graph.NewIndexedGraph()
+	g.AddEdge("n:a", "n:b", "next")
+	g.AddEdge("n:b", "n:a", "back")
+	g.AddEdge("n:b", "n:c", "next")
+
+	// Paths are simple, so the loop between a and b is never followed
+	paths, err := g.AllPaths(context.Background(), "n:a", "n:c", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pathNodes(paths); !reflect.DeepEqual(got, [][]string{{"n:a", "n:b", "n:c"}}) {
+		t.Errorf("Expected one simple path, got %v", got)
+	}
+}
+
+// weights weighs edges by their relationship
+func weights(byRelationship map[string]float64) graph.WeightFunc {
+	return func(from, to, relationship string) (float64, error) {
+		return byRelationship[relationship], nil
+	}
+}
+
+func TestShortestPathsWeights(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Weighted", func(t *testing.T) {
+		g := diamondGraph()
+		paths, err := g.ShortestPaths(ctx, "n:a", "n:d", 3, weights(map[string]float64{"next": 1, "detour": 1, "skip": 5}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"n:a", "n:b", "n:d"}, {"n:a", "n:b", "n:c", "n:d"}, {"n:a", "n:d"}}
+		if got := pathNodes(paths); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+		if paths[0].Weight != 2 || paths[1].Weight != 3 || paths[2].Weight != 5 {
+			t.Errorf("Expected weights 2, 3 and 5, got %v, %v and %v", paths[0].Weight, paths[1].Weight, paths[2].Weight)
+		}
+	})
+
+	t.Run("ZeroWeights", func(t *testing.T) {
+		// Every path weighs nothing, so fewer hops win the tie
+		g := diamondGraph()
+		paths, err := g.ShortestPaths(ctx, "n:a", "n:d", 3, weights(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"n:a", "n:d"}, {"n:a", "n:b", "n:d"}, {"n:a", "n:b", "n:c", "n:d"}}
+		if got := pathNodes(paths); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+		for _, p := range paths {
+			if p.Weight != 0 {
+				t.Errorf("Expected a zero weight, got %v for %v", p.Weight, p.Nodes)
+			}
+		}
+	})
+
+	t.Run("ZeroWeightCycle", func(t *testing.T) {
+		// A free loop must not be followed forever
+		g := graph.NewIndexedGraph()
+		g.AddEdge("n:a", "n:b", "free")
+		g.AddEdge("n:b", "n:a", "free")
+		g.AddEdge("n:b", "n:c", "paid")
+		paths, err := g.ShortestPaths(ctx, "n:a", "n:c", 5, weights(map[string]float64{"paid": 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pathNodes(paths); !reflect.DeepEqual(got, [][]string{{"n:a", "n:b", "n:c"}}) {
+			t.Errorf("Expected one loopless path, got %v", got)
+		}
+	})
+
+	t.Run("NegativeWeight", func(t *testing.T) {
+		g := diamondGraph()
+		_, err := g.ShortestPaths(ctx, "n:a", "n:d", 1, weights(map[string]float64{"next": 1, "skip": -1}))
+		if err == nil || !strings.Contains(err.Error(), "negative weight") {
+			t.Errorf("Expected a negative weight to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Arguments", func(t *testing.T) {
+		g := diamondGraph()
+		if _, err := g.ShortestPaths(ctx, "n:a", "n:d", 0, graph.UnitWeight); err == nil {
+			t.Error("Expected k of 0 to be refused")
+		}
+		if _, err := g.ShortestPaths(ctx, "n:a", "n:a", 1, graph.UnitWeight); err == nil {
+			t.Error("Expected a path to itself to be refused")
+		}
+		if _, err := g.ShortestPaths(ctx, "n:d", "n:a", 1, graph.UnitWeight); err == nil {
+			t.Error("Expected no path against the edges to be an error")
+		}
+	})
+}
@@ -0,0 +1,734 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// Pattern is a parsed graph pattern query such as
+//
+//	MATCH (u:users)-[:manager*1..3]->(m:users) WHERE m.name = "Alice" RETURN u
+//
+// Nodes are written (variable:label) where the label is an entity type.
+// Relationships are written -[:name]->, <-[:name]- or -[:name]- and may list
+// alternative names (:manager|:mentor) and a hop range (*, *2, *1..3, *..3).
+// WHERE conditions are ANDed property predicates on node variables.
+type Pattern struct {
+	Nodes         []PatternNode
+	Relationships []PatternRelationship           // Relationships[i] joins Nodes[i] and Nodes[i+1]
+	Where         map[string][]models.FilterParam // variable -> predicates
+	Return        []string
+	Limit         int // 0 means no limit
+}
+
+// PatternNode is a node in a pattern
+type PatternNode struct {
+	Variable string
+	Label    string // entity type; empty matches any node
+}
+
+// PatternRelationship is a relationship between two pattern nodes
+type PatternRelationship struct {
+	Names     []string // relationship names; empty matches any
+	Direction string   // "out", "in" or "both"
+	MinHops   int
+	MaxHops   int
+}
+
+// PatternSource provides the nodes, edges and properties a pattern is matched against
+type PatternSource interface {
+	// Nodes returns the IDs of every node with the given label
+	Nodes(ctx context.Context, label string) ([]string, error)
+	// Edges returns the edges leaving ("out") or entering ("in") a node
+	Edges(ctx context.Context, nodeID string, direction string) ([]models.GraphEdge, error)
+	// Matches reports whether the properties of a node satisfy the filters
+	Matches(ctx context.Context, nodeID string, filters []models.FilterParam) (bool, error)
+}
+
+// anonymousPrefix marks variables generated for unnamed pattern nodes
+const anonymousPrefix = "_anon"
+
+// ParsePattern parses a pattern query. Open-ended hop ranges are bounded by
+// maxDepth, and explicit ranges may not exceed it.
+func ParsePattern(query string, maxDepth int) (*Pattern, error) {
+	if maxDepth <= 0 {
+		return nil, fmt.Errorf("max depth must be positive")
+	}
+	
+	tokens, err := lexPattern(query)
+	if err != nil {
+		return nil, err
+	}
+	
+	p := &patternParser{tokens: tokens, maxDepth: maxDepth}
+	pattern, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return pattern, nil
+}
+
+// Match finds every binding of the pattern's variables to node IDs and returns
+// the distinct combinations of the RETURN variables, in a stable order
+func (p *Pattern) Match(ctx context.Context, src PatternSource) ([]map[string]string, error) {
+	checked := make(map[string]bool)
+	accepts := func(variable, nodeID string) (bool, error) {
+		key := variable + "\x00" + nodeID
+		if ok, seen := checked[key]; seen {
+			return ok, nil
+		}
+		ok := true
+		if filters := p.Where[variable]; len(filters) > 0 {
+			var err error
+			if ok, err = src.Matches(ctx, nodeID, filters); err != nil {
+				return false, err
+			}
+		}
+		checked[key] = ok
+		return ok, nil
+	}
+	
+	first := p.Nodes[0]
+	starts, err := src.Nodes(ctx, first.Label)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(starts)
+	
+	var bindings []map[string]string
+	for _, nodeID := range starts {
+		ok, err := accepts(first.Variable, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			bindings = append(bindings, map[string]string{first.Variable: nodeID})
+		}
+	}
+	
+	for i, rel := range p.Relationships {
+		from := p.Nodes[i]
+		to := p.Nodes[i+1]
+		var next []map[string]string
+		
+		for _, binding := range bindings {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			
+			targets, err := expandRelationship(ctx, src, binding[from.Variable], rel)
+			if err != nil {
+				return nil, err
+			}
+			
+			for _, target := range targets {
+				if to.Label != "" && nodeLabel(target) != to.Label {
+					continue
+				}
+				if bound, exists := binding[to.Variable]; exists && bound != target {
+					continue
+				}
+				ok, err := accepts(to.Variable, target)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+				
+				extended := make(map[string]string, len(binding)+1)
+				for k, v := range binding {
+					extended[k] = v
+				}
+				extended[to.Variable] = target
+				next = append(next, extended)
+			}
+		}
+		bindings = next
+	}
+	
+	results := []map[string]string{}
+	seen := make(map[string]bool)
+	for _, binding := range bindings {
+		row := make(map[string]string, len(p.Return))
+		keys := make([]string, len(p.Return))
+		for i, variable := range p.Return {
+			row[variable] = binding[variable]
+			keys[i] = binding[variable]
+		}
+		key := strings.Join(keys, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		results = append(results, row)
+		if p.Limit > 0 && len(results) >= p.Limit {
+			break
+		}
+	}
+	
+	return results, nil
+}
+
+// expandRelationship returns the nodes reachable from a node through between
+// MinHops and MaxHops edges of the relationship, sorted
+func expandRelationship(ctx context.Context, src PatternSource, from string, rel PatternRelationship) ([]string, error) {
+	var directions []string
+	if rel.Direction == "out" || rel.Direction == "both" {
+		directions = append(directions, "out")
+	}
+	if rel.Direction == "in" || rel.Direction == "both" {
+		directions = append(directions, "in")
+	}
+	
+	names := make(map[string]bool, len(rel.Names))
+	for _, name := range rel.Names {
+		names[name] = true
+	}
+	
+	reached := make(map[string]bool)
+	frontier := map[string]bool{from: true}
+	
+	for depth := 1; depth <= rel.MaxHops && len(frontier) > 0; depth++ {
+		next := make(map[string]bool)
+		for node := range frontier {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			for _, direction := range directions {
+				edges, err := src.Edges(ctx, node, direction)
+				if err != nil {
+					return nil, err
+				}
+				for _, edge := range edges {
+					if len(names) > 0 && !names[edge.Relationship] {
+						continue
+					}
+					if direction == "in" {
+						next[edge.From] = true
+					} else {
+						next[edge.To] = true
+					}
+				}
+			}
+		}
+		
+		if depth >= rel.MinHops {
+			for node := range next {
+				reached[node] = true
+			}
+		}
+		frontier = next
+	}
+	
+	result := make([]string, 0, len(reached))
+	for node := range reached {
+		result = append(result, node)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// nodeLabel returns the entity type of a node ID of the form "entity:id"
+func nodeLabel(nodeID string) string {
+	if idx := strings.LastIndex(nodeID, ":"); idx >= 0 {
+		return nodeID[:idx]
+	}
+	return nodeID
+}
+
+// Pattern token kinds
+const (
+	tokenIdent = iota
+	tokenString
+	tokenNumber
+	tokenSymbol
+	tokenEOF
+)
+
+type patternToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// patternSymbols lists multi-character symbols before their prefixes
+var patternSymbols = []string{"..", "!=", "<>", "<=", ">=", "(", ")", "[", "]", ":", "-", ">", "<", "*", ".", ",", "|", "="}
+
+// lexPattern splits a pattern query into tokens
+func lexPattern(query string) ([]patternToken, error) {
+	var tokens []patternToken
+	runes := []rune(query)
+	
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, patternToken{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			// A single dot followed by a digit is a decimal point; ".." is a range
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, patternToken{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == r {
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, patternToken{kind: tokenString, text: sb.String(), pos: start})
+		
+		default:
+			matched := false
+			for _, symbol := range patternSymbols {
+				if strings.HasPrefix(string(runes[i:]), symbol) {
+					tokens = append(tokens, patternToken{kind: tokenSymbol, text: symbol, pos: i})
+					i += len([]rune(symbol))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+	
+	tokens = append(tokens, patternToken{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// patternParser is a recursive-descent parser over pattern tokens
+type patternParser struct {
+	tokens    []patternToken
+	pos       int
+	maxDepth  int
+	anonymous int
+}
+
+func (p *patternParser) peek() patternToken {
+	return p.tokens[p.pos]
+}
+
+func (p *patternParser) next() patternToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isSymbol reports whether the next token is the given symbol
+func (p *patternParser) isSymbol(symbol string) bool {
+	tok := p.peek()
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+// isKeyword reports whether the next token is the given keyword, ignoring case
+func (p *patternParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *patternParser) expectSymbol(symbol string) error {
+	if !p.isSymbol(symbol) {
+		return p.errorf("expected %q", symbol)
+	}
+	p.next()
+	return nil
+}
+
+func (p *patternParser) expectKeyword(keyword string) error {
+	if !p.isKeyword(keyword) {
+		return p.errorf("expected %s", keyword)
+	}
+	p.next()
+	return nil
+}
+
+func (p *patternParser) expectIdent(what string) (string, error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent {
+		return "", p.errorf("expected %s", what)
+	}
+	p.next()
+	return tok.text, nil
+}
+
+func (p *patternParser) expectInt(what string) (int, error) {
+	tok := p.peek()
+	n, err := strconv.Atoi(tok.text)
+	if tok.kind != tokenNumber || err != nil {
+		return 0, p.errorf("expected %s", what)
+	}
+	p.next()
+	return n, nil
+}
+
+// errorf reports a syntax error at the current token
+func (p *patternParser) errorf(format string, args ...interface{}) error {
+	tok := p.peek()
+	found := tok.text
+	if tok.kind == tokenEOF {
+		found = "end of query"
+	}
+	return fmt.Errorf("%s at position %d (found %q)", fmt.Sprintf(format, args...), tok.pos, found)
+}
+
+// parse reads MATCH pattern [WHERE conditions] RETURN variables [LIMIT n]
+func (p *patternParser) parse() (*Pattern, error) {
+	pattern := &Pattern{Where: make(map[string][]models.FilterParam)}
+	
+	if err := p.expectKeyword("MATCH"); err != nil {
+		return nil, err
+	}
+	
+	node, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	if node.Label == "" {
+		return nil, fmt.Errorf("the first node of a pattern needs a label, e.g. (u:users)")
+	}
+	pattern.Nodes = append(pattern.Nodes, node)
+	
+	for p.isSymbol("-") || p.isSymbol("<") {
+		rel, err := p.parseRelationship()
+		if err != nil {
+			return nil, err
+		}
+		node, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		pattern.Relationships = append(pattern.Relationships, rel)
+		pattern.Nodes = append(pattern.Nodes, node)
+	}
+	
+	declared := make(map[string]bool)
+	for _, node := range pattern.Nodes {
+		declared[node.Variable] = true
+	}
+	
+	if p.isKeyword("WHERE") {
+		p.next()
+		for {
+			variable, filter, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			if !declared[variable] {
+				return nil, fmt.Errorf("unknown variable %q in WHERE", variable)
+			}
+			pattern.Where[variable] = append(pattern.Where[variable], filter)
+			
+			if !p.isKeyword("AND") {
+				break
+			}
+			p.next()
+		}
+	}
+	
+	if err := p.expectKeyword("RETURN"); err != nil {
+		return nil, err
+	}
+	for {
+		variable, err := p.expectIdent("variable")
+		if err != nil {
+			return nil, err
+		}
+		if !declared[variable] || strings.HasPrefix(variable, anonymousPrefix) {
+			return nil, fmt.Errorf("unknown variable %q in RETURN", variable)
+		}
+		pattern.Return = append(pattern.Return, variable)
+		
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	
+	if p.isKeyword("LIMIT") {
+		p.next()
+		limit, err := p.expectInt("limit")
+		if err != nil {
+			return nil, err
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("limit must be positive")
+		}
+		pattern.Limit = limit
+	}
+	
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("unexpected input")
+	}
+	
+	return pattern, nil
+}
+
+// parseNode reads (variable:label), where both parts are optional
+func (p *patternParser) parseNode() (PatternNode, error) {
+	var node PatternNode
+	
+	if err := p.expectSymbol("("); err != nil {
+		return node, err
+	}
+	if p.peek().kind == tokenIdent {
+		node.Variable = p.next().text
+		if strings.HasPrefix(node.Variable, anonymousPrefix) {
+			return node, fmt.Errorf("variable names may not start with %q", anonymousPrefix)
+		}
+	}
+	if p.isSymbol(":") {
+		p.next()
+		label, err := p.expectIdent("label")
+		if err != nil {
+			return node, err
+		}
+		node.Label = label
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return node, err
+	}
+	
+	if node.Variable == "" {
+		node.Variable = fmt.Sprintf("%s%d", anonymousPrefix, p.anonymous)
+		p.anonymous++
+	}
+	return node, nil
+}
+
+// parseRelationship reads -[...]->, <-[...]- or -[...]-
+func (p *patternParser) parseRelationship() (PatternRelationship, error) {
+	rel := PatternRelationship{Direction: "both", MinHops: 1, MaxHops: 1}
+	
+	incoming := false
+	if p.isSymbol("<") {
+		p.next()
+		incoming = true
+	}
+	if err := p.expectSymbol("-"); err != nil {
+		return rel, err
+	}
+	
+	if p.isSymbol("[") {
+		p.next()
+		if p.peek().kind == tokenIdent {
+			return rel, p.errorf("relationship variables are not supported")
+		}
+		if p.isSymbol(":") {
+			p.next()
+			for {
+				name, err := p.expectIdent("relationship name")
+				if err != nil {
+					return rel, err
+				}
+				rel.Names = append(rel.Names, name)
+				
+				if !p.isSymbol("|") {
+					break
+				}
+				p.next()
+				if p.isSymbol(":") {
+					p.next()
+				}
+			}
+		}
+		if p.isSymbol("*") {
+			p.next()
+			if err := p.parseHops(&rel); err != nil {
+				return rel, err
+			}
+		}
+		if err := p.expectSymbol("]"); err != nil {
+			return rel, err
+		}
+	}
+	
+	if err := p.expectSymbol("-"); err != nil {
+		return rel, err
+	}
+	if p.isSymbol(">") {
+		if incoming {
+			return rel, p.errorf("a relationship cannot point both ways")
+		}
+		p.next()
+		rel.Direction = "out"
+	} else if incoming {
+		rel.Direction = "in"
+	}
+	
+	return rel, nil
+}
+
+// parseHops reads the hop range after "*": nothing, n, n.., ..m or n..m
+func (p *patternParser) parseHops(rel *PatternRelationship) error {
+	rel.MinHops, rel.MaxHops = 1, p.maxDepth
+	
+	if p.peek().kind == tokenNumber {
+		n, err := p.expectInt("hop count")
+		if err != nil {
+			return err
+		}
+		rel.MinHops, rel.MaxHops = n, n
+	}
+	if p.isSymbol("..") {
+		p.next()
+		rel.MaxHops = p.maxDepth
+		if p.peek().kind == tokenNumber {
+			n, err := p.expectInt("hop count")
+			if err != nil {
+				return err
+			}
+			rel.MaxHops = n
+		}
+	}
+	
+	if rel.MinHops < 1 {
+		return fmt.Errorf("minimum hops must be at least 1")
+	}
+	if rel.MaxHops < rel.MinHops {
+		return fmt.Errorf("invalid hop range %d..%d", rel.MinHops, rel.MaxHops)
+	}
+	if rel.MaxHops > p.maxDepth {
+		return fmt.Errorf("hop range %d..%d exceeds the maximum depth of %d", rel.MinHops, rel.MaxHops, p.maxDepth)
+	}
+	return nil
+}
+
+// patternOperators maps comparison symbols onto filter operators
+var patternOperators = map[string]string{
+	"=":  "eq",
+	"!=": "ne",
+	"<>": "ne",
+	"<":  "lt",
+	"<=": "lte",
+	">":  "gt",
+	">=": "gte",
+}
+
+// parseCondition reads variable.field[.field...] operator literal
+func (p *patternParser) parseCondition() (string, models.FilterParam, error) {
+	var filter models.FilterParam
+	
+	variable, err := p.expectIdent("variable")
+	if err != nil {
+		return "", filter, err
+	}
+	if err := p.expectSymbol("."); err != nil {
+		return "", filter, err
+	}
+	
+	var path []string
+	for {
+		part, err := p.expectIdent("property name")
+		if err != nil {
+			return "", filter, err
+		}
+		path = append(path, part)
+		
+		if !p.isSymbol(".") {
+			break
+		}
+		p.next()
+	}
+	filter.Field = strings.Join(path, ".")
+	
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenSymbol && patternOperators[tok.text] != "":
+		p.next()
+		filter.Operator = patternOperators[tok.text]
+	case p.isKeyword("CONTAINS"):
+		p.next()
+		filter.Operator = "contains"
+	case p.isKeyword("STARTS"):
+		p.next()
+		if err := p.expectKeyword("WITH"); err != nil {
+			return "", filter, err
+		}
+		filter.Operator = "starts"
+	case p.isKeyword("ENDS"):
+		p.next()
+		if err := p.expectKeyword("WITH"); err != nil {
+			return "", filter, err
+		}
+		filter.Operator = "ends"
+	default:
+		return "", filter, p.errorf("expected comparison operator")
+	}
+	
+	value, err := p.parseLiteral()
+	if err != nil {
+		return "", filter, err
+	}
+	filter.Value = value
+	
+	return variable, filter, nil
+}
+
+// parseLiteral reads a string, number or boolean literal
+func (p *patternParser) parseLiteral() (string, error) {
+	negative := false
+	if p.isSymbol("-") {
+		p.next()
+		negative = true
+	}
+	
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenNumber:
+		p.next()
+		if negative {
+			return "-" + tok.text, nil
+		}
+		return tok.text, nil
+	case negative:
+		return "", p.errorf("expected number")
+	case tok.kind == tokenString:
+		p.next()
+		return tok.text, nil
+	case p.isKeyword("true"), p.isKeyword("false"):
+		p.next()
+		return strings.ToLower(tok.text), nil
+	}
+	return "", p.errorf("expected literal value")
+}
@@ -0,0 +1,80 @@
+package graph_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/graph"
+)
+
+func TestParsePatternErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string // part of the error message
+	}{
+		{"empty", "", "MATCH"},
+		{"unlabelled first node", "MATCH (u) RETURN u", "needs a label"},
+		{"unterminated string", `MATCH (u:users) WHERE u.name = "Ali RETURN u`, "unterminated string"},
+		{"bad character", "MATCH (u:users) RETURN u;", "unexpected character"},
+		{"unclosed node", "MATCH (u:users RETURN u", `expected ")"`},
+		{"relationship variable", "MATCH (u:users)-[r:manager]->(m) RETURN m", "relationship variables"},
+		{"both directions", "MATCH (u:users)<-[:manager]->(m) RETURN m", "both ways"},
+		{"zero hops", "MATCH (u:users)-[:manager*0..2]->(m) RETURN m", "at least 1"},
+		{"inverted range", "MATCH (u:users)-[:manager*3..1]->(m) RETURN m", "invalid hop range"},
+		{"range past max depth", "MATCH (u:users)-[:manager*1..6]->(m) RETURN m", "exceeds the maximum depth of 5"},
+		{"hops past max depth", "MATCH (u:users)-[:manager*6]->(m) RETURN m", "exceeds the maximum depth of 5"},
+		{"reserved variable", "MATCH (_anon0:users) RETURN _anon0", "may not start with"},
+		{"unknown WHERE variable", `MATCH (u:users) WHERE x.name = "Alice" RETURN u`, `unknown variable "x" in WHERE`},
+		{"missing operator", `MATCH (u:users) WHERE u.name "Alice" RETURN u`, "expected comparison operator"},
+		{"missing value", "MATCH (u:users) WHERE u.name = RETURN u", "expected literal value"},
+		{"negative string", `MATCH (u:users) WHERE u.age > -"1" RETURN u`, "expected number"},
+		{"missing RETURN", "MATCH (u:users)", "RETURN"},
+		{"unknown RETURN variable", "MATCH (u:users) RETURN m", `unknown variable "m" in RETURN`},
+		{"anonymous RETURN variable", "MATCH (u:users)-[:manager]->() RETURN _anon0", "unknown variable"},
+		{"zero limit", "MATCH (u:users) RETURN u LIMIT 0", "limit must be positive"},
+		{"trailing input", "MATCH (u:users) RETURN u u", "unexpected input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := graph.ParsePattern(tt.query, 5)
+			if err == nil {
+				t.Fatalf("Expected %q to be refused", tt.query)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := graph.ParsePattern("MATCH (u:users) RETURN u", 0); err == nil {
+		t.Error("Expected a zero max depth to be refused")
+	}
+}
+
+func TestParsePatternHops(t *testing.T) {
+	tests := []struct {
+		rel      string
+		min, max int
+	}{
+		{"-[:manager]->", 1, 1},
+		{"-[:manager*]->", 1, 5},
+		{"-[:manager*3]->", 3, 3},
+		{"-[:manager*2..]->", 2, 5},
+		{"-[:manager*..4]->", 1, 4},
+		{"-[:manager*1..5]->", 1, 5},
+	}
+
+	for _, tt := range tests {
+		pattern, err := graph.ParsePattern("MATCH (u:users)"+tt.rel+"(m) RETURN m", 5)
+		if err != nil {
+			t.Errorf("%s: %v", tt.rel, err)
+			continue
+		}
+		rel := pattern.Relationships[0]
+		if rel.MinHops != tt.min || rel.MaxHops != tt.max || rel.Direction != "out" {
+			t.Errorf("%s: expected %d..%d out, got %d..%d %s", tt.rel, tt.min, tt.max, rel.MinHops, rel.MaxHops, rel.Direction)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -71,6 +75,157 @@ func IsReference(v interface{}) (*Reference, bool) {
 	return nil, false
 }
 
+// ReferenceField is a reference held somewhere in a document, identified by
+// its JSON path such as "manager", "address.city" or "tags[2]"
+type ReferenceField struct {
+	Path string
+	Reference
+}
+
+// FindReferences walks a document, including nested objects and arrays, and
+// returns every reference it holds sorted by path. References are not
+// searched for further references.
+func FindReferences(data map[string]interface{}) []ReferenceField {
+	var refs []ReferenceField
+	findReferences(data, "", &refs)
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Path < refs[j].Path
+	})
+	return refs
+}
+
+func findReferences(v interface{}, path string, refs *[]ReferenceField) {
+	if ref, isRef := IsReference(v); isRef {
+		if path != "" {
+			*refs = append(*refs, ReferenceField{Path: path, Reference: *ref})
+		}
+		return
+	}
+	
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			findReferences(value, child, refs)
+		}
+	case []interface{}:
+		for i, value := range v {
+			findReferences(value, fmt.Sprintf("%s[%d]", path, i), refs)
+		}
+	}
+}
+
+// PathSegment is one step of a JSON path: an object key or an array index
+type PathSegment struct {
+	Key   string
+	Index int // -1 for object keys
+}
+
+// ParsePath splits a JSON path such as "tags[2].name" into its segments
+func ParsePath(path string) ([]PathSegment, error) {
+	var segments []PathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indices []string
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			key = part[:open]
+			rest := part[open:]
+			for rest != "" {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("invalid path %q", path)
+				}
+				indices = append(indices, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+		
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		segments = append(segments, PathSegment{Key: key, Index: -1})
+		for _, index := range indices {
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid index in path %q", path)
+			}
+			segments = append(segments, PathSegment{Index: n})
+		}
+	}
+	return segments, nil
+}
+
+// LookupPath resolves a JSON path in a document
+func LookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	
+	var current interface{} = data
+	for _, segment := range segments {
+		var ok bool
+		if current, ok = step(current, segment); !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetPath replaces the value at an existing JSON path in a document. It
+// reports whether the path was found.
+func SetPath(data map[string]interface{}, path string, value interface{}) bool {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return false
+	}
+	
+	var current interface{} = data
+	for _, segment := range segments[:len(segments)-1] {
+		var ok bool
+		if current, ok = step(current, segment); !ok {
+			return false
+		}
+	}
+	
+	last := segments[len(segments)-1]
+	switch c := current.(type) {
+	case map[string]interface{}:
+		if _, exists := c[last.Key]; last.Index >= 0 || !exists {
+			return false
+		}
+		c[last.Key] = value
+	case []interface{}:
+		if last.Index < 0 || last.Index >= len(c) {
+			return false
+		}
+		c[last.Index] = value
+	default:
+		return false
+	}
+	return true
+}
+
+// step follows one path segment from a value
+func step(v interface{}, segment PathSegment) (interface{}, bool) {
+	if segment.Index >= 0 {
+		items, ok := v.([]interface{})
+		if !ok || segment.Index >= len(items) {
+			return nil, false
+		}
+		return items[segment.Index], true
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := m[segment.Key]
+	return value, ok
+}
+
 // QueryStats tracks query execution statistics
 type QueryStats struct {
 	StartTime time.Time              `json:"start_time"`
@@ -102,6 +257,13 @@ type SortParam struct {
 	Order string `json:"order"` // asc or desc
 }
 
+// FilterParam represents a filter predicate on a field
+type FilterParam struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"` // eq, ne, gt, gte, lt, lte, contains, starts, ends, in, exists
+	Value    string `json:"value"`
+}
+
 // ErrorResponse represents an API error response
 type ErrorResponse struct {
 	Error struct {
@@ -140,21 +302,32 @@ type PagedResponse struct {
 // GraphNode represents a node in the graph
 type GraphNode struct {
 	ID         string                 `json:"id"`
-	Type       string                 `json:"type"`
+	Type       string                 `json:"type"` // entity type
 	Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
-// GraphEdge represents an edge in the graph
+// GraphEdge represents an edge in the graph. Several edges may join the same
+// pair of nodes; each comes from a different field of the source entity.
 type GraphEdge struct {
-	From         string `json:"from"`
-	To           string `json:"to"`
-	Relationship string `json:"relationship"`
+	From         string                 `json:"from"`
+	To           string                 `json:"to"`
+	Relationship string                 `json:"relationship"`
+	Field        string                 `json:"field,omitempty"` // source field path holding the reference
+	Properties   map[string]interface{} `json:"properties,omitempty"`
+}
+
+// GraphCycle represents a cycle or strongly connected component in the graph
+type GraphCycle struct {
+	Nodes []string    `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
 }
 
-// PathInfo represents a path between two nodes
+// PathInfo represents a path between two nodes. Path alternates GraphNode
+// and GraphEdge values, starting and ending with a node.
 type PathInfo struct {
 	From   string        `json:"from"`
 	To     string        `json:"to"`
 	Length int           `json:"length"`
+	Weight float64       `json:"weight"`
 	Path   []interface{} `json:"path"`
 }
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/graph"
+)
+
+const (
+	// defaultBetweennessSamples is the number of source nodes betweenness
+	// samples unless told otherwise; smaller graphs are computed exactly
+	defaultBetweennessSamples = 256
+	// defaultPageRankIterations bounds PageRank when no iteration count is given
+	defaultPageRankIterations = 100
+)
+
+// handleGraphAnalytics runs a whole-graph algorithm, restricted by the
+// entity_type and relationship query parameters
+func (s *Server) handleGraphAnalytics(w http.ResponseWriter, r *http.Request) {
+	analytics, ok := s.graph.(graph.Analytics)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "Graph analytics are not supported by this graph")
+		return
+	}
+	
+	query := r.URL.Query()
+	filter := graph.AnalyticsFilter{
+		EntityTypes:   splitList(query.Get("entity_type")),
+		Relationships: splitList(query.Get("relationship")),
+	}
+	
+	limit, err := intParam(query, "limit", 0)
+	if err != nil || limit < 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	
+	algorithm := chi.URLParam(r, "algorithm")
+	result := map[string]interface{}{
+		"algorithm": algorithm,
+		"filter":    filter,
+	}
+	
+	switch algorithm {
+	case "degree":
+		degrees := analytics.Degrees(filter)
+		in := make(map[int]int)
+		out := make(map[int]int)
+		for _, d := range degrees {
+			in[d.In]++
+			out[d.Out]++
+		}
+		result["count"] = len(degrees)
+		result["in_distribution"] = in
+		result["out_distribution"] = out
+		result["nodes"] = truncate(degrees, limit)
+		
+	case "wcc", "scc":
+		var components [][]string
+		if algorithm == "wcc" {
+			components = analytics.WeaklyConnectedComponents(filter)
+		} else {
+			components = analytics.StronglyConnectedComponents(filter)
+		}
+		result["count"] = len(components)
+		result["components"] = truncate(components, limit)
+		
+	case "pagerank":
+		damping, err := floatParam(query, "damping", 0.85)
+		if err != nil || damping <= 0 || damping >= 1 {
+			s.writeError(w, http.StatusBadRequest, "damping must be between 0 and 1")
+			return
+		}
+		iterations, err := intParam(query, "iterations", defaultPageRankIterations)
+		if err != nil || iterations <= 0 || iterations > 1000 {
+			s.writeError(w, http.StatusBadRequest, "iterations must be between 1 and 1000")
+			return
+		}
+		scores := analytics.PageRank(filter, damping, iterations)
+		result["damping"] = damping
+		result["iterations"] = iterations
+		result["count"] = len(scores)
+		result["scores"] = truncate(scores, limit)
+		
+	case "betweenness":
+		samples, err := intParam(query, "samples", defaultBetweennessSamples)
+		if err != nil || samples < 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid samples")
+			return
+		}
+		seed, err := intParam(query, "seed", 1)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid seed")
+			return
+		}
+		scores, used := analytics.Betweenness(filter, samples, int64(seed))
+		result["sampled"] = used < len(scores)
+		result["samples"] = used
+		result["count"] = len(scores)
+		result["scores"] = truncate(scores, limit)
+		
+	case "toposort":
+		order, err := analytics.TopologicalSort(filter)
+		if err != nil {
+			s.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		result["count"] = len(order)
+		result["order"] = truncate(order, limit)
+		
+	default:
+		s.writeError(w, http.StatusNotFound,
+			fmt.Sprintf("Unknown algorithm: %s (must be degree, wcc, scc, pagerank, betweenness or toposort)", algorithm))
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// splitList splits a comma-separated parameter, dropping empty entries
+func splitList(param string) []string {
+	var values []string
+	for _, value := range strings.Split(param, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// intParam reads an optional integer query parameter
+func intParam(query url.Values, name string, fallback int) (int, error) {
+	if query.Get(name) == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(query.Get(name))
+}
+
+// floatParam reads an optional numeric query parameter
+func floatParam(query url.Values, name string, fallback float64) (float64, error) {
+	if query.Get(name) == "" {
+		return fallback, nil
+	}
+	return strconv.ParseFloat(query.Get(name), 64)
+}
+
+// truncate returns at most limit items; a limit of 0 returns everything
+func truncate[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
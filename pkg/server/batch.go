@@ -0,0 +1,419 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/ha1tch/olu/pkg/validation"
+)
+
+// Batch operation kinds
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchPatch  = "patch"
+	batchDelete = "delete"
+	batchSave   = "save"
+)
+
+// batchRefPrefix marks a placeholder for the ID produced by an earlier
+// operation, written {"$ref": "op:0"}
+const batchRefPrefix = "op:"
+
+// batchOperation is one step of a batch request. ID is a number or a
+// {"$ref": "op:N"} placeholder, and placeholders may appear anywhere in Data.
+type batchOperation struct {
+	Op     string                 `json:"op"`
+	Entity string                 `json:"entity"`
+	ID     interface{}            `json:"id,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// batchResult is the outcome of a batch operation
+type batchResult struct {
+	Op      string `json:"op"`
+	Entity  string `json:"entity"`
+	ID      int    `json:"id"`
+	Status  int    `json:"status"`
+	Trashed bool   `json:"trashed,omitempty"`
+}
+
+// batchError is the failure of a batch operation
+type batchError struct {
+	index   int
+	status  int
+	message string
+	details []string
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.index, e.message)
+}
+
+// handleBatch runs an ordered list of writes across entity types in a single
+// transaction and reports the result of each. On stores without transactions
+// the operations before a failure stay applied.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Operations []batchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	
+	if len(req.Operations) == 0 {
+		s.writeError(w, http.StatusBadRequest, "Batch has no operations")
+		return
+	}
+	if s.config.MaxBatchOperations > 0 && len(req.Operations) > s.config.MaxBatchOperations {
+		s.writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Batch too large: %d operations (max: %d)", len(req.Operations), s.config.MaxBatchOperations))
+		return
+	}
+	
+	// Reject malformed operations before touching the store
+	for i, op := range req.Operations {
+		if err := checkBatchOperation(op); err != nil {
+			s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":     fmt.Sprintf("Operation %d is invalid: %s", i, err),
+				"operation": i,
+			})
+			return
+		}
+	}
+	
+	results := make([]batchResult, 0, len(req.Operations))
+	err := s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		pending := make(batchEdges)
+		record = pending.tracking(record)
+		for i, op := range req.Operations {
+			result, err := s.applyBatchOperation(r.Context(), tx, record, pending, op, results)
+			if err != nil {
+				err.index = i
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	
+	if err != nil {
+		var opErr *batchError
+		if !errors.As(err, &opErr) {
+			s.logger.Error().Err(err).Msg("Failed to commit batch")
+			s.writeError(w, http.StatusInternalServerError, "Failed to commit batch")
+			return
+		}
+		
+		response := map[string]interface{}{
+			"error":     fmt.Sprintf("Operation %d failed: %s", opErr.index, opErr.message),
+			"operation": opErr.index,
+		}
+		if len(opErr.details) > 0 {
+			response["details"] = opErr.details
+		}
+		s.writeJSON(w, opErr.status, response)
+		return
+	}
+	
+	s.logger.Info().Int("count", len(results)).Msg("Applied batch")
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// checkBatchOperation checks that an operation is well formed
+func checkBatchOperation(op batchOperation) error {
+	switch op.Op {
+	case batchCreate, batchUpdate, batchPatch, batchDelete, batchSave:
+	default:
+		return fmt.Errorf("unknown op %q (must be create, update, patch, delete or save)", op.Op)
+	}
+	
+	if err := validateEntityName(op.Entity); err != nil {
+		return err
+	}
+	if op.Op != batchCreate && op.ID == nil {
+		return fmt.Errorf("%s needs an id", op.Op)
+	}
+	if op.Op != batchDelete && op.Data == nil {
+		return fmt.Errorf("%s needs data", op.Op)
+	}
+	return nil
+}
+
+// applyBatchOperation runs one operation inside the batch transaction and
+// records the changes it makes. Placeholders are resolved against the results
+// of earlier operations. Deletes go to the trash or follow the onDelete rules
+// just as they do outside a batch.
+func (s *Server) applyBatchOperation(ctx context.Context, tx storage.Transaction, record recordFunc, pending batchEdges, op batchOperation, results []batchResult) (batchResult, *batchError) {
+	result := batchResult{Op: op.Op, Entity: op.Entity}
+	
+	var data map[string]interface{}
+	if op.Data != nil {
+		resolved, err := resolveBatchRefs(op.Data, results)
+		if err != nil {
+			return result, &batchError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		data = resolved.(map[string]interface{})
+	}
+	
+	if op.Op != batchCreate {
+		id, err := batchID(op.ID, results)
+		if err != nil {
+			return result, &batchError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		result.ID = id
+	}
+	
+	notFound := &batchError{
+		status:  http.StatusNotFound,
+		message: fmt.Sprintf("Resource of entity %s with id %d not found", op.Entity, result.ID),
+	}
+	failed := func(err error) *batchError {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return notFound
+		case errors.Is(err, errCascadeLimit), errors.Is(err, errReferenceRestricted):
+			return &batchError{status: http.StatusConflict, message: err.Error()}
+		}
+		return &batchError{status: http.StatusInternalServerError, message: err.Error()}
+	}
+	
+	var before map[string]interface{}
+	switch op.Op {
+	case batchCreate:
+		if err := s.checkBatchData(ctx, tx, pending, op.Entity, 0, data); err != nil {
+			return result, err
+		}
+		id, err := tx.Create(ctx, op.Entity, data)
+		if err != nil {
+			return result, failed(err)
+		}
+		result.ID = id
+		result.Status = http.StatusCreated
+		data["id"] = id
+	
+	case batchUpdate:
+		data["id"] = result.ID
+		if err := s.checkBatchData(ctx, tx, pending, op.Entity, result.ID, data); err != nil {
+			return result, err
+		}
+		before, _ = tx.Get(ctx, op.Entity, result.ID)
+		if err := tx.Update(ctx, op.Entity, result.ID, data); err != nil {
+			return result, failed(err)
+		}
+		result.Status = http.StatusOK
+	
+	case batchPatch:
+		existing, err := tx.Get(ctx, op.Entity, result.ID)
+		if err != nil {
+			return result, failed(err)
+		}
+		before = copyEntity(existing)
+		for key, value := range data {
+			if key == "id" {
+				continue
+			}
+			if value == nil && s.config.PatchNullBehavior == "delete" {
+				delete(existing, key)
+			} else {
+				existing[key] = value
+			}
+		}
+		data = existing
+		if err := s.checkBatchData(ctx, tx, pending, op.Entity, result.ID, data); err != nil {
+			return result, err
+		}
+		if err := tx.Update(ctx, op.Entity, result.ID, data); err != nil {
+			return result, failed(err)
+		}
+		result.Status = http.StatusOK
+	
+	case batchSave:
+		if tx.Exists(ctx, op.Entity, result.ID) {
+			return result, &batchError{
+				status:  http.StatusConflict,
+				message: fmt.Sprintf("Resource of entity %s with id %d already exists", op.Entity, result.ID),
+			}
+		}
+		data["id"] = result.ID
+		if err := s.checkBatchData(ctx, tx, pending, op.Entity, result.ID, data); err != nil {
+			return result, err
+		}
+		if err := tx.Save(ctx, op.Entity, result.ID, data); err != nil {
+			return result, failed(err)
+		}
+		result.Status = http.StatusCreated
+	
+	case batchDelete:
+		// Deletes record their own changes, which may reach other entities
+		if s.softDeletes(op.Entity) {
+			if err := s.trashInTx(ctx, tx, record, op.Entity, result.ID, 0); err != nil {
+				return result, failed(err)
+			}
+			result.Trashed = true
+		} else if _, err := s.deleteInTx(ctx, tx, record, op.Entity, result.ID, 0); err != nil {
+			return result, failed(err)
+		}
+		result.Status = http.StatusOK
+		return result, nil
+	}
+	
+	record(op.Op, op.Entity, result.ID, s.txRevision(ctx, tx, op.Entity, result.ID), before, data)
+	return result, nil
+}
+
+// checkBatchData validates data for a write within a batch, resolving REF
+// targets through the transaction so entities created earlier in the batch
+// count, and applies the size limit and cycle detection policy. Cycles are
+// checked against the graph with the edges of the batch's earlier writes laid
+// over it.
+func (s *Server) checkBatchData(ctx context.Context, tx storage.Transaction, pending batchEdges, entity string, id int, data map[string]interface{}) *batchError {
+	var valid bool
+	var details []string
+	if rv, ok := s.validator.(validation.ReferenceValidator); ok {
+		valid, details = rv.ValidateWithResolver(entity, data, func(refEntity string, refID int) bool {
+			return tx.Exists(ctx, refEntity, refID)
+		})
+	} else {
+		valid, details = s.validator.Validate(entity, data)
+	}
+	if !valid {
+		return &batchError{status: http.StatusBadRequest, message: "Validation failed", details: details}
+	}
+	
+	jsonData, _ := json.Marshal(data)
+	if len(jsonData) > s.config.MaxEntitySize {
+		return &batchError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("Entity too large: %d bytes (max: %d)", len(jsonData), s.config.MaxEntitySize),
+		}
+	}
+	
+	// New entities have no incoming edges, so they cannot close a cycle
+	if id == 0 {
+		return nil
+	}
+	gn, _ := tx.(storage.GraphNeighbors)
+	cycle, err := s.findWriteCycle(pending.over(s.cycleEdges(ctx, gn)), entity, id, data)
+	if err != nil {
+		return &batchError{status: http.StatusInternalServerError, message: err.Error()}
+	}
+	if cycle != nil {
+		description := describeCycle(cycle)
+		if s.config.GraphCycleDetection == cyclePolicyError {
+			return &batchError{
+				status:  http.StatusConflict,
+				message: fmt.Sprintf("Write would create a cycle: %s", description),
+			}
+		}
+		s.logger.Warn().Str("node", fmt.Sprintf("%s:%d", entity, id)).Str("cycle", description).
+			Msg("Write creates a graph cycle")
+	}
+	return nil
+}
+
+// batchEdges holds the outgoing edges of the entities written so far in a
+// batch, keyed by node ID, which the in-memory graph only learns of once the
+// batch commits. A deleted entity has none.
+type batchEdges map[string][]models.GraphEdge
+
+// tracking returns record wrapped to also note the edges each change leaves
+// its entity with
+func (e batchEdges) tracking(record recordFunc) recordFunc {
+	return func(operation string, entity string, id int, revision int, before, after map[string]interface{}) {
+		node := graph.NodeID(entity, id)
+		e[node] = graph.ReferenceEdges(node, after)
+		record(operation, entity, id, revision, before, after)
+	}
+}
+
+// over returns outgoing with the batch's edges in place of those it has for
+// the entities the batch wrote, or nil if outgoing is nil
+func (e batchEdges) over(outgoing graph.EdgeFunc) graph.EdgeFunc {
+	if outgoing == nil {
+		return nil
+	}
+	return func(nodeID string) ([]models.GraphEdge, error) {
+		if edges, ok := e[nodeID]; ok {
+			return edges, nil
+		}
+		return outgoing(nodeID)
+	}
+}
+
+// resolveBatchRefs returns a copy of v with every {"$ref": "op:N"} placeholder
+// replaced by the ID produced by operation N
+func resolveBatchRefs(v interface{}, results []batchResult) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok && len(v) == 1 && strings.HasPrefix(ref, batchRefPrefix) {
+			return batchRefID(ref, results)
+		}
+		
+		resolved := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			r, err := resolveBatchRefs(value, results)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, value := range v {
+			r, err := resolveBatchRefs(value, results)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	}
+	return v, nil
+}
+
+// batchRefID returns the ID produced by the operation a placeholder names
+func batchRefID(ref string, results []batchResult) (int, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(ref, batchRefPrefix))
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid reference %q", ref)
+	}
+	if index >= len(results) {
+		return 0, fmt.Errorf("reference %q must point to an earlier operation", ref)
+	}
+	return results[index].ID, nil
+}
+
+// batchID resolves an operation's id, given as a number or a placeholder
+func batchID(v interface{}, results []batchResult) (int, error) {
+	resolved, err := resolveBatchRefs(v, results)
+	if err != nil {
+		return 0, err
+	}
+	
+	switch id := resolved.(type) {
+	case int:
+		return id, nil
+	case float64:
+		if id >= 0 && id == math.Trunc(id) {
+			return int(id), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid id %v", v)
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/ha1tch/olu/pkg/validation"
+)
+
+var (
+	// errCascadeLimit is returned when a cascade exceeds the configured limits
+	errCascadeLimit = errors.New("cascade limit exceeded")
+	// errReferenceRestricted is returned when an onDelete restrict reference blocks a delete
+	errReferenceRestricted = errors.New("entity is still referenced")
+)
+
+// nodeRef identifies a stored entity instance
+type nodeRef struct {
+	entity string
+	id     int
+}
+
+// String returns the graph node ID for the reference
+func (n nodeRef) String() string {
+	return fmt.Sprintf("%s:%d", n.entity, n.id)
+}
+
+// incomingRef is a reference held by source to target in a relationship field
+type incomingRef struct {
+	source       nodeRef
+	target       nodeRef
+	relationship string
+}
+
+// fieldRef identifies a reference field on an entity
+type fieldRef struct {
+	node  nodeRef
+	field string
+}
+
+// String returns the field in "entity:id.field" form
+func (f fieldRef) String() string {
+	return fmt.Sprintf("%s.%s", f.node, f.field)
+}
+
+// deletePlan lists everything a delete touches
+type deletePlan struct {
+	deletes  []nodeRef  // root first, then dependents in discovery order
+	nullify  []fieldRef // set_null references on surviving entities
+	revision int        // revision the root must still be at, or 0 for any
+}
+
+// parseNodeID splits a graph node ID of the form "entity:id"
+func parseNodeID(nodeID string) (nodeRef, error) {
+	idx := strings.LastIndex(nodeID, ":")
+	if idx <= 0 {
+		return nodeRef{}, fmt.Errorf("invalid node ID: %s", nodeID)
+	}
+	
+	id, err := strconv.Atoi(nodeID[idx+1:])
+	if err != nil {
+		return nodeRef{}, fmt.Errorf("invalid node ID: %s", nodeID)
+	}
+	
+	return nodeRef{entity: nodeID[:idx], id: id}, nil
+}
+
+// planDelete works out everything a delete of the root entity touches, reading
+// from src (the store or a transaction) without changing anything. Each
+// incoming reference is handled according to the onDelete action declared on
+// the referencing schema property, falling back to cascade or ignore depending
+// on CascadingDelete.
+func (s *Server) planDelete(ctx context.Context, src storage.Store, entity string, id int) (*deletePlan, error) {
+	root := nodeRef{entity: entity, id: id}
+	plan := &deletePlan{deletes: []nodeRef{root}}
+	deleted := map[string]bool{root.String(): true}
+	nullified := make(map[string]bool)
+	var restricted []incomingRef
+	work := 0
+	
+	for i := 0; i < len(plan.deletes); i++ {
+		referrers, err := s.incomingReferences(ctx, src, plan.deletes[i])
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, ref := range referrers {
+			work++
+			if s.config.MaxCascadeWork > 0 && work > s.config.MaxCascadeWork {
+				return nil, fmt.Errorf("%w: more than %d references inspected",
+					errCascadeLimit, s.config.MaxCascadeWork)
+			}
+			
+			action := s.onDeleteAction(ref)
+			if action == validation.OnDeleteIgnore || deleted[ref.source.String()] {
+				continue
+			}
+			
+			// The in-memory graph may still hold nodes for entities that are gone
+			if !src.Exists(ctx, ref.source.entity, ref.source.id) {
+				continue
+			}
+			
+			switch action {
+			case validation.OnDeleteCascade:
+				deleted[ref.source.String()] = true
+				plan.deletes = append(plan.deletes, ref.source)
+				if s.config.MaxCascadeDeletions > 0 && len(plan.deletes) > s.config.MaxCascadeDeletions {
+					return nil, fmt.Errorf("%w: more than %d entities would be deleted",
+						errCascadeLimit, s.config.MaxCascadeDeletions)
+				}
+			case validation.OnDeleteRestrict:
+				restricted = append(restricted, ref)
+			case validation.OnDeleteSetNull:
+				field := fieldRef{node: ref.source, field: ref.relationship}
+				if !nullified[field.String()] {
+					nullified[field.String()] = true
+					plan.nullify = append(plan.nullify, field)
+				}
+			}
+		}
+	}
+	
+	// Restrictions and nullifications only matter for entities that survive
+	for _, ref := range restricted {
+		if !deleted[ref.source.String()] {
+			return nil, fmt.Errorf("%w: %s references %s through %s",
+				errReferenceRestricted, ref.source, ref.target, ref.relationship)
+		}
+	}
+	
+	surviving := plan.nullify[:0]
+	for _, field := range plan.nullify {
+		if !deleted[field.node.String()] {
+			surviving = append(surviving, field)
+		}
+	}
+	plan.nullify = surviving
+	
+	return plan, nil
+}
+
+// onDeleteAction returns the referential action for an incoming reference
+func (s *Server) onDeleteAction(ref incomingRef) string {
+	if rv, ok := s.validator.(validation.ReferenceValidator); ok {
+		if rule, ok := rv.ReferenceRule(ref.source.entity, ref.relationship); ok && rule.OnDelete != "" {
+			return rule.OnDelete
+		}
+	}
+	
+	if s.config.CascadingDelete {
+		return validation.OnDeleteCascade
+	}
+	return validation.OnDeleteIgnore
+}
+
+// incomingReferences returns the references held by other entities to node.
+// A transaction's own graph queries come first, as they see its uncommitted
+// writes; otherwise the in-memory graph is used when enabled and src's graph
+// queries when not.
+func (s *Server) incomingReferences(ctx context.Context, src storage.Store, node nodeRef) ([]incomingRef, error) {
+	var refs []incomingRef
+	
+	gn, hasNeighbors := src.(storage.GraphNeighbors)
+	_, inTx := src.(storage.Transaction)
+	if s.config.GraphEnabled && s.graph != nil && !(inTx && hasNeighbors) {
+		incoming, err := s.graph.GetIncomingEdges(node.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, edge := range incoming {
+			ref, err := parseNodeID(edge.From)
+			if err != nil {
+				continue
+			}
+			field := edge.Field
+			if field == "" {
+				field = edge.Relationship
+			}
+			refs = append(refs, incomingRef{source: ref, target: node, relationship: field})
+		}
+		return refs, nil
+	}
+	
+	if hasNeighbors {
+		neighbors, err := gn.GetNeighbors(ctx, node.entity, node.id, "in")
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range neighbors {
+			entityType, _ := n["_neighbor_type"].(string)
+			relationship, _ := n["_relationship"].(string)
+			id, ok := n["id"].(float64)
+			if entityType == "" || !ok {
+				continue
+			}
+			refs = append(refs, incomingRef{
+				source:       nodeRef{entity: entityType, id: int(id)},
+				target:       node,
+				relationship: relationship,
+			})
+		}
+		return refs, nil
+	}
+	
+	s.logger.Warn().Str("entity", node.entity).Int("id", node.id).
+		Msg("No graph available, cascade limited to the root entity")
+	return refs, nil
+}
+
+// applyDeletePlan carries out a delete planned by planDelete in one
+// transaction (when the store supports it). The plan is worked out again
+// inside the transaction, so references added since it was made are cascaded,
+// nulled or refuse the delete just the same, and plan is updated to what was
+// actually done.
+func (s *Server) applyDeletePlan(ctx context.Context, plan *deletePlan) error {
+	root := plan.deletes[0]
+	return s.writeChanges(ctx, func(tx storage.Transaction, record recordFunc) error {
+		done, err := s.deleteInTx(ctx, tx, record, root.entity, root.id, plan.revision)
+		if err != nil {
+			return err
+		}
+		*plan = *done
+		return nil
+	})
+}
+
+// deleteInTx plans the delete of an entity against tx and carries it out:
+// set_null references are nulled out and the planned entities deleted,
+// dependents first. When revision is set, the root is only deleted if it is
+// still at that revision.
+func (s *Server) deleteInTx(ctx context.Context, tx storage.Transaction, record recordFunc, entity string, id int, revision int) (*deletePlan, error) {
+	plan, err := s.planDelete(ctx, tx, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	plan.revision = revision
+	
+	for _, field := range plan.nullify {
+		data, err := tx.Get(ctx, field.node.entity, field.node.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", field.node, err)
+		}
+		before := copyEntity(data)
+		if !models.SetPath(data, field.field, nil) {
+			continue // The reference has already gone
+		}
+		if err := tx.Update(ctx, field.node.entity, field.node.id, data); err != nil {
+			return nil, fmt.Errorf("failed to null out %s: %w", field, err)
+		}
+		record(changeUpdate, field.node.entity, field.node.id,
+			s.txRevision(ctx, tx, field.node.entity, field.node.id), before, data)
+	}
+	
+	for i := len(plan.deletes) - 1; i >= 0; i-- {
+		node := plan.deletes[i]
+		before, _ := tx.Get(ctx, node.entity, node.id)
+		
+		if i == 0 && plan.revision != 0 {
+			rv, ok := s.txRevisioner(tx)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s cannot be deleted conditionally by this store",
+					storage.ErrRevisionMismatch, node)
+			}
+			if err := rv.DeleteIf(ctx, node.entity, node.id, plan.revision); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", node, err)
+			}
+		} else if err := tx.Delete(ctx, node.entity, node.id); err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %w", node, err)
+		}
+		record(changeDelete, node.entity, node.id, 0, before, nil)
+	}
+	return plan, nil
+}
+
+func nodeRefStrings(nodes []nodeRef) []string {
+	result := make([]string, len(nodes))
+	for i, node := range nodes {
+		result[i] = node.String()
+	}
+	return result
+}
+
+func fieldRefStrings(fields []fieldRef) []string {
+	result := make([]string, len(fields))
+	for i, field := range fields {
+		result[i] = field.String()
+	}
+	return result
+}
@@ -0,0 +1,735 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/rs/zerolog"
+)
+
+// Change feed operations. The first five match the batch operation kinds.
+const (
+	changeCreate  = "create"
+	changeUpdate  = "update"
+	changePatch   = "patch"
+	changeDelete  = "delete"
+	changeSave    = "save"
+	changeRevert  = "revert"
+	changeTrash   = "trash"
+	changeRestore = "restore"
+)
+
+const (
+	// changesPath is where the change feed is served
+	changesPath = "/api/v1/_changes"
+	// changeBuffer is how many changes a subscriber may fall behind by
+	// before it is dropped and has to resume from its last sequence number
+	changeBuffer = 256
+	// changeReplayPage is how many logged changes are read at a time when a
+	// subscriber resumes
+	changeReplayPage = 500
+	// changeHeartbeat is how often an idle stream is pinged, so proxies
+	// keep it open
+	changeHeartbeat = 15 * time.Second
+	// changeAppendTimeout bounds how long a write waits to log its change
+	changeAppendTimeout = 5 * time.Second
+	// memoryChangeLogSize is how many changes are kept for stores without
+	// a durable change log
+	memoryChangeLogSize = 10000
+)
+
+// errSubscriberDropped ends a stream that fell too far behind
+var errSubscriberDropped = errors.New("subscriber fell behind")
+
+// changeFeed logs changes and fans them out to subscribers in sequence
+// order. Transactions log their changes as they commit, which may not be in
+// sequence order, so a change is held back while a transaction that may have
+// logged an earlier one is still open.
+type changeFeed struct {
+	log storage.ChangeLog
+	// durable is set when the log is the store's own, so transactions can
+	// append to it
+	durable bool
+	
+	mu          sync.Mutex
+	subscribers map[*changeSubscriber]struct{}
+	writers     map[*changeWriter]struct{} // open transactions logging changes
+	held        []*storage.Change          // logged changes waiting on writers, in sequence order
+	published   int64                      // sequence number of the last change handed out
+}
+
+// changeWriter is a transaction logging changes. Until it knows the sequence
+// number of its first change, it may hold any number above published.
+type changeWriter struct {
+	appending bool
+	first     int64
+}
+
+// changeSubscriber is one open stream. A nil entities set takes every
+// entity type.
+type changeSubscriber struct {
+	entities map[string]bool
+	events   chan storage.Change
+}
+
+// newChangeFeed creates the change feed, logging changes in the store when it
+// can keep them and in memory otherwise. If the log cannot be read, streams
+// resumed before the first new change replay nothing.
+func newChangeFeed(store storage.Store, logger zerolog.Logger) *changeFeed {
+	log, durable := store.(storage.ChangeLog)
+	if !durable {
+		log = &memoryChangeLog{}
+	}
+	published, err := log.LastChangeSeq(context.Background())
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to read the change log")
+	}
+	return &changeFeed{
+		log:         log,
+		durable:     durable,
+		subscribers: make(map[*changeSubscriber]struct{}),
+		writers:     make(map[*changeWriter]struct{}),
+		published:   published,
+	}
+}
+
+// wants reports whether a subscriber takes changes to an entity type
+func (sub *changeSubscriber) wants(entity string) bool {
+	return sub.entities == nil || sub.entities[entity]
+}
+
+// begin registers a transaction that will log changes
+func (f *changeFeed) begin() *changeWriter {
+	w := &changeWriter{}
+	
+	f.mu.Lock()
+	f.writers[w] = struct{}{}
+	f.mu.Unlock()
+	return w
+}
+
+// appending marks a writer as about to log its changes, holding back those of
+// other writers until it knows where its own fall
+func (f *changeFeed) appending(w *changeWriter) {
+	f.mu.Lock()
+	w.appending = true
+	f.mu.Unlock()
+}
+
+// appended records the sequence number of a writer's first change
+func (f *changeFeed) appended(w *changeWriter, first int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	w.first = first
+	f.releaseLocked()
+}
+
+// abandon unregisters a writer whose transaction rolled back
+func (f *changeFeed) abandon(w *changeWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	delete(f.writers, w)
+	f.releaseLocked()
+}
+
+// publish logs those of the changes not logged yet and hands them all out,
+// unregistering the writer that committed them, if any. A subscriber whose
+// buffer is full is dropped rather than waited for. A change that fails to
+// log is not handed out, and the first such failure is returned.
+func (f *changeFeed) publish(ctx context.Context, w *changeWriter, changes []*storage.Change) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	if w != nil {
+		delete(f.writers, w)
+	}
+	
+	var firstErr error
+	for _, change := range changes {
+		// Changes logged by the write itself already have a sequence number
+		if change.Seq == 0 {
+			if err := f.log.AppendChange(ctx, change); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+		
+		i := sort.Search(len(f.held), func(i int) bool { return f.held[i].Seq > change.Seq })
+		f.held = append(f.held, nil)
+		copy(f.held[i+1:], f.held[i:])
+		f.held[i] = change
+	}
+	
+	f.releaseLocked()
+	return firstErr
+}
+
+// releaseLocked hands out the held changes no open writer can precede. The
+// caller must hold the lock.
+func (f *changeFeed) releaseLocked() {
+	for len(f.held) > 0 {
+		change := f.held[0]
+		for w := range f.writers {
+			if w.appending && (w.first == 0 || w.first < change.Seq) {
+				return
+			}
+		}
+		
+		f.held = f.held[1:]
+		if change.Seq > f.published {
+			f.published = change.Seq
+		}
+		for sub := range f.subscribers {
+			if !sub.wants(change.Entity) {
+				continue
+			}
+			select {
+			case sub.events <- *change:
+			default:
+				delete(f.subscribers, sub)
+				close(sub.events)
+			}
+		}
+	}
+}
+
+// subscribe opens a subscription to changes to the given entity types, or to
+// all of them if there are none. It also returns the sequence number of the
+// last change handed out: every change up to it is in the log, and every
+// later one will reach the subscriber.
+func (f *changeFeed) subscribe(entities []string) (*changeSubscriber, int64) {
+	sub := &changeSubscriber{events: make(chan storage.Change, changeBuffer)}
+	if len(entities) > 0 {
+		sub.entities = make(map[string]bool, len(entities))
+		for _, entity := range entities {
+			sub.entities[entity] = true
+		}
+	}
+	
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.subscribers[sub] = struct{}{}
+	return sub, f.published
+}
+
+// unsubscribe closes a subscription, unless publish already dropped it
+func (f *changeFeed) unsubscribe(sub *changeSubscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// memoryChangeLog keeps the most recent changes for stores that cannot log
+// them durably. Sequence numbers start again from 1 on restart.
+type memoryChangeLog struct {
+	mu      sync.RWMutex
+	changes []storage.Change
+	seq     int64
+}
+
+// AppendChange records a change, forgetting the oldest once the log is full
+func (l *memoryChangeLog) AppendChange(ctx context.Context, change *storage.Change) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	l.seq++
+	change.Seq = l.seq
+	l.changes = append(l.changes, *change)
+	if len(l.changes) > memoryChangeLogSize {
+		l.changes = l.changes[len(l.changes)-memoryChangeLogSize:]
+	}
+	return nil
+}
+
+// LastChangeSeq returns the sequence number of the last change recorded
+func (l *memoryChangeLog) LastChangeSeq(ctx context.Context) (int64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.seq, nil
+}
+
+// Changes returns up to limit changes recorded after a sequence number
+func (l *memoryChangeLog) Changes(ctx context.Context, after int64, limit int) ([]storage.Change, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	
+	changes := []storage.Change{}
+	for _, change := range l.changes {
+		if change.Seq > after && len(changes) < limit {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// recordFunc records a change a write made. before and after are the
+// entity's data around the write, nil where it did not exist.
+type recordFunc func(operation string, entity string, id int, revision int, before, after map[string]interface{})
+
+// newChange describes a write for the change feed
+func newChange(operation string, entity string, id int, revision int, before, after map[string]interface{}) *storage.Change {
+	node := graph.NodeID(entity, id)
+	added, removed := diffEdges(graph.ReferenceEdges(node, before), graph.ReferenceEdges(node, after))
+	
+	return &storage.Change{
+		Time:          time.Now().UTC(),
+		Entity:        entity,
+		ID:            id,
+		Operation:     operation,
+		Revision:      revision,
+		ChangedFields: changedFields(before, after),
+		EdgesAdded:    added,
+		EdgesRemoved:  removed,
+	}
+}
+
+// writeChanges runs write in a transaction, as storage.WithTransaction does,
+// and then brings the graph and cache up to date with the changes it records
+// and publishes them. When the transaction can log the changes, they are
+// appended to the change log inside it, so a write never commits without its
+// changes. Otherwise they are logged once write returns, which includes the
+// writes a store without transactions made before failing part way.
+func (s *Server) writeChanges(ctx context.Context, write func(tx storage.Transaction, record recordFunc) error) error {
+	var changes []*storage.Change
+	var afters []map[string]interface{}
+	record := func(operation string, entity string, id int, revision int, before, after map[string]interface{}) {
+		changes = append(changes, newChange(operation, entity, id, revision, before, after))
+		afters = append(afters, after)
+	}
+	
+	_, atomic := s.storage.(storage.Transactional)
+	if !atomic {
+		err := storage.WithTransaction(ctx, s.storage, func(tx storage.Transaction) error {
+			return write(tx, record)
+		})
+		s.syncChanges(changes, afters)
+		s.publishChanges(nil, changes)
+		return err
+	}
+	
+	w := s.changes.begin()
+	err := storage.WithTransaction(ctx, s.storage, func(tx storage.Transaction) error {
+		if err := write(tx, record); err != nil {
+			return err
+		}
+		appender, ok := tx.(storage.ChangeAppender)
+		if !ok || !s.changes.durable || len(changes) == 0 {
+			return nil
+		}
+		
+		s.changes.appending(w)
+		for _, change := range changes {
+			if err := appender.AppendChange(ctx, change); err != nil {
+				return err
+			}
+		}
+		s.changes.appended(w, changes[0].Seq)
+		return nil
+	})
+	if err != nil {
+		s.changes.abandon(w)
+		return err
+	}
+	
+	s.syncChanges(changes, afters)
+	s.publishChanges(w, changes)
+	return nil
+}
+
+// syncChanges brings the graph and cache up to date with written changes,
+// given the data each left the entity with, nil where it was deleted
+func (s *Server) syncChanges(changes []*storage.Change, afters []map[string]interface{}) {
+	if len(changes) == 0 {
+		return
+	}
+	
+	entities := make(map[string]bool)
+	for i, change := range changes {
+		entities[change.Entity] = true
+		if !s.config.GraphEnabled {
+			continue
+		}
+		
+		node := graph.NodeID(change.Entity, change.ID)
+		var err error
+		switch {
+		case change.Operation == changeTrash:
+			// Keep its edges aside until it is restored or purged
+			err = s.graph.SuspendNode(node)
+		case change.Operation == changeRestore:
+			// Put back the node and the edges held to it, then its own references
+			if err := s.graph.ResumeNode(node); err != nil {
+				s.logger.Warn().Err(err).Str("node", node).Msg("Restored entity had no suspended graph node")
+			}
+			err = s.graph.UpdateFromEntity(change.Entity, change.ID, afters[i])
+		case afters[i] == nil:
+			err = s.graph.RemoveNode(node)
+		default:
+			err = s.graph.UpdateFromEntity(change.Entity, change.ID, afters[i])
+		}
+		if err != nil {
+			s.logger.Error().Err(err).Str("entity", change.Entity).Int("id", change.ID).Msg("Failed to update graph")
+		}
+	}
+	
+	if s.config.GraphEnabled {
+		if err := s.graph.Save(s.config.GraphDataFile); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to save graph")
+		}
+	}
+	
+	for entity := range entities {
+		s.invalidateCache(entity)
+	}
+}
+
+// publishChanges logs those of the changes their writer w, if any, has not
+// logged, and publishes them to the change feed and the webhooks
+func (s *Server) publishChanges(w *changeWriter, changes []*storage.Change) {
+	ctx, cancel := context.WithTimeout(context.Background(), changeAppendTimeout)
+	defer cancel()
+	
+	if err := s.changes.publish(ctx, w, changes); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to record change")
+	}
+	for _, change := range changes {
+		s.webhooks.dispatch(*change)
+	}
+}
+
+// diffEdges returns the edges only in after, and those only in before
+func diffEdges(before, after []models.GraphEdge) (added, removed []models.GraphEdge) {
+	key := func(edge models.GraphEdge) string {
+		return edge.To + "\x00" + edge.Relationship
+	}
+	
+	old := make(map[string]bool, len(before))
+	for _, edge := range before {
+		old[key(edge)] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, edge := range after {
+		current[key(edge)] = true
+		if !old[key(edge)] {
+			added = append(added, edge)
+		}
+	}
+	for _, edge := range before {
+		if !current[key(edge)] {
+			removed = append(removed, edge)
+		}
+	}
+	return added, removed
+}
+
+// copyEntity returns a deep copy of an entity's data, so it can be kept as
+// the "before" of a write that modifies the original in place
+func copyEntity(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	return copyValue(data).(map[string]interface{})
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[key] = copyValue(value)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = copyValue(value)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// txRevisioner returns the revision support for writes through tx: the
+// transaction's own, or the store's when tx only passes writes through to a
+// store without transactions
+func (s *Server) txRevisioner(tx storage.Transaction) (storage.Revisioner, bool) {
+	if rv, ok := tx.(storage.Revisioner); ok {
+		return rv, true
+	}
+	if _, atomic := s.storage.(storage.Transactional); !atomic {
+		return s.revisioner()
+	}
+	return nil, false
+}
+
+// txRevision returns an entity's revision within a transaction, or 0 if the
+// store does not track them
+func (s *Server) txRevision(ctx context.Context, tx storage.Transaction, entity string, id int) int {
+	rv, ok := s.txRevisioner(tx)
+	if !ok {
+		return 0
+	}
+	_, revision, err := rv.GetRevision(ctx, entity, id)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// requestTimeout applies middleware.Timeout to every request but those for
+// the change feed, which streams for as long as the client stays
+func requestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+	return func(next http.Handler) http.Handler {
+		timed := withTimeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == changesPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handleChanges streams the change feed, as server-sent events or, when the
+// client asks to upgrade, over a WebSocket. entity limits it to a
+// comma-separated list of entity types. since, or the Last-Event-ID header of
+// a reconnecting event stream, replays the changes after that sequence
+// number first; without either only new changes are sent.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	var entities []string
+	for _, value := range r.URL.Query()["entity"] {
+		for _, entity := range strings.Split(value, ",") {
+			if entity = strings.TrimSpace(entity); entity == "" {
+				continue
+			}
+			if err := validateEntityName(entity); err != nil {
+				s.writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			entities = append(entities, entity)
+		}
+	}
+	
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		sinceStr = r.Header.Get("Last-Event-ID")
+	}
+	since := int64(-1)
+	if sinceStr != "" {
+		var err error
+		since, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil || since < 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid since: expected a sequence number")
+			return
+		}
+	}
+	
+	// Subscribe before responding, so a client that goes on to write once
+	// its stream is open sees the write
+	sub, published := s.changes.subscribe(entities)
+	defer s.changes.unsubscribe(sub)
+	
+	replay := changeReplay{since: since, until: published}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamChangesWebSocket(w, r, sub, replay)
+		return
+	}
+	s.streamChangesSSE(w, r, sub, replay)
+}
+
+// streamChangesSSE sends the change feed as server-sent events, each with
+// its sequence number as the event ID
+func (s *Server) streamChangesSSE(w http.ResponseWriter, r *http.Request, sub *changeSubscriber, replay changeReplay) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	
+	send := func(change storage.Change) error {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", change.Seq, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	ping := func() error {
+		if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	
+	if err := s.streamChanges(r.Context(), sub, replay, send, ping); err != nil {
+		s.logger.Debug().Err(err).Msg("Change stream ended")
+	}
+}
+
+// streamChangesWebSocket sends the change feed over a WebSocket, one JSON
+// text message per change. Messages from the client are ignored.
+func (s *Server) streamChangesWebSocket(w http.ResponseWriter, r *http.Request, sub *changeSubscriber, replay changeReplay) {
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded
+		s.logger.Debug().Err(err).Msg("Failed to upgrade change stream")
+		return
+	}
+	defer conn.Close()
+	
+	// Reading is how a closed connection is noticed
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	
+	send := func(change storage.Change) error {
+		return conn.WriteJSON(change)
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(changeHeartbeat))
+	}
+	
+	err = s.streamChanges(ctx, sub, replay, send, ping)
+	if errors.Is(err, errSubscriberDropped) {
+		// Ask the client to come back and resume
+		message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error())
+		_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	}
+	if err != nil {
+		s.logger.Debug().Err(err).Msg("Change stream ended")
+	}
+}
+
+// checkOrigin decides whether a browser page may open the change feed over a
+// WebSocket, which browsers allow across origins: pages served from the same
+// host may, and so may those from an origin in ChangeFeedOrigins. Requests
+// without an Origin header do not come from a browser and are let through.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range s.config.ChangeFeedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// changeReplay is the part of the change log a stream replays: the changes
+// after since, if it is not negative, up to until
+type changeReplay struct {
+	since int64
+	until int64
+}
+
+// streamChanges feeds a subscriber's changes to send until ctx ends or
+// sending fails. The subscription is opened before the log is replayed, and
+// the replay stops at the last change handed out then, so nothing is missed
+// and every later change comes from the subscription in order.
+func (s *Server) streamChanges(ctx context.Context, sub *changeSubscriber, replay changeReplay, send func(storage.Change) error, ping func() error) error {
+	last := replay.since
+	if replay.since >= 0 {
+	replaying:
+		for last < replay.until {
+			changes, err := s.changes.log.Changes(ctx, last, changeReplayPage)
+			if err != nil {
+				return fmt.Errorf("failed to read change log: %w", err)
+			}
+			for _, change := range changes {
+				if change.Seq > replay.until {
+					break replaying
+				}
+				last = change.Seq
+				if !sub.wants(change.Entity) {
+					continue
+				}
+				if err := send(change); err != nil {
+					return err
+				}
+			}
+			if len(changes) < changeReplayPage {
+				break
+			}
+		}
+	}
+	
+	heartbeat := time.NewTicker(changeHeartbeat)
+	defer heartbeat.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-sub.events:
+			if !ok {
+				return errSubscriberDropped
+			}
+			if change.Seq <= last {
+				continue
+			}
+			last = change.Seq
+			if err := send(change); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
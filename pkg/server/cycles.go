@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// Cycle detection policies
+const (
+	cyclePolicyWarn   = "warn"
+	cyclePolicyError  = "error"
+	cyclePolicyIgnore = "ignore"
+)
+
+// cycleHeader carries the cycle a write closed under the "warn" policy
+const cycleHeader = "X-Graph-Cycle"
+
+// checkCycles applies the GraphCycleDetection policy to a write that gives the
+// entity the references in data. Under "error" it responds with 409 and
+// returns false when the write would close a cycle; under "warn" it logs the
+// cycle and adds it as a response header. Only the relationships listed in
+// GraphCycleRelationships are considered, or all of them when none are.
+func (s *Server) checkCycles(ctx context.Context, w http.ResponseWriter, entity string, id int, data map[string]interface{}) bool {
+	gn, _ := s.storage.(storage.GraphNeighbors)
+	cycle, err := s.findWriteCycle(s.cycleEdges(ctx, gn), entity, id, data)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to check for cycles")
+		s.writeError(w, http.StatusInternalServerError, "Failed to check for cycles")
+		return false
+	}
+	if cycle == nil {
+		return true
+	}
+	
+	nodeID := fmt.Sprintf("%s:%d", entity, id)
+	description := describeCycle(cycle)
+	
+	if s.config.GraphCycleDetection == cyclePolicyError {
+		s.writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": fmt.Sprintf("Write would create a cycle: %s", description),
+			"cycle": cycle,
+		})
+		return false
+	}
+	
+	s.logger.Warn().Str("node", nodeID).Str("cycle", description).Msg("Write creates a graph cycle")
+	w.Header().Set(cycleHeader, description)
+	return true
+}
+
+// cycleDetection reports whether the policy asks for writes to be checked
+func (s *Server) cycleDetection() bool {
+	policy := s.config.GraphCycleDetection
+	return policy == cyclePolicyWarn || policy == cyclePolicyError
+}
+
+// CheckCycleDetection checks that there is a graph to check cycles in: the
+// in-memory graph or the store's own edges. Without one it fails under the
+// "error" policy, which could not be enforced, and logs a warning under
+// "warn", whose writes then go unchecked.
+func (s *Server) CheckCycleDetection() error {
+	if !s.cycleDetection() || s.config.GraphEnabled {
+		return nil
+	}
+	if _, ok := s.storage.(storage.GraphNeighbors); ok {
+		return nil
+	}
+	
+	if s.config.GraphCycleDetection == cyclePolicyError {
+		return fmt.Errorf("GRAPH_CYCLE_DETECTION=error needs the in-memory graph or a store with graph support; "+
+			"enable GRAPH_ENABLED or set GRAPH_CYCLE_DETECTION to warn or ignore")
+	}
+	s.logger.Warn().Str("policy", s.config.GraphCycleDetection).
+		Msg("Cycles cannot be checked without the in-memory graph or a store with graph support; writes will not be checked")
+	return nil
+}
+
+// cycleEdges returns the outgoing edges cycle detection follows: the in-memory
+// graph's when it is enabled, and otherwise those gn reports, which may be a
+// transaction so that its uncommitted writes count. It returns nil when there
+// are neither.
+func (s *Server) cycleEdges(ctx context.Context, gn storage.GraphNeighbors) graph.EdgeFunc {
+	if s.config.GraphEnabled && s.graph != nil {
+		return s.graph.GetNeighbors
+	}
+	if gn != nil {
+		return func(nodeID string) ([]models.GraphEdge, error) {
+			return storeEdges(ctx, gn, nodeID, "out")
+		}
+	}
+	return nil
+}
+
+// findWriteCycle returns the cycle a write giving the entity the references in
+// data would close, following the edges outgoing returns, or nil when there is
+// none or cycle detection is off
+func (s *Server) findWriteCycle(outgoing graph.EdgeFunc, entity string, id int, data map[string]interface{}) (*models.GraphCycle, error) {
+	if !s.cycleDetection() || outgoing == nil {
+		return nil, nil
+	}
+	
+	nodeID := fmt.Sprintf("%s:%d", entity, id)
+	return graph.FindCycleVia(nodeID, graph.ReferenceEdges(nodeID, data), s.config.GraphCycleRelationships, outgoing)
+}
+
+// describeCycle renders a cycle as "a -[rel]-> b -[rel]-> a"
+func describeCycle(cycle *models.GraphCycle) string {
+	var sb strings.Builder
+	for _, edge := range cycle.Edges {
+		if sb.Len() == 0 {
+			sb.WriteString(edge.From)
+		}
+		fmt.Fprintf(&sb, " -[%s]-> %s", edge.Relationship, edge.To)
+	}
+	return sb.String()
+}
+
+// handleGraphCycles lists the strongly connected components that contain a
+// cycle, optionally restricted to ?relationship=a,b
+func (s *Server) handleGraphCycles(w http.ResponseWriter, r *http.Request) {
+	cycles := s.graph.Cycles(splitList(r.URL.Query().Get("relationship")))
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":  len(cycles),
+		"cycles": cycles,
+	})
+}
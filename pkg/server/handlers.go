@@ -3,16 +3,18 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ha1tch/olu/pkg/graph"
 	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/ha1tch/olu/pkg/validation"
 )
 
 // handlePatch partially updates an entity
@@ -37,65 +39,76 @@ func (s *Server) handlePatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Get existing entity
-	existing, err := s.storage.Get(r.Context(), entity, id)
+	expected, err := s.ifMatchRevision(r.Context(), r, entity, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			s.writeError(w, http.StatusNotFound, 
-				fmt.Sprintf("Resource of entity %s with id %d not found", entity, id))
-			return
-		}
-		s.logger.Error().Err(err).Msg("Failed to get entity")
-		s.writeError(w, http.StatusInternalServerError, "Failed to get entity")
+		s.writePatchError(w, entity, id, err)
 		return
 	}
 	
-	// Handle null behavior
-	updatedFields := []string{}
-	for key, value := range patchData {
-		if key != "id" {
-			if value == nil && s.config.PatchNullBehavior == "delete" {
-				delete(existing, key)
-			} else {
-				existing[key] = value
+	// Merge and validate, then write only if nothing else has changed
+	// the entity since, so concurrent patches can't lose each other's
+	// fields
+	var updatedFields []string
+	_, revision, err := s.patchEntity(r.Context(), changePatch, entity, id, expected, func(existing map[string]interface{}) error {
+		updatedFields = []string{}
+		
+		// Handle null behavior
+		for key, value := range patchData {
+			if key != "id" {
+				if value == nil && s.config.PatchNullBehavior == "delete" {
+					delete(existing, key)
+				} else {
+					existing[key] = value
+				}
+				updatedFields = append(updatedFields, key)
 			}
-			updatedFields = append(updatedFields, key)
 		}
-	}
-	
-	// Validate merged data
-	if valid, errors := s.validator.Validate(entity, existing); !valid {
-		s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"error":   "Validation failed",
-			"details": errors,
-		})
-		return
-	}
-	
-	// Update
-	if err := s.storage.Update(r.Context(), entity, id, existing); err != nil {
-		s.logger.Error().Err(err).Msg("Failed to patch entity")
-		s.writeError(w, http.StatusInternalServerError, "Failed to patch entity")
-		return
-	}
-	
-	// Update graph
-	if s.config.GraphEnabled {
-		if err := s.graph.UpdateFromEntity(entity, id, existing); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to update graph")
+		
+		// Validate merged data
+		if valid, errors := s.validator.Validate(entity, existing); !valid {
+			s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":   "Validation failed",
+				"details": errors,
+			})
+			return errPatchRejected
 		}
-		_ = s.graph.Save(s.config.GraphDataFile)
+		
+		// Apply the cycle detection policy
+		if !s.checkCycles(r.Context(), w, entity, id, existing) {
+			return errPatchRejected
+		}
+		return nil
+	})
+	if err != nil {
+		s.writePatchError(w, entity, id, err)
+		return
 	}
 	
-	s.invalidateCache(entity)
 	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Patched entity")
 	
+	setETag(w, revision)
 	s.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"message":        fmt.Sprintf("%s with id %d patched successfully", entity, id),
 		"updated_fields": updatedFields,
 	})
 }
 
+// writePatchError responds to a failed patch, unless the patch was rejected
+// with a response already written
+func (s *Server) writePatchError(w http.ResponseWriter, entity string, id int, err error) {
+	switch {
+	case errors.Is(err, errPatchRejected):
+	case errors.Is(err, storage.ErrRevisionMismatch):
+		s.writePreconditionFailed(w, entity, id, err)
+	case errors.Is(err, storage.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, 
+			fmt.Sprintf("Resource of entity %s with id %d not found", entity, id))
+	default:
+		s.logger.Error().Err(err).Msg("Failed to patch entity")
+		s.writeError(w, http.StatusInternalServerError, "Failed to patch entity")
+	}
+}
+
 // handleDelete deletes an entity
 func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	entity := chi.URLParam(r, "entity")
@@ -119,40 +132,67 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Handle cascading delete
-	deletedRefs := []string{fmt.Sprintf("%s:%d", entity, id)}
-	if s.config.CascadingDelete {
-		refs, err := s.cascadeDelete(r.Context(), entity, id)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("Cascade delete failed")
-			s.writeError(w, http.StatusInternalServerError, err.Error())
+	// Refuse early if the entity has changed since the client read it; the
+	// delete checks again in its transaction
+	expected, err := s.checkIfMatch(r.Context(), r, entity, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			s.writePreconditionFailed(w, entity, id, err)
 			return
 		}
-		deletedRefs = refs
-	} else {
-		// Simple delete
-		if err := s.storage.Delete(r.Context(), entity, id); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to delete entity")
-			s.writeError(w, http.StatusInternalServerError, "Failed to delete entity")
+		s.logger.Error().Err(err).Msg("Failed to check revision")
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete entity")
+		return
+	}
+	
+	if s.softDeletes(entity) {
+		s.trashEntity(w, r, entity, id, expected)
+		return
+	}
+	
+	// Work out what has to go with it
+	plan, err := s.planDelete(r.Context(), s.storage, entity, id)
+	if err != nil {
+		if errors.Is(err, errCascadeLimit) || errors.Is(err, errReferenceRestricted) {
+			s.writeError(w, http.StatusConflict, err.Error())
 			return
 		}
-		
-		// Update graph
-		if s.config.GraphEnabled {
-			nodeID := fmt.Sprintf("%s:%d", entity, id)
-			if err := s.graph.RemoveNode(nodeID); err != nil {
-				s.logger.Error().Err(err).Msg("Failed to remove from graph")
-			}
-			_ = s.graph.Save(s.config.GraphDataFile)
+		s.logger.Error().Err(err).Msg("Failed to plan delete")
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	plan.revision = expected
+	
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"message":       fmt.Sprintf("%s with id %d would be deleted", entity, id),
+			"dry_run":       true,
+			"would_delete":  nodeRefStrings(plan.deletes),
+			"would_nullify": fieldRefStrings(plan.nullify),
+		})
+		return
+	}
+	
+	if err := s.applyDeletePlan(r.Context(), plan); err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			s.writePreconditionFailed(w, entity, id, err)
+			return
+		}
+		if errors.Is(err, errCascadeLimit) || errors.Is(err, errReferenceRestricted) {
+			s.writeError(w, http.StatusConflict, err.Error())
+			return
 		}
+		s.logger.Error().Err(err).Msg("Failed to delete entity")
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete entity")
+		return
 	}
 	
-	s.invalidateCache(entity)
-	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Deleted entity")
+	s.logger.Info().Str("entity", entity).Int("id", id).Int("count", len(plan.deletes)).Msg("Deleted entity")
 	
 	s.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"message":          fmt.Sprintf("%s with id %d deleted successfully", entity, id),
-		"cascaded_deletes": deletedRefs,
+		"message":              fmt.Sprintf("%s with id %d deleted successfully", entity, id),
+		"cascaded_deletes":     nodeRefStrings(plan.deletes),
+		"nullified_references": fieldRefStrings(plan.nullify),
 	})
 }
 
@@ -195,24 +235,31 @@ func (s *Server) handleSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Save
-	if err := s.storage.Save(r.Context(), entity, id, data); err != nil {
-		s.logger.Error().Err(err).Msg("Failed to save entity")
-		s.writeError(w, http.StatusInternalServerError, "Failed to save entity")
+	// Apply the cycle detection policy
+	if !s.checkCycles(r.Context(), w, entity, id, data) {
 		return
 	}
 	
-	// Update graph
-	if s.config.GraphEnabled {
-		if err := s.graph.UpdateFromEntity(entity, id, data); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to update graph")
+	// Save. A saved entity carries on from the revisions of any deleted one
+	// it replaces.
+	revision := 0
+	err = s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		if err := tx.Save(r.Context(), entity, id, data); err != nil {
+			return err
 		}
-		_ = s.graph.Save(s.config.GraphDataFile)
+		revision = s.txRevision(r.Context(), tx, entity, id)
+		record(changeSave, entity, id, revision, nil, data)
+		return nil
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to save entity")
+		s.writeError(w, http.StatusInternalServerError, "Failed to save entity")
+		return
 	}
 	
-	s.invalidateCache(entity)
 	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Saved entity")
 	
+	setETag(w, revision)
 	s.writeJSON(w, http.StatusCreated, map[string]interface{}{
 		"message": fmt.Sprintf("Resource of entity %s saved successfully with id %d", entity, id),
 	})
@@ -337,9 +384,23 @@ func (s *Server) handleCreateSchema(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if err := validation.CheckSchema(schema); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid schema: %v", err))
+		return
+	}
+	
+	// Persist first, so a schema in use always survives a restart
+	if schemaStore, ok := s.storage.(storage.SchemaStore); ok {
+		if err := schemaStore.SaveSchema(r.Context(), entity, schema); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to save schema")
+			s.writeError(w, http.StatusInternalServerError, "Failed to save schema")
+			return
+		}
+	}
+	
 	if err := s.validator.LoadSchema(entity, schema); err != nil {
 		s.logger.Error().Err(err).Msg("Failed to load schema")
-		s.writeError(w, http.StatusInternalServerError, "Failed to load schema")
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid schema: %v", err))
 		return
 	}
 	
@@ -373,6 +434,51 @@ func (s *Server) handleGetSchema(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, schema)
 }
 
+// handleDeleteSchema removes a schema, after which the entity is unvalidated
+func (s *Server) handleDeleteSchema(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	found := false
+	if schemaStore, ok := s.storage.(storage.SchemaStore); ok {
+		err := schemaStore.DeleteSchema(r.Context(), entity)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Error().Err(err).Msg("Failed to delete schema")
+			s.writeError(w, http.StatusInternalServerError, "Failed to delete schema")
+			return
+		}
+		found = err == nil
+	}
+	if s.validator.RemoveSchema(entity) {
+		found = true
+	}
+	
+	if !found {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("No schema found for %s", entity))
+		return
+	}
+	
+	s.logger.Info().Str("entity", entity).Msg("Deleted schema")
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Schema for %s deleted successfully", entity),
+	})
+}
+
+// handleListSchemas returns every schema in use, keyed by entity
+func (s *Server) handleListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := s.validator.ListSchemas()
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"schemas": schemas,
+		"count":   len(schemas),
+	})
+}
+
 // Helper functions
 
 func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -400,6 +506,8 @@ func (s *Server) invalidateCache(entity string) {
 	s.cache.DeletePattern(ctx, entity)
 }
 
+// embedReferences replaces the references an entity holds, including those in
+// nested objects and arrays, with the referenced entities up to depth levels
 func (s *Server) embedReferences(ctx context.Context, data map[string]interface{}, depth int) map[string]interface{} {
 	if depth <= 0 {
 		return data
@@ -407,66 +515,38 @@ func (s *Server) embedReferences(ctx context.Context, data map[string]interface{
 	
 	result := make(map[string]interface{})
 	for k, v := range data {
-		if ref, isRef := models.IsReference(v); isRef {
-			// Fetch the referenced entity
-			if refData, err := s.storage.Get(ctx, ref.Entity, ref.ID); err == nil {
-				// Recursively embed
-				result[k] = s.embedReferences(ctx, refData, depth-1)
-			} else {
-				result[k] = v
-			}
-		} else {
-			result[k] = v
-		}
+		result[k] = s.embedValue(ctx, v, depth)
 	}
 	
 	return result
 }
 
-func (s *Server) cascadeDelete(ctx context.Context, entity string, id int) ([]string, error) {
-	// This is a simplified cascade delete
-	// In production, you'd want more sophisticated logic
-	
-	deletedRefs := []string{}
-	toCheck := []struct {
-		entity string
-		id     int
-	}{{entity, id}}
-	
-	checked := make(map[string]bool)
-	
-	for len(toCheck) > 0 && len(deletedRefs) < s.config.MaxCascadeDeletions {
-		current := toCheck[0]
-		toCheck = toCheck[1:]
-		
-		key := fmt.Sprintf("%s:%d", current.entity, current.id)
-		if checked[key] {
-			continue
-		}
-		checked[key] = true
-		deletedRefs = append(deletedRefs, key)
-		
-		// Find referencing entities
-		// This would require scanning all entities - simplified here
-		
-		// Delete the entity
-		if err := s.storage.Delete(ctx, current.entity, current.id); err != nil {
-			s.logger.Error().Err(err).Str("entity", current.entity).Int("id", current.id).
-				Msg("Failed to delete during cascade")
-		}
-		
-		// Remove from graph
-		if s.config.GraphEnabled {
-			nodeID := fmt.Sprintf("%s:%d", current.entity, current.id)
-			s.graph.RemoveNode(nodeID)
+// embedValue expands the references within a single value
+func (s *Server) embedValue(ctx context.Context, v interface{}, depth int) interface{} {
+	if ref, isRef := models.IsReference(v); isRef {
+		// Fetch the referenced entity
+		if refData, err := s.storage.Get(ctx, ref.Entity, ref.ID); err == nil {
+			// Recursively embed
+			return s.embedReferences(ctx, refData, depth-1)
 		}
+		return v
 	}
 	
-	if s.config.GraphEnabled {
-		s.graph.Save(s.config.GraphDataFile)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			result[k] = s.embedValue(ctx, value, depth)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = s.embedValue(ctx, value, depth)
+		}
+		return result
 	}
-	
-	return deletedRefs, nil
+	return v
 }
 
 func validateEntityName(entity string) error {
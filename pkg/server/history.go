@@ -0,0 +1,310 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// versionResponse is one version in an entity's history
+type versionResponse struct {
+	Revision      int                    `json:"revision"`
+	ChangedAt     *time.Time             `json:"changed_at,omitempty"`
+	Deleted       bool                   `json:"deleted"`
+	ChangedFields []string               `json:"changed_fields"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+// changedFields lists the top-level fields that differ between two versions
+// of an entity, ignoring the ID. Either version may be nil.
+func changedFields(before, after map[string]interface{}) []string {
+	fields := []string{}
+	for key, value := range after {
+		if old, ok := before[key]; key != "id" && (!ok || !reflect.DeepEqual(old, value)) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; key != "id" && !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// entityHistory loads an entity's versions, writing the error response
+// itself if it can't
+func (s *Server) entityHistory(w http.ResponseWriter, r *http.Request, entity string, id int) ([]storage.EntityVersion, bool) {
+	h, ok := s.storage.(storage.Historian)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "Storage backend does not keep entity history")
+		return nil, false
+	}
+	
+	versions, err := h.History(r.Context(), entity, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, 
+				fmt.Sprintf("Resource of entity %s with id %d not found", entity, id))
+			return nil, false
+		}
+		s.logger.Error().Err(err).Msg("Failed to get history")
+		s.writeError(w, http.StatusInternalServerError, "Failed to get history")
+		return nil, false
+	}
+	return versions, true
+}
+
+// findVersion picks the version an as_of parameter names: a revision, or
+// the latest version written at or before an RFC 3339 timestamp. Versions
+// written before history was kept have no timestamp and count as the
+// earliest.
+func findVersion(versions []storage.EntityVersion, asOf string) (storage.EntityVersion, bool, error) {
+	if revision, err := strconv.Atoi(asOf); err == nil {
+		for _, version := range versions {
+			if version.Revision == revision {
+				return version, true, nil
+			}
+		}
+		return storage.EntityVersion{}, false, nil
+	}
+	
+	at, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return storage.EntityVersion{}, false, fmt.Errorf("as_of must be a revision or an RFC 3339 timestamp")
+	}
+	
+	var found storage.EntityVersion
+	ok := false
+	for _, version := range versions {
+		if version.ChangedAt.After(at) {
+			break
+		}
+		found, ok = version, true
+	}
+	return found, ok, nil
+}
+
+// handleHistory lists every version of an entity, oldest first
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	idStr := chi.URLParam(r, "id")
+	
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	
+	versions, ok := s.entityHistory(w, r, entity, id)
+	if !ok {
+		return
+	}
+	
+	response := make([]versionResponse, len(versions))
+	var previous map[string]interface{}
+	for i, version := range versions {
+		response[i] = versionResponse{
+			Revision:      version.Revision,
+			Deleted:       version.Deleted,
+			ChangedFields: changedFields(previous, version.Data),
+			Data:          version.Data,
+		}
+		if !version.ChangedAt.IsZero() {
+			changedAt := version.ChangedAt
+			response[i].ChangedAt = &changedAt
+		}
+		previous = version.Data
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entity":   entity,
+		"id":       id,
+		"versions": response,
+	})
+}
+
+// handleGetAsOf responds to a GET with as_of with the version of the entity
+// it names. Past versions are never cached.
+func (s *Server) handleGetAsOf(w http.ResponseWriter, r *http.Request, entity string, id int, asOf string) {
+	versions, ok := s.entityHistory(w, r, entity, id)
+	if !ok {
+		return
+	}
+	
+	version, found, err := findVersion(versions, asOf)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !found || version.Deleted {
+		s.writeError(w, http.StatusNotFound, 
+			fmt.Sprintf("Resource of entity %s with id %d did not exist as of %s", entity, id, asOf))
+		return
+	}
+	
+	s.writeEntity(w, r, version.Data, version.Revision, false)
+}
+
+// handleRevert restores an entity to the data of an earlier version, as a
+// new revision. A deleted entity is brought back.
+func (s *Server) handleRevert(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	idStr := chi.URLParam(r, "id")
+	
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	
+	target, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || target < 1 {
+		s.writeError(w, http.StatusBadRequest, "Invalid revision")
+		return
+	}
+	
+	versions, ok := s.entityHistory(w, r, entity, id)
+	if !ok {
+		return
+	}
+	
+	version, found, _ := findVersion(versions, strconv.Itoa(target))
+	if !found {
+		s.writeError(w, http.StatusNotFound, 
+			fmt.Sprintf("Revision %d of entity %s with id %d not found", target, entity, id))
+		return
+	}
+	if version.Deleted {
+		s.writeError(w, http.StatusBadRequest, 
+			fmt.Sprintf("Revision %d of entity %s with id %d is a delete; delete the entity instead", target, entity, id))
+		return
+	}
+	
+	var data map[string]interface{}
+	var revision int
+	current := versions[len(versions)-1]
+	if current.Deleted {
+		data, revision, ok = s.restoreVersion(w, r, entity, id, version, current.Data)
+	} else {
+		data, revision, ok = s.replaceWithVersion(w, r, entity, id, version)
+	}
+	if !ok {
+		return
+	}
+	
+	s.logger.Info().Str("entity", entity).Int("id", id).Int("revision", target).Msg("Reverted entity")
+	
+	setETag(w, revision)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Resource of entity %s with id %d reverted to revision %d", entity, id, target),
+		"data":    data,
+	})
+}
+
+// replaceWithVersion overwrites an existing entity with an earlier version's
+// data, validated outside the store's locks and subject to If-Match, as PATCH
+// is
+func (s *Server) replaceWithVersion(w http.ResponseWriter, r *http.Request, entity string, id int, version storage.EntityVersion) (map[string]interface{}, int, bool) {
+	expected, err := s.ifMatchRevision(r.Context(), r, entity, id)
+	if err != nil {
+		s.writePatchError(w, entity, id, err)
+		return nil, 0, false
+	}
+	
+	data, revision, err := s.patchEntity(r.Context(), changeRevert, entity, id, expected, func(existing map[string]interface{}) error {
+		for key := range existing {
+			delete(existing, key)
+		}
+		for key, value := range version.Data {
+			existing[key] = value
+		}
+		existing["id"] = id
+		
+		// The schema or the graph may have moved on since the version
+		// was written
+		if valid, errors := s.validator.Validate(entity, existing); !valid {
+			s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":   "Validation failed",
+				"details": errors,
+			})
+			return errPatchRejected
+		}
+		if !s.checkCycles(r.Context(), w, entity, id, existing) {
+			return errPatchRejected
+		}
+		return nil
+	})
+	if err != nil {
+		s.writePatchError(w, entity, id, err)
+		return nil, 0, false
+	}
+	return data, revision, true
+}
+
+// restoreVersion saves a deleted entity again with an earlier version's
+// data, recording before as what it replaces. There is no current revision
+// for If-Match to match.
+func (s *Server) restoreVersion(w http.ResponseWriter, r *http.Request, entity string, id int, version storage.EntityVersion, before map[string]interface{}) (map[string]interface{}, int, bool) {
+	if r.Header.Get("If-Match") != "" {
+		s.writePreconditionFailed(w, entity, id, 
+			fmt.Errorf("%w: %s with id %d is deleted", storage.ErrRevisionMismatch, entity, id))
+		return nil, 0, false
+	}
+	
+	data := make(map[string]interface{}, len(version.Data))
+	for key, value := range version.Data {
+		data[key] = value
+	}
+	data["id"] = id
+	
+	if valid, errors := s.validator.Validate(entity, data); !valid {
+		s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+		return nil, 0, false
+	}
+	if !s.checkCycles(r.Context(), w, entity, id, data) {
+		return nil, 0, false
+	}
+	
+	revision := 0
+	err := s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		if err := tx.Save(r.Context(), entity, id, data); err != nil {
+			return err
+		}
+		revision = s.txRevision(r.Context(), tx, entity, id)
+		record(changeRevert, entity, id, revision, before, data)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			s.writeError(w, http.StatusConflict, 
+				fmt.Sprintf("Resource of entity %s with id %d already exists", entity, id))
+			return nil, 0, false
+		}
+		s.logger.Error().Err(err).Msg("Failed to restore entity")
+		s.writeError(w, http.StatusInternalServerError, "Failed to restore entity")
+		return nil, 0, false
+	}
+	return data, revision, true
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+const (
+	// defaultPathLimit caps all-paths results when no limit is given
+	defaultPathLimit = 100
+	// maxPathLimit is the most paths a single request may ask for
+	maxPathLimit = 1000
+)
+
+// pathRequest is the body accepted by the path endpoints
+type pathRequest struct {
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	MaxDepth       int      `json:"max_depth"`       // all paths only
+	Limit          int      `json:"limit"`           // all paths only
+	K              int      `json:"k"`               // k-shortest only
+	WeightProperty string   `json:"weight_property"` // numeric property on the source entity of each edge
+	DefaultWeight  *float64 `json:"default_weight"`  // used when the property is missing; defaults to 1
+}
+
+// decodePathRequest reads and checks a path request, writing an error response on failure
+func (s *Server) decodePathRequest(w http.ResponseWriter, r *http.Request) (*pathRequest, bool) {
+	if !s.config.GraphEnabled {
+		s.writeError(w, http.StatusNotImplemented, "Graph operations are disabled")
+		return nil, false
+	}
+	
+	var req pathRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return nil, false
+	}
+	if req.From == "" || req.To == "" {
+		s.writeError(w, http.StatusBadRequest, "from and to are required")
+		return nil, false
+	}
+	if req.DefaultWeight != nil && *req.DefaultWeight < 0 {
+		s.writeError(w, http.StatusBadRequest, "default_weight must not be negative")
+		return nil, false
+	}
+	
+	return &req, true
+}
+
+// handleGraphAllPaths returns every simple path between two nodes up to max_depth edges
+func (s *Server) handleGraphAllPaths(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePathRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	maxDepth, err := s.queryDepth(req.MaxDepth)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	if req.Limit <= 0 {
+		req.Limit = defaultPathLimit
+	}
+	if req.Limit > maxPathLimit {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("limit may not exceed %d", maxPathLimit))
+		return
+	}
+	
+	paths, err := s.graph.AllPaths(r.Context(), req.From, req.To, maxDepth, req.Limit)
+	if err != nil {
+		s.writePathError(w, err)
+		return
+	}
+	
+	s.writePaths(w, req, paths)
+}
+
+// handleGraphShortestPaths returns the k shortest loopless paths between two
+// nodes, by hop count or by the weight_property of each edge's source entity
+func (s *Server) handleGraphShortestPaths(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePathRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	if req.K <= 0 {
+		req.K = 1
+	}
+	if req.K > maxPathLimit {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("k may not exceed %d", maxPathLimit))
+		return
+	}
+	
+	paths, err := s.graph.ShortestPaths(r.Context(), req.From, req.To, req.K, s.edgeWeights(r.Context(), req))
+	if err != nil {
+		s.writePathError(w, err)
+		return
+	}
+	
+	s.writePaths(w, req, paths)
+}
+
+// handleGraphWeightedPath returns the Dijkstra shortest path between two nodes,
+// weighing each edge by the weight_property of its source entity
+func (s *Server) handleGraphWeightedPath(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePathRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	if req.WeightProperty == "" {
+		s.writeError(w, http.StatusBadRequest, "weight_property is required")
+		return
+	}
+	
+	paths, err := s.graph.ShortestPaths(r.Context(), req.From, req.To, 1, s.edgeWeights(r.Context(), req))
+	if err != nil {
+		s.writePathError(w, err)
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, paths[0].Info())
+}
+
+// edgeWeights builds the weight function for a path request. Without a weight
+// property every edge weighs 1. Otherwise an edge weighs the numeric value of
+// the property on its source entity, or the default weight when the property
+// is missing or not a number.
+func (s *Server) edgeWeights(ctx context.Context, req *pathRequest) graph.WeightFunc {
+	if req.WeightProperty == "" {
+		return graph.UnitWeight
+	}
+	
+	defaultWeight := 1.0
+	if req.DefaultWeight != nil {
+		defaultWeight = *req.DefaultWeight
+	}
+	
+	var mu sync.Mutex
+	weights := make(map[string]float64)
+	
+	return func(from, to, relationship string) (float64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		
+		if w, ok := weights[from]; ok {
+			return w, nil
+		}
+		
+		w := defaultWeight
+		if node, err := parseNodeID(from); err == nil {
+			if data, err := s.storage.Get(ctx, node.entity, node.id); err == nil {
+				if value, ok := storage.LookupPath(data, req.WeightProperty); ok {
+					if number, ok := value.(float64); ok {
+						w = number
+					}
+				}
+			}
+		}
+		
+		weights[from] = w
+		return w, nil
+	}
+}
+
+// writePaths responds with a list of paths in wire format
+func (s *Server) writePaths(w http.ResponseWriter, req *pathRequest, paths []graph.Path) {
+	infos := make([]models.PathInfo, len(paths))
+	for i, path := range paths {
+		infos[i] = path.Info()
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":  req.From,
+		"to":    req.To,
+		"count": len(infos),
+		"paths": infos,
+	})
+}
+
+// writePathError maps a path search error onto an HTTP status
+func (s *Server) writePathError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "no path"):
+		s.writeError(w, http.StatusNotFound, msg)
+	case strings.Contains(msg, "negative weight"), strings.Contains(msg, "must be"):
+		s.writeError(w, http.StatusBadRequest, msg)
+	default:
+		s.logger.Error().Err(err).Msg("Path search failed")
+		s.writeError(w, http.StatusInternalServerError, "Path search failed")
+	}
+}
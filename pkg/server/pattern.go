@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// patternSource matches graph patterns using the store for nodes and
+// properties and either the in-memory graph or the store for edges
+type patternSource struct {
+	store storage.Store
+	edges func(ctx context.Context, nodeID string, direction string) ([]models.GraphEdge, error)
+}
+
+// patternSource returns a pattern source backed by the in-memory graph when it
+// is enabled and by the store's graph queries otherwise
+func (s *Server) patternSource() (*patternSource, error) {
+	src := &patternSource{store: s.storage}
+	
+	if s.config.GraphEnabled && s.graph != nil {
+		src.edges = func(ctx context.Context, nodeID string, direction string) ([]models.GraphEdge, error) {
+			if direction == "in" {
+				return s.graph.GetIncomingEdges(nodeID)
+			}
+			return s.graph.GetNeighbors(nodeID)
+		}
+		return src, nil
+	}
+	
+	if gn, ok := s.storage.(storage.GraphNeighbors); ok {
+		src.edges = func(ctx context.Context, nodeID string, direction string) ([]models.GraphEdge, error) {
+			return storeEdges(ctx, gn, nodeID, direction)
+		}
+		return src, nil
+	}
+	
+	return nil, errors.New("pattern queries need the in-memory graph or a store with graph support")
+}
+
+// storeEdges returns a node's edges in one direction as the store reports
+// them
+func storeEdges(ctx context.Context, gn storage.GraphNeighbors, nodeID string, direction string) ([]models.GraphEdge, error) {
+	node, err := parseNodeID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	neighbors, err := gn.GetNeighbors(ctx, node.entity, node.id, direction)
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]models.GraphEdge, 0, len(neighbors))
+	for _, n := range neighbors {
+		entityType, _ := n["_neighbor_type"].(string)
+		relationship, _ := n["_relationship"].(string)
+		id, ok := n["id"].(float64)
+		if entityType == "" || !ok {
+			continue
+		}
+		edge := models.GraphEdge{From: nodeID, To: fmt.Sprintf("%s:%d", entityType, int(id)), Relationship: relationship, Field: relationship}
+		if direction == "in" {
+			edge.From, edge.To = edge.To, edge.From
+		}
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+// Nodes returns the node IDs of every stored entity of the given type
+func (p *patternSource) Nodes(ctx context.Context, label string) ([]string, error) {
+	items, err := p.store.List(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+	
+	nodes := make([]string, 0, len(items))
+	for _, item := range items {
+		switch id := item["id"].(type) {
+		case float64:
+			nodes = append(nodes, fmt.Sprintf("%s:%d", label, int(id)))
+		case int:
+			nodes = append(nodes, fmt.Sprintf("%s:%d", label, id))
+		}
+	}
+	return nodes, nil
+}
+
+// Edges returns the edges leaving or entering a node
+func (p *patternSource) Edges(ctx context.Context, nodeID string, direction string) ([]models.GraphEdge, error) {
+	return p.edges(ctx, nodeID, direction)
+}
+
+// Matches loads a node's entity and checks it against the filters. Nodes whose
+// entity no longer exists never match.
+func (p *patternSource) Matches(ctx context.Context, nodeID string, filters []models.FilterParam) (bool, error) {
+	node, err := parseNodeID(nodeID)
+	if err != nil {
+		return false, nil
+	}
+	
+	data, err := p.store.Get(ctx, node.entity, node.id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return storage.MatchesFilters(data, filters), nil
+}
+
+// runPattern matches a pattern and resolves each returned node to its entity.
+// Rows referring to entities that no longer exist are dropped.
+func (s *Server) runPattern(ctx context.Context, pattern *graph.Pattern, src *patternSource) (interface{}, error) {
+	matches, err := pattern.Match(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	
+	entities := make(map[string]map[string]interface{})
+	rows := make([]map[string]interface{}, 0, len(matches))
+	
+	for _, match := range matches {
+		row := make(map[string]interface{}, len(match))
+		complete := true
+		for variable, nodeID := range match {
+			data, loaded := entities[nodeID]
+			if !loaded {
+				if node, err := parseNodeID(nodeID); err == nil {
+					data, err = s.storage.Get(ctx, node.entity, node.id)
+					if err != nil && !errors.Is(err, storage.ErrNotFound) {
+						return nil, err
+					}
+				}
+				entities[nodeID] = data
+			}
+			if data == nil {
+				complete = false
+				break
+			}
+			row[variable] = data
+		}
+		if complete {
+			rows = append(rows, row)
+		}
+	}
+	
+	return map[string]interface{}{
+		"columns": pattern.Return,
+		"rows":    rows,
+		"count":   len(rows),
+	}, nil
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/config"
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// Query job states
+const (
+	queryPending   = "pending"
+	queryRunning   = "running"
+	queryCompleted = "completed"
+	queryFailed    = "failed"
+)
+
+// queryPurgeInterval is how often expired queries are dropped
+const queryPurgeInterval = time.Minute
+
+// errTooManyQueries is returned when GraphMaxRunningQueries queries are
+// already running
+var errTooManyQueries = errors.New("too many graph queries running")
+
+// queryFunc runs a graph query and returns its result
+type queryFunc func(ctx context.Context) (interface{}, error)
+
+// queryJob tracks a submitted graph query
+type queryJob struct {
+	query     models.Query
+	cancel    context.CancelFunc
+	expiresAt time.Time // zero means never
+}
+
+// queryManager runs graph queries in the background, at most
+// GraphMaxRunningQueries at a time and each for at most GraphQueryTimeout,
+// and expires them after GraphQueryTTL (while pending or running) or
+// GraphResultTTL (once finished)
+type queryManager struct {
+	config  *config.Config
+	jobs    map[string]*queryJob
+	running int // jobs whose query has not returned yet
+	mu      sync.Mutex
+}
+
+// newQueryManager creates an empty query manager
+func newQueryManager(cfg *config.Config) *queryManager {
+	return &queryManager{
+		config: cfg,
+		jobs:   make(map[string]*queryJob),
+	}
+}
+
+// submit registers a query and starts running it in the background
+func (m *queryManager) submit(queryString string, parsed map[string]interface{}, run queryFunc) (models.Query, error) {
+	id, err := newQueryID()
+	if err != nil {
+		return models.Query{}, err
+	}
+	
+	now := time.Now()
+	
+	job := &queryJob{
+		query: models.Query{
+			ID:          id,
+			QueryString: queryString,
+			Status:      queryPending,
+			ParsedQuery: parsed,
+			Stats:       models.QueryStats{StartTime: now},
+		},
+		expiresAt: expiry(now, m.config.GraphQueryTTL),
+	}
+	
+	m.mu.Lock()
+	if m.config.GraphMaxRunningQueries > 0 && m.running >= m.config.GraphMaxRunningQueries {
+		m.mu.Unlock()
+		return models.Query{}, fmt.Errorf("%w (max: %d)", errTooManyQueries, m.config.GraphMaxRunningQueries)
+	}
+	m.running++
+	
+	var ctx context.Context
+	if m.config.GraphQueryTimeout > 0 {
+		ctx, job.cancel = context.WithTimeout(context.Background(), time.Duration(m.config.GraphQueryTimeout)*time.Second)
+	} else {
+		ctx, job.cancel = context.WithCancel(context.Background())
+	}
+	m.jobs[id] = job
+	snapshot := job.query
+	m.mu.Unlock()
+	
+	go m.run(ctx, job, run)
+	
+	return snapshot, nil
+}
+
+// run executes a job and records its outcome
+func (m *queryManager) run(ctx context.Context, job *queryJob, run queryFunc) {
+	m.mu.Lock()
+	job.query.Status = queryRunning
+	m.mu.Unlock()
+	
+	result, err := run(ctx)
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.running--
+	
+	// Cancelled jobs have already been removed
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	
+	end := time.Now()
+	job.query.Stats.EndTime = end
+	job.query.Stats.Duration = end.Sub(job.query.Stats.StartTime).Seconds()
+	job.expiresAt = expiry(end, m.config.GraphResultTTL)
+	job.cancel()
+	
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("query timed out after %d seconds", m.config.GraphQueryTimeout)
+	}
+	if err != nil {
+		job.query.Status = queryFailed
+		job.query.Error = err.Error()
+		return
+	}
+	
+	job.query.Status = queryCompleted
+	job.query.Result = result
+}
+
+// get returns a snapshot of a query. A query past its expiry is gone even if
+// the purge has not dropped it yet.
+func (m *queryManager) get(id string) (models.Query, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	job, exists := m.jobs[id]
+	if !exists || job.expired(time.Now()) {
+		return models.Query{}, false
+	}
+	return job.query, true
+}
+
+// cancel stops a query if it is still running and forgets it
+func (m *queryManager) cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	job, exists := m.jobs[id]
+	if !exists {
+		return false
+	}
+	
+	job.cancel()
+	delete(m.jobs, id)
+	return true
+}
+
+// purge drops expired queries, cancelling any that are still running
+func (m *queryManager) purge(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	for id, job := range m.jobs {
+		if job.expired(now) {
+			job.cancel()
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// expired reports whether a job is past its expiry
+func (job *queryJob) expired(now time.Time) bool {
+	return !job.expiresAt.IsZero() && now.After(job.expiresAt)
+}
+
+// StartQueryPurge drops expired graph queries in the background until ctx is
+// done
+func (s *Server) StartQueryPurge(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(queryPurgeInterval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.queries.purge(now)
+			}
+		}
+	}()
+}
+
+// expiry returns the expiry time for a TTL in seconds; non-positive TTLs never expire
+func expiry(from time.Time, ttlSeconds int) time.Time {
+	if ttlSeconds <= 0 {
+		return time.Time{}
+	}
+	return from.Add(time.Duration(ttlSeconds) * time.Second)
+}
+
+func newQueryID() (string, error) {
+	return newRandomID("query ID")
+}
+
+// newRandomID returns 128 random bits in hex, naming what it is for in the
+// error should the system run out of randomness
+func newRandomID(kind string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate %s: %w", kind, err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// queryDepth applies the MaxQueryDepth default and limit to a requested depth
+func (s *Server) queryDepth(requested int) (int, error) {
+	if requested <= 0 {
+		requested = s.config.MaxQueryDepth
+	}
+	if requested <= 0 {
+		return 0, errors.New("max_depth is required")
+	}
+	if s.config.MaxQueryDepth > 0 && requested > s.config.MaxQueryDepth {
+		return 0, fmt.Errorf("max_depth %d exceeds the limit of %d", requested, s.config.MaxQueryDepth)
+	}
+	return requested, nil
+}
+
+// graphQueryRequest describes a graph query job
+type graphQueryRequest struct {
+	Type      string `json:"type"`      // "path", "reachable" or "pattern"
+	From      string `json:"from"`      // path and reachable only
+	To        string `json:"to"`        // path only
+	MaxDepth  int    `json:"max_depth"`
+	Direction string `json:"direction"` // reachable only: "out", "in" or "both"
+	Query     string `json:"query"`     // pattern only
+}
+
+// handleSubmitGraphQuery submits a graph query to run in the background
+func (s *Server) handleSubmitGraphQuery(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	
+	var req graphQueryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	
+	if req.MaxDepth, err = s.queryDepth(req.MaxDepth); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (req.Type == "path" || req.Type == "reachable") && !s.config.GraphEnabled {
+		s.writeError(w, http.StatusNotImplemented, "Graph operations are disabled")
+		return
+	}
+	if req.Type != "pattern" && req.From == "" {
+		s.writeError(w, http.StatusBadRequest, "from is required")
+		return
+	}
+	
+	var run queryFunc
+	switch req.Type {
+	case "path":
+		if req.To == "" {
+			s.writeError(w, http.StatusBadRequest, "to is required for path queries")
+			return
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			path, err := s.graph.FindPathContext(ctx, req.From, req.To, req.MaxDepth)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"from":   req.From,
+				"to":     req.To,
+				"path":   path,
+				"length": len(path) - 1,
+			}, nil
+		}
+	case "reachable":
+		if req.Direction == "" {
+			req.Direction = "out"
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			nodes, err := s.graph.Reachable(ctx, req.From, req.MaxDepth, req.Direction)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"from":  req.From,
+				"nodes": nodes,
+				"count": len(nodes),
+			}, nil
+		}
+	case "pattern":
+		pattern, err := graph.ParsePattern(req.Query, req.MaxDepth)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid pattern: %v", err))
+			return
+		}
+		src, err := s.patternSource()
+		if err != nil {
+			s.writeError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			return s.runPattern(ctx, pattern, src)
+		}
+	default:
+		s.writeError(w, http.StatusBadRequest,
+			fmt.Sprintf("Unknown query type: %q (must be path, reachable or pattern)", req.Type))
+		return
+	}
+	
+	parsed := map[string]interface{}{
+		"type":      req.Type,
+		"max_depth": req.MaxDepth,
+	}
+	if req.From != "" {
+		parsed["from"] = req.From
+	}
+	if req.To != "" {
+		parsed["to"] = req.To
+	}
+	if req.Direction != "" {
+		parsed["direction"] = req.Direction
+	}
+	if req.Query != "" {
+		parsed["pattern"] = req.Query
+	}
+	
+	query, err := s.queries.submit(string(body), parsed, run)
+	if errors.Is(err, errTooManyQueries) {
+		s.writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to submit graph query")
+		s.writeError(w, http.StatusInternalServerError, "Failed to submit graph query")
+		return
+	}
+	
+	s.logger.Info().Str("query_id", query.ID).Str("type", req.Type).Msg("Submitted graph query")
+	
+	s.writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"id":     query.ID,
+		"status": query.Status,
+		"links": map[string]string{
+			"self": "/api/v1/graph/query/" + query.ID,
+		},
+	})
+}
+
+// handleGetGraphQuery returns the status and, once finished, the result of a query
+func (s *Server) handleGetGraphQuery(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	
+	query, exists := s.queries.get(id)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Query %s not found", id))
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, query)
+}
+
+// handleCancelGraphQuery cancels a query and discards it
+func (s *Server) handleCancelGraphQuery(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	
+	if !s.queries.cancel(id) {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Query %s not found", id))
+		return
+	}
+	
+	s.logger.Info().Str("query_id", id).Msg("Cancelled graph query")
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Query %s cancelled", id),
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// parseListOptions reads filter, sort and fields parameters from a query string.
+//
+//	filter[age][gte]=30   -> age >= 30
+//	filter[name]=Alice    -> name = "Alice"
+//	sort=-created_at,name -> created_at DESC, name ASC
+//	fields=name,email     -> only return name and email (plus id)
+func parseListOptions(query url.Values) (storage.ListOptions, error) {
+	var opts storage.ListOptions
+	
+	// Iterate keys in a stable order so the resulting SQL is deterministic
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		
+		field, operator, err := parseFilterKey(key)
+		if err != nil {
+			return opts, err
+		}
+		
+		for _, value := range query[key] {
+			opts.Filters = append(opts.Filters, models.FilterParam{
+				Field:    field,
+				Operator: operator,
+				Value:    value,
+			})
+		}
+	}
+	
+	if sortParam := query.Get("sort"); sortParam != "" {
+		for _, field := range strings.Split(sortParam, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			order := "asc"
+			if strings.HasPrefix(field, "-") {
+				order = "desc"
+				field = field[1:]
+			} else if strings.HasPrefix(field, "+") {
+				field = field[1:]
+			}
+			opts.Sort = append(opts.Sort, models.SortParam{Field: field, Order: order})
+		}
+	}
+	
+	if fieldsParam := query.Get("fields"); fieldsParam != "" {
+		for _, field := range strings.Split(fieldsParam, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				opts.Fields = append(opts.Fields, field)
+			}
+		}
+	}
+	
+	return opts, opts.Validate()
+}
+
+// parseFilterKey splits "filter[field]" or "filter[field][op]" into its parts
+func parseFilterKey(key string) (string, string, error) {
+	rest := strings.TrimPrefix(key, "filter[")
+	end := strings.Index(rest, "]")
+	if end <= 0 {
+		return "", "", fmt.Errorf("invalid filter parameter: %s", key)
+	}
+	
+	field := rest[:end]
+	rest = rest[end+1:]
+	if rest == "" {
+		return field, storage.OpEq, nil
+	}
+	
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") || len(rest) < 3 {
+		return "", "", fmt.Errorf("invalid filter parameter: %s", key)
+	}
+	return field, rest[1 : len(rest)-1], nil
+}
+
+// listOptionsKey builds a canonical cache key fragment from the list parameters
+func listOptionsKey(query url.Values) string {
+	relevant := url.Values{}
+	for key, values := range query {
+		if strings.HasPrefix(key, "filter[") || key == "sort" || key == "fields" {
+			relevant[key] = values
+		}
+	}
+	return relevant.Encode()
+}
+
+// pageParams reads the page and per_page parameters, falling back to the
+// configured page size
+func (s *Server) pageParams(query url.Values) (int, int) {
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = s.config.DefaultPageSize
+		if perPage < 1 {
+			perPage = 10 // Fallback default
+		}
+	}
+	return page, perPage
+}
+
+// paginate slices entities into the requested page
+func paginate(entities []map[string]interface{}, page, perPage int) models.PagedResponse {
+	totalItems := len(entities)
+	totalPages := (totalItems + perPage - 1) / perPage
+	
+	start := (page - 1) * perPage
+	end := start + perPage
+	if end > totalItems {
+		end = totalItems
+	}
+	
+	var pageData []map[string]interface{}
+	if start < totalItems {
+		pageData = entities[start:end]
+	} else {
+		pageData = []map[string]interface{}{}
+	}
+	
+	response := models.PagedResponse{
+		Data: pageData,
+	}
+	response.Pagination.Page = page
+	response.Pagination.PerPage = perPage
+	response.Pagination.TotalItems = totalItems
+	response.Pagination.TotalPages = totalPages
+	return response
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// errPatchRejected aborts a patch from inside its modify function once the
+// rejection has been written to the response
+var errPatchRejected = errors.New("patch rejected")
+
+// patchAttempts bounds how often a patch is retried when other writes keep
+// changing the entity between its read and its write
+const patchAttempts = 100
+
+// revisioner returns the store's revision support, if it has any
+func (s *Server) revisioner() (storage.Revisioner, bool) {
+	rv, ok := s.storage.(storage.Revisioner)
+	return rv, ok
+}
+
+// formatETag renders a revision as a strong entity tag
+func formatETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// setETag adds the ETag header for a revision, if the store tracks them
+func setETag(w http.ResponseWriter, revision int) {
+	if revision > 0 {
+		w.Header().Set("ETag", formatETag(revision))
+	}
+}
+
+// splitETags splits an If-Match or If-None-Match header into its entity tags
+func splitETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseETag returns the revision in one of our entity tags. Weak tags only
+// match when weak is set, as If-None-Match compares weakly and If-Match
+// strongly.
+func parseETag(tag string, weak bool) (int, bool) {
+	if strings.HasPrefix(tag, "W/") {
+		if !weak {
+			return 0, false
+		}
+		tag = tag[2:]
+	}
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	revision, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || revision < 1 {
+		return 0, false
+	}
+	return revision, true
+}
+
+// notModified reports whether an If-None-Match header matches the revision
+func notModified(r *http.Request, revision int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || revision == 0 {
+		return false
+	}
+	
+	for _, tag := range splitETags(header) {
+		if tag == "*" {
+			return true
+		}
+		if rev, ok := parseETag(tag, true); ok && rev == revision {
+			return true
+		}
+	}
+	return false
+}
+
+// getEntity retrieves an entity with its revision, which is 0 when the store
+// does not track them
+func (s *Server) getEntity(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	if rv, ok := s.revisioner(); ok {
+		return rv.GetRevision(ctx, entity, id)
+	}
+	data, err := s.storage.Get(ctx, entity, id)
+	return data, 0, err
+}
+
+// cachedEntity unpacks an entity cached by handleGet. The revision comes back
+// as a float64 from caches that store JSON.
+func cachedEntity(cached interface{}) (map[string]interface{}, int, bool) {
+	entry, ok := cached.(map[string]interface{})
+	if !ok {
+		return nil, 0, false
+	}
+	data, ok := entry["data"].(map[string]interface{})
+	if !ok {
+		return nil, 0, false
+	}
+	
+	switch revision := entry["revision"].(type) {
+	case int:
+		return data, revision, true
+	case float64:
+		return data, int(revision), true
+	}
+	return nil, 0, false
+}
+
+// writeEntity responds with an entity, tagged with its revision unless
+// references were embedded, or with 304 if the client's copy is current
+func (s *Server) writeEntity(w http.ResponseWriter, r *http.Request, data map[string]interface{}, revision int, embedded bool) {
+	if !embedded {
+		setETag(w, revision)
+		if notModified(r, revision) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, data)
+}
+
+// ifMatchRevision turns an If-Match header into the revision a write must
+// find the entity at, 0 meaning any. When no listed tag can match, or the
+// store cannot tell, the error wraps storage.ErrRevisionMismatch.
+func (s *Server) ifMatchRevision(ctx context.Context, r *http.Request, entity string, id int) (int, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	
+	rv, ok := s.revisioner()
+	if !ok {
+		return 0, fmt.Errorf("%w: the store does not track revisions", storage.ErrRevisionMismatch)
+	}
+	
+	var revisions []int
+	for _, tag := range splitETags(header) {
+		if tag == "*" {
+			return 0, nil
+		}
+		if revision, ok := parseETag(tag, false); ok {
+			revisions = append(revisions, revision)
+		}
+	}
+	
+	switch len(revisions) {
+	case 0:
+		return 0, fmt.Errorf("%w: no entity tag in If-Match can match", storage.ErrRevisionMismatch)
+	case 1:
+		return revisions[0], nil
+	}
+	
+	// Several tags: the write must find whichever one is current
+	_, current, err := rv.GetRevision(ctx, entity, id)
+	if err != nil {
+		return 0, err
+	}
+	for _, revision := range revisions {
+		if revision == current {
+			return current, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s with id %d is at revision %d",
+		storage.ErrRevisionMismatch, entity, id, current)
+}
+
+// checkIfMatch checks an If-Match header against the entity's current
+// revision up front, for writes that cannot pass the expected revision all
+// the way down to the store, and returns it for those that can
+func (s *Server) checkIfMatch(ctx context.Context, r *http.Request, entity string, id int) (int, error) {
+	expected, err := s.ifMatchRevision(ctx, r, entity, id)
+	if err != nil || expected == 0 {
+		return expected, err
+	}
+	
+	rv, _ := s.revisioner()
+	_, current, err := rv.GetRevision(ctx, entity, id)
+	if err != nil {
+		return 0, err
+	}
+	if current != expected {
+		return 0, fmt.Errorf("%w: %s with id %d is at revision %d, not %d",
+			storage.ErrRevisionMismatch, entity, id, current, expected)
+	}
+	return expected, nil
+}
+
+// writePreconditionFailed responds with 412 to a write whose If-Match did not
+// match the entity's current revision
+func (s *Server) writePreconditionFailed(w http.ResponseWriter, entity string, id int, err error) {
+	s.writeJSON(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error":   fmt.Sprintf("Resource of entity %s with id %d has been modified", entity, id),
+		"details": err.Error(),
+	})
+}
+
+// patchEntity applies modify to an entity and stores the result, recording
+// the change as operation, and returns the new data and revision. modify
+// runs outside the store's locks, so it may read from the store while
+// validating. When the store tracks revisions, the write only goes ahead if
+// the entity is still at the revision modify saw; if another write got in
+// between, the patch starts again from a fresh read, unless the client asked
+// for that revision with If-Match.
+func (s *Server) patchEntity(ctx context.Context, operation string, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	rv, ok := s.revisioner()
+	if !ok {
+		data, err := s.storage.Get(ctx, entity, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		before := copyEntity(data)
+		if err := modify(data); err != nil {
+			return nil, 0, err
+		}
+		err = s.writeChanges(ctx, func(tx storage.Transaction, record recordFunc) error {
+			if err := tx.Update(ctx, entity, id, data); err != nil {
+				return err
+			}
+			record(operation, entity, id, 0, before, data)
+			return nil
+		})
+		return data, 0, err
+	}
+	
+	for attempt := 1; ; attempt++ {
+		data, current, err := rv.GetRevision(ctx, entity, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if expected != 0 && current != expected {
+			return nil, 0, fmt.Errorf("%w: %s with id %d is at revision %d, not %d",
+				storage.ErrRevisionMismatch, entity, id, current, expected)
+		}
+		
+		before := copyEntity(data)
+		if err := modify(data); err != nil {
+			return nil, 0, err
+		}
+		data["id"] = id
+		
+		var revision int
+		err = s.writeChanges(ctx, func(tx storage.Transaction, record recordFunc) error {
+			var err error
+			if revision, err = s.updateEntity(ctx, tx, entity, id, current, data); err != nil {
+				return err
+			}
+			record(operation, entity, id, revision, before, data)
+			return nil
+		})
+		if errors.Is(err, storage.ErrRevisionMismatch) && expected == 0 && attempt < patchAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return data, revision, nil
+	}
+}
+
+// updateEntity replaces an entity through tx, checking its revision when the
+// store tracks them, and returns the new revision
+func (s *Server) updateEntity(ctx context.Context, tx storage.Transaction, entity string, id int, expected int, data map[string]interface{}) (int, error) {
+	if rv, ok := s.txRevisioner(tx); ok {
+		return rv.UpdateIf(ctx, entity, id, data, expected)
+	}
+	return 0, tx.Update(ctx, entity, id, data)
+}
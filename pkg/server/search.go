@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// handleSearch runs a single-field text search.
+//
+//	GET /api/v1/users/search?field=name&q=ali&match=contains
+//
+// match is one of contains (the default), starts, ends or exact. Every match
+// type is case-insensitive for ASCII letters.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	query := r.URL.Query()
+	field := query.Get("field")
+	match := query.Get("match")
+	if match == "" {
+		match = storage.MatchContains
+	}
+	if err := storage.ValidateSearch(field, match); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	page, perPage := s.pageParams(query)
+	
+	results, err := storage.SearchField(r.Context(), s.storage, entity, field, query.Get("q"), match)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to search entities")
+		s.writeError(w, http.StatusInternalServerError, "Failed to search entities")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, paginate(results, page, perPage))
+}
+
+// handleSearchQuery runs a compound search given as a tree of AND/OR groups
+// and field conditions. Field paths may be nested.
+//
+//	POST /api/v1/users/search
+//	{"and": [
+//	  {"field": "address.city", "op": "eq", "value": "Paris"},
+//	  {"or": [
+//	    {"field": "name", "op": "starts", "value": "al"},
+//	    {"field": "age", "op": "gte", "value": 30}
+//	  ]}
+//	]}
+func (s *Server) handleSearchQuery(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	var query storage.SearchQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := query.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	page, perPage := s.pageParams(r.URL.Query())
+	
+	results, err := storage.SearchWithQuery(r.Context(), s.storage, entity, query)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to search entities")
+		s.writeError(w, http.StatusInternalServerError, "Failed to search entities")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, paginate(results, page, perPage))
+}
+
+// maxTextHits bounds the limit of a full-text search
+const maxTextHits = 100
+
+// handleFullTextSearch ranks entities of every type, or of the listed types,
+// by the relevance of their string fields.
+//
+//	GET /api/v1/_search?q=graph+database&entities=articles,notes&limit=20
+func (s *Server) handleFullTextSearch(w http.ResponseWriter, r *http.Request) {
+	searcher, ok := s.storage.(storage.FullTextSearcher)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "Storage backend does not support full-text search")
+		return
+	}
+	
+	query := r.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		s.writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	
+	entities := splitList(query.Get("entities"))
+	for _, entity := range entities {
+		if err := validateEntityName(entity); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	
+	limit, err := intParam(query, "limit", 20)
+	if err != nil || limit < 1 || limit > maxTextHits {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTextHits))
+		return
+	}
+	
+	hits, err := searcher.SearchText(r.Context(), q, entities, limit)
+	if err != nil {
+		if errors.Is(err, storage.ErrFullTextDisabled) {
+			s.writeError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		s.logger.Error().Err(err).Msg("Full-text search failed")
+		s.writeError(w, http.StatusInternalServerError, "Full-text search failed")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query": q,
+		"count": len(hits),
+		"hits":  hits,
+	})
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,7 +18,6 @@ import (
 	"github.com/ha1tch/olu/pkg/cache"
 	"github.com/ha1tch/olu/pkg/config"
 	"github.com/ha1tch/olu/pkg/graph"
-	"github.com/ha1tch/olu/pkg/models"
 	"github.com/ha1tch/olu/pkg/storage"
 	"github.com/ha1tch/olu/pkg/validation"
 )
@@ -28,6 +31,9 @@ type Server struct {
 	validator validation.Validator
 	logger    zerolog.Logger
 	router    *chi.Mux
+	queries   *queryManager
+	changes   *changeFeed
+	webhooks  *webhookManager
 }
 
 // New creates a new server instance
@@ -47,19 +53,118 @@ func New(
 		validator: validator,
 		logger:    logger,
 		router:    chi.NewRouter(),
+		queries:   newQueryManager(cfg),
+		changes:   newChangeFeed(store, logger),
+		webhooks:  newWebhookManager(cfg, logger),
+	}
+	
+	// Let the validator check that REF targets exist
+	if rv, ok := validator.(validation.ReferenceValidator); ok {
+		rv.SetReferenceResolver(func(entity string, id int) bool {
+			return store.Exists(context.Background(), entity, id)
+		})
 	}
 	
 	s.setupRoutes()
 	return s
 }
 
+// LoadSchemas loads the schemas persisted in the store into the validator,
+// returning how many were loaded. On first start, while the store holds no
+// schemas, those in SchemaDir are imported into it; stores without schema
+// support load them from SchemaDir every time. A schema that fails to load is
+// logged and skipped.
+func (s *Server) LoadSchemas(ctx context.Context) (int, error) {
+	var schemas map[string]map[string]interface{}
+	if schemaStore, ok := s.storage.(storage.SchemaStore); ok {
+		stored, err := schemaStore.ListSchemas(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if len(stored) == 0 {
+			stored = s.importSchemaDir(ctx, schemaStore)
+		}
+		schemas = stored
+	} else {
+		schemas = s.readSchemaDir()
+	}
+	
+	count := 0
+	for entity, schema := range schemas {
+		if err := s.validator.LoadSchema(entity, schema); err != nil {
+			s.logger.Warn().Err(err).Str("entity", entity).Msg("Failed to load schema")
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// importSchemaDir saves the schemas in SchemaDir into the store, returning
+// those it saved
+func (s *Server) importSchemaDir(ctx context.Context, schemaStore storage.SchemaStore) map[string]map[string]interface{} {
+	imported := make(map[string]map[string]interface{})
+	for entity, schema := range s.readSchemaDir() {
+		if err := validation.CheckSchema(schema); err != nil {
+			s.logger.Warn().Err(err).Str("entity", entity).Msg("Skipping invalid schema file")
+			continue
+		}
+		if err := schemaStore.SaveSchema(ctx, entity, schema); err != nil {
+			s.logger.Warn().Err(err).Str("entity", entity).Msg("Failed to import schema")
+			continue
+		}
+		imported[entity] = schema
+	}
+	if len(imported) > 0 {
+		s.logger.Info().Int("count", len(imported)).Str("dir", s.config.SchemaDir).Msg("Imported schema files")
+	}
+	return imported
+}
+
+// readSchemaDir reads the <entity>.json schema files in SchemaDir. Files that
+// can't be read or parsed are logged and skipped.
+func (s *Server) readSchemaDir() map[string]map[string]interface{} {
+	schemas := make(map[string]map[string]interface{})
+	if s.config.SchemaDir == "" {
+		return schemas
+	}
+	
+	files, err := os.ReadDir(s.config.SchemaDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Warn().Err(err).Str("dir", s.config.SchemaDir).Msg("Failed to read schema directory")
+		}
+		return schemas
+	}
+	
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		
+		entity := strings.TrimSuffix(file.Name(), ".json")
+		data, err := os.ReadFile(filepath.Join(s.config.SchemaDir, file.Name()))
+		if err != nil {
+			s.logger.Warn().Err(err).Str("entity", entity).Msg("Failed to read schema file")
+			continue
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			s.logger.Warn().Err(err).Str("entity", entity).Msg("Failed to parse schema file")
+			continue
+		}
+		schemas[entity] = schema
+	}
+	return schemas
+}
+
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	s.router.Use(middleware.RequestID)
 	s.router.Use(middleware.RealIP)
 	s.router.Use(middleware.Logger)
 	s.router.Use(middleware.Recoverer)
-	s.router.Use(middleware.Timeout(60 * time.Second))
+	s.router.Use(requestTimeout(60 * time.Second))
 	
 	// Health check
 	s.router.Get("/health", s.handleHealth)
@@ -67,25 +172,70 @@ func (s *Server) setupRoutes() {
 	
 	// API routes
 	s.router.Route("/api/v1", func(r chi.Router) {
+		// Multi-operation batches
+		r.Post("/_batch", s.handleBatch)
+		
+		// Change feed, as server-sent events or over a WebSocket
+		r.Get("/_changes", s.handleChanges)
+		
+		// Webhook subscriptions and their deliveries
+		r.Post("/_webhooks", s.handleCreateWebhook)
+		r.Get("/_webhooks", s.handleListWebhooks)
+		r.Get("/_webhooks/{id}", s.handleGetWebhook)
+		r.Delete("/_webhooks/{id}", s.handleDeleteWebhook)
+		r.Get("/_webhooks/{id}/deliveries", s.handleWebhookDeliveries)
+		r.Get("/_webhooks/{id}/dead_letters", s.handleWebhookDeadLetters)
+		r.Post("/_webhooks/{id}/dead_letters/{delivery}/redeliver", s.handleRedeliver)
+		
+		// Full-text search across entity types
+		if s.config.FullTextEnabled {
+			r.Get("/_search", s.handleFullTextSearch)
+		}
+		
 		// Entity CRUD operations
 		r.Post("/{entity}", s.handleCreate)
 		r.Get("/{entity}", s.handleList)
+		r.Get("/{entity}/search", s.handleSearch)
+		r.Post("/{entity}/search", s.handleSearchQuery)
 		r.Get("/{entity}/{id}", s.handleGet)
 		r.Put("/{entity}/{id}", s.handleUpdate)
 		r.Patch("/{entity}/{id}", s.handlePatch)
 		r.Delete("/{entity}/{id}", s.handleDelete)
 		r.Post("/{entity}/save/{id}", s.handleSave)
 		
+		// Entity history
+		r.Get("/{entity}/{id}/history", s.handleHistory)
+		r.Post("/{entity}/{id}/revert/{revision}", s.handleRevert)
+		
+		// Soft delete
+		r.Get("/{entity}/_trash", s.handleListTrash)
+		r.Delete("/{entity}/_trash", s.handlePurgeTrash)
+		r.Post("/{entity}/{id}/restore", s.handleRestore)
+		
 		// Graph operations
 		if s.config.GraphEnabled {
 			r.Post("/graph/path", s.handleGraphPath)
 			r.Post("/graph/neighbors", s.handleGraphNeighbors)
 			r.Get("/graph/stats", s.handleGraphStats)
+			r.Get("/graph/cycles", s.handleGraphCycles)
+			r.Get("/graph/analytics/{algorithm}", s.handleGraphAnalytics)
+			r.Post("/graph/paths/all", s.handleGraphAllPaths)
+			r.Post("/graph/paths/shortest", s.handleGraphShortestPaths)
+			r.Post("/graph/paths/weighted", s.handleGraphWeightedPath)
+		}
+		
+		// Graph queries; pattern queries also run on stores with graph support
+		if _, ok := s.storage.(storage.GraphNeighbors); ok || s.config.GraphEnabled {
+			r.Post("/graph/query", s.handleSubmitGraphQuery)
+			r.Get("/graph/query/{id}", s.handleGetGraphQuery)
+			r.Delete("/graph/query/{id}", s.handleCancelGraphQuery)
 		}
 		
 		// Schema operations
+		r.Get("/schema", s.handleListSchemas)
 		r.Post("/schema/{entity}", s.handleCreateSchema)
 		r.Get("/schema/{entity}", s.handleGetSchema)
+		r.Delete("/schema/{entity}", s.handleDeleteSchema)
 	})
 }
 
@@ -148,32 +298,31 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	revision := 0
+	if _, ok := s.revisioner(); ok {
+		revision = 1
+	}
+	
 	// Create entity
-	id, err := s.storage.Create(r.Context(), entity, data)
+	var id int
+	err := s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		var err error
+		if id, err = tx.Create(r.Context(), entity, data); err != nil {
+			return err
+		}
+		data["id"] = id
+		record(changeCreate, entity, id, revision, nil, data)
+		return nil
+	})
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to create entity")
 		s.writeError(w, http.StatusInternalServerError, "Failed to create entity")
 		return
 	}
 	
-	// Update graph if enabled
-	if s.config.GraphEnabled {
-		data["id"] = id
-		if err := s.graph.UpdateFromEntity(entity, id, data); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to update graph")
-		}
-		
-		// Save graph
-		if err := s.graph.Save(s.config.GraphDataFile); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to save graph")
-		}
-	}
-	
-	// Invalidate cache
-	s.invalidateCache(entity)
-	
 	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Created entity")
 	
+	setETag(w, revision)
 	s.writeJSON(w, http.StatusCreated, map[string]interface{}{
 		"message": fmt.Sprintf("Resource of entity %s created successfully", entity),
 		"id":      id,
@@ -189,27 +338,24 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Get pagination params
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 || perPage > 100 {
-		perPage = s.config.DefaultPageSize
-		if perPage < 1 {
-			perPage = 10 // Fallback default
-		}
+	page, perPage := s.pageParams(r.URL.Query())
+	
+	// Get filter, sort and projection params
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 	
 	// Check cache
-	cacheKey := fmt.Sprintf("%s:list:%d:%d", entity, page, perPage)
+	cacheKey := fmt.Sprintf("%s:list:%d:%d:%s", entity, page, perPage, listOptionsKey(r.URL.Query()))
 	if cached, err := s.cache.Get(r.Context(), cacheKey); err == nil {
 		s.writeJSON(w, http.StatusOK, cached)
 		return
 	}
 	
-	// Get all entities
-	entities, err := s.storage.List(r.Context(), entity)
+	// Get matching entities
+	entities, err := storage.ListWithOptions(r.Context(), s.storage, entity, opts)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to list entities")
 		s.writeError(w, http.StatusInternalServerError, "Failed to list entities")
@@ -217,29 +363,7 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Apply pagination
-	totalItems := len(entities)
-	totalPages := (totalItems + perPage - 1) / perPage
-	
-	start := (page - 1) * perPage
-	end := start + perPage
-	if end > totalItems {
-		end = totalItems
-	}
-	
-	var pageData []map[string]interface{}
-	if start < totalItems {
-		pageData = entities[start:end]
-	} else {
-		pageData = []map[string]interface{}{}
-	}
-	
-	response := models.PagedResponse{
-		Data: pageData,
-	}
-	response.Pagination.Page = page
-	response.Pagination.PerPage = perPage
-	response.Pagination.TotalItems = totalItems
-	response.Pagination.TotalPages = totalPages
+	response := paginate(entities, page, perPage)
 	
 	// Cache result
 	_ = s.cache.Set(r.Context(), cacheKey, response, time.Duration(s.config.CacheTTL)*time.Second)
@@ -263,15 +387,31 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Reads of past versions go to the history instead
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		s.handleGetAsOf(w, r, entity, id, asOf)
+		return
+	}
+	
+	// Embedded references can change without the entity's revision changing,
+	// so only the plain entity is tagged
+	embedDepth, _ := strconv.Atoi(r.URL.Query().Get("embed_depth"))
+	embed := embedDepth > 0 && embedDepth <= s.config.MaxEmbedDepth
+	
 	// Check cache
 	cacheKey := fmt.Sprintf("%s:%d", entity, id)
+	if embed {
+		cacheKey += fmt.Sprintf(":embed:%d", embedDepth)
+	}
 	if cached, err := s.cache.Get(r.Context(), cacheKey); err == nil {
-		s.writeJSON(w, http.StatusOK, cached)
-		return
+		if data, revision, ok := cachedEntity(cached); ok {
+			s.writeEntity(w, r, data, revision, embed)
+			return
+		}
 	}
 	
 	// Get entity
-	data, err := s.storage.Get(r.Context(), entity, id)
+	data, revision, err := s.getEntity(r.Context(), entity, id)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			s.writeError(w, http.StatusNotFound, 
@@ -284,15 +424,17 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Embed references if requested
-	embedDepth, _ := strconv.Atoi(r.URL.Query().Get("embed_depth"))
-	if embedDepth > 0 && embedDepth <= s.config.MaxEmbedDepth {
+	if embed {
 		data = s.embedReferences(r.Context(), data, embedDepth)
 	}
 	
 	// Cache result
-	_ = s.cache.Set(r.Context(), cacheKey, data, time.Duration(s.config.CacheTTL)*time.Second)
+	_ = s.cache.Set(r.Context(), cacheKey, map[string]interface{}{
+		"data":     data,
+		"revision": revision,
+	}, time.Duration(s.config.CacheTTL)*time.Second)
 	
-	s.writeJSON(w, http.StatusOK, data)
+	s.writeEntity(w, r, data, revision, embed)
 }
 
 // handleUpdate updates an entire entity
@@ -327,8 +469,32 @@ func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Update
-	if err := s.storage.Update(r.Context(), entity, id, data); err != nil {
+	// Apply the cycle detection policy
+	if !s.checkCycles(r.Context(), w, entity, id, data) {
+		return
+	}
+	
+	// Keep the current version to tell what the update changed
+	before, _, _ := s.getEntity(r.Context(), entity, id)
+	
+	// Update, if the entity is still at the revision the client read
+	revision := 0
+	expected, err := s.ifMatchRevision(r.Context(), r, entity, id)
+	if err == nil {
+		err = s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+			var err error
+			if revision, err = s.updateEntity(r.Context(), tx, entity, id, expected, data); err != nil {
+				return err
+			}
+			record(changeUpdate, entity, id, revision, before, data)
+			return nil
+		})
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			s.writePreconditionFailed(w, entity, id, err)
+			return
+		}
 		if strings.Contains(err.Error(), "not found") {
 			s.writeError(w, http.StatusNotFound, 
 				fmt.Sprintf("Resource of entity %s with id %d not found", entity, id))
@@ -339,17 +505,9 @@ func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Update graph
-	if s.config.GraphEnabled {
-		if err := s.graph.UpdateFromEntity(entity, id, data); err != nil {
-			s.logger.Error().Err(err).Msg("Failed to update graph")
-		}
-		_ = s.graph.Save(s.config.GraphDataFile)
-	}
-	
-	s.invalidateCache(entity)
 	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Updated entity")
 	
+	setETag(w, revision)
 	s.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"message": fmt.Sprintf("Resource of entity %s with id %d updated successfully", entity, id),
 	})
@@ -1,19 +1,33 @@
 package server_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/ha1tch/olu/pkg/cache"
 	"github.com/ha1tch/olu/pkg/config"
 	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/models"
 	"github.com/ha1tch/olu/pkg/server"
 	"github.com/ha1tch/olu/pkg/storage"
 	"github.com/ha1tch/olu/pkg/validation"
@@ -25,11 +39,26 @@ type TestServer struct {
 	server *server.Server
 	ts     *httptest.Server
 	cfg    *config.Config
+	store  storage.Store
+	stop   context.CancelFunc // stops webhook delivery and the query purge
 	t      *testing.T
 }
 
 // setupTestServer creates a test server with temporary storage
 func setupTestServer(t *testing.T) *TestServer {
+	return setupTestServerWithStore(t, "jsonfile")
+}
+
+// setupTestServerWithStore creates a test server backed by the given store type.
+// SQLite and bolt servers run without the in-memory graph and rely on the
+// store's own edges instead.
+func setupTestServerWithStore(t *testing.T, storeType string) *TestServer {
+	return setupTestServerWithConfig(t, storeType, nil)
+}
+
+// setupTestServerWithConfig creates a test server after letting configure
+// adjust the configuration, for settings that take effect at startup
+func setupTestServerWithConfig(t *testing.T, storeType string, configure func(*config.Config)) *TestServer {
 	// Create temporary directory for test data
 	tmpDir, err := os.MkdirTemp("", "olu-test-*")
 	if err != nil {
@@ -56,13 +85,28 @@ func setupTestServer(t *testing.T) *TestServer {
 		MaxCascadeDeletions: 100,
 	}
 
+	if storeType == "sqlite" || storeType == "bolt" {
+		cfg.GraphEnabled = false
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
 	// Initialize components
 	storeConfig := map[string]interface{}{
 		"base_dir": cfg.BaseDir,
 		"schema":   cfg.Schema,
+		"fulltext": cfg.FullTextEnabled,
+	}
+
+	if storeType == "sqlite" {
+		storeConfig["db_path"] = filepath.Join(tmpDir, "olu.db")
+	}
+	if storeType == "bolt" {
+		storeConfig["bolt_path"] = filepath.Join(tmpDir, "olu.bolt")
 	}
 
-	store, err := storage.NewStore("jsonfile", storeConfig)
+	store, err := storage.NewStore(storeType, storeConfig)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,23 +119,35 @@ func setupTestServer(t *testing.T) *TestServer {
 
 	srv := server.New(cfg, store, memCache, g, validator, logger)
 	ts := httptest.NewServer(srv.Handler())
+	ctx, stop := context.WithCancel(context.Background())
+	srv.StartQueryPurge(ctx)
+	srv.StartWebhooks(ctx)
 
 	return &TestServer{
 		server: srv,
 		ts:     ts,
 		cfg:    cfg,
+		store:  store,
+		stop:   stop,
 		t:      t,
 	}
 }
 
 // cleanup removes temporary test data
 func (ts *TestServer) cleanup() {
+	ts.stop()
 	ts.ts.Close()
+	ts.store.Close()
 	os.RemoveAll(ts.cfg.BaseDir)
 }
 
 // doRequest makes HTTP request and returns response
 func (ts *TestServer) doRequest(method, path string, body interface{}) (*http.Response, []byte) {
+	return ts.doRequestWithHeaders(method, path, body, nil)
+}
+
+// doRequestWithHeaders makes HTTP request with extra headers and returns response
+func (ts *TestServer) doRequestWithHeaders(method, path string, body interface{}, headers map[string]string) (*http.Response, []byte) {
 	var bodyBytes []byte
 	if body != nil {
 		var err error
@@ -109,6 +165,9 @@ func (ts *TestServer) doRequest(method, path string, body interface{}) (*http.Re
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -399,6 +458,685 @@ func TestEntityReferences(t *testing.T) {
 	})
 }
 
+// TestNestedReferences tests references held in arrays and nested objects
+func TestNestedReferences(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			create := func(entity string, data map[string]interface{}) int {
+				resp, body := ts.doRequest("POST", "/api/v1/"+entity, data)
+				if resp.StatusCode != http.StatusCreated {
+					t.Fatalf("Failed to create %s: %s", entity, string(body))
+				}
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+				return int(result["id"].(float64))
+			}
+			ref := func(id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/posts", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tags": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"targetEntity": "users", "onDelete": "set_null"},
+					},
+				},
+			})
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("Failed to create schema: %s", string(body))
+			}
+
+			a := create("users", map[string]interface{}{"name": "A"})
+			b := create("users", map[string]interface{}{"name": "B"})
+			c := create("users", map[string]interface{}{"name": "C"})
+			post := create("posts", map[string]interface{}{
+				"title":   "Hello",
+				"tags":    []interface{}{ref(a), ref(b)},
+				"address": map[string]interface{}{"city": ref(c)},
+			})
+
+			t.Run("Edges are named by JSON path", func(t *testing.T) {
+				var relationships []string
+				if storeType == "sqlite" {
+					neighbors, err := ts.store.(storage.GraphNeighbors).GetNeighbors(context.Background(), "posts", post, "out")
+					if err != nil {
+						t.Fatalf("GetNeighbors failed: %v", err)
+					}
+					for _, n := range neighbors {
+						relationships = append(relationships, n["_relationship"].(string))
+					}
+				} else {
+					_, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{
+						"node_id": fmt.Sprintf("posts:%d", post),
+					})
+					var result struct {
+						Neighbors struct {
+							Outgoing []models.GraphEdge `json:"outgoing"`
+						} `json:"neighbors"`
+					}
+					json.Unmarshal(body, &result)
+					for _, edge := range result.Neighbors.Outgoing {
+						relationships = append(relationships, edge.Relationship)
+					}
+				}
+				sort.Strings(relationships)
+
+				expected := []string{"address.city", "tags[0]", "tags[1]"}
+				if !reflect.DeepEqual(relationships, expected) {
+					t.Errorf("Expected relationships %v, got %v", expected, relationships)
+				}
+			})
+
+			t.Run("Embedding expands nested references", func(t *testing.T) {
+				_, body := ts.doRequest("GET", fmt.Sprintf("/api/v1/posts/%d?embed_depth=1", post), nil)
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				tags := result["tags"].([]interface{})
+				if tag, ok := tags[1].(map[string]interface{}); !ok || tag["name"] != "B" {
+					t.Errorf("Expected tags[1] to embed B, got %v", tags[1])
+				}
+				address := result["address"].(map[string]interface{})
+				if city, ok := address["city"].(map[string]interface{}); !ok || city["name"] != "C" {
+					t.Errorf("Expected address.city to embed C, got %v", address["city"])
+				}
+			})
+
+			t.Run("Set null clears the array element", func(t *testing.T) {
+				resp, body := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", a), nil)
+				if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+					t.Fatalf("Failed to delete: %d %s", resp.StatusCode, string(body))
+				}
+
+				_, body = ts.doRequest("GET", fmt.Sprintf("/api/v1/posts/%d", post), nil)
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+				tags := result["tags"].([]interface{})
+				if len(tags) != 2 || tags[0] != nil || tags[1] == nil {
+					t.Errorf("Expected only tags[0] to be cleared, got %v", tags)
+				}
+			})
+		})
+	}
+}
+
+// TestBatch tests multi-operation batches with intra-batch references
+func TestBatch(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/posts", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"author": map[string]interface{}{"type": "object", "targetEntity": "users"},
+				},
+			})
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("Failed to create schema: %s", string(body))
+			}
+
+			count := func(entity string) int {
+				items, err := ts.store.List(context.Background(), entity)
+				if err != nil {
+					t.Fatalf("List failed: %v", err)
+				}
+				return len(items)
+			}
+			opRef := func(index int) map[string]interface{} {
+				return map[string]interface{}{"$ref": fmt.Sprintf("op:%d", index)}
+			}
+
+			t.Run("Operations resolve earlier results", func(t *testing.T) {
+				resp, body := ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+					"operations": []interface{}{
+						map[string]interface{}{"op": "create", "entity": "users", "data": map[string]interface{}{"name": "Alice"}},
+						map[string]interface{}{"op": "create", "entity": "posts", "data": map[string]interface{}{
+							"title":  "Hello",
+							"author": map[string]interface{}{"type": "REF", "entity": "users", "id": opRef(0)},
+						}},
+						map[string]interface{}{"op": "patch", "entity": "users", "id": opRef(0), "data": map[string]interface{}{"role": "admin"}},
+						map[string]interface{}{"op": "save", "entity": "users", "id": 100, "data": map[string]interface{}{"name": "Bob"}},
+					},
+				})
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+				}
+
+				var result struct {
+					Results []struct {
+						Op     string `json:"op"`
+						ID     int    `json:"id"`
+						Status int    `json:"status"`
+					} `json:"results"`
+				}
+				json.Unmarshal(body, &result)
+				if len(result.Results) != 4 {
+					t.Fatalf("Expected 4 results, got %s", string(body))
+				}
+				userID, postID := result.Results[0].ID, result.Results[1].ID
+				if result.Results[0].Status != http.StatusCreated || result.Results[2].ID != userID {
+					t.Errorf("Unexpected results: %s", string(body))
+				}
+
+				_, body = ts.doRequest("GET", fmt.Sprintf("/api/v1/posts/%d?embed_depth=1", postID), nil)
+				var post map[string]interface{}
+				json.Unmarshal(body, &post)
+				author, _ := post["author"].(map[string]interface{})
+				if author["name"] != "Alice" || author["role"] != "admin" {
+					t.Errorf("Expected the post to reference the patched user, got %v", post["author"])
+				}
+
+				if storeType == "jsonfile" {
+					_, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{
+						"node_id": fmt.Sprintf("posts:%d", postID),
+					})
+					if !strings.Contains(string(body), fmt.Sprintf(`"to":"users:%d"`, userID)) {
+						t.Errorf("Expected the graph to hold the new edge, got %s", string(body))
+					}
+				}
+			})
+
+			t.Run("Forward references are rejected", func(t *testing.T) {
+				resp, body := ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+					"operations": []interface{}{
+						map[string]interface{}{"op": "update", "entity": "users", "id": opRef(0), "data": map[string]interface{}{}},
+					},
+				})
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("Expected 400, got %d: %s", resp.StatusCode, string(body))
+				}
+			})
+
+			t.Run("Malformed operations are rejected up front", func(t *testing.T) {
+				users := count("users")
+				resp, body := ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+					"operations": []interface{}{
+						map[string]interface{}{"op": "create", "entity": "users", "data": map[string]interface{}{"name": "X"}},
+						map[string]interface{}{"op": "upsert", "entity": "users", "data": map[string]interface{}{}},
+					},
+				})
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("Expected 400, got %d: %s", resp.StatusCode, string(body))
+				}
+				if count("users") != users {
+					t.Error("Expected nothing to be written")
+				}
+			})
+
+			if storeType != "sqlite" {
+				return
+			}
+
+			t.Run("A failed operation rolls back the batch", func(t *testing.T) {
+				users := count("users")
+				resp, body := ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+					"operations": []interface{}{
+						map[string]interface{}{"op": "create", "entity": "users", "data": map[string]interface{}{"name": "Carol"}},
+						map[string]interface{}{"op": "delete", "entity": "users", "id": 9999},
+					},
+				})
+				if resp.StatusCode != http.StatusNotFound {
+					t.Fatalf("Expected 404, got %d: %s", resp.StatusCode, string(body))
+				}
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+				if result["operation"] != float64(1) {
+					t.Errorf("Expected operation 1 to fail, got %v", result["operation"])
+				}
+				if count("users") != users {
+					t.Error("Expected the create to be rolled back")
+				}
+			})
+		})
+	}
+}
+
+// TestBatchCycles tests that the cycle detection policy sees the edges of
+// earlier operations in the same batch
+func TestBatchCycles(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.GraphCycleDetection = "error"
+			})
+			defer ts.cleanup()
+
+			ref := func(id interface{}) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+			}
+			batch := func(operations ...interface{}) (*http.Response, []byte) {
+				return ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{"operations": operations})
+			}
+
+			for _, name := range []string{"A", "B"} {
+				resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": name})
+				if resp.StatusCode != http.StatusCreated {
+					t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+				}
+			}
+
+			// A -> B, then B -> A
+			resp, body := batch(
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 1, "data": map[string]interface{}{"manager": ref(2)}},
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 2, "data": map[string]interface{}{"manager": ref(1)}},
+			)
+			if resp.StatusCode != http.StatusConflict || !strings.Contains(string(body), `"operation":1`) {
+				t.Fatalf("Expected operation 1 to be rejected with 409, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			// A cycle through an entity created earlier in the batch
+			resp, body = batch(
+				map[string]interface{}{"op": "create", "entity": "users", "data": map[string]interface{}{"name": "C", "manager": ref(1)}},
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 1, "data": map[string]interface{}{"manager": ref(map[string]interface{}{"$ref": "op:0"})}},
+			)
+			if resp.StatusCode != http.StatusConflict {
+				t.Fatalf("Expected 409, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			// Removing an edge earlier in the batch opens the way for the reverse one
+			resp, body = batch(
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 1, "data": map[string]interface{}{"manager": ref(2)}},
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 1, "data": map[string]interface{}{"manager": nil}},
+				map[string]interface{}{"op": "patch", "entity": "users", "id": 2, "data": map[string]interface{}{"manager": ref(1)}},
+			)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+			}
+		})
+	}
+}
+
+// TestBatchDeletes tests that deletes in a batch follow the onDelete rules and
+// soft-delete mode like single deletes
+func TestBatchDeletes(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.SoftDeleteEntities = []string{"notes"}
+			})
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			ref := func(entity string, id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+			}
+			batch := func(operations ...interface{}) (*http.Response, []byte) {
+				return ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{"operations": operations})
+			}
+			deleteOp := func(entity string, id int) map[string]interface{} {
+				return map[string]interface{}{"op": "delete", "entity": entity, "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/users", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"team": map[string]interface{}{"type": "object", "targetEntity": "teams", "onDelete": "restrict"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/schema/posts", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"author": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "cascade"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+
+			for _, create := range []struct {
+				entity string
+				data   map[string]interface{}
+			}{
+				{"teams", map[string]interface{}{"name": "Core"}},
+				{"users", map[string]interface{}{"name": "Ann", "team": ref("teams", 1)}},
+				{"posts", map[string]interface{}{"title": "Hi", "author": ref("users", 1)}},
+				{"notes", map[string]interface{}{"text": "Keep"}},
+			} {
+				resp, body := ts.doRequest("POST", "/api/v1/"+create.entity, create.data)
+				expect(resp, body, http.StatusCreated)
+			}
+
+			// A restricted delete fails the batch
+			resp, body = batch(deleteOp("teams", 1))
+			expect(resp, body, http.StatusConflict)
+
+			// Cascades reach the post, and the note goes to the trash
+			resp, body = batch(deleteOp("users", 1), deleteOp("teams", 1), deleteOp("notes", 1))
+			expect(resp, body, http.StatusOK)
+			var result struct {
+				Results []struct {
+					Trashed bool `json:"trashed"`
+				} `json:"results"`
+			}
+			json.Unmarshal(body, &result)
+			if len(result.Results) != 3 || result.Results[0].Trashed || !result.Results[2].Trashed {
+				t.Errorf("Expected only the note to be trashed, got %s", string(body))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/posts/1", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("GET", "/api/v1/notes/_trash", nil)
+			expect(resp, body, http.StatusOK)
+			if !strings.Contains(string(body), "Keep") {
+				t.Errorf("Expected the note in the trash, got %s", string(body))
+			}
+			resp, body = ts.doRequest("POST", "/api/v1/notes/1/restore", nil)
+			expect(resp, body, http.StatusOK)
+		})
+	}
+}
+
+// TestCascadingDelete tests that deletes follow incoming references
+func TestCascadingDelete(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+	ts.cfg.CascadingDelete = true
+
+	create := func(entity string, data map[string]interface{}) int {
+		resp, body := ts.doRequest("POST", "/api/v1/"+entity, data)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Failed to create %s: %s", entity, string(body))
+		}
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return int(result["id"].(float64))
+	}
+	ref := func(entity string, id int) map[string]interface{} {
+		return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+	}
+
+	// ceo <- manager <- employee, ceo <- post
+	ceo := create("users", map[string]interface{}{"name": "CEO"})
+	manager := create("users", map[string]interface{}{"name": "Manager", "manager": ref("users", ceo)})
+	employee := create("users", map[string]interface{}{"name": "Employee", "manager": ref("users", manager)})
+	post := create("posts", map[string]interface{}{"title": "Hello", "author": ref("users", ceo)})
+	other := create("users", map[string]interface{}{"name": "Unrelated"})
+
+	t.Run("Dry run reports without deleting", func(t *testing.T) {
+		resp, body := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/users/%d?dry_run=true", ceo), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		wouldDelete := result["would_delete"].([]interface{})
+		if len(wouldDelete) != 4 {
+			t.Errorf("Expected 4 entities in plan, got %v", wouldDelete)
+		}
+
+		resp, _ = ts.doRequest("GET", fmt.Sprintf("/api/v1/users/%d", employee), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Dry run must not delete, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Limit exceeded deletes nothing", func(t *testing.T) {
+		ts.cfg.MaxCascadeDeletions = 2
+		defer func() { ts.cfg.MaxCascadeDeletions = 100 }()
+
+		resp, _ := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", ceo), nil)
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected 409, got %d", resp.StatusCode)
+		}
+
+		resp, _ = ts.doRequest("GET", fmt.Sprintf("/api/v1/users/%d", ceo), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected root to survive, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Cascade deletes dependents", func(t *testing.T) {
+		resp, body := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", ceo), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		for _, path := range []string{
+			fmt.Sprintf("/api/v1/users/%d", ceo),
+			fmt.Sprintf("/api/v1/users/%d", manager),
+			fmt.Sprintf("/api/v1/users/%d", employee),
+			fmt.Sprintf("/api/v1/posts/%d", post),
+		} {
+			resp, _ := ts.doRequest("GET", path, nil)
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected %s to be deleted, got %d", path, resp.StatusCode)
+			}
+		}
+
+		resp, _ = ts.doRequest("GET", fmt.Sprintf("/api/v1/users/%d", other), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected unrelated user to survive, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// TestReferentialActions tests schema-declared onDelete actions and REF validation
+func TestReferentialActions(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	create := func(entity string, data map[string]interface{}) (int, *http.Response, []byte) {
+		resp, body := ts.doRequest("POST", "/api/v1/"+entity, data)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		id, _ := result["id"].(float64)
+		return int(id), resp, body
+	}
+	ref := func(entity string, id int) map[string]interface{} {
+		return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+	}
+
+	schemas := map[string]map[string]interface{}{
+		"teams": {"type": "object"},
+		"users": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"manager": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "set_null"},
+				"team":    map[string]interface{}{"type": "object", "targetEntity": "teams", "onDelete": "restrict"},
+			},
+		},
+		"posts": {
+			"type": "object",
+			"properties": map[string]interface{}{
+				"author": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "cascade"},
+			},
+		},
+	}
+	for entity, schema := range schemas {
+		resp, body := ts.doRequest("POST", "/api/v1/schema/"+entity, schema)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Failed to create schema for %s: %s", entity, string(body))
+		}
+	}
+
+	team, _, _ := create("teams", map[string]interface{}{"name": "Core"})
+	boss, _, _ := create("users", map[string]interface{}{"name": "Boss"})
+	worker, _, _ := create("users", map[string]interface{}{"name": "Worker", "manager": ref("users", boss), "team": ref("teams", team)})
+	post, _, _ := create("posts", map[string]interface{}{"title": "Hello", "author": ref("users", boss)})
+
+	t.Run("Reject reference to wrong entity type", func(t *testing.T) {
+		_, resp, body := create("users", map[string]interface{}{"name": "X", "manager": ref("teams", team)})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d: %s", resp.StatusCode, string(body))
+		}
+	})
+
+	t.Run("Reject reference to missing entity", func(t *testing.T) {
+		_, resp, body := create("users", map[string]interface{}{"name": "X", "manager": ref("users", 9999)})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d: %s", resp.StatusCode, string(body))
+		}
+	})
+
+	t.Run("Reject invalid onDelete in schema", func(t *testing.T) {
+		schema := map[string]interface{}{
+			"properties": map[string]interface{}{
+				"owner": map[string]interface{}{"onDelete": "explode"},
+			},
+		}
+		resp, _ := ts.doRequest("POST", "/api/v1/schema/things", schema)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Restrict blocks delete", func(t *testing.T) {
+		resp, body := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/teams/%d", team), nil)
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected 409, got %d: %s", resp.StatusCode, string(body))
+		}
+	})
+
+	t.Run("Cascade and set_null", func(t *testing.T) {
+		resp, body := ts.doRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", boss), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		resp, _ = ts.doRequest("GET", fmt.Sprintf("/api/v1/posts/%d", post), nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected post to be cascaded, got %d", resp.StatusCode)
+		}
+
+		resp, body = ts.doRequest("GET", fmt.Sprintf("/api/v1/users/%d", worker), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected worker to survive, got %d", resp.StatusCode)
+		}
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		if manager, exists := result["manager"]; !exists || manager != nil {
+			t.Errorf("Expected manager to be nulled out, got %v", manager)
+		}
+	})
+}
+
+// TestDeleteReferencesInTransaction tests that deletes check references
+// through each store's transaction, which the delete is planned against again
+func TestDeleteReferencesInTransaction(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			ref := func(entity string, id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/users", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"team": map[string]interface{}{"type": "object", "targetEntity": "teams", "onDelete": "restrict"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/schema/posts", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"author": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "cascade"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("POST", "/api/v1/teams", map[string]interface{}{"name": "Core"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Ann", "team": ref("teams", 1)})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/posts", map[string]interface{}{"title": "Hi", "author": ref("users", 1)})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("DELETE", "/api/v1/teams/1", nil)
+			expect(resp, body, http.StatusConflict)
+
+			// A stale If-Match refuses the delete before anything is touched
+			resp, body = ts.doRequestWithHeaders("DELETE", "/api/v1/users/1", nil, map[string]string{"If-Match": `"7"`})
+			expect(resp, body, http.StatusPreconditionFailed)
+			resp, body = ts.doRequest("GET", "/api/v1/posts/1", nil)
+			expect(resp, body, http.StatusOK)
+
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/1", nil)
+			expect(resp, body, http.StatusOK)
+			var result map[string]interface{}
+			json.Unmarshal(body, &result)
+			if deleted, _ := result["cascaded_deletes"].([]interface{}); len(deleted) != 2 {
+				t.Errorf("Expected the user and their post to be deleted, got %v", result["cascaded_deletes"])
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/posts/1", nil)
+			expect(resp, body, http.StatusNotFound)
+
+			// With its member gone the team can go too
+			resp, body = ts.doRequest("DELETE", "/api/v1/teams/1", nil)
+			expect(resp, body, http.StatusOK)
+		})
+	}
+}
+
+// TestPatchReferences tests patches that are validated against their
+// references, which read from the store while the patch is under way
+func TestPatchReferences(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			ref := func(id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/users", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"manager": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "set_null"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Boss"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Worker"})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("PATCH", "/api/v1/users/2", map[string]interface{}{"manager": ref(1)})
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("PATCH", "/api/v1/users/2", map[string]interface{}{"manager": ref(9999)})
+			expect(resp, body, http.StatusBadRequest)
+
+			resp, body = ts.doRequest("GET", "/api/v1/users/2", nil)
+			expect(resp, body, http.StatusOK)
+			var worker map[string]interface{}
+			json.Unmarshal(body, &worker)
+			manager, _ := worker["manager"].(map[string]interface{})
+			if manager == nil || manager["id"] != float64(1) {
+				t.Errorf("Expected the valid patch to stick and the invalid one to be rejected, got %v", worker)
+			}
+		})
+	}
+}
+
 // TestGraphOperations tests graph endpoints
 func TestGraphOperations(t *testing.T) {
 	ts := setupTestServer(t)
@@ -500,91 +1238,2385 @@ func TestGraphOperations(t *testing.T) {
 	})
 }
 
-// TestSchemaOperations tests schema endpoints
-func TestSchemaOperations(t *testing.T) {
+func TestGraphMultiEdges(t *testing.T) {
 	ts := setupTestServer(t)
 	defer ts.cleanup()
 
-	entity := "products"
+	_, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Alice"})
+	var user map[string]interface{}
+	json.Unmarshal(body, &user)
+	userID := int(user["id"].(float64))
 
-	t.Run("POST /api/v1/schema/{entity}", func(t *testing.T) {
-		schema := map[string]interface{}{
-			"type": "object",
-			"required": []string{"name", "price"},
-			"properties": map[string]interface{}{
-				"name": map[string]interface{}{
-					"type": "string",
-				},
-				"price": map[string]interface{}{
-					"type": "number",
-				},
-			},
+	ref := map[string]interface{}{"type": "REF", "entity": "users", "id": userID}
+	_, body = ts.doRequest("POST", "/api/v1/posts", map[string]interface{}{
+		"title":    "Hello",
+		"author":   ref,
+		"reviewer": ref,
+	})
+	var post map[string]interface{}
+	json.Unmarshal(body, &post)
+	postID := int(post["id"].(float64))
+
+	t.Run("Both references become edges", func(t *testing.T) {
+		resp, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{
+			"node_id":   fmt.Sprintf("posts:%d", postID),
+			"direction": "out",
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
 		}
 
-		resp, body := ts.doRequest("POST", "/api/v1/schema/"+entity, schema)
-		if resp.StatusCode != http.StatusCreated {
-			t.Errorf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+		var result struct {
+			Neighbors struct {
+				Outgoing []struct {
+					To           string `json:"to"`
+					Relationship string `json:"relationship"`
+					Field        string `json:"field"`
+				} `json:"outgoing"`
+			} `json:"neighbors"`
+		}
+		json.Unmarshal(body, &result)
+
+		var fields []string
+		for _, edge := range result.Neighbors.Outgoing {
+			if edge.To != fmt.Sprintf("users:%d", userID) {
+				t.Errorf("Unexpected edge target %s", edge.To)
+			}
+			fields = append(fields, edge.Field)
+		}
+		if !reflect.DeepEqual(fields, []string{"author", "reviewer"}) {
+			t.Errorf("Expected author and reviewer edges, got %v", fields)
 		}
 	})
 
-	t.Run("GET /api/v1/schema/{entity}", func(t *testing.T) {
-		resp, body := ts.doRequest("GET", "/api/v1/schema/"+entity, nil)
+	t.Run("Removing one field keeps the other edge", func(t *testing.T) {
+		ts.doRequest("PUT", fmt.Sprintf("/api/v1/posts/%d", postID), map[string]interface{}{
+			"title":  "Hello",
+			"author": ref,
+		})
+
+		resp, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{
+			"node_id":   fmt.Sprintf("users:%d", userID),
+			"direction": "in",
+		})
 		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
 		}
 
-		var result map[string]interface{}
+		var result struct {
+			Neighbors struct {
+				Incoming []map[string]interface{} `json:"incoming"`
+			} `json:"neighbors"`
+		}
 		json.Unmarshal(body, &result)
+		if len(result.Neighbors.Incoming) != 1 || result.Neighbors.Incoming[0]["field"] != "author" {
+			t.Errorf("Expected only the author edge, got %v", result.Neighbors.Incoming)
+		}
+	})
 
-		if result["type"] != "object" {
-			t.Error("Expected schema type object")
+	t.Run("Save and load keep node types and parallel edges", func(t *testing.T) {
+		g := graph.NewIndexedGraph()
+		g.AddNode("users:1", "users")
+		g.AddNode("posts:1", "posts")
+		g.PutEdge(models.GraphEdge{From: "posts:1", To: "users:1", Relationship: "author", Field: "author"})
+		g.PutEdge(models.GraphEdge{From: "posts:1", To: "users:1", Relationship: "reviewer", Field: "reviewer"})
+
+		filename := filepath.Join(t.TempDir(), "graph.data")
+		if err := g.Save(filename); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		loaded := graph.NewIndexedGraph()
+		if err := loaded.Load(filename); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+
+		node, ok := loaded.GetNode("posts:1")
+		if !ok || node.Type != "posts" {
+			t.Errorf("Expected posts node, got %v", node)
+		}
+		if loaded.EdgeCount() != 2 {
+			t.Errorf("Expected 2 edges, got %d", loaded.EdgeCount())
 		}
 	})
 }
 
-// TestPagination tests list pagination
-func TestPagination(t *testing.T) {
+// waitForQuery polls a graph query until it leaves the pending/running states
+func (ts *TestServer) waitForQuery(id string) map[string]interface{} {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, body := ts.doRequest("GET", "/api/v1/graph/query/"+id, nil)
+		if resp.StatusCode != http.StatusOK {
+			ts.t.Fatalf("Expected 200 polling query, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		var query map[string]interface{}
+		json.Unmarshal(body, &query)
+		if status := query["status"]; status != "pending" && status != "running" {
+			return query
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ts.t.Fatalf("Query %s did not finish", id)
+	return nil
+}
+
+// TestGraphQueryJobs tests asynchronous graph queries
+func TestGraphQueryJobs(t *testing.T) {
 	ts := setupTestServer(t)
 	defer ts.cleanup()
 
-	// Create 10 test entities
-	for i := 0; i < 10; i++ {
-		data := map[string]interface{}{
-			"name": fmt.Sprintf("User%d", i),
-			"age":  20 + i,
+	// users:1 <- users:2 <- users:3
+	var ids []int
+	for i := 0; i < 3; i++ {
+		data := map[string]interface{}{"name": fmt.Sprintf("User%d", i)}
+		if i > 0 {
+			data["friend"] = map[string]interface{}{"type": "REF", "entity": "users", "id": ids[i-1]}
 		}
-		ts.doRequest("POST", "/api/v1/users", data)
+		_, body := ts.doRequest("POST", "/api/v1/users", data)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		ids = append(ids, int(result["id"].(float64)))
 	}
 
-	t.Run("Pagination parameters", func(t *testing.T) {
-		resp, body := ts.doRequest("GET", "/api/v1/users?page=1&per_page=5", nil)
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected 200, got %d", resp.StatusCode)
+	submit := func(req map[string]interface{}) string {
+		resp, body := ts.doRequest("POST", "/api/v1/graph/query", req)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d: %s", resp.StatusCode, string(body))
 		}
-
 		var result map[string]interface{}
 		json.Unmarshal(body, &result)
+		return result["id"].(string)
+	}
 
-		pagination, ok := result["pagination"].(map[string]interface{})
-		if !ok {
-			t.Fatal("Expected pagination in response")
+	t.Run("Path query", func(t *testing.T) {
+		id := submit(map[string]interface{}{
+			"type":      "path",
+			"from":      fmt.Sprintf("users:%d", ids[2]),
+			"to":        fmt.Sprintf("users:%d", ids[0]),
+			"max_depth": 5,
+		})
+
+		query := ts.waitForQuery(id)
+		if query["status"] != "completed" {
+			t.Fatalf("Expected completed, got %v (%v)", query["status"], query["error"])
 		}
+		result := query["result"].(map[string]interface{})
+		if result["length"].(float64) != 2 {
+			t.Errorf("Expected path length 2, got %v", result["length"])
+		}
+	})
 
-		if pagination["page"].(float64) != 1 {
-			t.Errorf("Expected page 1, got %v", pagination["page"])
+	t.Run("Reachable query", func(t *testing.T) {
+		id := submit(map[string]interface{}{
+			"type":      "reachable",
+			"from":      fmt.Sprintf("users:%d", ids[0]),
+			"direction": "in",
+			"max_depth": 5,
+		})
+
+		query := ts.waitForQuery(id)
+		result := query["result"].(map[string]interface{})
+		if result["count"].(float64) != 2 {
+			t.Errorf("Expected 2 reachable nodes, got %v", result["count"])
 		}
-		if pagination["per_page"].(float64) != 5 {
-			t.Errorf("Expected per_page 5, got %v", pagination["per_page"])
+	})
+
+	t.Run("Failed query", func(t *testing.T) {
+		id := submit(map[string]interface{}{"type": "path", "from": "users:999", "to": "users:1", "max_depth": 3})
+
+		query := ts.waitForQuery(id)
+		if query["status"] != "failed" || query["error"] == nil {
+			t.Errorf("Expected failed query with error, got %v", query)
 		}
+	})
 
-		data, ok := result["data"].([]interface{})
-		if !ok {
-			t.Fatal("Expected data array")
+	t.Run("Cancel query", func(t *testing.T) {
+		id := submit(map[string]interface{}{"type": "reachable", "from": fmt.Sprintf("users:%d", ids[0]), "max_depth": 3})
+
+		resp, _ := ts.doRequest("DELETE", "/api/v1/graph/query/"+id, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
 		}
-		if len(data) != 5 {
-			t.Errorf("Expected 5 items, got %d", len(data))
+
+		resp, _ = ts.doRequest("GET", "/api/v1/graph/query/"+id, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 after cancel, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		resp, _ := ts.doRequest("POST", "/api/v1/graph/query", map[string]interface{}{"type": "bogus", "from": "users:1", "max_depth": 1})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
 		}
 	})
+
+	t.Run("Results expire", func(t *testing.T) {
+		ts.cfg.GraphResultTTL = 1
+		defer func() { ts.cfg.GraphResultTTL = 0 }()
+
+		id := submit(map[string]interface{}{"type": "reachable", "from": fmt.Sprintf("users:%d", ids[0]), "max_depth": 3})
+		ts.waitForQuery(id)
+
+		time.Sleep(1100 * time.Millisecond)
+		resp, _ := ts.doRequest("GET", "/api/v1/graph/query/"+id, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 after result TTL, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// TestGraphQueryLimits tests the graph query timeout and the limit on
+// queries running at once. An open SQLite transaction holds the query up, as
+// pattern queries read the store.
+func TestGraphQueryLimits(t *testing.T) {
+	ts := setupTestServerWithConfig(t, "sqlite", func(cfg *config.Config) {
+		cfg.GraphQueryTimeout = 1
+		cfg.GraphMaxRunningQueries = 1
+	})
+	defer ts.cleanup()
+
+	resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Ann"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+	}
+	query := map[string]interface{}{"type": "pattern", "query": "MATCH (u:users) RETURN u", "max_depth": 1}
+
+	tx, err := ts.store.(storage.Transactional).Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body = ts.doRequest("POST", "/api/v1/graph/query", query)
+	if resp.StatusCode != http.StatusAccepted {
+		tx.Rollback()
+		t.Fatalf("Expected 202, got %d: %s", resp.StatusCode, string(body))
+	}
+	var submitted map[string]interface{}
+	json.Unmarshal(body, &submitted)
+
+	// The first query is still running, so there is no room for another
+	resp, body = ts.doRequest("POST", "/api/v1/graph/query", query)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	tx.Rollback()
+
+	result := ts.waitForQuery(submitted["id"].(string))
+	if result["status"] != "failed" || !strings.Contains(fmt.Sprint(result["error"]), "timed out") {
+		t.Errorf("Expected the query to time out, got %v", result)
+	}
+
+	// Once it has finished another may run
+	resp, body = ts.doRequest("POST", "/api/v1/graph/query", query)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", resp.StatusCode, string(body))
+	}
+	json.Unmarshal(body, &submitted)
+	if result := ts.waitForQuery(submitted["id"].(string)); result["status"] != "completed" {
+		t.Errorf("Expected the query to complete, got %v", result)
+	}
+}
+
+// TestGraphPatternQueries tests pattern queries against the in-memory graph and SQLite graph_edges
+func TestGraphPatternQueries(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			// Alice <-manager- Bob <-manager- Carol <-manager- Dave, and Carol -mentor-> Alice
+			create := func(entity string, data map[string]interface{}) int {
+				resp, body := ts.doRequest("POST", "/api/v1/"+entity, data)
+				if resp.StatusCode != http.StatusCreated {
+					t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+				}
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+				return int(result["id"].(float64))
+			}
+			ref := func(entity string, id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+			}
+
+			alice := create("users", map[string]interface{}{"name": "Alice", "age": 50})
+			bob := create("users", map[string]interface{}{"name": "Bob", "age": 40, "manager": ref("users", alice)})
+			carol := create("users", map[string]interface{}{"name": "Carol", "age": 30, "manager": ref("users", bob), "mentor": ref("users", alice)})
+			create("users", map[string]interface{}{"name": "Dave", "age": 20, "manager": ref("users", carol)})
+			create("projects", map[string]interface{}{"name": "Apollo", "owner": ref("users", bob)})
+
+			run := func(query string, maxDepth int) map[string]interface{} {
+				resp, body := ts.doRequest("POST", "/api/v1/graph/query", map[string]interface{}{
+					"type":      "pattern",
+					"query":     query,
+					"max_depth": maxDepth,
+				})
+				if resp.StatusCode != http.StatusAccepted {
+					t.Fatalf("Expected 202, got %d: %s", resp.StatusCode, string(body))
+				}
+				var submitted map[string]interface{}
+				json.Unmarshal(body, &submitted)
+
+				finished := ts.waitForQuery(submitted["id"].(string))
+				if finished["status"] != "completed" {
+					t.Fatalf("Expected completed, got %v (%v)", finished["status"], finished["error"])
+				}
+				return finished["result"].(map[string]interface{})
+			}
+			names := func(result map[string]interface{}, column string) []string {
+				var names []string
+				for _, row := range result["rows"].([]interface{}) {
+					entity := row.(map[string]interface{})[column].(map[string]interface{})
+					names = append(names, entity["name"].(string))
+				}
+				sort.Strings(names)
+				return names
+			}
+
+			t.Run("Variable-length hops with property filter", func(t *testing.T) {
+				result := run(`MATCH (u:users)-[:manager*1..3]->(m:users) WHERE m.name = "Alice" RETURN u`, 5)
+				if got := names(result, "u"); !reflect.DeepEqual(got, []string{"Bob", "Carol", "Dave"}) {
+					t.Errorf("Expected Bob, Carol and Dave, got %v", got)
+				}
+			})
+
+			t.Run("Exact hop count", func(t *testing.T) {
+				result := run(`MATCH (u:users)-[:manager*2]->(m:users) WHERE m.name = "Alice" RETURN u`, 5)
+				if got := names(result, "u"); !reflect.DeepEqual(got, []string{"Carol"}) {
+					t.Errorf("Expected Carol, got %v", got)
+				}
+			})
+
+			t.Run("Incoming relationship and multiple filters", func(t *testing.T) {
+				result := run(`MATCH (m:users)<-[:manager]-(u:users) WHERE m.age >= 40 AND u.name STARTS WITH "c" RETURN m, u`, 5)
+				if got := names(result, "m"); !reflect.DeepEqual(got, []string{"Bob"}) {
+					t.Errorf("Expected Bob, got %v", got)
+				}
+			})
+
+			t.Run("Relationship alternatives", func(t *testing.T) {
+				result := run(`MATCH (u:users)-[:manager|:mentor]->(m:users) WHERE m.name = 'Alice' RETURN u`, 5)
+				if got := names(result, "u"); !reflect.DeepEqual(got, []string{"Bob", "Carol"}) {
+					t.Errorf("Expected Bob and Carol, got %v", got)
+				}
+			})
+
+			t.Run("Label on target node", func(t *testing.T) {
+				result := run(`MATCH (p:projects)-[:owner]->(u)-[:manager]->(m) RETURN p, m`, 5)
+				if got := names(result, "m"); !reflect.DeepEqual(got, []string{"Alice"}) {
+					t.Errorf("Expected Alice, got %v", got)
+				}
+				result = run(`MATCH (u:users)<--(p:projects) RETURN u LIMIT 1`, 5)
+				if got := names(result, "u"); !reflect.DeepEqual(got, []string{"Bob"}) {
+					t.Errorf("Expected Bob, got %v", got)
+				}
+			})
+		})
+	}
+
+	t.Run("Invalid patterns", func(t *testing.T) {
+		ts := setupTestServer(t)
+		defer ts.cleanup()
+
+		for _, query := range []string{
+			`MATCH (u)-->(m:users) RETURN m`,
+			`MATCH (u:users)-[:manager*1..9]->(m) RETURN u`,
+			`MATCH (u:users)-[:manager*]->(m) RETURN x`,
+			`MATCH (u:users) WHERE u.name = RETURN u`,
+			`MATCH (u:users)<-[:manager]->(m) RETURN u`,
+			`MATCH (u:users) RETURN u LIMIT`,
+		} {
+			resp, body := ts.doRequest("POST", "/api/v1/graph/query", map[string]interface{}{
+				"type":      "pattern",
+				"query":     query,
+				"max_depth": 5,
+			})
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d: %s", query, resp.StatusCode, string(body))
+			}
+		}
+	})
+}
+
+// TestGraphPaths tests the all-paths, k-shortest and weighted path endpoints
+func TestGraphPaths(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	create := func(data map[string]interface{}) string {
+		_, body := ts.doRequest("POST", "/api/v1/users", data)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return fmt.Sprintf("users:%d", int(result["id"].(float64)))
+	}
+	ref := func(node string) map[string]interface{} {
+		var id int
+		fmt.Sscanf(node, "users:%d", &id)
+		return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+	}
+
+	// a -manager-> b -manager-> d (cost 1 + 5)
+	// a -mentor-> c -manager-> e -manager-> d (cost 1 + 1 + 1)
+	d := create(map[string]interface{}{"name": "D", "cost": 1})
+	e := create(map[string]interface{}{"name": "E", "cost": 1, "manager": ref(d)})
+	c := create(map[string]interface{}{"name": "C", "cost": 1, "manager": ref(e)})
+	b := create(map[string]interface{}{"name": "B", "cost": 5, "manager": ref(d)})
+	a := create(map[string]interface{}{"name": "A", "cost": 1, "manager": ref(b), "mentor": ref(c)})
+
+	// nodes extracts the node IDs of a path and checks that it alternates nodes and edges
+	nodes := func(path map[string]interface{}) []string {
+		var ids []string
+		for i, element := range path["path"].([]interface{}) {
+			item := element.(map[string]interface{})
+			if i%2 == 0 {
+				ids = append(ids, item["id"].(string))
+			} else if item["relationship"] == nil {
+				t.Errorf("Expected an edge with a relationship at position %d, got %v", i, item)
+			}
+		}
+		return ids
+	}
+	post := func(t *testing.T, path string, req map[string]interface{}, expected int) map[string]interface{} {
+		resp, body := ts.doRequest("POST", path, req)
+		if resp.StatusCode != expected {
+			t.Fatalf("Expected %d, got %d: %s", expected, resp.StatusCode, string(body))
+		}
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return result
+	}
+
+	t.Run("All paths", func(t *testing.T) {
+		result := post(t, "/api/v1/graph/paths/all", map[string]interface{}{"from": a, "to": d, "max_depth": 5}, http.StatusOK)
+		paths := result["paths"].([]interface{})
+		if len(paths) != 2 {
+			t.Fatalf("Expected 2 paths, got %d", len(paths))
+		}
+		if got := nodes(paths[0].(map[string]interface{})); !reflect.DeepEqual(got, []string{a, b, d}) {
+			t.Errorf("Expected shortest path first, got %v", got)
+		}
+
+		edge := paths[0].(map[string]interface{})["path"].([]interface{})[1].(map[string]interface{})
+		if edge["from"] != a || edge["to"] != b || edge["relationship"] != "manager" {
+			t.Errorf("Unexpected edge %v", edge)
+		}
+
+		result = post(t, "/api/v1/graph/paths/all", map[string]interface{}{"from": a, "to": d, "max_depth": 2}, http.StatusOK)
+		if result["count"].(float64) != 1 {
+			t.Errorf("Expected 1 path within depth 2, got %v", result["count"])
+		}
+	})
+
+	t.Run("K shortest by hops", func(t *testing.T) {
+		result := post(t, "/api/v1/graph/paths/shortest", map[string]interface{}{"from": a, "to": d, "k": 5}, http.StatusOK)
+		paths := result["paths"].([]interface{})
+		if len(paths) != 2 {
+			t.Fatalf("Expected 2 paths, got %d", len(paths))
+		}
+		if got := nodes(paths[1].(map[string]interface{})); !reflect.DeepEqual(got, []string{a, c, e, d}) {
+			t.Errorf("Expected the longer path second, got %v", got)
+		}
+	})
+
+	t.Run("K shortest by weight", func(t *testing.T) {
+		result := post(t, "/api/v1/graph/paths/shortest", map[string]interface{}{
+			"from": a, "to": d, "k": 2, "weight_property": "cost",
+		}, http.StatusOK)
+		paths := result["paths"].([]interface{})
+		first := paths[0].(map[string]interface{})
+		second := paths[1].(map[string]interface{})
+		if first["weight"].(float64) != 3 || second["weight"].(float64) != 6 {
+			t.Errorf("Expected weights 3 and 6, got %v and %v", first["weight"], second["weight"])
+		}
+	})
+
+	t.Run("Weighted shortest path", func(t *testing.T) {
+		result := post(t, "/api/v1/graph/paths/weighted", map[string]interface{}{
+			"from": a, "to": d, "weight_property": "cost",
+		}, http.StatusOK)
+		if got := nodes(result); !reflect.DeepEqual(got, []string{a, c, e, d}) {
+			t.Errorf("Expected the cheaper path, got %v", got)
+		}
+		if result["length"].(float64) != 3 || result["weight"].(float64) != 3 {
+			t.Errorf("Expected length 3 and weight 3, got %v and %v", result["length"], result["weight"])
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		post(t, "/api/v1/graph/paths/weighted", map[string]interface{}{"from": a, "to": d}, http.StatusBadRequest)
+		post(t, "/api/v1/graph/paths/shortest", map[string]interface{}{"from": d, "to": a}, http.StatusNotFound)
+		post(t, "/api/v1/graph/paths/all", map[string]interface{}{"from": a, "to": "users:999", "max_depth": 3}, http.StatusNotFound)
+		post(t, "/api/v1/graph/paths/all", map[string]interface{}{"from": a}, http.StatusBadRequest)
+	})
+}
+
+// TestGraphCycles tests cycle reporting and the cycle detection policies
+func TestGraphCycles(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	ts.cfg.GraphCycleDetection = "error"
+	ts.cfg.GraphCycleRelationships = []string{"manager"}
+
+	ref := func(id int) map[string]interface{} {
+		return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+	}
+	create := func(data map[string]interface{}) int {
+		_, body := ts.doRequest("POST", "/api/v1/users", data)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return int(result["id"].(float64))
+	}
+	cycles := func(query string) []interface{} {
+		_, body := ts.doRequest("GET", "/api/v1/graph/cycles"+query, nil)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return result["cycles"].([]interface{})
+	}
+
+	a := create(map[string]interface{}{"name": "A"})
+	b := create(map[string]interface{}{"name": "B", "manager": ref(a)})
+
+	t.Run("Error policy rejects a cycle", func(t *testing.T) {
+		resp, body := ts.doRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", a), map[string]interface{}{"manager": ref(b)})
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("Expected 409, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		cycle := result["cycle"].(map[string]interface{})
+		if len(cycle["nodes"].([]interface{})) != 2 || len(cycle["edges"].([]interface{})) != 2 {
+			t.Errorf("Expected a two-node cycle, got %v", cycle)
+		}
+
+		resp, _ = ts.doRequest("GET", fmt.Sprintf("/api/v1/users/%d", a), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected entity to be untouched, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Self reference is a cycle", func(t *testing.T) {
+		resp, _ := ts.doRequest("PUT", fmt.Sprintf("/api/v1/users/%d", a), map[string]interface{}{"name": "A", "manager": ref(a)})
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected 409, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Unscoped relationships are not checked", func(t *testing.T) {
+		resp, body := ts.doRequest("PATCH", fmt.Sprintf("/api/v1/users/%d", a), map[string]interface{}{"mentor": ref(b)})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+		if got := cycles("?relationship=manager"); len(got) != 0 {
+			t.Errorf("Expected no manager cycles, got %v", got)
+		}
+		if got := cycles(""); len(got) != 1 {
+			t.Errorf("Expected one cycle over all relationships, got %v", got)
+		}
+	})
+
+	t.Run("Warn policy allows the cycle with a header", func(t *testing.T) {
+		ts.cfg.GraphCycleDetection = "warn"
+		defer func() { ts.cfg.GraphCycleDetection = "error" }()
+
+		resp, body := ts.doRequest("PUT", fmt.Sprintf("/api/v1/users/%d", a), map[string]interface{}{"name": "A", "manager": ref(b)})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+		if resp.Header.Get("X-Graph-Cycle") == "" {
+			t.Error("Expected X-Graph-Cycle header")
+		}
+
+		got := cycles("?relationship=manager")
+		if len(got) != 1 {
+			t.Fatalf("Expected one manager cycle, got %v", got)
+		}
+		if nodes := got[0].(map[string]interface{})["nodes"].([]interface{}); len(nodes) != 2 {
+			t.Errorf("Expected two nodes in the cycle, got %v", nodes)
+		}
+	})
+
+	t.Run("Replacing a reference removes its edge", func(t *testing.T) {
+		resp, _ := ts.doRequest("PUT", fmt.Sprintf("/api/v1/users/%d", a), map[string]interface{}{"name": "A"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		if got := cycles(""); len(got) != 0 {
+			t.Errorf("Expected no cycles, got %v", got)
+		}
+	})
+}
+
+// TestStoreCycleDetection tests the cycle detection policies against the
+// store's own edges, with the in-memory graph disabled
+func TestStoreCycleDetection(t *testing.T) {
+	for _, storeType := range []string{"sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.GraphCycleDetection = "error"
+				cfg.GraphCycleRelationships = []string{"manager"}
+			})
+			defer ts.cleanup()
+
+			if err := ts.server.CheckCycleDetection(); err != nil {
+				t.Fatalf("Expected the store's edges to enforce the policy: %v", err)
+			}
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			ref := func(id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "A"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "B", "manager": ref(1)})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("PATCH", "/api/v1/users/1", map[string]interface{}{"manager": ref(2)})
+			expect(resp, body, http.StatusConflict)
+			resp, body = ts.doRequest("PUT", "/api/v1/users/1", map[string]interface{}{"name": "A", "manager": ref(1)})
+			expect(resp, body, http.StatusConflict)
+			resp, body = ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+				"operations": []interface{}{
+					map[string]interface{}{"op": "patch", "entity": "users", "id": 1, "data": map[string]interface{}{"manager": ref(2)}},
+				},
+			})
+			expect(resp, body, http.StatusConflict)
+
+			resp, body = ts.doRequest("PATCH", "/api/v1/users/1", map[string]interface{}{"mentor": ref(2)})
+			expect(resp, body, http.StatusOK)
+
+			ts.cfg.GraphCycleDetection = "warn"
+			resp, body = ts.doRequest("PATCH", "/api/v1/users/1", map[string]interface{}{"manager": ref(2)})
+			expect(resp, body, http.StatusOK)
+			if got := resp.Header.Get("X-Graph-Cycle"); got != "users:1 -[manager]-> users:2 -[manager]-> users:1" {
+				t.Errorf("Expected the cycle in the header, got %q", got)
+			}
+		})
+	}
+
+	t.Run("jsonfile without graph", func(t *testing.T) {
+		ts := setupTestServerWithConfig(t, "jsonfile", func(cfg *config.Config) {
+			cfg.GraphEnabled = false
+			cfg.GraphCycleDetection = "warn"
+		})
+		defer ts.cleanup()
+
+		// warn, the default, only logs that cycles go unchecked
+		if err := ts.server.CheckCycleDetection(); err != nil {
+			t.Errorf("Expected warn to start without a graph, got %v", err)
+		}
+		ref := map[string]interface{}{"type": "REF", "entity": "users", "id": 1}
+		ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "A"})
+		resp, body := ts.doRequest("PATCH", "/api/v1/users/1", map[string]interface{}{"manager": ref})
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected unchecked writes to go through, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		ts.cfg.GraphCycleDetection = "error"
+		if err := ts.server.CheckCycleDetection(); err == nil {
+			t.Error("Expected a policy that can't be enforced to be refused")
+		}
+		ts.cfg.GraphCycleDetection = "ignore"
+		if err := ts.server.CheckCycleDetection(); err != nil {
+			t.Errorf("Expected ignore to need no graph, got %v", err)
+		}
+	})
+}
+
+// TestGraphAnalytics tests the graph analytics endpoints
+func TestGraphAnalytics(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	create := func(entity string, data map[string]interface{}) string {
+		_, body := ts.doRequest("POST", "/api/v1/"+entity, data)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return fmt.Sprintf("%s:%d", entity, int(result["id"].(float64)))
+	}
+	ref := func(node string) map[string]interface{} {
+		parts := strings.SplitN(node, ":", 2)
+		id, _ := strconv.Atoi(parts[1])
+		return map[string]interface{}{"type": "REF", "entity": parts[0], "id": id}
+	}
+	get := func(t *testing.T, path string, expected int) map[string]interface{} {
+		resp, body := ts.doRequest("GET", "/api/v1/graph/analytics/"+path, nil)
+		if resp.StatusCode != expected {
+			t.Fatalf("Expected %d, got %d: %s", expected, resp.StatusCode, string(body))
+		}
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return result
+	}
+
+	// t4 -> t2 -> t1 <- t3 (depends_on), every task -> u1 (owner), u1 <-> u2 (manager)
+	u1 := create("users", map[string]interface{}{"name": "U1"})
+	u2 := create("users", map[string]interface{}{"name": "U2", "manager": ref(u1)})
+	ts.doRequest("PATCH", "/api/v1/"+strings.Replace(u1, ":", "/", 1), map[string]interface{}{"manager": ref(u2)})
+	t1 := create("tasks", map[string]interface{}{"name": "T1", "owner": ref(u1)})
+	t2 := create("tasks", map[string]interface{}{"name": "T2", "owner": ref(u1), "depends_on": ref(t1)})
+	t3 := create("tasks", map[string]interface{}{"name": "T3", "owner": ref(u1), "depends_on": ref(t1)})
+	t4 := create("tasks", map[string]interface{}{"name": "T4", "owner": ref(u1), "depends_on": ref(t2)})
+
+	t.Run("Degree", func(t *testing.T) {
+		result := get(t, "degree?entity_type=tasks", http.StatusOK)
+		if result["count"].(float64) != 4 {
+			t.Errorf("Expected 4 tasks, got %v", result["count"])
+		}
+		for _, item := range result["nodes"].([]interface{}) {
+			node := item.(map[string]interface{})
+			if node["node"] == t1 && node["in"].(float64) != 2 {
+				t.Errorf("Expected in-degree 2 for %s, got %v", t1, node["in"])
+			}
+		}
+		in := result["in_distribution"].(map[string]interface{})
+		if in["0"].(float64) != 2 {
+			t.Errorf("Expected two tasks without dependents, got %v", in)
+		}
+	})
+
+	t.Run("Connected components", func(t *testing.T) {
+		result := get(t, "wcc?entity_type=tasks&relationship=depends_on", http.StatusOK)
+		if result["count"].(float64) != 1 {
+			t.Errorf("Expected one weak component, got %v", result["components"])
+		}
+
+		result = get(t, "scc", http.StatusOK)
+		largest := result["components"].([]interface{})[0].([]interface{})
+		if !reflect.DeepEqual(largest, []interface{}{u1, u2}) {
+			t.Errorf("Expected the users to form the largest strong component, got %v", largest)
+		}
+	})
+
+	t.Run("PageRank", func(t *testing.T) {
+		result := get(t, "pagerank?entity_type=tasks&relationship=depends_on&limit=1", http.StatusOK)
+		scores := result["scores"].([]interface{})
+		if len(scores) != 1 || scores[0].(map[string]interface{})["node"] != t1 {
+			t.Errorf("Expected %s to rank highest, got %v", t1, scores)
+		}
+
+		get(t, "pagerank?damping=2", http.StatusBadRequest)
+	})
+
+	t.Run("Betweenness", func(t *testing.T) {
+		result := get(t, "betweenness?entity_type=tasks", http.StatusOK)
+		if result["sampled"] != false {
+			t.Errorf("Expected an exact result for a small graph, got %v", result["sampled"])
+		}
+		top := result["scores"].([]interface{})[0].(map[string]interface{})
+		if top["node"] != t2 || top["score"].(float64) != 1 {
+			t.Errorf("Expected %s with score 1 on top, got %v", t2, top)
+		}
+
+		result = get(t, "betweenness?samples=2", http.StatusOK)
+		if result["sampled"] != true || result["samples"].(float64) != 2 {
+			t.Errorf("Expected a sampled result, got %v", result)
+		}
+	})
+
+	t.Run("Topological sort", func(t *testing.T) {
+		result := get(t, "toposort?entity_type=tasks&relationship=depends_on", http.StatusOK)
+		position := make(map[string]int)
+		for i, node := range result["order"].([]interface{}) {
+			position[node.(string)] = i
+		}
+		if !(position[t4] < position[t2] && position[t2] < position[t1] && position[t3] < position[t1]) {
+			t.Errorf("Order does not respect dependencies: %v", result["order"])
+		}
+
+		get(t, "toposort?relationship=manager", http.StatusConflict)
+	})
+
+	t.Run("Unknown algorithm", func(t *testing.T) {
+		get(t, "bogus", http.StatusNotFound)
+	})
+}
+
+// TestSchemaOperations tests schema endpoints
+func TestSchemaOperations(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	entity := "products"
+
+	t.Run("POST /api/v1/schema/{entity}", func(t *testing.T) {
+		schema := map[string]interface{}{
+			"type": "object",
+			"required": []string{"name", "price"},
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type": "string",
+				},
+				"price": map[string]interface{}{
+					"type": "number",
+				},
+			},
+		}
+
+		resp, body := ts.doRequest("POST", "/api/v1/schema/"+entity, schema)
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+		}
+	})
+
+	t.Run("GET /api/v1/schema/{entity}", func(t *testing.T) {
+		resp, body := ts.doRequest("GET", "/api/v1/schema/"+entity, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		if result["type"] != "object" {
+			t.Error("Expected schema type object")
+		}
+	})
+}
+
+// TestSchemaPersistence tests that schemas are kept in the store and survive
+// a restart, for each store type
+func TestSchemaPersistence(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+		},
+	}
+
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			// restart builds a new server over the same store
+			restart := func() (*validation.JSONSchemaValidator, int) {
+				validator := validation.NewJSONSchemaValidator("")
+				srv := server.New(ts.cfg, ts.store, cache.NewMemoryCache(10, time.Minute), graph.NewIndexedGraph(),
+					validator, zerolog.New(os.Stdout).Level(zerolog.Disabled))
+				count, err := srv.LoadSchemas(context.Background())
+				if err != nil {
+					t.Fatalf("Failed to load schemas: %v", err)
+				}
+				return validator, count
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/products", schema)
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			resp, body = ts.doRequest("GET", "/api/v1/schema", nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+			}
+			var list struct {
+				Schemas map[string]map[string]interface{} `json:"schemas"`
+				Count   int                               `json:"count"`
+			}
+			json.Unmarshal(body, &list)
+			if list.Count != 1 || list.Schemas["products"]["type"] != "object" {
+				t.Errorf("Expected the products schema, got %s", string(body))
+			}
+
+			validator, count := restart()
+			if count != 1 || !validator.HasSchema("products") {
+				t.Fatalf("Expected the schema to survive a restart, loaded %d", count)
+			}
+			if valid, _ := validator.Validate("products", map[string]interface{}{"price": 1}); valid {
+				t.Error("Expected the reloaded schema to be enforced")
+			}
+
+			resp, body = ts.doRequest("DELETE", "/api/v1/schema/products", nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+			}
+			resp, _ = ts.doRequest("DELETE", "/api/v1/schema/products", nil)
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected 404 for a deleted schema, got %d", resp.StatusCode)
+			}
+			resp, _ = ts.doRequest("GET", "/api/v1/schema/products", nil)
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected 404 for a deleted schema, got %d", resp.StatusCode)
+			}
+			resp, body = ts.doRequest("POST", "/api/v1/products", map[string]interface{}{"price": 1})
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("Expected 201 without a schema, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			if _, count := restart(); count != 0 {
+				t.Errorf("Expected no schemas after delete, loaded %d", count)
+			}
+		})
+	}
+}
+
+// TestSchemaImport tests that schema files in SCHEMA_DIR are imported into a
+// store that has no schemas yet, skipping those that don't load
+func TestSchemaImport(t *testing.T) {
+	files := map[string]string{
+		"users.json":  `{"type": "object", "required": ["name"]}`,
+		"broken.json": `{"type": `,
+		"teams.json":  `{"properties": {"owner": {"onDelete": "explode"}}}`,
+		"notes.txt":   `not a schema`,
+	}
+
+	for _, storeType := range []string{"sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			schemaDir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(schemaDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.SchemaDir = schemaDir
+			})
+			defer ts.cleanup()
+
+			load := func() (*validation.JSONSchemaValidator, int) {
+				validator := validation.NewJSONSchemaValidator("")
+				srv := server.New(ts.cfg, ts.store, cache.NewMemoryCache(10, time.Minute), graph.NewIndexedGraph(),
+					validator, zerolog.New(os.Stdout).Level(zerolog.Disabled))
+				count, err := srv.LoadSchemas(context.Background())
+				if err != nil {
+					t.Fatalf("Failed to load schemas: %v", err)
+				}
+				return validator, count
+			}
+
+			validator, count := load()
+			if count != 1 || !validator.HasSchema("users") {
+				t.Fatalf("Expected only the users schema to load, loaded %d", count)
+			}
+			if valid, _ := validator.Validate("users", map[string]interface{}{"age": 1}); valid {
+				t.Error("Expected the imported schema to be enforced")
+			}
+			stored, err := ts.store.(storage.SchemaStore).ListSchemas(context.Background())
+			if err != nil || len(stored) != 1 || stored["users"] == nil {
+				t.Fatalf("Expected the users schema in the store, got %v (%v)", stored, err)
+			}
+
+			// Once the store has schemas, the files are no longer read
+			os.WriteFile(filepath.Join(schemaDir, "users.json"), []byte(`{"type": "object"}`), 0644)
+			validator, count = load()
+			if count != 1 {
+				t.Fatalf("Expected 1 schema from the store, loaded %d", count)
+			}
+			if valid, _ := validator.Validate("users", map[string]interface{}{"age": 1}); valid {
+				t.Error("Expected the stored schema, not the changed file")
+			}
+		})
+	}
+}
+
+// TestConditionalRequests tests ETags and If-Match / If-None-Match handling
+func TestConditionalRequests(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int, etag string) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+				if got := resp.Header.Get("ETag"); got != etag {
+					t.Errorf("Expected ETag %s, got %q", etag, got)
+				}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{"title": "Draft"})
+			expect(resp, body, http.StatusCreated, `"1"`)
+
+			// The second read comes from the cache
+			for i := 0; i < 2; i++ {
+				resp, body = ts.doRequest("GET", "/api/v1/docs/1", nil)
+				expect(resp, body, http.StatusOK, `"1"`)
+				resp, body = ts.doRequestWithHeaders("GET", "/api/v1/docs/1", nil, map[string]string{"If-None-Match": `"1"`})
+				expect(resp, body, http.StatusNotModified, `"1"`)
+				if len(body) != 0 {
+					t.Errorf("Expected no body with 304, got %s", string(body))
+				}
+			}
+
+			// Embedded representations are not tagged
+			resp, body = ts.doRequestWithHeaders("GET", "/api/v1/docs/1?embed_depth=1", nil, map[string]string{"If-None-Match": `"1"`})
+			expect(resp, body, http.StatusOK, "")
+
+			resp, body = ts.doRequestWithHeaders("PUT", "/api/v1/docs/1", map[string]interface{}{"title": "Second"}, map[string]string{"If-Match": `"1"`})
+			expect(resp, body, http.StatusOK, `"2"`)
+			resp, body = ts.doRequestWithHeaders("PUT", "/api/v1/docs/1", map[string]interface{}{"title": "Lost"}, map[string]string{"If-Match": `"1"`})
+			expect(resp, body, http.StatusPreconditionFailed, "")
+
+			resp, body = ts.doRequestWithHeaders("GET", "/api/v1/docs/1", nil, map[string]string{"If-None-Match": `"1"`})
+			expect(resp, body, http.StatusOK, `"2"`)
+			var doc map[string]interface{}
+			json.Unmarshal(body, &doc)
+			if doc["title"] != "Second" {
+				t.Errorf("Expected the conditional update to win, got %v", doc)
+			}
+
+			resp, body = ts.doRequestWithHeaders("PATCH", "/api/v1/docs/1", map[string]interface{}{"body": "Text"}, map[string]string{"If-Match": `"2"`})
+			expect(resp, body, http.StatusOK, `"3"`)
+			resp, body = ts.doRequestWithHeaders("PATCH", "/api/v1/docs/1", map[string]interface{}{"body": "Lost"}, map[string]string{"If-Match": `"2"`})
+			expect(resp, body, http.StatusPreconditionFailed, "")
+			resp, body = ts.doRequestWithHeaders("PATCH", "/api/v1/docs/1", map[string]interface{}{"body": "Weak"}, map[string]string{"If-Match": `W/"3"`})
+			expect(resp, body, http.StatusPreconditionFailed, "")
+			resp, body = ts.doRequestWithHeaders("PATCH", "/api/v1/docs/1", map[string]interface{}{"tags": "a"}, map[string]string{"If-Match": `"1", "3"`})
+			expect(resp, body, http.StatusOK, `"4"`)
+			resp, body = ts.doRequestWithHeaders("PATCH", "/api/v1/docs/9", map[string]interface{}{"tags": "a"}, map[string]string{"If-Match": "*"})
+			expect(resp, body, http.StatusNotFound, "")
+
+			// Concurrent patches keep each other's fields
+			const writers = 10
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					patch, _ := json.Marshal(map[string]interface{}{fmt.Sprintf("field%d", i): i})
+					req, _ := http.NewRequest("PATCH", ts.ts.URL+"/api/v1/docs/1", bytes.NewReader(patch))
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Errorf("Patch failed: %v", err)
+						return
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						t.Errorf("Expected 200, got %d", resp.StatusCode)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK, fmt.Sprintf(`"%d"`, 4+writers))
+			doc = nil
+			json.Unmarshal(body, &doc)
+			for i := 0; i < writers; i++ {
+				if _, ok := doc[fmt.Sprintf("field%d", i)]; !ok {
+					t.Errorf("Expected field%d to survive concurrent patches, got %v", i, doc)
+				}
+			}
+
+			resp, body = ts.doRequestWithHeaders("DELETE", "/api/v1/docs/1", nil, map[string]string{"If-Match": `"3"`})
+			expect(resp, body, http.StatusPreconditionFailed, "")
+			resp, body = ts.doRequestWithHeaders("DELETE", "/api/v1/docs/1", nil, map[string]string{"If-Match": fmt.Sprintf(`"%d"`, 4+writers)})
+			expect(resp, body, http.StatusOK, "")
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusNotFound, "")
+		})
+	}
+}
+
+// TestEntityHistory tests history, as-of reads and revert
+func TestEntityHistory(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			// Outgoing edges in the in-memory graph, which the SQL and bolt
+			// stores replace with their own
+			edges := func() int {
+				t.Helper()
+				if !ts.cfg.GraphEnabled {
+					return -1
+				}
+				resp, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{"node_id": "docs:1"})
+				expect(resp, body, http.StatusOK)
+				var result struct {
+					Neighbors struct {
+						Outgoing []interface{} `json:"outgoing"`
+					} `json:"neighbors"`
+				}
+				json.Unmarshal(body, &result)
+				return len(result.Neighbors.Outgoing)
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{
+				"title":  "Draft",
+				"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1},
+			})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("PUT", "/api/v1/docs/1", map[string]interface{}{"title": "Final", "status": "done"})
+			expect(resp, body, http.StatusOK)
+			if n := edges(); n > 0 {
+				t.Errorf("Expected no edges once the reference is gone, got %d", n)
+			}
+
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1/history", nil)
+			expect(resp, body, http.StatusOK)
+			var history struct {
+				Versions []struct {
+					Revision      int                    `json:"revision"`
+					ChangedAt     string                 `json:"changed_at"`
+					Deleted       bool                   `json:"deleted"`
+					ChangedFields []string               `json:"changed_fields"`
+					Data          map[string]interface{} `json:"data"`
+				} `json:"versions"`
+			}
+			json.Unmarshal(body, &history)
+			if len(history.Versions) != 2 {
+				t.Fatalf("Expected 2 versions, got %s", string(body))
+			}
+			first, second := history.Versions[0], history.Versions[1]
+			if first.Revision != 1 || first.Data["title"] != "Draft" || first.ChangedAt == "" {
+				t.Errorf("Unexpected first version: %+v", first)
+			}
+			if fmt.Sprint(first.ChangedFields) != "[author title]" {
+				t.Errorf("Expected every field changed by the create, got %v", first.ChangedFields)
+			}
+			if second.Revision != 2 || fmt.Sprint(second.ChangedFields) != "[author status title]" {
+				t.Errorf("Unexpected second version: %+v", second)
+			}
+
+			// As-of reads by revision and by time
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of=1", nil)
+			expect(resp, body, http.StatusOK)
+			if resp.Header.Get("ETag") != `"1"` || !strings.Contains(string(body), "Draft") {
+				t.Errorf("Expected revision 1, got %s (ETag %s)", string(body), resp.Header.Get("ETag"))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of="+url.QueryEscape(first.ChangedAt), nil)
+			expect(resp, body, http.StatusOK)
+			if !strings.Contains(string(body), "Draft") {
+				t.Errorf("Expected the version current at its own timestamp, got %s", string(body))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of=2000-01-01T00:00:00Z", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of=9", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of=yesterday", nil)
+			expect(resp, body, http.StatusBadRequest)
+
+			// Reverting writes the old data as a new revision and restores
+			// its edges
+			resp, body = ts.doRequestWithHeaders("POST", "/api/v1/docs/1/revert/1", nil, map[string]string{"If-Match": `"1"`})
+			expect(resp, body, http.StatusPreconditionFailed)
+			resp, body = ts.doRequestWithHeaders("POST", "/api/v1/docs/1/revert/1", nil, map[string]string{"If-Match": `"2"`})
+			expect(resp, body, http.StatusOK)
+			if resp.Header.Get("ETag") != `"3"` {
+				t.Errorf("Expected ETag \"3\", got %q", resp.Header.Get("ETag"))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK)
+			var doc map[string]interface{}
+			json.Unmarshal(body, &doc)
+			if doc["title"] != "Draft" || doc["author"] == nil || doc["status"] != nil {
+				t.Errorf("Expected revision 1's data, got %v", doc)
+			}
+			if n := edges(); n == 0 {
+				t.Error("Expected the reverted reference to be back in the graph")
+			}
+
+			// A deleted entity can be brought back, but not reverted to its delete
+			resp, body = ts.doRequest("DELETE", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1?as_of=4", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("POST", "/api/v1/docs/1/revert/4", nil)
+			expect(resp, body, http.StatusBadRequest)
+			resp, body = ts.doRequest("POST", "/api/v1/docs/1/revert/2", nil)
+			expect(resp, body, http.StatusOK)
+			if resp.Header.Get("ETag") != `"5"` {
+				t.Errorf("Expected ETag \"5\", got %q", resp.Header.Get("ETag"))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK)
+			doc = nil
+			json.Unmarshal(body, &doc)
+			if doc["title"] != "Final" || doc["status"] != "done" {
+				t.Errorf("Expected revision 2's data, got %v", doc)
+			}
+
+			resp, body = ts.doRequest("GET", "/api/v1/docs/9/history", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("POST", "/api/v1/docs/1/revert/0", nil)
+			expect(resp, body, http.StatusBadRequest)
+			resp, body = ts.doRequest("POST", "/api/v1/docs/1/revert/99", nil)
+			expect(resp, body, http.StatusNotFound)
+		})
+	}
+}
+
+// TestRevertReferences tests reverts that are validated against their
+// references, which read from the store while the revert is under way
+func TestRevertReferences(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			ref := func(id int) map[string]interface{} {
+				return map[string]interface{}{"type": "REF", "entity": "users", "id": id}
+			}
+
+			resp, body := ts.doRequest("POST", "/api/v1/schema/users", map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"manager": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "set_null"},
+				},
+			})
+			expect(resp, body, http.StatusCreated)
+
+			for _, name := range []string{"Boss", "Other"} {
+				resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": name})
+				expect(resp, body, http.StatusCreated)
+			}
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "Worker", "manager": ref(1)})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("PUT", "/api/v1/users/3", map[string]interface{}{"name": "Worker", "manager": ref(2)})
+			expect(resp, body, http.StatusOK)
+
+			resp, body = ts.doRequest("POST", "/api/v1/users/3/revert/1", nil)
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("GET", "/api/v1/users/3", nil)
+			expect(resp, body, http.StatusOK)
+			var worker map[string]interface{}
+			json.Unmarshal(body, &worker)
+			if manager, _ := worker["manager"].(map[string]interface{}); manager == nil || manager["id"] != float64(1) {
+				t.Errorf("Expected revision 1's manager back, got %v", worker)
+			}
+
+			// Revision 2's manager has gone since, so it can't be restored
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/2", nil)
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("POST", "/api/v1/users/3/revert/2", nil)
+			expect(resp, body, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.SoftDeleteEntities = []string{"users"}
+			})
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+			edges := func() int {
+				t.Helper()
+				if !ts.cfg.GraphEnabled {
+					return -1
+				}
+				resp, body := ts.doRequest("POST", "/api/v1/graph/neighbors", map[string]interface{}{"node_id": "docs:1"})
+				expect(resp, body, http.StatusOK)
+				var result struct {
+					Neighbors struct {
+						Outgoing []interface{} `json:"outgoing"`
+					} `json:"neighbors"`
+				}
+				json.Unmarshal(body, &result)
+				return len(result.Neighbors.Outgoing)
+			}
+			trash := func(entity string) []map[string]interface{} {
+				t.Helper()
+				resp, body := ts.doRequest("GET", "/api/v1/"+entity+"/_trash", nil)
+				expect(resp, body, http.StatusOK)
+				var result struct {
+					Data []map[string]interface{} `json:"data"`
+				}
+				json.Unmarshal(body, &result)
+				return result.Data
+			}
+			feed := ts.openChangeStream("", nil)
+			defer feed.close()
+
+			resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{
+				"title":  "Draft",
+				"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1},
+			})
+			expect(resp, body, http.StatusCreated)
+
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/1?dry_run=true", nil)
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/1", nil)
+			expect(resp, body, http.StatusOK)
+			var deleted map[string]interface{}
+			json.Unmarshal(body, &deleted)
+			if deleted["trashed"] != true {
+				t.Errorf("Expected the user to be trashed, got %s", string(body))
+			}
+
+			// Gone from reads and the graph, but listed in the trash
+			resp, body = ts.doRequest("GET", "/api/v1/users/1", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("GET", "/api/v1/users", nil)
+			expect(resp, body, http.StatusOK)
+			if strings.Contains(string(body), "alice") {
+				t.Errorf("Expected the trashed user not to be listed, got %s", string(body))
+			}
+			if n := edges(); n > 0 {
+				t.Errorf("Expected the edge to the trashed user to be dormant, got %d edges", n)
+			}
+			items := trash("users")
+			if len(items) != 1 || items[0]["id"] != float64(1) || items[0]["deleted_at"] == nil {
+				t.Fatalf("Expected the user in the trash, got %v", items)
+			}
+			if data, _ := items[0]["data"].(map[string]interface{}); data["name"] != "alice" {
+				t.Errorf("Expected the trashed data, got %v", items[0])
+			}
+
+			resp, body = ts.doRequest("POST", "/api/v1/users/2/restore", nil)
+			expect(resp, body, http.StatusNotFound)
+			resp, body = ts.doRequest("POST", "/api/v1/users/1/restore", nil)
+			expect(resp, body, http.StatusOK)
+			if resp.Header.Get("ETag") != `"3"` {
+				t.Errorf("Expected the restore to be revision 3, got ETag %q", resp.Header.Get("ETag"))
+			}
+			resp, body = ts.doRequest("GET", "/api/v1/users/1", nil)
+			expect(resp, body, http.StatusOK)
+			if n := edges(); n == 0 {
+				t.Error("Expected the edge to the restored user back")
+			}
+			if items := trash("users"); len(items) != 0 {
+				t.Errorf("Expected an empty trash, got %v", items)
+			}
+
+			// Entity types not configured for it are deleted outright
+			resp, body = ts.doRequest("DELETE", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK)
+			if strings.Contains(string(body), "trashed") {
+				t.Errorf("Expected a hard delete, got %s", string(body))
+			}
+			if items := trash("docs"); len(items) != 0 {
+				t.Errorf("Expected no docs in the trash, got %v", items)
+			}
+
+			// Purging honours older_than
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/1", nil)
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/_trash?older_than=3600", nil)
+			expect(resp, body, http.StatusOK)
+			if items := trash("users"); len(items) != 1 {
+				t.Errorf("Expected the user to outlive the purge, got %v", items)
+			}
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/_trash", nil)
+			expect(resp, body, http.StatusOK)
+			var purged struct {
+				Purged []string `json:"purged"`
+			}
+			json.Unmarshal(body, &purged)
+			if len(purged.Purged) != 1 || purged.Purged[0] != "users:1" {
+				t.Errorf("Expected users:1 purged, got %s", string(body))
+			}
+			resp, body = ts.doRequest("POST", "/api/v1/users/1/restore", nil)
+			expect(resp, body, http.StatusNotFound)
+
+			// Trash, restore and purge all reach the change feed, the purge
+			// as a delete
+			for i, want := range []string{"users:1 create", "docs:1 create", "users:1 trash", "users:1 restore",
+				"docs:1 delete", "users:1 trash", "users:1 delete"} {
+				_, change := feed.next()
+				if got := fmt.Sprintf("%s:%d %s", change.Entity, change.ID, change.Operation); got != want {
+					t.Fatalf("Change %d: expected %s, got %s", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+// changeStream reads server-sent events from the change feed
+type changeStream struct {
+	t      *testing.T
+	resp   *http.Response
+	reader *bufio.Reader
+	cancel context.CancelFunc
+}
+
+// openChangeStream opens the change feed as an event stream
+func (ts *TestServer) openChangeStream(query string, headers map[string]string) *changeStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.ts.URL+"/api/v1/_changes"+query, nil)
+	if err != nil {
+		cancel()
+		ts.t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		ts.t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		cancel()
+		ts.t.Fatalf("Expected 200 opening the change feed, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		ts.t.Errorf("Expected an event stream, got %s", ct)
+	}
+	return &changeStream{t: ts.t, resp: resp, reader: bufio.NewReader(resp.Body), cancel: cancel}
+}
+
+// next reads the next change event, skipping comments
+func (cs *changeStream) next() (string, storage.Change) {
+	cs.t.Helper()
+
+	type event struct {
+		id     string
+		change storage.Change
+		err    error
+	}
+	events := make(chan event, 1)
+	go func() {
+		var e event
+		for {
+			line, err := cs.reader.ReadString('\n')
+			if err != nil {
+				e.err = err
+				events <- e
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "" && e.id != "":
+				events <- e
+				return
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				e.err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.change)
+			}
+		}
+	}()
+
+	select {
+	case e := <-events:
+		if e.err != nil {
+			cs.t.Fatal(e.err)
+		}
+		return e.id, e.change
+	case <-time.After(5 * time.Second):
+		cs.t.Fatal("Timed out waiting for a change")
+		return "", storage.Change{}
+	}
+}
+
+func (cs *changeStream) close() {
+	cs.cancel()
+	cs.resp.Body.Close()
+}
+
+// TestChangeFeed tests the change feed over server-sent events and WebSockets
+func TestChangeFeed(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			expect := func(resp *http.Response, body []byte, status int) {
+				t.Helper()
+				if resp.StatusCode != status {
+					t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+				}
+			}
+
+			// A live stream sees writes made after it opens
+			live := ts.openChangeStream("", nil)
+			defer live.close()
+
+			resp, body := ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{
+				"title":  "Draft",
+				"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1},
+			})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("PATCH", "/api/v1/docs/1", map[string]interface{}{"title": "Final"})
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("PUT", "/api/v1/docs/1", map[string]interface{}{"title": "Final"})
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("POST", "/api/v1/_batch", map[string]interface{}{
+				"operations": []map[string]interface{}{
+					{"op": "create", "entity": "docs", "data": map[string]interface{}{"title": "Batched"}},
+				},
+			})
+			expect(resp, body, http.StatusOK)
+			resp, body = ts.doRequest("DELETE", "/api/v1/docs/1", nil)
+			expect(resp, body, http.StatusOK)
+
+			want := []struct {
+				entity    string
+				id        int
+				operation string
+			}{
+				{"users", 1, "create"},
+				{"docs", 1, "create"},
+				{"docs", 1, "patch"},
+				{"docs", 1, "update"},
+				{"docs", 2, "create"},
+				{"docs", 1, "delete"},
+			}
+			var changes []storage.Change
+			for i, w := range want {
+				id, change := live.next()
+				if change.Entity != w.entity || change.ID != w.id || change.Operation != w.operation {
+					t.Fatalf("Change %d: expected %s %s:%d, got %+v", i, w.operation, w.entity, w.id, change)
+				}
+				if id != strconv.FormatInt(change.Seq, 10) {
+					t.Errorf("Expected event ID %d, got %s", change.Seq, id)
+				}
+				changes = append(changes, change)
+			}
+
+			// Each change carries what it changed, including graph edges
+			if created := changes[1]; created.Revision != 1 ||
+				len(created.EdgesAdded) != 1 || created.EdgesAdded[0].To != "users:1" ||
+				!reflect.DeepEqual(created.ChangedFields, []string{"author", "title"}) {
+				t.Errorf("Unexpected create: %+v", created)
+			}
+			if patched := changes[2]; patched.Revision != 2 || !reflect.DeepEqual(patched.ChangedFields, []string{"title"}) ||
+				len(patched.EdgesAdded)+len(patched.EdgesRemoved) != 0 {
+				t.Errorf("Unexpected patch: %+v", patched)
+			}
+			if updated := changes[3]; updated.Revision != 3 || !reflect.DeepEqual(updated.ChangedFields, []string{"author"}) ||
+				len(updated.EdgesRemoved) != 1 || updated.EdgesRemoved[0].To != "users:1" {
+				t.Errorf("Unexpected update: %+v", updated)
+			}
+			if deleted := changes[5]; !reflect.DeepEqual(deleted.ChangedFields, []string{"title"}) {
+				t.Errorf("Unexpected delete: %+v", deleted)
+			}
+
+			// Resuming replays the log after a sequence number, filtered by
+			// entity type
+			resumed := ts.openChangeStream(fmt.Sprintf("?entity=docs&since=%d", changes[1].Seq), nil)
+			defer resumed.close()
+			for _, expected := range changes[2:] {
+				if _, change := resumed.next(); change.Seq != expected.Seq {
+					t.Fatalf("Expected change %d on resume, got %+v", expected.Seq, change)
+				}
+			}
+
+			// A reconnecting event stream resumes from Last-Event-ID
+			reconnected := ts.openChangeStream("?entity=users,docs", map[string]string{
+				"Last-Event-ID": strconv.FormatInt(changes[4].Seq, 10),
+			})
+			defer reconnected.close()
+			if _, change := reconnected.next(); change.Seq != changes[5].Seq {
+				t.Fatalf("Expected change %d after reconnecting, got %+v", changes[5].Seq, change)
+			}
+
+			// Filtered streams skip other entity types, also when live
+			resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "bob"})
+			expect(resp, body, http.StatusCreated)
+			resp, body = ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{"title": "Later"})
+			expect(resp, body, http.StatusCreated)
+			if _, change := resumed.next(); change.Entity != "docs" || change.ID != 3 {
+				t.Errorf("Expected the filtered stream to skip to docs:3, got %+v", change)
+			}
+			if _, change := live.next(); change.Entity != "users" || change.ID != 2 {
+				t.Errorf("Expected users:2 on the live stream, got %+v", change)
+			}
+
+			// The same feed over a WebSocket
+			wsURL := "ws" + strings.TrimPrefix(ts.ts.URL, "http") + "/api/v1/_changes?entity=users&since=0"
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for _, id := range []int{1, 2} {
+				var change storage.Change
+				if err := conn.ReadJSON(&change); err != nil {
+					t.Fatal(err)
+				}
+				if change.Entity != "users" || change.ID != id || change.Operation != "create" {
+					t.Errorf("Expected users:%d over the WebSocket, got %+v", id, change)
+				}
+			}
+
+			resp, body = ts.doRequest("DELETE", "/api/v1/users/2", nil)
+			expect(resp, body, http.StatusOK)
+			var change storage.Change
+			if err := conn.ReadJSON(&change); err != nil {
+				t.Fatal(err)
+			}
+			if change.ID != 2 || change.Operation != "delete" {
+				t.Errorf("Expected the delete of users:2 over the WebSocket, got %+v", change)
+			}
+
+			resp, body = ts.doRequest("GET", "/api/v1/_changes?since=-1", nil)
+			expect(resp, body, http.StatusBadRequest)
+			resp, body = ts.doRequest("GET", "/api/v1/_changes?entity=bad!name", nil)
+			expect(resp, body, http.StatusBadRequest)
+		})
+	}
+}
+
+// TestChangeFeedConcurrentWrites tests that concurrent writes reach every
+// stream once each, in sequence order, including one that resumes while they
+// are still being made
+func TestChangeFeedConcurrentWrites(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			const writes = 30
+			live := ts.openChangeStream("", nil)
+			defer live.close()
+
+			var wg sync.WaitGroup
+			for i := 0; i < writes; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					entity := []string{"users", "docs", "tags"}[i%3]
+					ts.doRequest("POST", "/api/v1/"+entity, map[string]interface{}{"n": i})
+				}(i)
+			}
+			time.Sleep(5 * time.Millisecond)
+			resumed := ts.openChangeStream("?since=0", nil)
+			defer resumed.close()
+			wg.Wait()
+
+			for name, stream := range map[string]*changeStream{"live": live, "resumed": resumed} {
+				last := int64(0)
+				for i := 0; i < writes; i++ {
+					_, change := stream.next()
+					if change.Seq <= last {
+						t.Fatalf("%s: expected sequence numbers to increase, got %d after %d", name, change.Seq, last)
+					}
+					last = change.Seq
+				}
+			}
+		})
+	}
+}
+
+// TestChangeFeedOrigins tests which origins may open the change feed over a
+// WebSocket
+func TestChangeFeedOrigins(t *testing.T) {
+	ts := setupTestServerWithConfig(t, "jsonfile", func(cfg *config.Config) {
+		cfg.ChangeFeedOrigins = []string{"https://app.example.com"}
+	})
+	defer ts.cleanup()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.ts.URL, "http") + "/api/v1/_changes"
+	for _, tc := range []struct {
+		origin string
+		allow  bool
+	}{
+		{"", true},
+		{ts.ts.URL, true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://app.example.com", false},
+	} {
+		header := http.Header{}
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if tc.allow {
+			if err != nil {
+				t.Errorf("Expected origin %q to be allowed, got %v", tc.origin, err)
+				continue
+			}
+			conn.Close()
+			continue
+		}
+		if err == nil {
+			conn.Close()
+			t.Errorf("Expected origin %q to be refused", tc.origin)
+			continue
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for origin %q, got %v", tc.origin, resp)
+		}
+	}
+}
+
+// webhookReceiver records webhook deliveries, failing the first ones if told to
+type webhookReceiver struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(failures int) *webhookReceiver {
+	wr := &webhookReceiver{failures: failures}
+	wr.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &bytes.Buffer{}
+		body.ReadFrom(r.Body)
+
+		wr.mu.Lock()
+		defer wr.mu.Unlock()
+		wr.requests = append(wr.requests, r)
+		wr.bodies = append(wr.bodies, body.Bytes())
+		if wr.failures != 0 {
+			wr.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return wr
+}
+
+// received returns how many deliveries have arrived, failed or not
+func (wr *webhookReceiver) received() int {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return len(wr.requests)
+}
+
+func (wr *webhookReceiver) setFailures(failures int) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.failures = failures
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestWebhooks tests webhook registration, signed delivery, retries and dead letters
+func TestWebhooks(t *testing.T) {
+	ts := setupTestServerWithConfig(t, "jsonfile", func(cfg *config.Config) {
+		cfg.WebhooksFile = filepath.Join(cfg.BaseDir, "webhooks.json")
+		cfg.WebhookMaxAttempts = 3
+		cfg.WebhookRetryDelay = 20
+	})
+	defer ts.cleanup()
+
+	expect := func(resp *http.Response, body []byte, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("Expected %d, got %d: %s", status, resp.StatusCode, string(body))
+		}
+	}
+	register := func(hook map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		resp, body := ts.doRequest("POST", "/api/v1/_webhooks", hook)
+		expect(resp, body, http.StatusCreated)
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return result
+	}
+	deliveries := func(id, list string) []map[string]interface{} {
+		t.Helper()
+		resp, body := ts.doRequest("GET", "/api/v1/_webhooks/"+id+"/"+list, nil)
+		expect(resp, body, http.StatusOK)
+		var result struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(body, &result)
+		return result.Data
+	}
+
+	receiver := newWebhookReceiver(0)
+	defer receiver.server.Close()
+	flaky := newWebhookReceiver(2)
+	defer flaky.server.Close()
+	down := newWebhookReceiver(-1)
+	defer down.server.Close()
+
+	t.Run("Registration", func(t *testing.T) {
+		resp, body := ts.doRequest("POST", "/api/v1/_webhooks", map[string]interface{}{"url": "ftp://example.com"})
+		expect(resp, body, http.StatusBadRequest)
+		resp, body = ts.doRequest("POST", "/api/v1/_webhooks", map[string]interface{}{
+			"url": receiver.server.URL, "events": []string{"explode"},
+		})
+		expect(resp, body, http.StatusBadRequest)
+
+		// A generated secret is returned once, and never listed
+		hook := register(map[string]interface{}{"url": receiver.server.URL})
+		if secret, _ := hook["secret"].(string); len(secret) != 32 {
+			t.Errorf("Expected a generated secret, got %v", hook["secret"])
+		}
+		id := hook["id"].(string)
+
+		resp, body = ts.doRequest("GET", "/api/v1/_webhooks/"+id, nil)
+		expect(resp, body, http.StatusOK)
+		if strings.Contains(string(body), "secret") {
+			t.Errorf("Expected the secret to be hidden, got %s", string(body))
+		}
+		resp, body = ts.doRequest("DELETE", "/api/v1/_webhooks/"+id, nil)
+		expect(resp, body, http.StatusOK)
+		resp, body = ts.doRequest("GET", "/api/v1/_webhooks/"+id, nil)
+		expect(resp, body, http.StatusNotFound)
+	})
+
+	hook := register(map[string]interface{}{
+		"url":      receiver.server.URL,
+		"secret":   "s3cret",
+		"entities": []string{"docs"},
+		"events":   []string{"create", "edge_removed"},
+	})
+	flakyHook := register(map[string]interface{}{"url": flaky.server.URL, "entities": []string{"users"}})
+	downHook := register(map[string]interface{}{"url": down.server.URL, "entities": []string{"users"}})
+
+	resp, body := ts.doRequest("GET", "/api/v1/_webhooks", nil)
+	expect(resp, body, http.StatusOK)
+	if strings.Contains(string(body), "s3cret") {
+		t.Errorf("Expected secrets to be hidden, got %s", string(body))
+	}
+
+	// Subscriptions are saved, secrets and all, for the owner's eyes only
+	info, err := os.Stat(ts.cfg.WebhooksFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the webhooks file to be private, got %v", info.Mode().Perm())
+	}
+	saved, _ := os.ReadFile(ts.cfg.WebhooksFile)
+	if !strings.Contains(string(saved), "s3cret") || strings.Count(string(saved), `"url"`) != 3 {
+		t.Errorf("Expected three saved webhooks, got %s", string(saved))
+	}
+
+	resp, body = ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice"})
+	expect(resp, body, http.StatusCreated)
+	resp, body = ts.doRequest("POST", "/api/v1/docs", map[string]interface{}{
+		"title":  "Draft",
+		"author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1},
+	})
+	expect(resp, body, http.StatusCreated)
+	resp, body = ts.doRequest("PATCH", "/api/v1/docs/1", map[string]interface{}{"title": "Final"})
+	expect(resp, body, http.StatusOK)
+	resp, body = ts.doRequest("PATCH", "/api/v1/docs/1", map[string]interface{}{"author": nil})
+	expect(resp, body, http.StatusOK)
+
+	t.Run("SignedDelivery", func(t *testing.T) {
+		// The create, and the patch that dropped the author edge; not the
+		// user or the title patch
+		waitFor(t, "two deliveries", func() bool { return receiver.received() == 2 })
+		time.Sleep(50 * time.Millisecond)
+		if n := receiver.received(); n != 2 {
+			t.Fatalf("Expected 2 deliveries, got %d", n)
+		}
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		wantEvents := map[string][]string{"create": {"create"}, "patch": {"edge_removed"}}
+		for i, r := range receiver.requests {
+			body := receiver.bodies[i]
+			mac := hmac.New(sha256.New, []byte("s3cret"))
+			mac.Write(body)
+			if signature := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get("X-Olu-Signature") != signature {
+				t.Errorf("Delivery %d: expected signature %s, got %s", i, signature, r.Header.Get("X-Olu-Signature"))
+			}
+
+			var payload struct {
+				DeliveryID string         `json:"delivery_id"`
+				WebhookID  string         `json:"webhook_id"`
+				Events     []string       `json:"events"`
+				Change     storage.Change `json:"change"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.WebhookID != hook["id"] || payload.DeliveryID != r.Header.Get("X-Olu-Delivery") {
+				t.Errorf("Delivery %d: unexpected IDs in %s", i, string(body))
+			}
+			want := wantEvents[payload.Change.Operation]
+			delete(wantEvents, payload.Change.Operation)
+			if !reflect.DeepEqual(payload.Events, want) || payload.Change.Entity != "docs" {
+				t.Errorf("Delivery %d: expected events %v for docs, got %s", i, want, string(body))
+			}
+		}
+
+		log := deliveries(hook["id"].(string), "deliveries")
+		if len(log) != 2 || log[0]["change"].(map[string]interface{})["operation"] != "patch" {
+			t.Errorf("Expected two deliveries, newest first, got %+v", log)
+		}
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		// Two failures, then accepted on the third attempt
+		waitFor(t, "the retried delivery", func() bool {
+			log := deliveries(flakyHook["id"].(string), "deliveries")
+			return len(log) == 1 && log[0]["status"] == "delivered"
+		})
+		log := deliveries(flakyHook["id"].(string), "deliveries")
+		attempts := log[0]["attempts"].([]interface{})
+		if len(attempts) != 3 {
+			t.Fatalf("Expected 3 attempts, got %+v", attempts)
+		}
+		if status := attempts[0].(map[string]interface{})["status_code"]; status != float64(500) {
+			t.Errorf("Expected the first attempt to record a 500, got %v", status)
+		}
+
+		// The wait doubles between attempts
+		var times []time.Time
+		for _, attempt := range attempts {
+			at, _ := time.Parse(time.RFC3339Nano, attempt.(map[string]interface{})["time"].(string))
+			times = append(times, at)
+		}
+		if first, second := times[1].Sub(times[0]), times[2].Sub(times[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+			t.Errorf("Expected backoff of at least 20ms then 40ms, got %v then %v", first, second)
+		}
+	})
+
+	t.Run("DeadLetters", func(t *testing.T) {
+		id := downHook["id"].(string)
+		waitFor(t, "the dead letter", func() bool { return len(deliveries(id, "dead_letters")) == 1 })
+		if n := down.received(); n != 3 {
+			t.Errorf("Expected 3 attempts before giving up, got %d", n)
+		}
+		dead := deliveries(id, "dead_letters")[0]
+		if dead["status"] != "failed" {
+			t.Errorf("Expected a failed delivery, got %+v", dead)
+		}
+
+		resp, body := ts.doRequest("POST", "/api/v1/_webhooks/"+id+"/dead_letters/nope/redeliver", nil)
+		expect(resp, body, http.StatusNotFound)
+
+		// Once the receiver is back, a redelivery gets through
+		down.setFailures(0)
+		resp, body = ts.doRequest("POST", "/api/v1/_webhooks/"+id+"/dead_letters/"+dead["id"].(string)+"/redeliver", nil)
+		expect(resp, body, http.StatusAccepted)
+		waitFor(t, "the redelivery", func() bool {
+			log := deliveries(id, "deliveries")
+			return len(log) == 1 && log[0]["status"] == "delivered"
+		})
+		if dead := deliveries(id, "dead_letters"); len(dead) != 0 {
+			t.Errorf("Expected no dead letters left, got %+v", dead)
+		}
+	})
+
+	resp, body = ts.doRequest("GET", "/api/v1/_webhooks/missing/deliveries", nil)
+	expect(resp, body, http.StatusNotFound)
+}
+
+// TestWebhookInFlightLimit tests that no more than WebhookMaxInFlight
+// deliveries are attempted at once
+func TestWebhookInFlightLimit(t *testing.T) {
+	ts := setupTestServerWithConfig(t, "jsonfile", func(cfg *config.Config) {
+		cfg.WebhooksFile = ""
+		cfg.WebhookMaxAttempts = 1
+		cfg.WebhookMaxInFlight = 1
+	})
+	defer ts.cleanup()
+
+	var mu sync.Mutex
+	active, peak, received := 0, 0, 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		received++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	resp, body := ts.doRequest("POST", "/api/v1/_webhooks", map[string]interface{}{"url": receiver.URL})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+	}
+	for i := 0; i < 5; i++ {
+		ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": fmt.Sprintf("User%d", i)})
+	}
+
+	waitFor(t, "five deliveries", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received == 5
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 1 {
+		t.Errorf("Expected one delivery at a time, got %d at once", peak)
+	}
+}
+
+// TestWebhookBlockPrivate tests that WebhookBlockPrivate keeps deliveries
+// out of the server's own network
+func TestWebhookBlockPrivate(t *testing.T) {
+	ts := setupTestServerWithConfig(t, "jsonfile", func(cfg *config.Config) {
+		cfg.WebhooksFile = ""
+		cfg.WebhookMaxAttempts = 1
+		cfg.WebhookBlockPrivate = true
+	})
+	defer ts.cleanup()
+
+	receiver := newWebhookReceiver(0)
+	defer receiver.server.Close()
+
+	// Addresses are refused when registering...
+	for _, target := range []string{receiver.server.URL, "http://10.0.0.1/hook", "http://[::1]/hook", "http://169.254.169.254/"} {
+		resp, body := ts.doRequest("POST", "/api/v1/_webhooks", map[string]interface{}{"url": target})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to be refused, got %d: %s", target, resp.StatusCode, string(body))
+		}
+	}
+
+	// ...and host names when they are dialled
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(receiver.server.URL, "http://"))
+	resp, body := ts.doRequest("POST", "/api/v1/_webhooks", map[string]interface{}{"url": "http://localhost:" + port})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.StatusCode, string(body))
+	}
+	var hook map[string]interface{}
+	json.Unmarshal(body, &hook)
+	ts.doRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice"})
+
+	var dead []map[string]interface{}
+	waitFor(t, "the dead letter", func() bool {
+		_, body := ts.doRequest("GET", "/api/v1/_webhooks/"+hook["id"].(string)+"/dead_letters", nil)
+		var result struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(body, &result)
+		dead = result.Data
+		return len(dead) == 1
+	})
+	attempts := dead[0]["attempts"].([]interface{})
+	if msg, _ := attempts[0].(map[string]interface{})["error"].(string); !strings.Contains(msg, "private address") {
+		t.Errorf("Expected the delivery to be refused as private, got %q", msg)
+	}
+	if n := receiver.received(); n != 0 {
+		t.Errorf("Expected nothing delivered, got %d", n)
+	}
+}
+
+// TestPagination tests list pagination
+func TestPagination(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	// Create 10 test entities
+	for i := 0; i < 10; i++ {
+		data := map[string]interface{}{
+			"name": fmt.Sprintf("User%d", i),
+			"age":  20 + i,
+		}
+		ts.doRequest("POST", "/api/v1/users", data)
+	}
+
+	t.Run("Pagination parameters", func(t *testing.T) {
+		resp, body := ts.doRequest("GET", "/api/v1/users?page=1&per_page=5", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		pagination, ok := result["pagination"].(map[string]interface{})
+		if !ok {
+			t.Fatal("Expected pagination in response")
+		}
+
+		if pagination["page"].(float64) != 1 {
+			t.Errorf("Expected page 1, got %v", pagination["page"])
+		}
+		if pagination["per_page"].(float64) != 5 {
+			t.Errorf("Expected per_page 5, got %v", pagination["per_page"])
+		}
+
+		data, ok := result["data"].([]interface{})
+		if !ok {
+			t.Fatal("Expected data array")
+		}
+		if len(data) != 5 {
+			t.Errorf("Expected 5 items, got %d", len(data))
+		}
+	})
+}
+
+// TestListQuery tests filtering, sorting and field projection on list
+func TestListQuery(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.cleanup()
+
+	for i := 0; i < 6; i++ {
+		data := map[string]interface{}{
+			"name":  fmt.Sprintf("User%d", i),
+			"email": fmt.Sprintf("user%d@example.com", i),
+			"age":   20 + i*5,
+		}
+		ts.doRequest("POST", "/api/v1/users", data)
+	}
+
+	t.Run("Filter and sort", func(t *testing.T) {
+		resp, body := ts.doRequest("GET", "/api/v1/users?filter[age][gte]=30&sort=-age", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+		}
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		data := result["data"].([]interface{})
+		if len(data) != 4 {
+			t.Fatalf("Expected 4 items, got %d", len(data))
+		}
+		first := data[0].(map[string]interface{})
+		if first["age"].(float64) != 45 {
+			t.Errorf("Expected oldest user first, got age %v", first["age"])
+		}
+
+		pagination := result["pagination"].(map[string]interface{})
+		if pagination["total_items"].(float64) != 4 {
+			t.Errorf("Expected total_items 4, got %v", pagination["total_items"])
+		}
+	})
+
+	t.Run("Equality filter shorthand", func(t *testing.T) {
+		_, body := ts.doRequest("GET", "/api/v1/users?filter[name]=User2", nil)
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		data := result["data"].([]interface{})
+		if len(data) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(data))
+		}
+	})
+
+	t.Run("Field projection", func(t *testing.T) {
+		_, body := ts.doRequest("GET", "/api/v1/users?fields=name", nil)
+
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+
+		item := result["data"].([]interface{})[0].(map[string]interface{})
+		if _, ok := item["email"]; ok {
+			t.Error("Expected email to be projected out")
+		}
+		if item["name"] == nil || item["id"] == nil {
+			t.Errorf("Expected name and id, got %v", item)
+		}
+	})
+
+	t.Run("Invalid operator", func(t *testing.T) {
+		resp, _ := ts.doRequest("GET", "/api/v1/users?filter[age][between]=1", nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// TestSearch tests single-field and compound search on every backend
+func TestSearch(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithStore(t, storeType)
+			defer ts.cleanup()
+
+			users := []map[string]interface{}{
+				{"name": "Alice", "age": 34, "address": map[string]interface{}{"city": "Lisbon"}},
+				{"name": "alan", "age": 25, "address": map[string]interface{}{"city": "Porto"}},
+				{"name": "Bob", "age": 41, "address": map[string]interface{}{"city": "Lisbon"}},
+			}
+			for _, user := range users {
+				ts.doRequest("POST", "/api/v1/users", user)
+			}
+
+			t.Run("Field search", func(t *testing.T) {
+				resp, body := ts.doRequest("GET", "/api/v1/users/search?field=name&q=AL&match=starts&per_page=1", nil)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+				}
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				data := result["data"].([]interface{})
+				if len(data) != 1 {
+					t.Fatalf("Expected 1 item on the page, got %d", len(data))
+				}
+				pagination := result["pagination"].(map[string]interface{})
+				if pagination["total_items"].(float64) != 2 {
+					t.Errorf("Expected total_items 2, got %v", pagination["total_items"])
+				}
+			})
+
+			t.Run("Compound search", func(t *testing.T) {
+				query := map[string]interface{}{
+					"and": []interface{}{
+						map[string]interface{}{"field": "address.city", "op": "eq", "value": "Lisbon"},
+						map[string]interface{}{"or": []interface{}{
+							map[string]interface{}{"field": "name", "op": "contains", "value": "ali"},
+							map[string]interface{}{"field": "age", "op": "gt", "value": 40},
+						}},
+					},
+				}
+				resp, body := ts.doRequest("POST", "/api/v1/users/search", query)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+				}
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				data := result["data"].([]interface{})
+				if len(data) != 2 {
+					t.Fatalf("Expected 2 items, got %d", len(data))
+				}
+			})
+
+			t.Run("Invalid search", func(t *testing.T) {
+				resp, _ := ts.doRequest("GET", "/api/v1/users/search?field=name&q=a&match=fuzzy", nil)
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("Expected 400 for bad match type, got %d", resp.StatusCode)
+				}
+
+				resp, _ = ts.doRequest("POST", "/api/v1/users/search", map[string]interface{}{"field": "name", "op": "like"})
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("Expected 400 for bad operator, got %d", resp.StatusCode)
+				}
+			})
+		})
+	}
+}
+
+// TestFullTextSearch tests ranked search across entity types on both backends
+func TestFullTextSearch(t *testing.T) {
+	for _, storeType := range []string{"jsonfile", "sqlite"} {
+		t.Run(storeType, func(t *testing.T) {
+			ts := setupTestServerWithConfig(t, storeType, func(cfg *config.Config) {
+				cfg.FullTextEnabled = true
+			})
+			defer ts.cleanup()
+
+			ts.doRequest("POST", "/api/v1/articles", map[string]interface{}{
+				"title": "Graph databases",
+				"body":  "A graph database stores nodes and edges. Graph queries follow edges.",
+			})
+			ts.doRequest("POST", "/api/v1/articles", map[string]interface{}{
+				"title": "Relational databases",
+				"body":  "Tables, rows and joins.",
+			})
+			ts.doRequest("POST", "/api/v1/notes", map[string]interface{}{
+				"text": "Remember to read about graph theory",
+				"tags": []interface{}{"Graph", "todo"},
+			})
+
+			t.Run("Ranked hits across entities", func(t *testing.T) {
+				resp, body := ts.doRequest("GET", "/api/v1/_search?q=GRAPH", nil)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, string(body))
+				}
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				hits := result["hits"].([]interface{})
+				if len(hits) != 2 {
+					t.Fatalf("Expected 2 hits, got %d", len(hits))
+				}
+				first := hits[0].(map[string]interface{})
+				if first["entity"] != "articles" || first["id"].(float64) != 1 {
+					t.Errorf("Expected articles:1 to rank first, got %v:%v", first["entity"], first["id"])
+				}
+				if !strings.Contains(first["snippet"].(string), "<mark>Graph</mark>") {
+					t.Errorf("Expected a highlighted snippet, got %q", first["snippet"])
+				}
+				if first["data"].(map[string]interface{})["title"] != "Graph databases" {
+					t.Errorf("Expected hit data, got %v", first["data"])
+				}
+			})
+
+			t.Run("Every term must match", func(t *testing.T) {
+				_, body := ts.doRequest("GET", "/api/v1/_search?q=graph+theory", nil)
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				if result["count"].(float64) != 1 {
+					t.Errorf("Expected 1 hit, got %v", result["count"])
+				}
+			})
+
+			t.Run("Restrict entity types", func(t *testing.T) {
+				_, body := ts.doRequest("GET", "/api/v1/_search?q=graph&entities=notes", nil)
+
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+
+				hits := result["hits"].([]interface{})
+				if len(hits) != 1 || hits[0].(map[string]interface{})["entity"] != "notes" {
+					t.Errorf("Expected only the note, got %v", hits)
+				}
+			})
+
+			t.Run("Index follows writes", func(t *testing.T) {
+				ts.doRequest("PUT", "/api/v1/notes/1", map[string]interface{}{"text": "Nothing here"})
+				ts.doRequest("DELETE", "/api/v1/articles/2", nil)
+
+				_, body := ts.doRequest("GET", "/api/v1/_search?q=graph", nil)
+				var result map[string]interface{}
+				json.Unmarshal(body, &result)
+				if result["count"].(float64) != 1 {
+					t.Errorf("Expected 1 hit after update, got %v", result["count"])
+				}
+
+				_, body = ts.doRequest("GET", "/api/v1/_search?q=joins", nil)
+				json.Unmarshal(body, &result)
+				if result["count"].(float64) != 0 {
+					t.Errorf("Expected no hits after delete, got %v", result["count"])
+				}
+			})
+
+			t.Run("Missing query", func(t *testing.T) {
+				resp, _ := ts.doRequest("GET", "/api/v1/_search", nil)
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("Expected 400, got %d", resp.StatusCode)
+				}
+			})
+		})
+	}
 }
 
 // TestErrorHandling tests error responses
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/graph"
+	"github.com/ha1tch/olu/pkg/storage"
+)
+
+// trashPurgeInterval is the longest the background purge waits between runs
+const trashPurgeInterval = time.Hour
+
+// errSoftDeleteUnsupported is returned when a transaction cannot reach the
+// store's trash
+var errSoftDeleteUnsupported = errors.New("storage backend does not support soft delete in transactions")
+
+// softDeleter returns the store's trash support, if it has any
+func (s *Server) softDeleter() (storage.SoftDeleter, bool) {
+	sd, ok := s.storage.(storage.SoftDeleter)
+	return sd, ok
+}
+
+// txSoftDeleter returns the trash support to use within a transaction. As
+// with txRevisioner, a store without transactions is used directly.
+func (s *Server) txSoftDeleter(tx storage.Transaction) (storage.SoftDeleter, bool) {
+	if sd, ok := tx.(storage.SoftDeleter); ok {
+		return sd, true
+	}
+	if _, atomic := s.storage.(storage.Transactional); !atomic {
+		return s.softDeleter()
+	}
+	return nil, false
+}
+
+// trashInTx moves an entity to the trash through tx if it is still at the
+// expected revision, 0 meaning any
+func (s *Server) trashInTx(ctx context.Context, tx storage.Transaction, record recordFunc, entity string, id int, expected int) error {
+	sd, ok := s.txSoftDeleter(tx)
+	if !ok {
+		return errSoftDeleteUnsupported
+	}
+	
+	before, _ := tx.Get(ctx, entity, id)
+	if err := sd.Trash(ctx, entity, id, expected); err != nil {
+		return err
+	}
+	record(changeTrash, entity, id, 0, before, nil)
+	return nil
+}
+
+// softDeletes reports whether deletes of an entity type go to the trash
+func (s *Server) softDeletes(entity string) bool {
+	if _, ok := s.softDeleter(); !ok {
+		return false
+	}
+	if s.config.SoftDelete {
+		return true
+	}
+	for _, name := range s.config.SoftDeleteEntities {
+		if name == entity {
+			return true
+		}
+	}
+	return false
+}
+
+// trashEntity responds to a delete in soft-delete mode by moving the entity
+// to the trash. Only the entity itself goes; references to it are left in
+// place, so restoring it makes them whole again.
+func (s *Server) trashEntity(w http.ResponseWriter, r *http.Request, entity string, id int, expected int) {
+	node := graph.NodeID(entity, id)
+	
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"message":     fmt.Sprintf("%s with id %d would be moved to the trash", entity, id),
+			"dry_run":     true,
+			"would_trash": []string{node},
+		})
+		return
+	}
+	
+	err := s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		return s.trashInTx(r.Context(), tx, record, entity, id, expected)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.writePreconditionFailed(w, entity, id, err)
+		case errors.Is(err, storage.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, 
+				fmt.Sprintf("Resource of entity %s with id %d not found", entity, id))
+		default:
+			s.logger.Error().Err(err).Msg("Failed to trash entity")
+			s.writeError(w, http.StatusInternalServerError, "Failed to delete entity")
+		}
+		return
+	}
+	
+	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Trashed entity")
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("%s with id %d moved to the trash", entity, id),
+		"trashed": true,
+	})
+}
+
+// handleListTrash lists an entity type's trashed entities, most recently
+// deleted first
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	sd, ok := s.softDeleter()
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "Storage backend does not support soft delete")
+		return
+	}
+	
+	page, perPage := s.pageParams(r.URL.Query())
+	
+	trashed, err := sd.ListTrash(r.Context(), entity)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to list trash")
+		s.writeError(w, http.StatusInternalServerError, "Failed to list trash")
+		return
+	}
+	
+	items := make([]map[string]interface{}, len(trashed))
+	for i, item := range trashed {
+		items[i] = map[string]interface{}{
+			"id":         item.ID,
+			"deleted_at": item.DeletedAt,
+			"data":       item.Data,
+		}
+	}
+	
+	s.writeJSON(w, http.StatusOK, paginate(items, page, perPage))
+}
+
+// handleRestore brings a trashed entity back, together with the graph edges
+// that pointed at it
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	idStr := chi.URLParam(r, "id")
+	
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	
+	if _, ok := s.softDeleter(); !ok {
+		s.writeError(w, http.StatusNotImplemented, "Storage backend does not support soft delete")
+		return
+	}
+	
+	var data map[string]interface{}
+	var revision int
+	err = s.writeChanges(r.Context(), func(tx storage.Transaction, record recordFunc) error {
+		sd, ok := s.txSoftDeleter(tx)
+		if !ok {
+			return errSoftDeleteUnsupported
+		}
+		var err error
+		if data, err = sd.Restore(r.Context(), entity, id); err != nil {
+			return err
+		}
+		revision = s.txRevision(r.Context(), tx, entity, id)
+		record(changeRestore, entity, id, revision, nil, data)
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, 
+				fmt.Sprintf("Resource of entity %s with id %d not found in the trash", entity, id))
+		case errors.Is(err, storage.ErrAlreadyExists):
+			s.writeError(w, http.StatusConflict, 
+				fmt.Sprintf("Resource of entity %s with id %d already exists", entity, id))
+		default:
+			s.logger.Error().Err(err).Msg("Failed to restore entity")
+			s.writeError(w, http.StatusInternalServerError, "Failed to restore entity")
+		}
+		return
+	}
+	
+	s.logger.Info().Str("entity", entity).Int("id", id).Msg("Restored entity")
+	
+	setETag(w, revision)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Resource of entity %s with id %d restored", entity, id),
+		"data":    data,
+	})
+}
+
+// handlePurgeTrash permanently removes an entity type's trashed entities,
+// or with older_than only those trashed longer ago than that many seconds
+func (s *Server) handlePurgeTrash(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	if err := validateEntityName(entity); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	if _, ok := s.softDeleter(); !ok {
+		s.writeError(w, http.StatusNotImplemented, "Storage backend does not support soft delete")
+		return
+	}
+	
+	before := time.Now()
+	if val := r.URL.Query().Get("older_than"); val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds < 0 {
+			s.writeError(w, http.StatusBadRequest, "older_than must be a number of seconds")
+			return
+		}
+		before = before.Add(-time.Duration(seconds) * time.Second)
+	}
+	
+	purged, err := s.purgeTrash(r.Context(), entity, before)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to purge trash")
+		s.writeError(w, http.StatusInternalServerError, "Failed to purge trash")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Purged %d trashed %s", len(purged), entity),
+		"purged":  purged,
+	})
+}
+
+// purgeTrash permanently removes the entities trashed before a cutoff, of
+// one entity type or of all when entity is empty, recording each as deleted,
+// and returns their node IDs
+func (s *Server) purgeTrash(ctx context.Context, entity string, before time.Time) ([]string, error) {
+	var purged []string
+	err := s.writeChanges(ctx, func(tx storage.Transaction, record recordFunc) error {
+		sd, ok := s.txSoftDeleter(tx)
+		if !ok {
+			return errSoftDeleteUnsupported
+		}
+		items, err := sd.PurgeTrash(ctx, entity, before)
+		if err != nil {
+			return err
+		}
+		
+		purged = make([]string, len(items))
+		for i, item := range items {
+			purged[i] = graph.NodeID(item.Entity, item.ID)
+			record(changeDelete, item.Entity, item.ID, 0, item.Data, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	if len(purged) > 0 {
+		s.logger.Info().Int("count", len(purged)).Msg("Purged trash")
+	}
+	return purged, nil
+}
+
+// StartTrashPurge purges entities kept in the trash longer than
+// TRASH_RETENTION in the background until ctx is done. It does nothing when
+// the retention is 0 or the store has no trash.
+func (s *Server) StartTrashPurge(ctx context.Context) {
+	retention := time.Duration(s.config.TrashRetention) * time.Second
+	if _, ok := s.softDeleter(); !ok || retention <= 0 {
+		return
+	}
+	
+	interval := trashPurgeInterval
+	if retention < interval {
+		interval = retention
+	}
+	
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		
+		for {
+			if _, err := s.purgeTrash(ctx, "", time.Now().Add(-retention)); err != nil {
+				s.logger.Error().Err(err).Msg("Failed to purge trash")
+			}
+			
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,845 @@
+package server
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ha1tch/olu/pkg/config"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/rs/zerolog"
+)
+
+// Graph events, sent for changes that add or remove REF edges. The other
+// webhook events are the change feed operations.
+const (
+	webhookEdgeAdded   = "edge_added"
+	webhookEdgeRemoved = "edge_removed"
+)
+
+// webhookEvents lists the events a webhook can subscribe to
+var webhookEvents = map[string]bool{
+	changeCreate:       true,
+	changeUpdate:       true,
+	changePatch:        true,
+	changeDelete:       true,
+	changeSave:         true,
+	changeRevert:       true,
+	changeTrash:        true,
+	changeRestore:      true,
+	webhookEdgeAdded:   true,
+	webhookEdgeRemoved: true,
+}
+
+// Delivery states
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const (
+	// webhookLogSize is how many deliveries are kept per webhook, and how
+	// many dead letters
+	webhookLogSize = 1000
+	// webhookMaxRetryDelay caps the backoff between delivery attempts
+	webhookMaxRetryDelay = 10 * time.Minute
+	// webhookDefaultTimeout applies when WebhookTimeout is not set
+	webhookDefaultTimeout = 10 * time.Second
+	// webhookDefaultInFlight applies when WebhookMaxInFlight is not set
+	webhookDefaultInFlight = 10
+	// webhookQueueSize caps the deliveries waiting for an attempt; past it,
+	// new deliveries are dead-lettered straight away
+	webhookQueueSize = 10000
+	// webhookSignatureHeader carries the HMAC-SHA256 of the payload, keyed
+	// with the webhook's secret
+	webhookSignatureHeader = "X-Olu-Signature"
+)
+
+// webhook is a subscription to changes. Empty Entities or Events take all.
+type webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Entities  []string  `json:"entities"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// webhookPayload is the JSON body of a delivery
+type webhookPayload struct {
+	DeliveryID string         `json:"delivery_id"`
+	WebhookID  string         `json:"webhook_id"`
+	Events     []string       `json:"events"`
+	Change     storage.Change `json:"change"`
+}
+
+// webhookDelivery is one change sent to one webhook, with every attempt
+type webhookDelivery struct {
+	ID          string            `json:"id"`
+	WebhookID   string            `json:"webhook_id"`
+	Events      []string          `json:"events"`
+	Change      storage.Change    `json:"change"`
+	Status      string            `json:"status"`
+	Attempts    []deliveryAttempt `json:"attempts"`
+	CreatedAt   time.Time         `json:"created_at"`
+	NextAttempt *time.Time        `json:"next_attempt,omitempty"`
+	
+	body  []byte        // signed payload, the same for every attempt
+	state *webhookState // the webhook it goes to
+	round int           // attempts made since it was last queued afresh
+	due   time.Time     // when its next attempt falls due
+}
+
+// deliveryQueue is a min-heap of deliveries ordered by when they fall due
+type deliveryQueue []*webhookDelivery
+
+func (q deliveryQueue) Len() int            { return len(q) }
+func (q deliveryQueue) Less(i, j int) bool  { return q[i].due.Before(q[j].due) }
+func (q deliveryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *deliveryQueue) Push(x interface{}) { *q = append(*q, x.(*webhookDelivery)) }
+func (q *deliveryQueue) Pop() interface{} {
+	old := *q
+	delivery := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return delivery
+}
+
+// deliveryAttempt is one POST of a delivery to its webhook
+type deliveryAttempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   float64   `json:"duration"` // seconds
+}
+
+// webhookState is a webhook with its recent deliveries and dead letters,
+// oldest first
+type webhookState struct {
+	hook        webhook
+	deliveries  []*webhookDelivery
+	deadLetters []*webhookDelivery
+	deleted     bool
+}
+
+// webhookManager delivers changes to the registered webhooks. Deliveries
+// wait in a queue for a worker, started by run, which makes their attempts
+// as they fall due, a limited number at a time. Each delivery is retried with
+// exponential backoff until it succeeds or runs out of attempts, when it
+// becomes a dead letter.
+//
+// Only the subscriptions are saved, to WebhooksFile. Deliveries, dead letters
+// and the queue are kept in memory, so they are lost on restart, including
+// deliveries still waiting to be retried.
+type webhookManager struct {
+	config   *config.Config
+	client   *http.Client
+	logger   zerolog.Logger
+	hooks    map[string]*webhookState
+	queue    deliveryQueue // deliveries waiting for their next attempt
+	inFlight int           // attempts being made
+	wake     chan struct{} // tells the worker the queue or inFlight changed
+	mu       sync.Mutex
+}
+
+// errWebhookQueueFull refuses a delivery while webhookQueueSize are waiting
+var errWebhookQueueFull = errors.New("webhook delivery queue is full")
+
+// errPrivateAddress refuses a webhook target in the server's own network
+var errPrivateAddress = errors.New("webhook target is a private address")
+
+// newWebhookManager creates a webhook manager with no subscriptions
+func newWebhookManager(cfg *config.Config, logger zerolog.Logger) *webhookManager {
+	timeout := webhookDefaultTimeout
+	if cfg.WebhookTimeout > 0 {
+		timeout = time.Duration(cfg.WebhookTimeout) * time.Second
+	}
+	
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.WebhookBlockPrivate {
+		// Check the address actually dialled, so neither DNS nor redirects
+		// can lead a delivery into the private network. A proxy would hide
+		// that address, so none is used.
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refusePrivate}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+	
+	return &webhookManager{
+		config: cfg,
+		client: &http.Client{Timeout: timeout, Transport: transport},
+		logger: logger,
+		hooks:  make(map[string]*webhookState),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// privateIP reports whether an address is loopback, private, link-local or
+// otherwise not one a webhook on the internet would have
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// refusePrivate is a net.Dialer Control function refusing private addresses
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// wants returns the events of a change that a webhook subscribes to
+func (h webhook) wants(change storage.Change) []string {
+	if len(h.Entities) > 0 && !containsString(h.Entities, change.Entity) {
+		return nil
+	}
+	
+	events := []string{change.Operation}
+	if len(change.EdgesAdded) > 0 {
+		events = append(events, webhookEdgeAdded)
+	}
+	if len(change.EdgesRemoved) > 0 {
+		events = append(events, webhookEdgeRemoved)
+	}
+	if len(h.Events) == 0 {
+		return events
+	}
+	
+	var matched []string
+	for _, event := range events {
+		if containsString(h.Events, event) {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+// withoutSecret returns the webhook as it is shown once registered
+func (h webhook) withoutSecret() webhook {
+	h.Secret = ""
+	return h
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns the signature header value for a payload
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// load reads the saved subscriptions, returning how many there are. A
+// missing file means none.
+func (m *webhookManager) load() (int, error) {
+	if m.config.WebhooksFile == "" {
+		return 0, nil
+	}
+	
+	data, err := os.ReadFile(m.config.WebhooksFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	
+	var hooks []webhook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", m.config.WebhooksFile, err)
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	for _, hook := range hooks {
+		m.hooks[hook.ID] = &webhookState{hook: hook}
+	}
+	return len(hooks), nil
+}
+
+// saveLocked writes the subscriptions to WebhooksFile, which holds their
+// secrets and so is readable by the owner only. The caller must hold the
+// lock.
+func (m *webhookManager) saveLocked() error {
+	if m.config.WebhooksFile == "" {
+		return nil
+	}
+	
+	data, err := json.MarshalIndent(m.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	tmp := m.config.WebhooksFile + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(m.config.WebhooksFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.config.WebhooksFile)
+}
+
+// listLocked returns the webhooks, oldest first. The caller must hold the
+// lock.
+func (m *webhookManager) listLocked() []webhook {
+	hooks := make([]webhook, 0, len(m.hooks))
+	for _, state := range m.hooks {
+		hooks = append(hooks, state.hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks
+}
+
+// register adds a webhook and saves the subscriptions
+func (m *webhookManager) register(hook webhook) (webhook, error) {
+	id, err := newRandomID("webhook ID")
+	if err != nil {
+		return webhook{}, err
+	}
+	hook.ID = id
+	hook.CreatedAt = time.Now().UTC()
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.hooks[id] = &webhookState{hook: hook}
+	if err := m.saveLocked(); err != nil {
+		delete(m.hooks, id)
+		return webhook{}, fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	return hook, nil
+}
+
+// list returns the webhooks, oldest first, without their secrets
+func (m *webhookManager) list() []webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	hooks := m.listLocked()
+	for i := range hooks {
+		hooks[i] = hooks[i].withoutSecret()
+	}
+	return hooks
+}
+
+// get returns a webhook without its secret
+func (m *webhookManager) get(id string) (webhook, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	state, exists := m.hooks[id]
+	if !exists {
+		return webhook{}, false
+	}
+	return state.hook.withoutSecret(), true
+}
+
+// remove deletes a webhook and saves the subscriptions. Deliveries still
+// queued for it are dropped when they fall due.
+func (m *webhookManager) remove(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	state, exists := m.hooks[id]
+	if !exists {
+		return false, nil
+	}
+	state.deleted = true
+	delete(m.hooks, id)
+	return true, m.saveLocked()
+}
+
+// dispatch queues a change for delivery to every webhook that wants it
+func (m *webhookManager) dispatch(change storage.Change) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	for _, state := range m.hooks {
+		events := state.hook.wants(change)
+		if len(events) == 0 {
+			continue
+		}
+		
+		id, err := newRandomID("delivery ID")
+		if err != nil {
+			m.logger.Error().Err(err).Msg("Failed to create webhook delivery")
+			continue
+		}
+		body, err := json.Marshal(webhookPayload{
+			DeliveryID: id,
+			WebhookID:  state.hook.ID,
+			Events:     events,
+			Change:     change,
+		})
+		if err != nil {
+			m.logger.Error().Err(err).Msg("Failed to marshal webhook payload")
+			continue
+		}
+		
+		delivery := &webhookDelivery{
+			ID:        id,
+			WebhookID: state.hook.ID,
+			Events:    events,
+			Change:    change,
+			Status:    deliveryPending,
+			CreatedAt: time.Now().UTC(),
+			body:      body,
+			state:     state,
+		}
+		state.deliveries = appendBounded(state.deliveries, delivery)
+		if err := m.enqueueLocked(delivery, time.Now()); err != nil {
+			m.failLocked(delivery, deliveryAttempt{Time: time.Now().UTC(), Error: err.Error()})
+		}
+	}
+}
+
+// appendBounded appends a delivery, dropping the oldest past webhookLogSize
+func appendBounded(deliveries []*webhookDelivery, delivery *webhookDelivery) []*webhookDelivery {
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > webhookLogSize {
+		deliveries = deliveries[len(deliveries)-webhookLogSize:]
+	}
+	return deliveries
+}
+
+// enqueueLocked queues a delivery's next attempt for a given time, unless
+// the queue is full. The caller must hold the lock.
+func (m *webhookManager) enqueueLocked(delivery *webhookDelivery, due time.Time) error {
+	if len(m.queue) >= webhookQueueSize {
+		return errWebhookQueueFull
+	}
+	delivery.due = due
+	heap.Push(&m.queue, delivery)
+	m.signal()
+	return nil
+}
+
+// failLocked dead-letters a delivery after its last attempt. The caller must
+// hold the lock.
+func (m *webhookManager) failLocked(delivery *webhookDelivery, last deliveryAttempt) {
+	delivery.Attempts = append(delivery.Attempts, last)
+	delivery.Status = deliveryFailed
+	delivery.NextAttempt = nil
+	delivery.state.deadLetters = appendBounded(delivery.state.deadLetters, delivery)
+	m.logger.Warn().Str("webhook", delivery.WebhookID).Str("delivery", delivery.ID).
+		Str("error", last.Error).Msg("Webhook delivery failed")
+}
+
+// signal wakes the worker, if it is not already due to wake
+func (m *webhookManager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// maxInFlight returns how many attempts may be made at once
+func (m *webhookManager) maxInFlight() int {
+	if m.config.WebhookMaxInFlight > 0 {
+		return m.config.WebhookMaxInFlight
+	}
+	return webhookDefaultInFlight
+}
+
+// retryDelay returns how long to wait after a delivery's nth failed attempt
+// in a round: WebhookRetryDelay, doubling with each attempt, up to
+// webhookMaxRetryDelay
+func (m *webhookManager) retryDelay(n int) time.Duration {
+	delay := time.Duration(m.config.WebhookRetryDelay) * time.Millisecond
+	for i := 1; i < n && delay < webhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetryDelay {
+		delay = webhookMaxRetryDelay
+	}
+	return delay
+}
+
+// run makes the queued delivery attempts as they fall due, at most
+// WebhookMaxInFlight at a time, until ctx is done. Attempts still being made
+// then are cancelled and their deliveries left pending.
+func (m *webhookManager) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	
+	for {
+		m.mu.Lock()
+		wait := time.Duration(-1)
+		for len(m.queue) > 0 && m.inFlight < m.maxInFlight() {
+			if until := time.Until(m.queue[0].due); until > 0 {
+				wait = until
+				break
+			}
+			delivery := heap.Pop(&m.queue).(*webhookDelivery)
+			m.inFlight++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.attempt(ctx, delivery)
+			}()
+		}
+		m.mu.Unlock()
+		
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait >= 0 {
+			timer.Reset(wait)
+		}
+		
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt posts a delivery once, then marks it delivered, queues its next
+// attempt with exponential backoff, or dead-letters it once
+// WebhookMaxAttempts have failed in a row. A delivery to a removed webhook
+// is dropped instead.
+func (m *webhookManager) attempt(ctx context.Context, delivery *webhookDelivery) {
+	m.mu.Lock()
+	deleted := delivery.state.deleted
+	m.mu.Unlock()
+	
+	var result deliveryAttempt
+	if !deleted {
+		result = m.post(ctx, delivery.state.hook, delivery)
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.signal()
+	m.inFlight--
+	
+	switch {
+	case deleted:
+		delivery.Status = deliveryFailed
+		delivery.NextAttempt = nil
+		return
+	case ctx.Err() != nil:
+		// Shutting down; the attempt was cut short, so it does not count
+		return
+	}
+	
+	delivery.round++
+	if result.Error == "" {
+		delivery.Attempts = append(delivery.Attempts, result)
+		delivery.Status = deliveryDelivered
+		delivery.NextAttempt = nil
+		return
+	}
+	if delivery.round >= m.config.WebhookMaxAttempts {
+		m.failLocked(delivery, result)
+		return
+	}
+	
+	next := time.Now().Add(m.retryDelay(delivery.round)).UTC()
+	if err := m.enqueueLocked(delivery, next); err != nil {
+		m.failLocked(delivery, result)
+		return
+	}
+	delivery.Attempts = append(delivery.Attempts, result)
+	delivery.NextAttempt = &next
+}
+
+// post makes one delivery attempt. Any 2xx response accepts the delivery.
+func (m *webhookManager) post(ctx context.Context, hook webhook, delivery *webhookDelivery) deliveryAttempt {
+	start := time.Now()
+	result := deliveryAttempt{Time: start.UTC()}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "olu/"+config.Version)
+	req.Header.Set("X-Olu-Delivery", delivery.ID)
+	req.Header.Set("X-Olu-Event", strings.Join(delivery.Events, ","))
+	req.Header.Set(webhookSignatureHeader, sign(hook.Secret, delivery.body))
+	
+	resp, err := m.client.Do(req)
+	result.Duration = time.Since(start).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Error = fmt.Sprintf("receiver responded %s", resp.Status)
+	}
+	return result
+}
+
+// snapshot copies deliveries, newest first, for a response
+func snapshot(deliveries []*webhookDelivery) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(deliveries))
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		delivery := deliveries[i]
+		item := map[string]interface{}{
+			"id":         delivery.ID,
+			"webhook_id": delivery.WebhookID,
+			"events":     delivery.Events,
+			"change":     delivery.Change,
+			"status":     delivery.Status,
+			"attempts":   append([]deliveryAttempt{}, delivery.Attempts...),
+			"created_at": delivery.CreatedAt,
+		}
+		if delivery.NextAttempt != nil {
+			item["next_attempt"] = *delivery.NextAttempt
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// deliveries returns a webhook's delivery log, or its dead letters, newest
+// first
+func (m *webhookManager) deliveries(id string, deadLetters bool) ([]map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	state, exists := m.hooks[id]
+	if !exists {
+		return nil, false
+	}
+	if deadLetters {
+		return snapshot(state.deadLetters), true
+	}
+	return snapshot(state.deliveries), true
+}
+
+// redeliver takes a dead letter off the list and queues it for a fresh round
+// of attempts. It fails with errWebhookQueueFull, leaving the dead letter in
+// place, when the queue is full.
+func (m *webhookManager) redeliver(id string, deliveryID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	state, exists := m.hooks[id]
+	if !exists {
+		return false, fmt.Errorf("webhook %s not found", id)
+	}
+	for i, delivery := range state.deadLetters {
+		if delivery.ID == deliveryID {
+			if err := m.enqueueLocked(delivery, time.Now()); err != nil {
+				return false, err
+			}
+			state.deadLetters = append(state.deadLetters[:i:i], state.deadLetters[i+1:]...)
+			delivery.Status = deliveryPending
+			delivery.round = 0
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LoadWebhooks loads the saved webhook subscriptions, returning how many
+// were loaded
+func (s *Server) LoadWebhooks() (int, error) {
+	return s.webhooks.load()
+}
+
+// StartWebhooks starts delivering webhooks in the background until ctx is
+// done. Deliveries are queued but not sent until it is called.
+func (s *Server) StartWebhooks(ctx context.Context) {
+	go s.webhooks.run(ctx)
+}
+
+// handleCreateWebhook registers a webhook. Without a secret one is generated;
+// either way the secret is only returned here.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	
+	target, err := url.Parse(hook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		s.writeError(w, http.StatusBadRequest, "Invalid url: expected an absolute http or https URL")
+		return
+	}
+	// Host names are checked when they are dialled, as they may resolve
+	// differently by then
+	if ip := net.ParseIP(target.Hostname()); ip != nil && s.config.WebhookBlockPrivate && privateIP(ip) {
+		s.writeError(w, http.StatusBadRequest, "Invalid url: private addresses are not allowed")
+		return
+	}
+	for _, entity := range hook.Entities {
+		if err := validateEntityName(entity); err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	for _, event := range hook.Events {
+		if !webhookEvents[event] {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event: %s", event))
+			return
+		}
+	}
+	if hook.Entities == nil {
+		hook.Entities = []string{}
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	
+	if hook.Secret == "" {
+		secret, err := newRandomID("webhook secret")
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		hook.Secret = secret
+	}
+	
+	hook, err = s.webhooks.register(hook)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to register webhook")
+		s.writeError(w, http.StatusInternalServerError, "Failed to register webhook")
+		return
+	}
+	
+	s.logger.Info().Str("webhook", hook.ID).Str("url", hook.URL).Msg("Registered webhook")
+	s.writeJSON(w, http.StatusCreated, hook)
+}
+
+// handleListWebhooks lists the registered webhooks
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks := s.webhooks.list()
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks": hooks,
+		"count":    len(hooks),
+	})
+}
+
+// handleGetWebhook returns a webhook
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	hook, exists := s.webhooks.get(id)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Webhook %s not found", id))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, hook)
+}
+
+// handleDeleteWebhook removes a webhook
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	removed, err := s.webhooks.remove(id)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to save webhooks")
+		s.writeError(w, http.StatusInternalServerError, "Failed to save webhooks")
+		return
+	}
+	if !removed {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Webhook %s not found", id))
+		return
+	}
+	
+	s.logger.Info().Str("webhook", id).Msg("Removed webhook")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Webhook %s removed", id),
+	})
+}
+
+// handleWebhookDeliveries lists a webhook's recent deliveries, newest first
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	s.writeDeliveries(w, r, false)
+}
+
+// handleWebhookDeadLetters lists a webhook's deliveries that ran out of
+// attempts, newest first
+func (s *Server) handleWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	s.writeDeliveries(w, r, true)
+}
+
+func (s *Server) writeDeliveries(w http.ResponseWriter, r *http.Request, deadLetters bool) {
+	id := chi.URLParam(r, "id")
+	page, perPage := s.pageParams(r.URL.Query())
+	
+	items, exists := s.webhooks.deliveries(id, deadLetters)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Webhook %s not found", id))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, paginate(items, page, perPage))
+}
+
+// handleRedeliver retries a dead letter
+func (s *Server) handleRedeliver(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	deliveryID := chi.URLParam(r, "delivery")
+	
+	found, err := s.webhooks.redeliver(id, deliveryID)
+	if errors.Is(err, errWebhookQueueFull) {
+		s.writeError(w, http.StatusServiceUnavailable, "Webhook delivery queue is full")
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("Webhook %s not found", id))
+		return
+	}
+	if !found {
+		s.writeError(w, http.StatusNotFound,
+			fmt.Sprintf("Delivery %s is not a dead letter of webhook %s", deliveryID, id))
+		return
+	}
+	
+	s.writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": fmt.Sprintf("Delivery %s queued for redelivery", deliveryID),
+	})
+}
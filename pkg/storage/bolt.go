@@ -0,0 +1,815 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/olu/pkg/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Top-level buckets of a BoltStore
+var (
+	// entitiesBucket holds one nested bucket per entity type, keyed by
+	// big-endian ID. The nested bucket's sequence is the entity's ID counter.
+	entitiesBucket = []byte("entities")
+	
+	// edgesOutBucket maps "source\x00id\x00path" to "target\x00id\x00"
+	edgesOutBucket = []byte("edges_out")
+	
+	// edgesInBucket indexes edges by target: "target\x00id\x00source\x00id\x00path"
+	edgesInBucket = []byte("edges_in")
+	
+	// schemasBucket maps entity type to its JSON schema
+	schemasBucket = []byte("schemas")
+	
+	// revisionsBucket maps "entity\x00id\x00" to the entity's big-endian
+	// revision
+	revisionsBucket = []byte("revisions")
+	
+	// historyBucket maps "entity\x00id\x00" plus a big-endian revision to
+	// that version of the entity
+	historyBucket = []byte("history")
+	
+	// trashBucket maps "entity\x00id\x00" to a trashed entity
+	trashBucket = []byte("trash")
+	
+	// dormantEdgesBucket holds "target\x00id\x00source\x00id\x00" for every
+	// entity that pointed at a trashed one
+	dormantEdgesBucket = []byte("dormant_edges")
+	
+	// changesBucket maps a big-endian sequence number to a change as JSON
+	changesBucket = []byte("changes")
+)
+
+// BoltStore implements Store interface using an embedded bbolt database.
+// Everything lives in a single file, and every write is a durable,
+// crash-safe transaction.
+type BoltStore struct {
+	db     *bolt.DB
+	path   string
+	config BoltConfig
+}
+
+// BoltConfig holds bbolt-specific configuration
+type BoltConfig struct {
+	Path        string
+	LockTimeout time.Duration // How long to wait for another process to release the file
+	NoSync      bool          // Skip fsync on commit; faster but not crash-safe
+}
+
+// NewBoltStore creates a new bbolt-based storage
+func NewBoltStore(path string, config BoltConfig) (*BoltStore, error) {
+	if path == "" {
+		path = "olu.bolt"
+	}
+	
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: config.LockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.NoSync = config.NoSync
+	
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{entitiesBucket, edgesOutBucket, edgesInBucket, schemasBucket, revisionsBucket, historyBucket, trashBucket, dormantEdgesBucket, changesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	
+	return &BoltStore{db: db, path: path, config: config}, nil
+}
+
+// Info returns store information
+func (s *BoltStore) Info() StoreInfo {
+	return StoreInfo{
+		Type:                "bolt",
+		Version:             "1.0.0",
+		SupportsSearch:      true,
+		SupportsBatch:       true,
+		SupportsTransaction: true,
+	}
+}
+
+// NextID reserves the next ID in an entity's sequence
+func (s *BoltStore) NextID(ctx context.Context, entity string) (int, error) {
+	var id int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := entityBucket(tx, entity, true)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		id = int(seq)
+		return err
+	})
+	return id, err
+}
+
+// Create inserts a new entity with auto-generated ID
+func (s *BoltStore) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	var id int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		id, err = s.create(tx, entity, data)
+		return err
+	})
+	return id, err
+}
+
+// Get retrieves an entity by ID
+func (s *BoltStore) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		result, err = s.get(tx, entity, id)
+		return err
+	})
+	return result, err
+}
+
+// Update replaces an entity completely
+func (s *BoltStore) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.update(tx, entity, id, data)
+	})
+}
+
+// Patch partially updates an entity
+func (s *BoltStore) Patch(ctx context.Context, entity string, id int, updates map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.patch(tx, entity, id, updates)
+	})
+}
+
+// Delete removes an entity
+func (s *BoltStore) Delete(ctx context.Context, entity string, id int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.delete(tx, entity, id)
+	})
+}
+
+// Save creates an entity with a specific ID (fails if exists)
+func (s *BoltStore) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.save(tx, entity, id, data)
+	})
+}
+
+// List returns all entities of a given type in ID order
+func (s *BoltStore) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		results, err = s.list(tx, entity)
+		return err
+	})
+	return results, err
+}
+
+// Exists checks if an entity exists
+func (s *BoltStore) Exists(ctx context.Context, entity string, id int) bool {
+	exists := false
+	s.db.View(func(tx *bolt.Tx) error {
+		exists = s.exists(tx, entity, id)
+		return nil
+	})
+	return exists
+}
+
+// ListEntities returns every entity type that has a bucket
+func (s *BoltStore) ListEntities(ctx context.Context) ([]string, error) {
+	entities := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entitiesBucket).ForEach(func(k, v []byte) error {
+			if v == nil {
+				entities = append(entities, string(k))
+			}
+			return nil
+		})
+	})
+	return entities, err
+}
+
+// BatchCreate inserts several entities in a single transaction, returning
+// their IDs in order. Either every entity is created or none is.
+func (s *BoltStore) BatchCreate(ctx context.Context, entity string, items []map[string]interface{}) ([]int, error) {
+	ids := make([]int, 0, len(items))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for i, item := range items {
+			id, err := s.create(tx, entity, item)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// BatchSave saves several entities under their own IDs in a single
+// transaction. If any of them already exists, nothing is saved.
+func (s *BoltStore) BatchSave(ctx context.Context, entity string, items []map[string]interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for i, item := range items {
+			id := entityID(item)
+			if id <= 0 {
+				return fmt.Errorf("item %d: %w", i, ErrInvalidID)
+			}
+			if err := s.save(tx, entity, id, item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// BatchDelete removes several entities in a single transaction. If any of
+// them does not exist, nothing is deleted.
+func (s *BoltStore) BatchDelete(ctx context.Context, entity string, ids []int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if err := s.delete(tx, entity, id); err != nil {
+				return fmt.Errorf("id %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSchema creates or replaces the JSON schema of an entity type
+func (s *BoltStore) SaveSchema(ctx context.Context, entity string, schema map[string]interface{}) error {
+	if entity == "" {
+		return ErrInvalidEntity
+	}
+	jsonData, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schemasBucket).Put([]byte(entity), jsonData)
+	})
+}
+
+// DeleteSchema removes the JSON schema of an entity type
+func (s *BoltStore) DeleteSchema(ctx context.Context, entity string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schemasBucket)
+		if bucket.Get([]byte(entity)) == nil {
+			return fmt.Errorf("%w: schema for %s", ErrNotFound, entity)
+		}
+		return bucket.Delete([]byte(entity))
+	})
+}
+
+// ListSchemas returns every stored JSON schema keyed by entity type
+func (s *BoltStore) ListSchemas(ctx context.Context) (map[string]map[string]interface{}, error) {
+	schemas := make(map[string]map[string]interface{})
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(schemasBucket).ForEach(func(k, v []byte) error {
+			var schema map[string]interface{}
+			if err := json.Unmarshal(v, &schema); err != nil {
+				return fmt.Errorf("failed to unmarshal schema for %s: %w", k, err)
+			}
+			schemas[string(k)] = schema
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// entityBucket returns the bucket holding an entity type, creating it if
+// asked to. Without create, a missing bucket is returned as nil.
+func entityBucket(tx *bolt.Tx, entity string, create bool) (*bolt.Bucket, error) {
+	root := tx.Bucket(entitiesBucket)
+	if !create {
+		return root.Bucket([]byte(entity)), nil
+	}
+	if entity == "" {
+		return nil, ErrInvalidEntity
+	}
+	return root.CreateBucketIfNotExists([]byte(entity))
+}
+
+// idKey encodes an ID so that keys sort in ID order
+func idKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// nodeKey encodes a node as "entity\x00id\x00", so that a node's key is never
+// a prefix of another node's key
+func nodeKey(entity string, id int) string {
+	return entity + "\x00" + strconv.Itoa(id) + "\x00"
+}
+
+// put marshals and stores an entity, setting its id
+func (s *BoltStore) put(b *bolt.Bucket, id int, data map[string]interface{}) (map[string]interface{}, error) {
+	// Create a copy to avoid mutating input
+	dataCopy := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		dataCopy[k] = v
+	}
+	dataCopy["id"] = id
+	
+	jsonData, err := json.Marshal(dataCopy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	
+	if err := b.Put(idKey(id), jsonData); err != nil {
+		return nil, fmt.Errorf("failed to store entity: %w", err)
+	}
+	return dataCopy, nil
+}
+
+// create inserts a new entity with the next ID in the entity's sequence
+func (s *BoltStore) create(tx *bolt.Tx, entity string, data map[string]interface{}) (int, error) {
+	b, err := entityBucket(tx, entity, true)
+	if err != nil {
+		return 0, err
+	}
+	
+	seq, err := b.NextSequence()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next ID: %w", err)
+	}
+	id := int(seq)
+	
+	stored, err := s.put(b, id, data)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordBoltVersion(tx, entity, id, 1, stored); err != nil {
+		return 0, err
+	}
+	
+	if err := syncBoltEdges(tx, entity, id, stored); err != nil {
+		return 0, fmt.Errorf("failed to sync graph: %w", err)
+	}
+	return id, nil
+}
+
+// get loads an entity
+func (s *BoltStore) get(tx *bolt.Tx, entity string, id int) (map[string]interface{}, error) {
+	b, _ := entityBucket(tx, entity, false)
+	if b == nil {
+		return nil, fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	
+	value := b.Get(idKey(id))
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	
+	var result map[string]interface{}
+	if err := json.Unmarshal(value, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	return result, nil
+}
+
+// update replaces an entity's data and its graph edges
+func (s *BoltStore) update(tx *bolt.Tx, entity string, id int, data map[string]interface{}) error {
+	if !s.exists(tx, entity, id) {
+		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	
+	// Keep the version being replaced if it predates history
+	revision, err := archiveBoltVersion(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	
+	b, _ := entityBucket(tx, entity, false)
+	stored, err := s.put(b, id, data)
+	if err != nil {
+		return err
+	}
+	if err := recordBoltVersion(tx, entity, id, revision+1, stored); err != nil {
+		return err
+	}
+	
+	if err := syncBoltEdges(tx, entity, id, stored); err != nil {
+		return fmt.Errorf("failed to sync graph: %w", err)
+	}
+	return nil
+}
+
+// patch merges updates into an entity. Null values remove fields.
+func (s *BoltStore) patch(tx *bolt.Tx, entity string, id int, updates map[string]interface{}) error {
+	existing, err := s.get(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	
+	for key, value := range updates {
+		if key != "id" {
+			if value == nil {
+				delete(existing, key)
+			} else {
+				existing[key] = value
+			}
+		}
+	}
+	
+	return s.update(tx, entity, id, existing)
+}
+
+// delete removes an entity and every edge touching it
+func (s *BoltStore) delete(tx *bolt.Tx, entity string, id int) error {
+	if !s.exists(tx, entity, id) {
+		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	
+	if err := recordBoltDelete(tx, entity, id); err != nil {
+		return err
+	}
+	
+	b, _ := entityBucket(tx, entity, false)
+	if err := b.Delete(idKey(id)); err != nil {
+		return fmt.Errorf("failed to delete entity: %w", err)
+	}
+	if err := tx.Bucket(revisionsBucket).Delete([]byte(nodeKey(entity, id))); err != nil {
+		return fmt.Errorf("failed to delete revision: %w", err)
+	}
+	
+	if err := deleteBoltEdges(tx, entity, id); err != nil {
+		return fmt.Errorf("failed to delete graph edges: %w", err)
+	}
+	return nil
+}
+
+// save inserts an entity with a specific ID and advances the sequence past it
+func (s *BoltStore) save(tx *bolt.Tx, entity string, id int, data map[string]interface{}) error {
+	if s.exists(tx, entity, id) {
+		return fmt.Errorf("%w: %s with id %d", ErrAlreadyExists, entity, id)
+	}
+	
+	b, err := entityBucket(tx, entity, true)
+	if err != nil {
+		return err
+	}
+	
+	if uint64(id) > b.Sequence() {
+		if err := b.SetSequence(uint64(id)); err != nil {
+			return fmt.Errorf("failed to update sequence: %w", err)
+		}
+	}
+	
+	// Carry on from the revisions of any deleted entity with this ID
+	stored, err := s.put(b, id, data)
+	if err != nil {
+		return err
+	}
+	if err := recordBoltVersion(tx, entity, id, lastBoltRevision(tx, entity, id)+1, stored); err != nil {
+		return err
+	}
+	
+	if err := syncBoltEdges(tx, entity, id, stored); err != nil {
+		return fmt.Errorf("failed to sync graph: %w", err)
+	}
+	return nil
+}
+
+// list loads every entity of a type in ID order
+func (s *BoltStore) list(tx *bolt.Tx, entity string) ([]map[string]interface{}, error) {
+	results := []map[string]interface{}{}
+	
+	b, _ := entityBucket(tx, entity, false)
+	if b == nil {
+		return results, nil
+	}
+	
+	err := b.ForEach(func(k, v []byte) error {
+		var data map[string]interface{}
+		if err := json.Unmarshal(v, &data); err != nil {
+			return fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		results = append(results, data)
+		return nil
+	})
+	return results, err
+}
+
+// exists reports whether an entity exists
+func (s *BoltStore) exists(tx *bolt.Tx, entity string, id int) bool {
+	b, _ := entityBucket(tx, entity, false)
+	return b != nil && b.Get(idKey(id)) != nil
+}
+
+// syncBoltEdges replaces the edges leaving an entity with one per reference
+// it holds, named by the reference's JSON path
+func syncBoltEdges(tx *bolt.Tx, entity string, id int, data map[string]interface{}) error {
+	out := tx.Bucket(edgesOutBucket)
+	in := tx.Bucket(edgesInBucket)
+	source := nodeKey(entity, id)
+	
+	if err := deleteOutgoingEdges(tx, source); err != nil {
+		return err
+	}
+	
+	for _, ref := range models.FindReferences(data) {
+		if ref.Path == "id" || ref.ID == 0 {
+			continue
+		}
+		
+		target := nodeKey(ref.Entity, ref.ID)
+		if err := out.Put([]byte(source+ref.Path), []byte(target)); err != nil {
+			return err
+		}
+		if err := in.Put([]byte(target+source+ref.Path), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOutgoingEdges removes every edge leaving a node
+func deleteOutgoingEdges(tx *bolt.Tx, source string) error {
+	out := tx.Bucket(edgesOutBucket)
+	in := tx.Bucket(edgesInBucket)
+	
+	var keys [][]byte
+	c := out.Cursor()
+	for k, v := c.Seek([]byte(source)); k != nil && bytes.HasPrefix(k, []byte(source)); k, v = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+		path := string(k[len(source):])
+		if err := in.Delete([]byte(string(v) + source + path)); err != nil {
+			return err
+		}
+	}
+	
+	for _, k := range keys {
+		if err := out.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteBoltEdges removes every edge leaving or entering a node
+func deleteBoltEdges(tx *bolt.Tx, entity string, id int) error {
+	node := nodeKey(entity, id)
+	if err := deleteOutgoingEdges(tx, node); err != nil {
+		return err
+	}
+	
+	out := tx.Bucket(edgesOutBucket)
+	in := tx.Bucket(edgesInBucket)
+	
+	var keys [][]byte
+	c := in.Cursor()
+	for k, _ := c.Seek([]byte(node)); k != nil && bytes.HasPrefix(k, []byte(node)); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+		if err := out.Delete(k[len(node):]); err != nil {
+			return err
+		}
+	}
+	
+	for _, k := range keys {
+		if err := in.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltEdge is a decoded edge
+type boltEdge struct {
+	sourceEntity string
+	sourceID     int
+	targetEntity string
+	targetID     int
+	relationship string
+}
+
+// key renders the edge the way VerifyGraphIntegrity reports it
+func (e boltEdge) key() string {
+	return fmt.Sprintf("%s:%d:%s:%d:%s", e.sourceEntity, e.sourceID, e.targetEntity, e.targetID, e.relationship)
+}
+
+// splitNodeKey decodes the node at the start of a key and returns the rest
+func splitNodeKey(key string) (string, int, string, error) {
+	parts := strings.SplitN(key, "\x00", 3)
+	if len(parts) != 3 {
+		return "", 0, "", fmt.Errorf("malformed edge key: %q", key)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("malformed edge key: %q", key)
+	}
+	return parts[0], id, parts[2], nil
+}
+
+// parseOutEdge decodes an entry of the edges_out bucket
+func parseOutEdge(k, v []byte) (boltEdge, error) {
+	var edge boltEdge
+	var err error
+	if edge.sourceEntity, edge.sourceID, edge.relationship, err = splitNodeKey(string(k)); err != nil {
+		return edge, err
+	}
+	edge.targetEntity, edge.targetID, _, err = splitNodeKey(string(v))
+	return edge, err
+}
+
+// parseInEdge decodes a key of the edges_in bucket
+func parseInEdge(k []byte) (boltEdge, error) {
+	var edge boltEdge
+	rest := ""
+	var err error
+	if edge.targetEntity, edge.targetID, rest, err = splitNodeKey(string(k)); err != nil {
+		return edge, err
+	}
+	edge.sourceEntity, edge.sourceID, edge.relationship, err = splitNodeKey(rest)
+	return edge, err
+}
+
+// Search implements field-based search over the entities of a type
+func (s *BoltStore) Search(ctx context.Context, entity string, field string, query string, matchType string) ([]map[string]interface{}, error) {
+	all, err := s.List(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return searchItems(all, field, query, matchType)
+}
+
+// GetNeighbors returns graph neighbors for an entity. Edges to entities that
+// no longer exist are skipped.
+func (s *BoltStore) GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		results, err = s.getNeighbors(tx, entity, id, direction)
+		return err
+	})
+	return results, err
+}
+
+// getNeighbors reads a node's neighbors within a transaction
+func (s *BoltStore) getNeighbors(tx *bolt.Tx, entity string, id int, direction string) ([]map[string]interface{}, error) {
+	if direction != "out" && direction != "in" {
+		return nil, fmt.Errorf("invalid direction: %s (must be 'in' or 'out')", direction)
+	}
+	
+	node := nodeKey(entity, id)
+	
+	var edges []boltEdge
+	bucket, parse := edgesOutBucket, parseOutEdge
+	if direction == "in" {
+		bucket = edgesInBucket
+		parse = func(k, v []byte) (boltEdge, error) { return parseInEdge(k) }
+	}
+	
+	c := tx.Bucket(bucket).Cursor()
+	for k, v := c.Seek([]byte(node)); k != nil && bytes.HasPrefix(k, []byte(node)); k, v = c.Next() {
+		edge, err := parse(k, v)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+	
+	var results []map[string]interface{}
+	for _, edge := range edges {
+		neighborType, neighborID := edge.targetEntity, edge.targetID
+		if direction == "in" {
+			neighborType, neighborID = edge.sourceEntity, edge.sourceID
+		}
+		
+		data, err := s.get(tx, neighborType, neighborID)
+		if err != nil {
+			continue
+		}
+		
+		// Add metadata
+		data["_neighbor_type"] = neighborType
+		data["_relationship"] = edge.relationship
+		data["_direction"] = direction
+		
+		results = append(results, data)
+	}
+	return results, nil
+}
+
+// VerifyGraphIntegrity checks that the edge buckets match the references
+// held by stored entities
+func (s *BoltStore) VerifyGraphIntegrity(ctx context.Context) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		expectedEdges := make(map[string]bool)
+		err := tx.Bucket(entitiesBucket).ForEach(func(name, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			entity := string(name)
+			items, err := s.list(tx, entity)
+			if err != nil {
+				return err
+			}
+			for _, data := range items {
+				id := entityID(data)
+				for _, ref := range models.FindReferences(data) {
+					if ref.Path != "id" && ref.ID > 0 {
+						edge := boltEdge{entity, id, ref.Entity, ref.ID, ref.Path}
+						expectedEdges[edge.key()] = true
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		
+		actualEdges := make(map[string]bool)
+		err = tx.Bucket(edgesOutBucket).ForEach(func(k, v []byte) error {
+			edge, err := parseOutEdge(k, v)
+			if err != nil {
+				return fmt.Errorf("graph integrity error: %w", err)
+			}
+			
+			target := nodeKey(edge.targetEntity, edge.targetID)
+			source := nodeKey(edge.sourceEntity, edge.sourceID)
+			if tx.Bucket(edgesInBucket).Get([]byte(target+source+edge.relationship)) == nil {
+				return fmt.Errorf("graph integrity error: edge missing from incoming index: %s", edge.key())
+			}
+			actualEdges[edge.key()] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		
+		if in := tx.Bucket(edgesInBucket).Stats().KeyN; in != len(actualEdges) {
+			return fmt.Errorf("graph integrity error: %d incoming index entries for %d edges", in, len(actualEdges))
+		}
+		
+		// Compare
+		for edge := range expectedEdges {
+			if !actualEdges[edge] {
+				return fmt.Errorf("graph integrity error: missing edge: %s", edge)
+			}
+		}
+		for edge := range actualEdges {
+			if !expectedEdges[edge] {
+				return fmt.Errorf("graph integrity error: unexpected edge: %s", edge)
+			}
+		}
+		return nil
+	})
+}
+
+// RebuildGraph rebuilds both edge buckets from the stored entities
+func (s *BoltStore) RebuildGraph(ctx context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{edgesOutBucket, edgesInBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		
+		return tx.Bucket(entitiesBucket).ForEach(func(name, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			items, err := s.list(tx, string(name))
+			if err != nil {
+				return err
+			}
+			for _, data := range items {
+				if err := syncBoltEdges(tx, string(name), entityID(data), data); err != nil {
+					return fmt.Errorf("failed to insert edge: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	
+	bolt "go.etcd.io/bbolt"
+)
+
+// AppendChange records a change in the change log
+func (s *BoltStore) AppendChange(ctx context.Context, change *Change) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return appendChange(tx, change)
+	})
+}
+
+func appendChange(tx *bolt.Tx, change *Change) error {
+	bucket := tx.Bucket(changesBucket)
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return fmt.Errorf("failed to allocate sequence number: %w", err)
+	}
+	change.Seq = int64(seq)
+	
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	if err := bucket.Put(key, data); err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	return nil
+}
+
+// AppendChange records a change in the change log, as part of the transaction
+func (t *boltTransaction) AppendChange(ctx context.Context, change *Change) error {
+	return appendChange(t.tx, change)
+}
+
+// LastChangeSeq returns the sequence number of the last change recorded
+func (s *BoltStore) LastChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(changesBucket).Cursor().Last(); k != nil {
+			seq = int64(binary.BigEndian.Uint64(k))
+		}
+		return nil
+	})
+	return seq, err
+}
+
+// Changes returns up to limit changes recorded after a sequence number
+func (s *BoltStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	changes := []Change{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, uint64(after+1))
+		
+		c := tx.Bucket(changesBucket).Cursor()
+		for k, v := c.Seek(start); k != nil && len(changes) < limit; k, v = c.Next() {
+			var change Change
+			if err := json.Unmarshal(v, &change); err != nil {
+				return fmt.Errorf("failed to unmarshal change: %w", err)
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	return changes, err
+}
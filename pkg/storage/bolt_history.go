@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+	
+	bolt "go.etcd.io/bbolt"
+)
+
+// History lists an entity's versions oldest first, ending with the current
+// one. A deleted entity's history ends with the delete.
+func (s *BoltStore) History(ctx context.Context, entity string, id int) ([]EntityVersion, error) {
+	var versions []EntityVersion
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(nodeKey(entity, id))
+		c := tx.Bucket(historyBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var stored storedVersion
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return fmt.Errorf("failed to unmarshal version: %w", err)
+			}
+			versions = append(versions, EntityVersion{
+				Revision:  int(binary.BigEndian.Uint64(k[len(prefix):])),
+				Data:      stored.Data,
+				Deleted:   stored.Data == nil,
+				ChangedAt: parseHistoryTime(stored.ChangedAt),
+			})
+		}
+		
+		// An entity untouched since before history was kept has only its
+		// current version
+		if !s.exists(tx, entity, id) {
+			if len(versions) == 0 {
+				return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+			}
+			return nil
+		}
+		revision := boltRevision(tx, entity, id)
+		if len(versions) == 0 || versions[len(versions)-1].Revision < revision {
+			data, err := s.get(tx, entity, id)
+			if err != nil {
+				return err
+			}
+			versions = append(versions, EntityVersion{Revision: revision, Data: data})
+		}
+		return nil
+	})
+	return versions, err
+}
+
+// historyKey returns the history bucket key for one version of an entity
+func historyKey(entity string, id int, revision int) []byte {
+	key := make([]byte, 0, len(entity)+30)
+	key = append(key, nodeKey(entity, id)...)
+	return binary.BigEndian.AppendUint64(key, uint64(revision))
+}
+
+// putBoltVersion stores one version of an entity
+func putBoltVersion(tx *bolt.Tx, entity string, id int, revision int, version storedVersion) error {
+	value, err := json.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to marshal version: %w", err)
+	}
+	if err := tx.Bucket(historyBucket).Put(historyKey(entity, id, revision), value); err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// recordBoltVersion sets an entity's revision and records the version a
+// write has just produced
+func recordBoltVersion(tx *bolt.Tx, entity string, id int, revision int, data map[string]interface{}) error {
+	if err := setBoltRevision(tx, entity, id, revision); err != nil {
+		return err
+	}
+	return putBoltVersion(tx, entity, id, revision, storedVersion{
+		Data:      data,
+		ChangedAt: historyTime(time.Now()),
+	})
+}
+
+// archiveBoltVersion records the version a write is about to replace, which
+// is only missing from history if it was written before history was kept,
+// and returns its revision
+func archiveBoltVersion(tx *bolt.Tx, entity string, id int) (int, error) {
+	revision := boltRevision(tx, entity, id)
+	if tx.Bucket(historyBucket).Get(historyKey(entity, id, revision)) != nil {
+		return revision, nil
+	}
+	
+	b, _ := entityBucket(tx, entity, false)
+	var data map[string]interface{}
+	if err := json.Unmarshal(b.Get(idKey(id)), &data); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	return revision, putBoltVersion(tx, entity, id, revision, storedVersion{Data: data})
+}
+
+// recordBoltDelete archives the version being deleted and records the delete
+// as the revision after it
+func recordBoltDelete(tx *bolt.Tx, entity string, id int) error {
+	revision, err := archiveBoltVersion(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	return putBoltVersion(tx, entity, id, revision+1, storedVersion{
+		ChangedAt: historyTime(time.Now()),
+	})
+}
+
+// lastBoltRevision returns the latest revision in an entity's history, 0 if
+// it has none
+func lastBoltRevision(tx *bolt.Tx, entity string, id int) int {
+	prefix := []byte(nodeKey(entity, id))
+	c := tx.Bucket(historyBucket).Cursor()
+	
+	// Step back from the first key past the entity's versions
+	end := append(append([]byte(nil), prefix...), 0xff)
+	k, _ := c.Seek(end)
+	if k == nil {
+		k, _ = c.Last()
+	} else {
+		k, _ = c.Prev()
+	}
+	if k == nil || !bytes.HasPrefix(k, prefix) || len(k) != len(prefix)+8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(k[len(prefix):]))
+}
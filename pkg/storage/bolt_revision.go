@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	
+	bolt "go.etcd.io/bbolt"
+)
+
+// GetRevision retrieves an entity together with its current revision
+func (s *BoltStore) GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	var result map[string]interface{}
+	var revision int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		result, revision, err = s.getRevision(tx, entity, id)
+		return err
+	})
+	return result, revision, err
+}
+
+// UpdateIf replaces an entity if it is still at the expected revision
+func (s *BoltStore) UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	var revision int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		revision, err = s.updateIf(tx, entity, id, data, expected)
+		return err
+	})
+	return revision, err
+}
+
+// PatchIf applies modify to an entity if it is still at the expected
+// revision. bbolt's single writer makes the read-modify-write atomic.
+func (s *BoltStore) PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	var data map[string]interface{}
+	var revision int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		data, revision, err = s.patchIf(tx, entity, id, expected, modify)
+		return err
+	})
+	return data, revision, err
+}
+
+// DeleteIf removes an entity if it is still at the expected revision
+func (s *BoltStore) DeleteIf(ctx context.Context, entity string, id int, expected int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.deleteIf(tx, entity, id, expected)
+	})
+}
+
+// boltRevision returns an existing entity's revision. Entities written
+// before revisions were tracked have none stored and are at revision 1.
+func boltRevision(tx *bolt.Tx, entity string, id int) int {
+	value := tx.Bucket(revisionsBucket).Get([]byte(nodeKey(entity, id)))
+	if len(value) != 8 {
+		return 1
+	}
+	return int(binary.BigEndian.Uint64(value))
+}
+
+// setBoltRevision records an entity's revision
+func setBoltRevision(tx *bolt.Tx, entity string, id int, revision int) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(revision))
+	if err := tx.Bucket(revisionsBucket).Put([]byte(nodeKey(entity, id)), value); err != nil {
+		return fmt.Errorf("failed to store revision: %w", err)
+	}
+	return nil
+}
+
+// getRevision loads an entity and its revision
+func (s *BoltStore) getRevision(tx *bolt.Tx, entity string, id int) (map[string]interface{}, int, error) {
+	data, err := s.get(tx, entity, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, boltRevision(tx, entity, id), nil
+}
+
+// revision checks an existing entity's revision against the expected one
+func (s *BoltStore) revision(tx *bolt.Tx, entity string, id int, expected int) (int, error) {
+	if !s.exists(tx, entity, id) {
+		return 0, fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	revision := boltRevision(tx, entity, id)
+	return revision, checkRevision(entity, id, revision, expected)
+}
+
+// updateIf replaces an entity after checking its revision
+func (s *BoltStore) updateIf(tx *bolt.Tx, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	revision, err := s.revision(tx, entity, id, expected)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.update(tx, entity, id, data); err != nil {
+		return 0, err
+	}
+	return revision + 1, nil
+}
+
+// patchIf reads, modifies and writes back an entity after checking its
+// revision
+func (s *BoltStore) patchIf(tx *bolt.Tx, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	revision, err := s.revision(tx, entity, id, expected)
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	data, err := s.get(tx, entity, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := modify(data); err != nil {
+		return nil, 0, err
+	}
+	data["id"] = id
+	
+	if err := s.update(tx, entity, id, data); err != nil {
+		return nil, 0, err
+	}
+	return data, revision + 1, nil
+}
+
+// deleteIf removes an entity after checking its revision
+func (s *BoltStore) deleteIf(tx *bolt.Tx, entity string, id int, expected int) error {
+	if _, err := s.revision(tx, entity, id, expected); err != nil {
+		return err
+	}
+	return s.delete(tx, entity, id)
+}
+
+// GetRevision retrieves an entity and its revision, including uncommitted
+// changes
+func (t *boltTransaction) GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	return t.store.getRevision(t.tx, entity, id)
+}
+
+// UpdateIf replaces an entity if it is still at the expected revision
+func (t *boltTransaction) UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	return t.store.updateIf(t.tx, entity, id, data, expected)
+}
+
+// PatchIf applies modify to an entity if it is still at the expected revision
+func (t *boltTransaction) PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	return t.store.patchIf(t.tx, entity, id, expected, modify)
+}
+
+// DeleteIf removes an entity if it is still at the expected revision
+func (t *boltTransaction) DeleteIf(ctx context.Context, entity string, id int, expected int) error {
+	return t.store.deleteIf(t.tx, entity, id, expected)
+}
@@ -0,0 +1,426 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBoltTest(t *testing.T) (storage.Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "olu.bolt")
+	store, err := storage.NewStore("bolt", map[string]interface{}{"bolt_path": path})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store, path
+}
+
+func boltRef(entity string, id int) map[string]interface{} {
+	return map[string]interface{}{"type": "REF", "entity": entity, "id": id}
+}
+
+// =============================================================================
+// Basic CRUD Tests
+// =============================================================================
+
+func TestBoltStore_CRUD(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, "users", testUserData("alice"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	id2, err := store.Create(ctx, "users", testUserData("bob"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, id2)
+
+	data, err := store.Get(ctx, "users", id)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", data["name"])
+	assert.Equal(t, float64(id), data["id"])
+
+	require.NoError(t, store.Patch(ctx, "users", id, map[string]interface{}{"age": 31, "email": nil}))
+	data, _ = store.Get(ctx, "users", id)
+	assert.Equal(t, float64(31), data["age"])
+	assert.NotContains(t, data, "email")
+
+	require.NoError(t, store.Update(ctx, "users", id, map[string]interface{}{"name": "Alice"}))
+	data, _ = store.Get(ctx, "users", id)
+	assert.Equal(t, "Alice", data["name"])
+	assert.NotContains(t, data, "age")
+
+	require.NoError(t, store.Delete(ctx, "users", id))
+	assert.False(t, store.Exists(ctx, "users", id))
+
+	_, err = store.Get(ctx, "users", id)
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Update(ctx, "users", id, testUserData("x")), storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Delete(ctx, "users", id), storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Delete(ctx, "missing", 1), storage.ErrNotFound))
+}
+
+func TestBoltStore_List(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	empty, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Len(t, empty, 0)
+
+	for i := 0; i < 12; i++ {
+		store.Create(ctx, "users", testUserData(fmt.Sprintf("user%d", i)))
+	}
+
+	results, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	require.Len(t, results, 12)
+	for i, item := range results {
+		assert.Equal(t, float64(i+1), item["id"], "List should return entities in ID order")
+	}
+}
+
+func TestBoltStore_SaveUpdatesSequence(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "users", 10, testUserData("ten")))
+	assert.True(t, errors.Is(store.Save(ctx, "users", 10, testUserData("again")), storage.ErrAlreadyExists))
+
+	id, err := store.Create(ctx, "users", testUserData("next"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, id)
+
+	gen, ok := store.(storage.IDGenerator)
+	require.True(t, ok, "BoltStore should implement IDGenerator interface")
+	next, err := gen.NextID(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, 12, next)
+}
+
+func TestBoltStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.bolt")
+	ctx := context.Background()
+
+	store, err := storage.NewStore("bolt", map[string]interface{}{"bolt_path": path})
+	require.NoError(t, err)
+	managerID, _ := store.Create(ctx, "users", testUserData("manager"))
+	store.Create(ctx, "users", map[string]interface{}{"name": "employee", "manager": boltRef("users", managerID)})
+	require.NoError(t, store.Close())
+
+	store, err = storage.NewStore("bolt", map[string]interface{}{"bolt_path": path})
+	require.NoError(t, err)
+	defer store.Close()
+
+	results, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	id, _ := store.Create(ctx, "users", testUserData("third"))
+	assert.Equal(t, 3, id, "Sequence should survive a reopen")
+
+	neighbors, err := store.(storage.GraphNeighbors).GetNeighbors(ctx, "users", managerID, "in")
+	require.NoError(t, err)
+	assert.Len(t, neighbors, 1)
+}
+
+// =============================================================================
+// Search Tests
+// =============================================================================
+
+func TestBoltStore_Search(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	store.Create(ctx, "users", map[string]interface{}{"name": "Alice Smith"})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Bob Smith"})
+	store.Create(ctx, "users", map[string]interface{}{"name": "alice jones"})
+
+	searcher, ok := store.(storage.Searcher)
+	require.True(t, ok, "BoltStore should implement Searcher interface")
+
+	results, err := searcher.Search(ctx, "users", "name", "ALICE", "starts")
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = searcher.Search(ctx, "users", "name", "smith", "ends")
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	_, err = searcher.Search(ctx, "users", "name", "x", "fuzzy")
+	assert.Error(t, err)
+}
+
+// =============================================================================
+// Graph Tests
+// =============================================================================
+
+func TestBoltStore_GraphSync(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	manager1, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager1"})
+	manager2, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager2"})
+	employee, _ := store.Create(ctx, "users", map[string]interface{}{
+		"name":    "Employee",
+		"manager": boltRef("users", manager1),
+		"mentors": []interface{}{boltRef("users", manager1), boltRef("users", manager2)},
+	})
+
+	gn, ok := store.(storage.GraphNeighbors)
+	require.True(t, ok, "BoltStore should implement GraphNeighbors interface")
+
+	neighbors, err := gn.GetNeighbors(ctx, "users", employee, "out")
+	require.NoError(t, err)
+	relationships := make(map[string]string)
+	for _, n := range neighbors {
+		relationships[n["_relationship"].(string)] = n["name"].(string)
+		assert.Equal(t, "out", n["_direction"])
+		assert.Equal(t, "users", n["_neighbor_type"])
+	}
+	assert.Equal(t, map[string]string{
+		"manager":    "Manager1",
+		"mentors[0]": "Manager1",
+		"mentors[1]": "Manager2",
+	}, relationships)
+
+	incoming, err := gn.GetNeighbors(ctx, "users", manager1, "in")
+	require.NoError(t, err)
+	assert.Len(t, incoming, 2)
+
+	// Updating replaces the old edges
+	require.NoError(t, store.Update(ctx, "users", employee, map[string]interface{}{
+		"name":    "Employee",
+		"manager": boltRef("users", manager2),
+	}))
+	incoming, _ = gn.GetNeighbors(ctx, "users", manager1, "in")
+	assert.Len(t, incoming, 0)
+	incoming, _ = gn.GetNeighbors(ctx, "users", manager2, "in")
+	assert.Len(t, incoming, 1)
+
+	// Deleting removes edges in both directions
+	require.NoError(t, store.Delete(ctx, "users", employee))
+	incoming, _ = gn.GetNeighbors(ctx, "users", manager2, "in")
+	assert.Len(t, incoming, 0)
+
+	_, err = gn.GetNeighbors(ctx, "users", manager2, "both")
+	assert.Error(t, err)
+}
+
+func TestBoltStore_GraphIntegrity(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	managerID, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager"})
+	employeeID, _ := store.Create(ctx, "users", map[string]interface{}{
+		"name":    "Employee",
+		"manager": boltRef("users", managerID),
+	})
+	store.Create(ctx, "posts", map[string]interface{}{"author": boltRef("users", employeeID)})
+
+	gi, ok := store.(storage.GraphIntegrity)
+	require.True(t, ok, "BoltStore should implement GraphIntegrity interface")
+	require.NoError(t, gi.VerifyGraphIntegrity(ctx))
+
+	// Deleting a target drops its incoming edges, leaving a dangling reference
+	require.NoError(t, store.Delete(ctx, "users", managerID))
+	assert.Error(t, gi.VerifyGraphIntegrity(ctx))
+
+	require.NoError(t, gi.RebuildGraph(ctx))
+	require.NoError(t, gi.VerifyGraphIntegrity(ctx))
+
+	neighbors, err := store.(storage.GraphNeighbors).GetNeighbors(ctx, "posts", 1, "out")
+	require.NoError(t, err)
+	require.Len(t, neighbors, 1)
+	assert.Equal(t, "Employee", neighbors[0]["name"])
+}
+
+// =============================================================================
+// Transaction and Concurrency Tests
+// =============================================================================
+
+func TestBoltStore_Transactions(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	ts, ok := store.(storage.Transactional)
+	require.True(t, ok, "BoltStore should implement Transactional interface")
+
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := ts.Begin(ctx)
+		require.NoError(t, err)
+
+		managerID, err := tx.Create(ctx, "users", testUserData("manager"))
+		require.NoError(t, err)
+		employeeID, err := tx.Create(ctx, "users", map[string]interface{}{"name": "employee", "manager": boltRef("users", managerID)})
+		require.NoError(t, err)
+		assert.True(t, tx.Exists(ctx, "users", employeeID))
+
+		require.NoError(t, tx.Commit())
+		assert.Error(t, tx.Rollback(), "Rollback after Commit should fail")
+
+		neighbors, err := store.(storage.GraphNeighbors).GetNeighbors(ctx, "users", employeeID, "out")
+		require.NoError(t, err)
+		assert.Len(t, neighbors, 1)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		tx, err := ts.Begin(ctx)
+		require.NoError(t, err)
+
+		id, err := tx.Create(ctx, "users", testUserData("ghost"))
+		require.NoError(t, err)
+		require.NoError(t, tx.Delete(ctx, "users", 1))
+		require.NoError(t, tx.Rollback())
+
+		assert.False(t, store.Exists(ctx, "users", id))
+		assert.True(t, store.Exists(ctx, "users", 1))
+
+		next, _ := store.Create(ctx, "users", testUserData("next"))
+		assert.Equal(t, id, next, "A rolled back ID should be reused")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		batcher, ok := store.(storage.Batcher)
+		require.True(t, ok, "BoltStore should implement Batcher interface")
+
+		ids, err := batcher.BatchCreate(ctx, "tags", []map[string]interface{}{{"name": "a"}, {"name": "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, ids)
+
+		err = batcher.BatchDelete(ctx, "tags", []int{1, 99})
+		assert.Error(t, err)
+		assert.True(t, store.Exists(ctx, "tags", 1), "A failed batch delete should delete nothing")
+
+		err = batcher.BatchSave(ctx, "tags", []map[string]interface{}{{"id": 7, "name": "c"}, {"id": 1, "name": "dup"}})
+		assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+		assert.False(t, store.Exists(ctx, "tags", 7), "A failed batch save should save nothing")
+		require.NoError(t, batcher.BatchSave(ctx, "tags", []map[string]interface{}{{"id": 7, "name": "c"}}))
+		assert.True(t, store.Exists(ctx, "tags", 7))
+	})
+}
+
+func TestBoltStore_Schemas(t *testing.T) {
+	store, path := setupBoltTest(t)
+	testSchemaStore(t, store)
+
+	// Schemas persist across reopening
+	require.NoError(t, store.Close())
+	reopened, err := storage.NewStore("bolt", map[string]interface{}{"bolt_path": path})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	schemas, err := reopened.(storage.SchemaStore).ListSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, schemas, "users")
+}
+
+func TestBoltStore_Revisions(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	testRevisioner(t, store)
+
+	// Writes inside a transaction advance the revision as well
+	ctx := context.Background()
+	id, err := store.Create(ctx, "notes", map[string]interface{}{"text": "a"})
+	require.NoError(t, err)
+
+	err = storage.WithTransaction(ctx, store, func(tx storage.Transaction) error {
+		if err := tx.Update(ctx, "notes", id, map[string]interface{}{"text": "b"}); err != nil {
+			return err
+		}
+		_, err := tx.(storage.Revisioner).UpdateIf(ctx, "notes", id, map[string]interface{}{"text": "c"}, 2)
+		return err
+	})
+	require.NoError(t, err)
+
+	_, revision, err := store.(storage.Revisioner).GetRevision(ctx, "notes", id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, revision)
+}
+
+func TestBoltStore_History(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	testHistorian(t, store)
+}
+
+func TestBoltStore_SoftDelete(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	testSoftDeleter(t, store)
+	testTxSoftDeleter(t, store)
+}
+
+func TestBoltStore_ChangeLog(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	testChangeLog(t, store)
+	testTxChangeLog(t, store)
+}
+
+func TestBoltStore_ConcurrentCreates(t *testing.T) {
+	store, _ := setupBoltTest(t)
+	ctx := context.Background()
+
+	count := 20
+	var wg sync.WaitGroup
+	ids := make(chan int, count)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			id, err := store.Create(ctx, "users", testUserData(fmt.Sprintf("user%d", n)))
+			if err != nil {
+				t.Errorf("Concurrent create error: %v", err)
+				return
+			}
+			ids <- id
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "Duplicate ID: %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, count)
+}
+
+func TestBoltStore_Info(t *testing.T) {
+	store, _ := setupBoltTest(t)
+
+	info := store.(storage.InfoProvider).Info()
+	assert.Equal(t, "bolt", info.Type)
+	assert.True(t, info.SupportsSearch)
+	assert.True(t, info.SupportsBatch)
+	assert.True(t, info.SupportsTransaction)
+}
+
+// =============================================================================
+// Benchmark Tests
+// =============================================================================
+
+func BenchmarkBoltStore_Create(b *testing.B) {
+	store, err := storage.NewStore("bolt", map[string]interface{}{
+		"bolt_path": filepath.Join(b.TempDir(), "olu.bolt"),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Create(ctx, "users", testUserData(fmt.Sprintf("user%d", i)))
+	}
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	
+	bolt "go.etcd.io/bbolt"
+)
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (s *BoltStore) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.trash(tx, entity, id, expected)
+	})
+}
+
+// trash moves an entity to the trash within a transaction
+func (s *BoltStore) trash(tx *bolt.Tx, entity string, id int, expected int) error {
+	if _, err := s.revision(tx, entity, id, expected); err != nil {
+		return err
+	}
+	
+	data, err := s.get(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(trashRecord{Data: data, DeletedAt: historyTime(time.Now())})
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	node := nodeKey(entity, id)
+	if err := tx.Bucket(trashBucket).Put([]byte(node), record); err != nil {
+		return fmt.Errorf("failed to trash entity: %w", err)
+	}
+	
+	// Keep the entities pointing at it, so restoring it can bring their
+	// edges back
+	dormant := tx.Bucket(dormantEdgesBucket)
+	c := tx.Bucket(edgesInBucket).Cursor()
+	for k, _ := c.Seek([]byte(node)); k != nil && bytes.HasPrefix(k, []byte(node)); k, _ = c.Next() {
+		edge, err := parseInEdge(k)
+		if err != nil {
+			return err
+		}
+		if edge.sourceEntity == entity && edge.sourceID == id {
+			continue
+		}
+		if err := dormant.Put([]byte(node+nodeKey(edge.sourceEntity, edge.sourceID)), nil); err != nil {
+			return fmt.Errorf("failed to keep graph edges: %w", err)
+		}
+	}
+	
+	return s.delete(tx, entity, id)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (s *BoltStore) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		data, err = s.restore(tx, entity, id)
+		return err
+	})
+	return data, err
+}
+
+// restore brings a trashed entity back within a transaction
+func (s *BoltStore) restore(tx *bolt.Tx, entity string, id int) (map[string]interface{}, error) {
+	node := []byte(nodeKey(entity, id))
+	value := tx.Bucket(trashBucket).Get(node)
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s with id %d in the trash", ErrNotFound, entity, id)
+	}
+	var record trashRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	
+	// Saving it rebuilds its own edges from its references
+	if err := s.save(tx, entity, id, record.Data); err != nil {
+		return nil, err
+	}
+	if err := s.wakeDormantEdges(tx, entity, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Bucket(trashBucket).Delete(node); err != nil {
+		return nil, err
+	}
+	
+	data := record.Data
+	data["id"] = id
+	return data, nil
+}
+
+// wakeDormantEdges re-syncs the edges of every live entity that pointed at a
+// restored one, which brings back those that still do
+func (s *BoltStore) wakeDormantEdges(tx *bolt.Tx, entity string, id int) error {
+	node := nodeKey(entity, id)
+	keys := dormantKeys(tx, node)
+	for _, k := range keys {
+		sourceEntity, sourceID, _, err := splitNodeKey(string(k[len(node):]))
+		if err != nil {
+			return err
+		}
+		if !s.exists(tx, sourceEntity, sourceID) {
+			continue
+		}
+		data, err := s.get(tx, sourceEntity, sourceID)
+		if err != nil {
+			return err
+		}
+		if err := syncBoltEdges(tx, sourceEntity, sourceID, data); err != nil {
+			return fmt.Errorf("failed to sync graph: %w", err)
+		}
+	}
+	return dropDormantEdges(tx, keys)
+}
+
+// dormantKeys returns the dormant edge keys of a trashed node
+func dormantKeys(tx *bolt.Tx, node string) [][]byte {
+	var keys [][]byte
+	c := tx.Bucket(dormantEdgesBucket).Cursor()
+	for k, _ := c.Seek([]byte(node)); k != nil && bytes.HasPrefix(k, []byte(node)); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	return keys
+}
+
+// dropDormantEdges forgets dormant edges
+func dropDormantEdges(tx *bolt.Tx, keys [][]byte) error {
+	dormant := tx.Bucket(dormantEdgesBucket)
+	for _, k := range keys {
+		if err := dormant.Delete(k); err != nil {
+			return fmt.Errorf("failed to drop dormant edges: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListTrash lists an entity type's trashed entities
+func (s *BoltStore) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	var items []TrashedEntity
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		items, err = listBoltTrash(tx, entity)
+		return err
+	})
+	return items, err
+}
+
+// listBoltTrash loads the trash of one entity type, or of all when entity is
+// empty
+func listBoltTrash(tx *bolt.Tx, entity string) ([]TrashedEntity, error) {
+	var prefix []byte
+	if entity != "" {
+		prefix = []byte(entity + "\x00")
+	}
+	
+	items := []TrashedEntity{}
+	c := tx.Bucket(trashBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var item TrashedEntity
+		var err error
+		if item.Entity, item.ID, _, err = splitNodeKey(string(k)); err != nil {
+			return nil, err
+		}
+		var record trashRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		item.Data = record.Data
+		item.DeletedAt = parseHistoryTime(record.DeletedAt)
+		items = append(items, item)
+	}
+	
+	sortTrash(items)
+	return items, nil
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (s *BoltStore) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	var purged []TrashedEntity
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		purged, err = purgeBoltTrash(tx, entity, before)
+		return err
+	})
+	return purged, err
+}
+
+// purgeBoltTrash permanently removes the entities trashed before a cutoff
+// within a transaction
+func purgeBoltTrash(tx *bolt.Tx, entity string, before time.Time) ([]TrashedEntity, error) {
+	items, err := listBoltTrash(tx, entity)
+	if err != nil {
+		return nil, err
+	}
+	
+	purged := expiredTrash(items, before)
+	for _, item := range purged {
+		node := nodeKey(item.Entity, item.ID)
+		if err := tx.Bucket(trashBucket).Delete([]byte(node)); err != nil {
+			return nil, fmt.Errorf("failed to purge entity: %w", err)
+		}
+		if err := dropDormantEdges(tx, dormantKeys(tx, node)); err != nil {
+			return nil, err
+		}
+	}
+	return purged, nil
+}
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (t *boltTransaction) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return t.store.trash(t.tx, entity, id, expected)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (t *boltTransaction) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.restore(t.tx, entity, id)
+}
+
+// ListTrash lists an entity type's trashed entities, including uncommitted
+// changes
+func (t *boltTransaction) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	return listBoltTrash(t.tx, entity)
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (t *boltTransaction) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	return purgeBoltTrash(t.tx, entity, before)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltTransaction runs Store operations inside a single bbolt read-write
+// transaction. bbolt allows one writer at a time, so other writes block from
+// Begin until Commit or Rollback; the store itself must not be written to
+// from within the transaction.
+type boltTransaction struct {
+	store *BoltStore
+	tx    *bolt.Tx
+	done  bool
+}
+
+// Begin starts a transaction. Writes made through it become visible together
+// on Commit, or not at all on Rollback.
+func (s *BoltStore) Begin(ctx context.Context) (Transaction, error) {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &boltTransaction{store: s, tx: tx}, nil
+}
+
+// Create inserts a new entity with auto-generated ID
+func (t *boltTransaction) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	return t.store.create(t.tx, entity, data)
+}
+
+// Get retrieves an entity by ID, including uncommitted changes
+func (t *boltTransaction) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.get(t.tx, entity, id)
+}
+
+// Update replaces an entity completely
+func (t *boltTransaction) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.update(t.tx, entity, id, data)
+}
+
+// Patch partially updates an entity
+func (t *boltTransaction) Patch(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.patch(t.tx, entity, id, data)
+}
+
+// Delete removes an entity
+func (t *boltTransaction) Delete(ctx context.Context, entity string, id int) error {
+	return t.store.delete(t.tx, entity, id)
+}
+
+// Save creates an entity with a specific ID (fails if exists)
+func (t *boltTransaction) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.save(t.tx, entity, id, data)
+}
+
+// List returns all entities of a given type, including uncommitted changes
+func (t *boltTransaction) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
+	return t.store.list(t.tx, entity)
+}
+
+// Exists checks if an entity exists, including uncommitted changes
+func (t *boltTransaction) Exists(ctx context.Context, entity string, id int) bool {
+	return t.store.exists(t.tx, entity, id)
+}
+
+// GetNeighbors returns graph neighbors for an entity, including uncommitted
+// changes
+func (t *boltTransaction) GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error) {
+	return t.store.getNeighbors(t.tx, entity, id, direction)
+}
+
+// Close rolls back the transaction if it has not been committed
+func (t *boltTransaction) Close() error {
+	if t.done {
+		return nil
+	}
+	return t.Rollback()
+}
+
+// Commit makes the transaction's writes durable and visible
+func (t *boltTransaction) Commit() error {
+	if t.done {
+		return bolt.ErrTxClosed
+	}
+	t.done = true
+	
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the transaction's writes
+func (t *boltTransaction) Rollback() error {
+	if t.done {
+		return bolt.ErrTxClosed
+	}
+	t.done = true
+	
+	return t.tx.Rollback()
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// Change is one write in the change feed
+type Change struct {
+	Seq           int64              `json:"seq"`
+	Time          time.Time          `json:"time"`
+	Entity        string             `json:"entity"`
+	ID            int                `json:"id"`
+	Operation     string             `json:"operation"`
+	Revision      int                `json:"revision,omitempty"`
+	ChangedFields []string           `json:"changed_fields"`
+	EdgesAdded    []models.GraphEdge `json:"edges_added,omitempty"`
+	EdgesRemoved  []models.GraphEdge `json:"edges_removed,omitempty"`
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // StoreFactory is a function that creates a new Store instance
@@ -60,7 +61,22 @@ func init() {
 			schema = "default"
 		}
 		
-		return NewJSONFileStore(baseDir, schema)
+		store, err := NewJSONFileStore(baseDir, schema)
+		if err != nil {
+			return nil, err
+		}
+		
+		if schemaDir, ok := config["schema_dir"].(string); ok && schemaDir != "" {
+			store.SetSchemaDir(schemaDir)
+		}
+		
+		if fulltext, ok := config["fulltext"].(bool); ok && fulltext {
+			if err := store.EnableFullText(context.Background()); err != nil {
+				return nil, fmt.Errorf("failed to build full-text index: %w", err)
+			}
+		}
+		
+		return store, nil
 	})
 	
 	// Register SQLiteStore
@@ -91,9 +107,62 @@ func init() {
 		if timeout, ok := config["busy_timeout"].(int); ok {
 			sqliteConfig.BusyTimeout = timeout
 		}
+		if fulltext, ok := config["fulltext"].(bool); ok {
+			sqliteConfig.EnableFullText = fulltext
+		}
+		if skip, ok := config["skip_migrations"].(bool); ok {
+			sqliteConfig.SkipMigrations = skip
+		}
 		
 		return NewSQLiteStore(dbPath, sqliteConfig)
 	})
+	
+	// Register BoltStore
+	RegisterStore("bolt", func(config map[string]interface{}) (Store, error) {
+		path, ok := config["bolt_path"].(string)
+		if !ok {
+			path = "olu.bolt"
+		}
+		
+		boltConfig := BoltConfig{
+			Path:        path,
+			LockTimeout: 5 * time.Second,
+		}
+		
+		// Allow overriding config options
+		if noSync, ok := config["no_sync"].(bool); ok {
+			boltConfig.NoSync = noSync
+		}
+		
+		return NewBoltStore(path, boltConfig)
+	})
+	
+	// Register PostgresStore
+	RegisterStore("postgres", func(config map[string]interface{}) (Store, error) {
+		dsn, ok := config["dsn"].(string)
+		if !ok {
+			dsn = "postgres://localhost:5432/olu?sslmode=disable"
+		}
+		
+		pgConfig := PostgresConfig{
+			DSN:          dsn,
+			MaxOpenConns: 25,
+			MaxIdleConns: 5,
+		}
+		
+		// Allow overriding config options
+		if conns, ok := config["max_open_conns"].(int); ok {
+			pgConfig.MaxOpenConns = conns
+		}
+		if conns, ok := config["max_idle_conns"].(int); ok {
+			pgConfig.MaxIdleConns = conns
+		}
+		if fulltext, ok := config["fulltext"].(bool); ok {
+			pgConfig.EnableFullText = fulltext
+		}
+		
+		return NewPostgresStore(pgConfig)
+	})
 }
 
 // Helper functions for common operations
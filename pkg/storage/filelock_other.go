@@ -0,0 +1,16 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable. Writes are still atomic,
+// but processes sharing a data directory are not kept from racing each other.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases a lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return nil
+}
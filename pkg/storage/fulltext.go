@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// ErrFullTextDisabled is returned by SearchText when the store was opened
+// without a full-text index
+var ErrFullTextDisabled = errors.New("full-text search is not enabled")
+
+// Snippet markers around matched terms, and the number of tokens a snippet spans
+const (
+	snippetOpen   = "<mark>"
+	snippetClose  = "</mark>"
+	snippetTokens = 12
+)
+
+// TextHit is a ranked full-text search result. Higher scores rank first.
+type TextHit struct {
+	Entity  string                 `json:"entity"`
+	ID      int                    `json:"id"`
+	Score   float64                `json:"score"`
+	Snippet string                 `json:"snippet"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// textContent joins the string values of a document, including those nested
+// in objects and arrays, in key order. References are skipped.
+func textContent(data map[string]interface{}) string {
+	var parts []string
+	collectText(data, &parts)
+	return strings.Join(parts, " ")
+}
+
+func collectText(v interface{}, parts *[]string) {
+	if _, isRef := models.IsReference(v); isRef {
+		return
+	}
+	
+	switch val := v.(type) {
+	case string:
+		if val != "" {
+			*parts = append(*parts, val)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectText(val[key], parts)
+		}
+	case []interface{}:
+		for _, item := range val {
+			collectText(item, parts)
+		}
+	}
+}
+
+// textToken is a lowercased term and its byte span in the source text
+type textToken struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text on anything that is not a letter or digit and folds
+// case, like SQLite's unicode61 tokenizer with remove_diacritics 0
+func tokenize(text string) []textToken {
+	var tokens []textToken
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, textToken{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, textToken{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// queryTerms returns the distinct terms of a search query
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range tokenize(query) {
+		if !seen[token.term] {
+			seen[token.term] = true
+			terms = append(terms, token.term)
+		}
+	}
+	return terms
+}
+
+// ftsMatch builds an FTS5 MATCH expression requiring every term. Terms only
+// hold letters and digits, so quoting them is enough to keep them literal.
+func ftsMatch(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// textDocKey identifies an indexed entity
+type textDocKey struct {
+	entity string
+	id     int
+}
+
+// textDoc is an indexed entity's text and term frequencies
+type textDoc struct {
+	content string
+	length  int
+	terms   map[string]int
+}
+
+// textIndex is an in-process inverted index over the string fields of
+// entities. Ranking uses BM25 with the same parameters as SQLite FTS5.
+type textIndex struct {
+	mu       sync.RWMutex
+	docs     map[textDocKey]*textDoc
+	postings map[string]map[textDocKey]int
+	totalLen int
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		docs:     make(map[textDocKey]*textDoc),
+		postings: make(map[string]map[textDocKey]int),
+	}
+}
+
+// put indexes an entity, replacing any previous version of it
+func (x *textIndex) put(entity string, id int, data map[string]interface{}) {
+	key := textDocKey{entity, id}
+	content := textContent(data)
+	tokens := tokenize(content)
+	
+	doc := &textDoc{content: content, length: len(tokens), terms: make(map[string]int)}
+	for _, token := range tokens {
+		doc.terms[token.term]++
+	}
+	
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	
+	x.removeLocked(key)
+	if doc.length == 0 {
+		return
+	}
+	
+	x.docs[key] = doc
+	x.totalLen += doc.length
+	for term, tf := range doc.terms {
+		posting, ok := x.postings[term]
+		if !ok {
+			posting = make(map[textDocKey]int)
+			x.postings[term] = posting
+		}
+		posting[key] = tf
+	}
+}
+
+// remove drops an entity from the index
+func (x *textIndex) remove(entity string, id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	
+	x.removeLocked(textDocKey{entity, id})
+}
+
+func (x *textIndex) removeLocked(key textDocKey) {
+	doc, ok := x.docs[key]
+	if !ok {
+		return
+	}
+	
+	delete(x.docs, key)
+	x.totalLen -= doc.length
+	for term := range doc.terms {
+		delete(x.postings[term], key)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+}
+
+// search ranks the entities containing every term. Hits carry no data.
+func (x *textIndex) search(terms []string, entities []string, limit int) []TextHit {
+	if len(terms) == 0 {
+		return nil
+	}
+	
+	allowed := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		allowed[entity] = true
+	}
+	
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	
+	// Walk the rarest term's postings and check the others
+	sorted := append([]string(nil), terms...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(x.postings[sorted[i]]) < len(x.postings[sorted[j]])
+	})
+	
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / math.Max(n, 1)
+	
+	var hits []TextHit
+	for key := range x.postings[sorted[0]] {
+		if len(allowed) > 0 && !allowed[key.entity] {
+			continue
+		}
+		
+		doc := x.docs[key]
+		score := 0.0
+		for _, term := range terms {
+			tf, ok := doc.terms[term]
+			if !ok {
+				score = -1
+				break
+			}
+			score += bm25Term(float64(tf), float64(len(x.postings[term])), n, float64(doc.length), avgLen)
+		}
+		if score < 0 {
+			continue
+		}
+		
+		hits = append(hits, TextHit{
+			Entity:  key.entity,
+			ID:      key.id,
+			Score:   score,
+			Snippet: snippet(doc.content, terms),
+		})
+	}
+	
+	sortHits(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// bm25Term scores one term of a document the way FTS5's bm25() does, with
+// k1 = 1.2 and b = 0.75
+func bm25Term(tf, docsWithTerm, totalDocs, docLen, avgLen float64) float64 {
+	const k1, b = 1.2, 0.75
+	
+	idf := math.Log((totalDocs - docsWithTerm + 0.5) / (docsWithTerm + 0.5))
+	if idf <= 0 {
+		idf = 1e-6
+	}
+	return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*docLen/avgLen))
+}
+
+// sortHits orders hits by descending score, then by entity type and id
+func sortHits(hits []TextHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Entity != hits[j].Entity {
+			return hits[i].Entity < hits[j].Entity
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
+
+// snippet returns a window of text around the first matched term, with
+// matched terms wrapped in snippet markers
+func snippet(content string, terms []string) string {
+	matches := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		matches[term] = true
+	}
+	
+	tokens := tokenize(content)
+	first := 0
+	for i, token := range tokens {
+		if matches[token.term] {
+			first = i
+			break
+		}
+	}
+	
+	start := first - snippetTokens/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetTokens
+	if end > len(tokens) {
+		end = len(tokens)
+	}
+	
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		token := tokens[i]
+		if i > start {
+			sb.WriteString(content[tokens[i-1].end:token.start])
+		}
+		if matches[token.term] {
+			sb.WriteString(snippetOpen + content[token.start:token.end] + snippetClose)
+		} else {
+			sb.WriteString(content[token.start:token.end])
+		}
+	}
+	if end < len(tokens) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "time"
+
+// EntityVersion is one recorded version of an entity
+type EntityVersion struct {
+	Revision  int
+	Data      map[string]interface{} // nil for a delete
+	Deleted   bool
+	ChangedAt time.Time // zero for versions written before history was kept
+}
+
+// storedVersion is a version as the bolt and JSON file stores keep it. Data
+// is nil for a delete, and ChangedAt empty for a version written before
+// history was kept.
+type storedVersion struct {
+	Data      map[string]interface{} `json:"data"`
+	ChangedAt string                 `json:"changed_at,omitempty"`
+}
+
+// historyTime formats a version's timestamp for storage, keeping the
+// sub-second precision as-of reads need to tell rapid writes apart
+func historyTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseHistoryTime reads a timestamp written by historyTime. Versions without
+// one come back with the zero time.
+func parseHistoryTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +18,8 @@ type JSONFileStore struct {
 	idLocks   map[string]*sync.Mutex
 	idMutex   sync.RWMutex
 	entityMux sync.RWMutex
+	text      *textIndex // nil unless full-text search is enabled
+	schemaDir string     // holds one <entity>.json JSON schema per entity type
 }
 
 // NewJSONFileStore creates a new JSON file-based storage
@@ -26,11 +29,18 @@ func NewJSONFileStore(baseDir, schema string) (*JSONFileStore, error) {
 		return nil, fmt.Errorf("failed to create schema directory: %w", err)
 	}
 	
-	return &JSONFileStore{
-		baseDir: baseDir,
-		schema:  schema,
-		idLocks: make(map[string]*sync.Mutex),
-	}, nil
+	store := &JSONFileStore{
+		baseDir:   baseDir,
+		schema:    schema,
+		idLocks:   make(map[string]*sync.Mutex),
+		schemaDir: filepath.Join(schemaPath, "_schemas"),
+	}
+	
+	if err := store.recover(); err != nil {
+		return nil, fmt.Errorf("failed to recover data directory: %w", err)
+	}
+	
+	return store, nil
 }
 
 // Info returns store information
@@ -41,23 +51,10 @@ func (s *JSONFileStore) Info() StoreInfo {
 		SupportsSearch:      true,
 		SupportsBatch:       false,
 		SupportsTransaction: false,
+		SupportsFullText:    s.text != nil,
 	}
 }
 
-// getIDLock gets or creates a mutex for an entity's ID generation
-func (s *JSONFileStore) getIDLock(entity string) *sync.Mutex {
-	s.idMutex.Lock()
-	defer s.idMutex.Unlock()
-	
-	if lock, exists := s.idLocks[entity]; exists {
-		return lock
-	}
-	
-	lock := &sync.Mutex{}
-	s.idLocks[entity] = lock
-	return lock
-}
-
 // GetEntityDir returns the directory path for an entity
 func (s *JSONFileStore) GetEntityDir(entity string) string {
 	return filepath.Join(s.baseDir, s.schema, entity)
@@ -73,41 +70,21 @@ func (s *JSONFileStore) getNextIDFile(entity string) string {
 	return filepath.Join(s.GetEntityDir(entity), "_next_id.json")
 }
 
-// NextID gets the next available ID for an entity
+// NextID gets the next available ID for an entity. The ID file is locked,
+// so processes sharing the data directory never hand out the same ID.
 func (s *JSONFileStore) NextID(ctx context.Context, entity string) (int, error) {
-	lock := s.getIDLock(entity)
-	lock.Lock()
-	defer lock.Unlock()
-	
-	entityDir := s.GetEntityDir(entity)
-	if err := os.MkdirAll(entityDir, 0755); err != nil {
-		return 0, fmt.Errorf("failed to create entity directory: %w", err)
-	}
-	
-	nextIDFile := s.getNextIDFile(entity)
-	
-	// Read current next ID
-	var nextID int = 1
-	if data, err := os.ReadFile(nextIDFile); err == nil {
-		var idData struct {
-			NextID int `json:"next_id"`
-		}
-		if err := json.Unmarshal(data, &idData); err == nil {
-			nextID = idData.NextID
-		}
+	unlock, err := s.lockEntity(entity, idLockFile)
+	if err != nil {
+		return 0, err
 	}
+	defer unlock()
 	
-	// Write incremented ID
-	idData := struct {
-		NextID int `json:"next_id"`
-	}{NextID: nextID + 1}
-	
-	data, err := json.Marshal(idData)
+	nextID, err := s.readNextID(entity)
 	if err != nil {
 		return 0, err
 	}
 	
-	if err := os.WriteFile(nextIDFile, data, 0644); err != nil {
+	if err := s.writeNextID(entity, nextID+1); err != nil {
 		return 0, err
 	}
 	
@@ -116,24 +93,52 @@ func (s *JSONFileStore) NextID(ctx context.Context, entity string) (int, error)
 
 // Create creates a new entity with auto-generated ID
 func (s *JSONFileStore) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	unlock, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	
 	id, err := s.NextID(ctx, entity)
 	if err != nil {
 		return 0, err
 	}
 	
+	if err := s.writeEntity(entity, id, data); err != nil {
+		return 0, err
+	}
+	
+	return id, nil
+}
+
+// writeEntity atomically writes an entity's file, advances its revision and
+// indexes it. The caller holds the entity type's write lock.
+func (s *JSONFileStore) writeEntity(entity string, id int, data map[string]interface{}) error {
 	data["id"] = id
 	
-	filePath := s.getEntityFile(entity, id)
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return 0, err
+		return err
 	}
 	
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return 0, err
+	revision, err := s.nextRevision(entity, id)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.getEntityFile(entity, id), jsonData, 0644); err != nil {
+		return err
+	}
+	if err := s.writeRevision(entity, id, revision); err != nil {
+		return err
+	}
+	if err := s.recordVersion(entity, id, revision, data); err != nil {
+		return err
 	}
 	
-	return id, nil
+	if s.text != nil {
+		s.text.put(entity, id, data)
+	}
+	return nil
 }
 
 // Get retrieves an entity by ID
@@ -158,24 +163,38 @@ func (s *JSONFileStore) Get(ctx context.Context, entity string, id int) (map[str
 
 // Update replaces an entity completely
 func (s *JSONFileStore) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
-	filePath := s.getEntityFile(entity, id)
-	
 	if !s.Exists(ctx, entity, id) {
 		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
 	}
 	
-	data["id"] = id
-	
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	unlock, err := s.lockEntity(entity, writeLockFile)
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	
+	// Another process may have deleted it while we waited for the lock
+	if !s.Exists(ctx, entity, id) {
+		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
 	
-	return os.WriteFile(filePath, jsonData, 0644)
+	return s.writeEntity(entity, id, data)
 }
 
 // Patch partially updates an entity
 func (s *JSONFileStore) Patch(ctx context.Context, entity string, id int, patchData map[string]interface{}) error {
+	if !s.Exists(ctx, entity, id) {
+		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+	}
+	
+	// Hold the lock from read to write so concurrent patches don't lose
+	// each other's changes
+	unlock, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
 	existing, err := s.Get(ctx, entity, id)
 	if err != nil {
 		return err
@@ -188,40 +207,77 @@ func (s *JSONFileStore) Patch(ctx context.Context, entity string, id int, patchD
 		}
 	}
 	
-	return s.Update(ctx, entity, id, existing)
+	return s.writeEntity(entity, id, existing)
 }
 
 // Delete removes an entity
 func (s *JSONFileStore) Delete(ctx context.Context, entity string, id int) error {
-	filePath := s.getEntityFile(entity, id)
-	
 	if !s.Exists(ctx, entity, id) {
 		return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
 	}
 	
-	return os.Remove(filePath)
+	unlock, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
+	return s.removeEntity(entity, id)
+}
+
+// removeEntity removes an entity's files and unindexes it. The caller holds
+// the entity type's write lock.
+func (s *JSONFileStore) removeEntity(entity string, id int) error {
+	if err := s.recordDelete(entity, id); err != nil {
+		return err
+	}
+	if err := removeFileDurably(s.getEntityFile(entity, id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+		}
+		return err
+	}
+	if err := os.Remove(s.getRevisionFile(entity, id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	
+	if s.text != nil {
+		s.text.remove(entity, id)
+	}
+	return nil
 }
 
-// Save saves an entity with a specific ID (creates if doesn't exist)
+// Save saves an entity with a specific ID (fails if it exists) and advances
+// the entity's next ID past it
 func (s *JSONFileStore) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	unlock, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
 	if s.Exists(ctx, entity, id) {
 		return fmt.Errorf("%w: %s with id %d", ErrAlreadyExists, entity, id)
 	}
 	
-	entityDir := s.GetEntityDir(entity)
-	if err := os.MkdirAll(entityDir, 0755); err != nil {
-		return fmt.Errorf("failed to create entity directory: %w", err)
+	if err := s.writeEntity(entity, id, data); err != nil {
+		return err
 	}
 	
-	data["id"] = id
-	filePath := s.getEntityFile(entity, id)
-	
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	unlockIDs, err := s.lockEntity(entity, idLockFile)
 	if err != nil {
 		return err
 	}
+	defer unlockIDs()
 	
-	return os.WriteFile(filePath, jsonData, 0644)
+	nextID, err := s.readNextID(entity)
+	if err != nil {
+		return err
+	}
+	if nextID > id {
+		return nil
+	}
+	return s.writeNextID(entity, id+1)
 }
 
 // List returns all entities of a given type
@@ -259,6 +315,20 @@ func (s *JSONFileStore) List(ctx context.Context, entity string) ([]map[string]i
 	return results, nil
 }
 
+// Query lists entities with filters, sorting and projection evaluated in memory
+func (s *JSONFileStore) Query(ctx context.Context, entity string, opts ListOptions) ([]map[string]interface{}, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	
+	all, err := s.List(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	
+	return ApplyListOptions(all, opts), nil
+}
+
 // Exists checks if an entity exists
 func (s *JSONFileStore) Exists(ctx context.Context, entity string, id int) bool {
 	filePath := s.getEntityFile(entity, id)
@@ -285,7 +355,8 @@ func (s *JSONFileStore) ListEntities(ctx context.Context) ([]string, error) {
 	
 	var entities []string
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Entity names start with a letter; "_" marks store metadata
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), "_") {
 			entities = append(entities, entry.Name())
 		}
 	}
@@ -293,6 +364,78 @@ func (s *JSONFileStore) ListEntities(ctx context.Context) ([]string, error) {
 	return entities, nil
 }
 
+// SetSchemaDir sets the directory JSON schemas are kept in, such as
+// SCHEMA_DIR. It defaults to _schemas inside the schema directory.
+func (s *JSONFileStore) SetSchemaDir(dir string) {
+	s.schemaDir = dir
+}
+
+// getSchemaFile returns the file path of an entity type's JSON schema
+func (s *JSONFileStore) getSchemaFile(entity string) string {
+	return filepath.Join(s.schemaDir, entity+".json")
+}
+
+// SaveSchema creates or replaces the JSON schema of an entity type
+func (s *JSONFileStore) SaveSchema(ctx context.Context, entity string, schema map[string]interface{}) error {
+	if entity == "" {
+		return ErrInvalidEntity
+	}
+	
+	jsonData, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	if err := os.MkdirAll(s.schemaDir, 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+	return writeFileAtomic(s.getSchemaFile(entity), jsonData, 0644)
+}
+
+// DeleteSchema removes the JSON schema of an entity type
+func (s *JSONFileStore) DeleteSchema(ctx context.Context, entity string) error {
+	if err := removeFileDurably(s.getSchemaFile(entity)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: schema for %s", ErrNotFound, entity)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListSchemas returns every stored JSON schema keyed by entity type
+func (s *JSONFileStore) ListSchemas(ctx context.Context) (map[string]map[string]interface{}, error) {
+	schemas := make(map[string]map[string]interface{})
+	
+	files, err := os.ReadDir(s.schemaDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return schemas, nil
+		}
+		return nil, err
+	}
+	
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		
+		data, err := os.ReadFile(filepath.Join(s.schemaDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		
+		entity := strings.TrimSuffix(file.Name(), ".json")
+		var schema map[string]interface{}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("failed to parse schema for %s: %w", entity, err)
+		}
+		schemas[entity] = schema
+	}
+	
+	return schemas, nil
+}
+
 // Search implements field-based search
 func (s *JSONFileStore) Search(ctx context.Context, entity string, field string, query string, matchType string) ([]map[string]interface{}, error) {
 	all, err := s.List(ctx, entity)
@@ -300,31 +443,51 @@ func (s *JSONFileStore) Search(ctx context.Context, entity string, field string,
 		return nil, err
 	}
 	
-	var results []map[string]interface{}
-	query = strings.ToLower(query)
-	
-	for _, item := range all {
-		if value, ok := item[field]; ok {
-			valueStr := strings.ToLower(fmt.Sprintf("%v", value))
-			
-			matched := false
-			switch matchType {
-			case "contains":
-				matched = strings.Contains(valueStr, query)
-			case "starts":
-				matched = strings.HasPrefix(valueStr, query)
-			case "ends":
-				matched = strings.HasSuffix(valueStr, query)
-			case "exact":
-				matched = valueStr == query
-			default:
-				matched = strings.Contains(valueStr, query)
-			}
-			
-			if matched {
-				results = append(results, item)
+	return searchItems(all, field, query, matchType)
+}
+
+// EnableFullText builds an in-process full-text index over the string fields
+// of every stored entity and keeps it up to date on writes made through
+// this store
+func (s *JSONFileStore) EnableFullText(ctx context.Context) error {
+	entities, err := s.ListEntities(ctx)
+	if err != nil {
+		return err
+	}
+	
+	index := newTextIndex()
+	for _, entity := range entities {
+		items, err := s.List(ctx, entity)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			index.put(entity, entityID(item), item)
+		}
+	}
+	
+	s.text = index
+	return nil
+}
+
+// SearchText ranks entities by the BM25 relevance of their string fields
+func (s *JSONFileStore) SearchText(ctx context.Context, query string, entities []string, limit int) ([]TextHit, error) {
+	if s.text == nil {
+		return nil, ErrFullTextDisabled
+	}
+	
+	hits := s.text.search(queryTerms(query), entities, limit)
+	results := make([]TextHit, 0, len(hits))
+	for _, hit := range hits {
+		data, err := s.Get(ctx, hit.Entity, hit.ID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
 			}
+			return nil, err
 		}
+		hit.Data = data
+		results = append(results, hit)
 	}
 	
 	return results, nil
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// changesDirName is the directory under the schema directory that holds the
+// change log. Its leading underscore keeps it out of ListEntities, and lets
+// it share the entity types' lock files.
+const (
+	changesDirName  = "_changes"
+	changesFileName = "changes.log"
+)
+
+// getChangesFile returns the append-only change log, one change as JSON per
+// line
+func (s *JSONFileStore) getChangesFile() string {
+	return filepath.Join(s.GetEntityDir(changesDirName), changesFileName)
+}
+
+// AppendChange records a change at the end of the change log
+func (s *JSONFileStore) AppendChange(ctx context.Context, change *Change) error {
+	unlock, err := s.lockEntity(changesDirName, writeLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
+	f, err := os.OpenFile(s.getChangesFile(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open change log: %w", err)
+	}
+	defer f.Close()
+	
+	last, end, err := lastChange(f)
+	if err != nil {
+		return err
+	}
+	// Drop a line torn by a crash mid-append
+	if err := f.Truncate(end); err != nil {
+		return err
+	}
+	
+	change.Seq = last + 1
+	line, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	if _, err := f.WriteAt(append(line, '\n'), end); err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	return f.Sync()
+}
+
+// lastChange returns the sequence number of the last complete line of the
+// change log, and the offset just past it
+func lastChange(f *os.File) (int64, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	
+	// Read back from the end until the last two newlines are in the buffer
+	size := info.Size()
+	chunk := int64(4096)
+	for {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		
+		end := bytes.LastIndexByte(buf, '\n')
+		if end < 0 {
+			if chunk == size {
+				return 0, 0, nil
+			}
+			chunk *= 2
+			continue
+		}
+		start := bytes.LastIndexByte(buf[:end], '\n')
+		if start < 0 && chunk < size {
+			chunk *= 2
+			continue
+		}
+		
+		var change Change
+		if err := json.Unmarshal(buf[start+1:end], &change); err != nil {
+			return 0, 0, fmt.Errorf("failed to unmarshal change: %w", err)
+		}
+		return change.Seq, size - chunk + int64(end) + 1, nil
+	}
+}
+
+// LastChangeSeq returns the sequence number of the last change recorded
+func (s *JSONFileStore) LastChangeSeq(ctx context.Context) (int64, error) {
+	f, err := os.Open(s.getChangesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open change log: %w", err)
+	}
+	defer f.Close()
+	
+	seq, _, err := lastChange(f)
+	return seq, err
+}
+
+// Changes returns up to limit changes recorded after a sequence number
+func (s *JSONFileStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	changes := []Change{}
+	f, err := os.Open(s.getChangesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return changes, nil
+		}
+		return nil, fmt.Errorf("failed to open change log: %w", err)
+	}
+	defer f.Close()
+	
+	reader := bufio.NewReader(f)
+	for len(changes) < limit {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// An unterminated last line is an append still in progress
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		
+		var change Change
+		if err := json.Unmarshal(line, &change); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal change: %w", err)
+		}
+		if change.Seq > after {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lock files in each entity directory. The write lock is held while an
+// entity's files change, the ID lock while _next_id.json is read and
+// rewritten. A holder of both takes the write lock first.
+const (
+	writeLockFile = "_write.lock"
+	idLockFile    = "_next_id.lock"
+	tempSuffix    = ".tmp"
+)
+
+// writeFileAtomic replaces path with data so that readers, and the file left
+// behind by a crash, hold either the old contents or the new ones. The data
+// goes to a temporary file in the same directory, is synced, and is renamed
+// over path; the directory is synced so the rename itself is durable.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// removeFileDurably removes path and syncs its directory
+func removeFileDurably(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory's entries to disk. Windows cannot sync
+// directories, and makes renames durable on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	
+	return d.Sync()
+}
+
+// getLock gets or creates the in-process mutex for a lock file
+func (s *JSONFileStore) getLock(path string) *sync.Mutex {
+	s.idMutex.Lock()
+	defer s.idMutex.Unlock()
+	
+	if lock, exists := s.idLocks[path]; exists {
+		return lock
+	}
+	
+	lock := &sync.Mutex{}
+	s.idLocks[path] = lock
+	return lock
+}
+
+// lockEntity takes one of an entity type's locks, creating the entity
+// directory if needed. Goroutines of this store queue on a mutex; other
+// processes sharing the directory are excluded by an advisory file lock.
+func (s *JSONFileStore) lockEntity(entity string, name string) (func(), error) {
+	entityDir := s.GetEntityDir(entity)
+	if err := os.MkdirAll(entityDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create entity directory: %w", err)
+	}
+	
+	path := filepath.Join(entityDir, name)
+	mu := s.getLock(path)
+	mu.Lock()
+	
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	
+	return func() {
+		unlockFile(f)
+		f.Close()
+		mu.Unlock()
+	}, nil
+}
+
+// readNextID reads an entity's next ID. If _next_id.json is missing or
+// unreadable, it is derived from the highest stored ID instead.
+func (s *JSONFileStore) readNextID(entity string) (int, error) {
+	if nextID, ok := s.storedNextID(entity); ok {
+		return nextID, nil
+	}
+	
+	maxID, err := s.maxStoredID(entity)
+	if err != nil {
+		return 0, err
+	}
+	return maxID + 1, nil
+}
+
+// storedNextID returns the ID recorded in _next_id.json, if it is valid
+func (s *JSONFileStore) storedNextID(entity string) (int, bool) {
+	data, err := os.ReadFile(s.getNextIDFile(entity))
+	if err != nil {
+		return 0, false
+	}
+	
+	var idData struct {
+		NextID int `json:"next_id"`
+	}
+	if err := json.Unmarshal(data, &idData); err != nil || idData.NextID < 1 {
+		return 0, false
+	}
+	return idData.NextID, true
+}
+
+// maxStoredID returns the highest ID with an entity file, or 0
+func (s *JSONFileStore) maxStoredID(entity string) (int, error) {
+	files, err := os.ReadDir(s.GetEntityDir(entity))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	
+	maxID := 0
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err == nil && id > maxID {
+			maxID = id
+		}
+	}
+	return maxID, nil
+}
+
+// writeNextID records an entity's next ID
+func (s *JSONFileStore) writeNextID(entity string, nextID int) error {
+	data, err := json.Marshal(struct {
+		NextID int `json:"next_id"`
+	}{NextID: nextID})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.getNextIDFile(entity), data, 0644)
+}
+
+// recover cleans up after a crash: it removes temporary files left by
+// interrupted writes and makes sure _next_id.json is past every stored ID.
+// Each entity type is recovered under its locks, so a process already
+// writing to the same directory is not disturbed.
+func (s *JSONFileStore) recover() error {
+	entities, err := s.ListEntities(context.Background())
+	if err != nil {
+		return err
+	}
+	
+	for _, entity := range entities {
+		if err := s.recoverEntity(entity); err != nil {
+			return fmt.Errorf("failed to recover %s: %w", entity, err)
+		}
+	}
+	return nil
+}
+
+func (s *JSONFileStore) recoverEntity(entity string) error {
+	unlockWrites, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlockWrites()
+	
+	unlockIDs, err := s.lockEntity(entity, idLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlockIDs()
+	
+	files, err := os.ReadDir(s.GetEntityDir(entity))
+	if err != nil {
+		return err
+	}
+	
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), tempSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.GetEntityDir(entity), file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	
+	maxID, err := s.maxStoredID(entity)
+	if err != nil {
+		return err
+	}
+	if nextID, ok := s.storedNextID(entity); ok && nextID > maxID {
+		return nil
+	}
+	return s.writeNextID(entity, maxID+1)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyDirName is the directory under an entity type's directory that holds
+// one directory of versions per entity
+const historyDirName = "_history"
+
+// getHistoryDir returns the directory holding an entity's versions
+func (s *JSONFileStore) getHistoryDir(entity string, id int) string {
+	return filepath.Join(s.GetEntityDir(entity), historyDirName, strconv.Itoa(id))
+}
+
+// getVersionFile returns the file holding one version of an entity
+func (s *JSONFileStore) getVersionFile(entity string, id int, revision int) string {
+	return filepath.Join(s.getHistoryDir(entity, id), fmt.Sprintf("%d.json", revision))
+}
+
+// History lists an entity's versions oldest first, ending with the current
+// one. A deleted entity's history ends with the delete.
+func (s *JSONFileStore) History(ctx context.Context, entity string, id int) ([]EntityVersion, error) {
+	revisions, err := s.historyRevisions(entity, id)
+	if err != nil {
+		return nil, err
+	}
+	
+	var versions []EntityVersion
+	for _, revision := range revisions {
+		data, err := os.ReadFile(s.getVersionFile(entity, id, revision))
+		if err != nil {
+			return nil, err
+		}
+		var stored storedVersion
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal version: %w", err)
+		}
+		versions = append(versions, EntityVersion{
+			Revision:  revision,
+			Data:      stored.Data,
+			Deleted:   stored.Data == nil,
+			ChangedAt: parseHistoryTime(stored.ChangedAt),
+		})
+	}
+	
+	// An entity untouched since before history was kept has only its
+	// current version
+	data, revision, err := s.GetRevision(ctx, entity, id)
+	if err != nil {
+		if len(versions) > 0 && errors.Is(err, ErrNotFound) {
+			return versions, nil
+		}
+		return nil, err
+	}
+	if len(versions) == 0 || versions[len(versions)-1].Revision < revision {
+		versions = append(versions, EntityVersion{Revision: revision, Data: data})
+	}
+	return versions, nil
+}
+
+// historyRevisions lists the revisions recorded for an entity in order
+func (s *JSONFileStore) historyRevisions(entity string, id int) ([]int, error) {
+	files, err := os.ReadDir(s.getHistoryDir(entity, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	
+	var revisions []int
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if revision, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err == nil {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Ints(revisions)
+	return revisions, nil
+}
+
+// lastRecordedRevision returns the latest revision in an entity's history,
+// so an entity saved over a deleted one carries on from its revisions
+func (s *JSONFileStore) lastRecordedRevision(entity string, id int) int {
+	revisions, err := s.historyRevisions(entity, id)
+	if err != nil || len(revisions) == 0 {
+		return 0
+	}
+	return revisions[len(revisions)-1]
+}
+
+// writeVersion records one version of an entity
+func (s *JSONFileStore) writeVersion(entity string, id int, revision int, version storedVersion) error {
+	jsonData, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.getHistoryDir(entity, id), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.getVersionFile(entity, id, revision), jsonData, 0644)
+}
+
+// recordVersion records the version a write has just produced
+func (s *JSONFileStore) recordVersion(entity string, id int, revision int, data map[string]interface{}) error {
+	return s.writeVersion(entity, id, revision, storedVersion{
+		Data:      data,
+		ChangedAt: historyTime(time.Now()),
+	})
+}
+
+// archiveVersion records the current version of an existing entity before a
+// write replaces it, which is only missing from history if it was written
+// before history was kept, and returns its revision
+func (s *JSONFileStore) archiveVersion(entity string, id int) (int, error) {
+	revision := s.readRevision(entity, id)
+	if _, err := os.Stat(s.getVersionFile(entity, id, revision)); err == nil {
+		return revision, nil
+	}
+	
+	data, err := s.Get(context.Background(), entity, id)
+	if err != nil {
+		return 0, err
+	}
+	return revision, s.writeVersion(entity, id, revision, storedVersion{Data: data})
+}
+
+// recordDelete archives the version being deleted and records the delete as
+// the revision after it
+func (s *JSONFileStore) recordDelete(entity string, id int) error {
+	revision, err := s.archiveVersion(entity, id)
+	if err != nil {
+		return err
+	}
+	return s.writeVersion(entity, id, revision+1, storedVersion{
+		ChangedAt: historyTime(time.Now()),
+	})
+}
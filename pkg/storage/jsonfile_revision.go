@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// getRevisionFile returns the file holding an entity's revision, next to its
+// JSON file
+func (s *JSONFileStore) getRevisionFile(entity string, id int) string {
+	return filepath.Join(s.GetEntityDir(entity), fmt.Sprintf("%d.rev", id))
+}
+
+// readRevision returns an existing entity's revision. Entities written before
+// revisions were tracked have no revision file and are at revision 1.
+func (s *JSONFileStore) readRevision(entity string, id int) int {
+	data, err := os.ReadFile(s.getRevisionFile(entity, id))
+	if err != nil {
+		return 1
+	}
+	
+	revision, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || revision < 1 {
+		return 1
+	}
+	return revision
+}
+
+// nextRevision returns the revision the next write gives an entity. The
+// version it replaces is archived first if it predates history, and a new
+// entity carries on from the revisions of any deleted one with its ID.
+func (s *JSONFileStore) nextRevision(entity string, id int) (int, error) {
+	if _, err := os.Stat(s.getEntityFile(entity, id)); err != nil {
+		return s.lastRecordedRevision(entity, id) + 1, nil
+	}
+	
+	revision, err := s.archiveVersion(entity, id)
+	if err != nil {
+		return 0, err
+	}
+	return revision + 1, nil
+}
+
+// writeRevision records an entity's revision. It is written after the entity
+// file, so a reader that reads the revision first never pairs a revision with
+// older data.
+func (s *JSONFileStore) writeRevision(entity string, id int, revision int) error {
+	return writeFileAtomic(s.getRevisionFile(entity, id), []byte(strconv.Itoa(revision)), 0644)
+}
+
+// GetRevision retrieves an entity together with its current revision
+func (s *JSONFileStore) GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	revision := s.readRevision(entity, id)
+	
+	data, err := s.Get(ctx, entity, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, revision, nil
+}
+
+// lockRevision takes the entity type's write lock and checks an existing
+// entity's revision against the expected one
+func (s *JSONFileStore) lockRevision(entity string, id int, expected int) (int, func(), error) {
+	unlock, err := s.lockEntity(entity, writeLockFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	
+	if _, err := os.Stat(s.getEntityFile(entity, id)); err != nil {
+		unlock()
+		if os.IsNotExist(err) {
+			return 0, nil, fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+		}
+		return 0, nil, err
+	}
+	
+	revision := s.readRevision(entity, id)
+	if err := checkRevision(entity, id, revision, expected); err != nil {
+		unlock()
+		return 0, nil, err
+	}
+	return revision, unlock, nil
+}
+
+// UpdateIf replaces an entity if it is still at the expected revision
+func (s *JSONFileStore) UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	revision, unlock, err := s.lockRevision(entity, id, expected)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	
+	if err := s.writeEntity(entity, id, data); err != nil {
+		return 0, err
+	}
+	return revision + 1, nil
+}
+
+// PatchIf applies modify to an entity if it is still at the expected
+// revision. The write lock is held from the read to the write.
+func (s *JSONFileStore) PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	revision, unlock, err := s.lockRevision(entity, id, expected)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+	
+	data, err := s.Get(ctx, entity, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := modify(data); err != nil {
+		return nil, 0, err
+	}
+	
+	if err := s.writeEntity(entity, id, data); err != nil {
+		return nil, 0, err
+	}
+	return data, revision + 1, nil
+}
+
+// DeleteIf removes an entity if it is still at the expected revision
+func (s *JSONFileStore) DeleteIf(ctx context.Context, entity string, id int, expected int) error {
+	_, unlock, err := s.lockRevision(entity, id, expected)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
+	return s.removeEntity(entity, id)
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashDirName is the directory under an entity type's directory that holds
+// its trashed entities
+const trashDirName = "_trash"
+
+// getTrashFile returns the file holding a trashed entity
+func (s *JSONFileStore) getTrashFile(entity string, id int) string {
+	return filepath.Join(s.GetEntityDir(entity), trashDirName, fmt.Sprintf("%d.json", id))
+}
+
+// Trash moves an entity to the trash if it is still at the expected revision.
+// Graph edges are kept by the server's graph, so there are none to keep here.
+func (s *JSONFileStore) Trash(ctx context.Context, entity string, id int, expected int) error {
+	_, unlock, err := s.lockRevision(entity, id, expected)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	
+	data, err := s.Get(ctx, entity, id)
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(trashRecord{Data: data, DeletedAt: historyTime(time.Now())})
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	
+	path := s.getTrashFile(entity, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, record, 0644); err != nil {
+		return err
+	}
+	return s.removeEntity(entity, id)
+}
+
+// Restore brings a trashed entity back
+func (s *JSONFileStore) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	record, err := s.readTrash(entity, id)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := s.Save(ctx, entity, id, record.Data); err != nil {
+		return nil, err
+	}
+	if err := removeFileDurably(s.getTrashFile(entity, id)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	
+	data := record.Data
+	data["id"] = id
+	return data, nil
+}
+
+// readTrash loads a trashed entity
+func (s *JSONFileStore) readTrash(entity string, id int) (trashRecord, error) {
+	var record trashRecord
+	data, err := os.ReadFile(s.getTrashFile(entity, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return record, fmt.Errorf("%w: %s with id %d in the trash", ErrNotFound, entity, id)
+		}
+		return record, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	return record, nil
+}
+
+// ListTrash lists an entity type's trashed entities
+func (s *JSONFileStore) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	files, err := os.ReadDir(filepath.Join(s.GetEntityDir(entity), trashDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	
+	items := []TrashedEntity{}
+	for _, file := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil || file.IsDir() {
+			continue
+		}
+		record, err := s.readTrash(entity, id)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, TrashedEntity{
+			Entity:    entity,
+			ID:        id,
+			Data:      record.Data,
+			DeletedAt: parseHistoryTime(record.DeletedAt),
+		})
+	}
+	
+	sortTrash(items)
+	return items, nil
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (s *JSONFileStore) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	entities := []string{entity}
+	if entity == "" {
+		var err error
+		if entities, err = s.ListEntities(ctx); err != nil {
+			return nil, err
+		}
+	}
+	
+	var purged []TrashedEntity
+	for _, entity := range entities {
+		items, err := s.ListTrash(ctx, entity)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range expiredTrash(items, before) {
+			if err := removeFileDurably(s.getTrashFile(entity, item.ID)); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			purged = append(purged, item)
+		}
+	}
+	
+	sortTrash(purged)
+	return purged, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrMigrationModified is returned when a migration already applied to a
+	// database differs from the one of the same version in this build
+	ErrMigrationModified = errors.New("applied migration has been modified")
+	// ErrSchemaTooNew is returned when a database has migrations applied
+	// that this build does not know about
+	ErrSchemaTooNew = errors.New("database schema is newer than this build")
+)
+
+// MigrationStatus describes one schema migration of a database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string // empty unless applied
+	Modified  bool   // applied from SQL that differs from this build's
+	Unknown   bool   // applied, but not part of this build
+}
+
+// migration is one step of an embedded, ordered migration set
+type migration struct {
+	version  int
+	name     string
+	sql      string
+	checksum string
+}
+
+// appliedMigration is a migration as recorded in a database. The checksum is
+// empty for migrations applied before checksums were recorded.
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt string
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+
+// loadMigrations reads the NNNN_name.sql files in dir. Versions must run
+// from 1 without gaps, so the order migrations apply in is never ambiguous.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	
+	var migrations []migration
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+		
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, migration{
+			version:  version,
+			name:     match[2],
+			sql:      string(data),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %s: expected version %d, got %d", m.name, i+1, m.version)
+		}
+	}
+	return migrations, nil
+}
+
+// mustLoadMigrations loads a migration set embedded in the binary, where a
+// malformed set is a programming error
+func mustLoadMigrations(fsys fs.FS, dir string) []migration {
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("storage: invalid migrations in %s: %v", dir, err))
+	}
+	return migrations
+}
+
+// checkApplied verifies that every migration recorded in a database is one
+// this build knows, unchanged
+func checkApplied(known []migration, applied map[int]appliedMigration) error {
+	for version, a := range applied {
+		if version < 1 || version > len(known) {
+			return fmt.Errorf("%w: version %d applied, this build knows %d", ErrSchemaTooNew, version, len(known))
+		}
+		m := known[version-1]
+		if a.checksum != "" && a.checksum != m.checksum {
+			return fmt.Errorf("%w: %d (%s)", ErrMigrationModified, m.version, m.name)
+		}
+	}
+	return nil
+}
+
+// migrationStatus merges a migration set with the migrations recorded in a
+// database, in version order
+func migrationStatus(known []migration, applied map[int]appliedMigration) []MigrationStatus {
+	statuses := make([]MigrationStatus, 0, len(known))
+	for _, m := range known {
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+			status.Modified = a.checksum != "" && a.checksum != m.checksum
+		}
+		statuses = append(statuses, status)
+	}
+	
+	var unknown []int
+	for version := range applied {
+		if version < 1 || version > len(known) {
+			unknown = append(unknown, version)
+		}
+	}
+	sort.Ints(unknown)
+	for _, version := range unknown {
+		a := applied[version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: a.appliedAt,
+			Unknown:   true,
+		})
+	}
+	return statuses
+}
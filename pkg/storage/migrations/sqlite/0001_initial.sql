@@ -0,0 +1,44 @@
+-- Initial schema. IF NOT EXISTS keeps this safe to adopt on databases
+-- created before migrations were tracked.
+
+-- Main entities table (JSON blob approach)
+CREATE TABLE IF NOT EXISTS entities (
+	entity_type TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	data TEXT NOT NULL, -- JSON stored as TEXT
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (entity_type, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_type ON entities(entity_type);
+CREATE INDEX IF NOT EXISTS idx_updated_at ON entities(updated_at);
+
+-- Graph relationships table (kept in sync by the store)
+CREATE TABLE IF NOT EXISTS graph_edges (
+	source_entity TEXT NOT NULL,
+	source_id INTEGER NOT NULL,
+	target_entity TEXT NOT NULL,
+	target_id INTEGER NOT NULL,
+	relationship_name TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (source_entity, source_id, target_entity, target_id, relationship_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_graph_source ON graph_edges(source_entity, source_id);
+CREATE INDEX IF NOT EXISTS idx_graph_target ON graph_edges(target_entity, target_id);
+CREATE INDEX IF NOT EXISTS idx_graph_relationship ON graph_edges(relationship_name);
+
+-- ID sequences table (replaces _next_id.json files)
+CREATE TABLE IF NOT EXISTS entity_sequences (
+	entity_type TEXT PRIMARY KEY,
+	next_id INTEGER NOT NULL DEFAULT 1
+);
+
+-- Schema metadata table (optional schema storage)
+CREATE TABLE IF NOT EXISTS schemas (
+	entity_type TEXT PRIMARY KEY,
+	schema TEXT NOT NULL, -- JSON schema
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Per-entity revisions for optimistic concurrency. Every write increments
+-- an entity's revision; existing entities start at revision 1.
+ALTER TABLE entities ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
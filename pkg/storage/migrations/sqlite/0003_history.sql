@@ -0,0 +1,11 @@
+-- Every version of every entity, written in the same transaction as the
+-- entity. data is NULL for a delete; changed_at is NULL for versions written
+-- before history was kept, which are archived on their next write.
+CREATE TABLE IF NOT EXISTS entity_history (
+	entity_type TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	revision INTEGER NOT NULL,
+	data TEXT,
+	changed_at TEXT,
+	PRIMARY KEY (entity_type, id, revision)
+);
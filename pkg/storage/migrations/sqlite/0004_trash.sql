@@ -0,0 +1,19 @@
+-- Entities moved to the trash by a soft delete, and the edges that pointed at
+-- them while they were live. Both are dropped once the entity is restored or
+-- purged.
+CREATE TABLE IF NOT EXISTS trash (
+	entity_type TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	deleted_at TEXT NOT NULL,
+	PRIMARY KEY (entity_type, id)
+);
+
+CREATE TABLE IF NOT EXISTS dormant_edges (
+	source_entity TEXT NOT NULL,
+	source_id INTEGER NOT NULL,
+	target_entity TEXT NOT NULL,
+	target_id INTEGER NOT NULL,
+	relationship_name TEXT NOT NULL,
+	PRIMARY KEY (target_entity, target_id, source_entity, source_id, relationship_name)
+);
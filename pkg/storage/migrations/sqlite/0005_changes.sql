@@ -0,0 +1,6 @@
+-- The change feed. Each row holds one change as JSON; seq orders the feed
+-- and is never reused.
+CREATE TABLE IF NOT EXISTS changes (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	data TEXT NOT NULL
+);
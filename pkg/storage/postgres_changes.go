@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AppendChange records a change in the change log
+func (s *PostgresStore) AppendChange(ctx context.Context, change *Change) error {
+	return s.appendChange(ctx, s.db, change)
+}
+
+func (s *PostgresStore) appendChange(ctx context.Context, q sqlQuerier, change *Change) error {
+	// The sequence number is only known once the row is in, so it is left
+	// out of the stored JSON and added back on read
+	change.Seq = 0
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	
+	var seq int64
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO changes (data)
+		VALUES ($1)
+		RETURNING seq
+	`, data).Scan(&seq)
+	if err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	change.Seq = seq
+	return nil
+}
+
+// AppendChange records a change in the change log, as part of the transaction
+func (t *postgresTransaction) AppendChange(ctx context.Context, change *Change) error {
+	return t.store.appendChange(ctx, t.tx, change)
+}
+
+// LastChangeSeq returns the sequence number of the last change recorded
+func (s *PostgresStore) LastChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM changes`).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query changes: %w", err)
+	}
+	return seq, nil
+}
+
+// Changes returns up to limit changes recorded after a sequence number
+func (s *PostgresStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, data FROM changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+	
+	changes := []Change{}
+	for rows.Next() {
+		var seq int64
+		var data []byte
+		if err := rows.Scan(&seq, &data); err != nil {
+			return nil, err
+		}
+		var change Change
+		if err := json.Unmarshal(data, &change); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal change: %w", err)
+		}
+		change.Seq = seq
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgresHeadline configures ts_headline to produce snippets like the other
+// stores: matched terms wrapped in snippet markers, about snippetTokens long
+var postgresHeadline = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=%d, MinWords=%d, ShortWord=0`,
+	snippetOpen, snippetClose, snippetTokens, snippetTokens/2)
+
+// initFullText creates the tsvector index when full-text search is enabled
+// and rebuilds it if it has fallen out of step with the entities table. When
+// disabled the index is dropped, since writes no longer keep it in sync.
+//
+// The 'simple' configuration lowercases terms without stemming or stop
+// words, matching the tokenization of the other stores.
+func (s *PostgresStore) initFullText(ctx context.Context) error {
+	if !s.config.EnableFullText {
+		_, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS entities_fts")
+		return err
+	}
+	
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS entities_fts (
+			entity_type TEXT NOT NULL,
+			id BIGINT NOT NULL,
+			content TEXT NOT NULL,
+			document TSVECTOR NOT NULL,
+			PRIMARY KEY (entity_type, id)
+		);
+		
+		CREATE INDEX IF NOT EXISTS idx_entities_fts ON entities_fts USING GIN (document);
+	`)
+	if err != nil {
+		return err
+	}
+	
+	var indexed, stored int
+	err = s.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM entities_fts), (SELECT COUNT(*) FROM entities)
+	`).Scan(&indexed, &stored)
+	if err != nil {
+		return err
+	}
+	if indexed == stored {
+		return nil
+	}
+	
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.rebuildFullText(ctx, tx)
+	})
+}
+
+// rebuildFullText reindexes every entity
+func (s *PostgresStore) rebuildFullText(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM entities_fts"); err != nil {
+		return fmt.Errorf("failed to clear full-text index: %w", err)
+	}
+	
+	documents, err := pgDocuments(ctx, tx)
+	if err != nil {
+		return err
+	}
+	
+	for _, doc := range documents {
+		if err := s.syncFullText(ctx, tx, doc.entity, doc.id, doc.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncFullText replaces an entity's entry in the full-text index
+func (s *PostgresStore) syncFullText(ctx context.Context, tx *sql.Tx, entity string, id int, data map[string]interface{}) error {
+	if !s.config.EnableFullText {
+		return nil
+	}
+	
+	content := textContent(data)
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO entities_fts (entity_type, id, content, document)
+		VALUES ($1, $2, $3, to_tsvector('simple', $3::text))
+		ON CONFLICT (entity_type, id) DO UPDATE
+		SET content = excluded.content, document = excluded.document
+	`, entity, id, content)
+	if err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	return nil
+}
+
+// removeFullText drops an entity from the full-text index
+func (s *PostgresStore) removeFullText(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	if !s.config.EnableFullText {
+		return nil
+	}
+	
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM entities_fts WHERE entity_type = $1 AND id = $2", entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to unindex entity: %w", err)
+	}
+	return nil
+}
+
+// SearchText ranks entities with ts_rank, requiring every query term
+func (s *PostgresStore) SearchText(ctx context.Context, query string, entities []string, limit int) ([]TextHit, error) {
+	if !s.config.EnableFullText {
+		return nil, ErrFullTextDisabled
+	}
+	
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []TextHit{}, nil
+	}
+	
+	// Terms only hold letters and digits, so they are safe in a tsquery
+	args := pgArgs{strings.Join(terms, " & "), postgresHeadline}
+	entityClause := ""
+	if len(entities) > 0 {
+		entityClause = "AND f.entity_type = ANY(" + args.add(pq.Array(entities)) + "::text[])"
+	}
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+	limitClause := "LIMIT " + args.add(limitArg)
+	
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT f.entity_type, f.id, e.data, ts_rank(f.document, q) AS score,
+		       ts_headline('simple', f.content, q, $2)
+		FROM entities_fts f
+		JOIN entities e ON e.entity_type = f.entity_type AND e.id = f.id,
+		     to_tsquery('simple', $1) q
+		WHERE f.document @@ q `+entityClause+`
+		ORDER BY score DESC, f.entity_type, f.id
+		`+limitClause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	
+	hits := []TextHit{}
+	for rows.Next() {
+		var hit TextHit
+		var jsonData []byte
+		if err := rows.Scan(&hit.Entity, &hit.ID, &jsonData, &hit.Score, &hit.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal(jsonData, &hit.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	
+	return hits, rows.Err()
+}
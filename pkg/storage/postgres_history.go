@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// History lists an entity's versions oldest first, ending with the current
+// one. A deleted entity's history ends with the delete.
+func (s *PostgresStore) History(ctx context.Context, entity string, id int) ([]EntityVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT revision, data, changed_at FROM entity_history
+		WHERE entity_type = $1 AND id = $2
+		ORDER BY revision
+	`, entity, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+	
+	var versions []EntityVersion
+	for rows.Next() {
+		var version EntityVersion
+		var data []byte
+		var changedAt sql.NullTime
+		if err := rows.Scan(&version.Revision, &data, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &version.Data); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal version: %w", err)
+			}
+		} else {
+			version.Deleted = true
+		}
+		if changedAt.Valid {
+			version.ChangedAt = changedAt.Time.UTC()
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	// An entity untouched since before history was kept has only its
+	// current version
+	data, revision, err := s.getRevision(ctx, s.db, entity, id, false)
+	if errors.Is(err, ErrNotFound) {
+		if len(versions) == 0 {
+			return nil, err
+		}
+		return versions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[len(versions)-1].Revision < revision {
+		versions = append(versions, EntityVersion{Revision: revision, Data: data})
+	}
+	return versions, nil
+}
+
+// copyToHistory copies an entity's current row into its history, unless that
+// revision is already recorded. Without a timestamp it archives a version
+// written before history was kept.
+func (s *PostgresStore) copyToHistory(ctx context.Context, tx *sql.Tx, entity string, id int, stamped bool) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_history (entity_type, id, revision, data, changed_at)
+		SELECT entity_type, id, revision, data,
+			CASE WHEN $3 THEN clock_timestamp() END
+		FROM entities
+		WHERE entity_type = $1 AND id = $2
+		ON CONFLICT DO NOTHING
+	`, entity, id, stamped)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// recordVersion records the version a write has just produced
+func (s *PostgresStore) recordVersion(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	return s.copyToHistory(ctx, tx, entity, id, true)
+}
+
+// archiveVersion records the version a write is about to replace, which is
+// only missing from history if it was written before history was kept
+func (s *PostgresStore) archiveVersion(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	return s.copyToHistory(ctx, tx, entity, id, false)
+}
+
+// recordDelete archives the version being deleted and records the delete as
+// the revision after it
+func (s *PostgresStore) recordDelete(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	if err := s.archiveVersion(ctx, tx, entity, id); err != nil {
+		return err
+	}
+	
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_history (entity_type, id, revision, data, changed_at)
+		SELECT entity_type, id, revision + 1, NULL, clock_timestamp()
+		FROM entities
+		WHERE entity_type = $1 AND id = $2
+		ON CONFLICT DO NOTHING
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to record delete: %w", err)
+	}
+	return nil
+}
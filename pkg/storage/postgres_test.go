@@ -0,0 +1,596 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/models"
+	"github.com/ha1tch/olu/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The PostgreSQL tests run against OLU_TEST_POSTGRES_DSN when it is set.
+// Otherwise they start a throwaway server with initdb and pg_ctl from PATH
+// or a standard install location, and skip if neither is available. Each
+// test gets its own schema, dropped when the test ends.
+var pgServer struct {
+	once    sync.Once
+	dir     string // set when the harness started the server
+	pgCtl   string
+	dsn     string
+	err     error
+	schemas int64
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	stopPostgres()
+	os.Exit(code)
+}
+
+// findPostgresBinary looks for a PostgreSQL server binary
+func findPostgresBinary(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin/", "/usr/local/pgsql/bin/", "/opt/homebrew/bin/"} {
+		matches, _ := filepath.Glob(pattern + name)
+		if len(matches) > 0 {
+			return matches[len(matches)-1], nil
+		}
+	}
+	return "", fmt.Errorf("%s not found; install PostgreSQL or set OLU_TEST_POSTGRES_DSN", name)
+}
+
+func startPostgres() {
+	if dsn := os.Getenv("OLU_TEST_POSTGRES_DSN"); dsn != "" {
+		pgServer.dsn = dsn
+		return
+	}
+
+	initdb, err := findPostgresBinary("initdb")
+	if err != nil {
+		pgServer.err = err
+		return
+	}
+	pgCtl, err := findPostgresBinary("pg_ctl")
+	if err != nil {
+		pgServer.err = err
+		return
+	}
+	if os.Geteuid() == 0 {
+		pgServer.err = fmt.Errorf("initdb cannot run as root; set OLU_TEST_POSTGRES_DSN")
+		return
+	}
+
+	// Keep the path short: it holds the server's unix socket
+	dir, err := os.MkdirTemp("", "olu-pg-")
+	if err != nil {
+		pgServer.err = err
+		return
+	}
+	dataDir := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", dataDir, "-U", "olu", "-A", "trust", "-E", "UTF8", "--no-locale", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		pgServer.err = fmt.Errorf("initdb failed: %v: %s", err, out)
+		return
+	}
+
+	// Listen on a unix socket in dir only, so nothing else can conflict
+	options := fmt.Sprintf("-F -h '' -k %s -p 5432", dir)
+	out, err = exec.Command(pgCtl, "-D", dataDir, "-l", filepath.Join(dir, "server.log"), "-o", options, "-w", "start").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		pgServer.err = fmt.Errorf("pg_ctl start failed: %v: %s", err, out)
+		return
+	}
+
+	pgServer.dir = dir
+	pgServer.pgCtl = pgCtl
+	pgServer.dsn = fmt.Sprintf("host=%s port=5432 user=olu dbname=postgres sslmode=disable", dir)
+}
+
+func stopPostgres() {
+	if pgServer.dir == "" {
+		return
+	}
+	exec.Command(pgServer.pgCtl, "-D", filepath.Join(pgServer.dir, "data"), "-m", "immediate", "stop").Run()
+	os.RemoveAll(pgServer.dir)
+}
+
+// postgresTestDSN returns a DSN whose search_path is a fresh schema
+func postgresTestDSN(t *testing.T) string {
+	t.Helper()
+
+	pgServer.once.Do(startPostgres)
+	if pgServer.err != nil {
+		t.Skipf("PostgreSQL unavailable: %v", pgServer.err)
+	}
+
+	admin, err := sql.Open("postgres", pgServer.dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+
+	schema := fmt.Sprintf("olu_test_%d_%d", os.Getpid(), atomic.AddInt64(&pgServer.schemas, 1))
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		admin, err := sql.Open("postgres", pgServer.dsn)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	// lib/pq sends unknown DSN keys to the server as run-time parameters
+	if strings.HasPrefix(pgServer.dsn, "postgres://") || strings.HasPrefix(pgServer.dsn, "postgresql://") {
+		separator := "?"
+		if strings.Contains(pgServer.dsn, "?") {
+			separator = "&"
+		}
+		return pgServer.dsn + separator + "search_path=" + schema
+	}
+	return pgServer.dsn + " search_path=" + schema
+}
+
+func setupPostgresTest(t *testing.T, config map[string]interface{}) storage.Store {
+	t.Helper()
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["dsn"] = postgresTestDSN(t)
+
+	store, err := storage.NewStore("postgres", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func resultIDs(results []map[string]interface{}) []float64 {
+	ids := make([]float64, len(results))
+	for i, r := range results {
+		ids[i] = r["id"].(float64)
+	}
+	return ids
+}
+
+// =============================================================================
+// Basic CRUD Tests
+// =============================================================================
+
+func TestPostgresStore_CRUD(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, "users", testUserData("alice"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	id2, err := store.Create(ctx, "users", testUserData("bob"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, id2)
+
+	data, err := store.Get(ctx, "users", id)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", data["name"])
+	assert.Equal(t, float64(id), data["id"])
+
+	require.NoError(t, store.Patch(ctx, "users", id, map[string]interface{}{"age": 31, "email": nil}))
+	data, _ = store.Get(ctx, "users", id)
+	assert.Equal(t, float64(31), data["age"])
+	assert.NotContains(t, data, "email")
+
+	require.NoError(t, store.Update(ctx, "users", id, map[string]interface{}{"name": "Alice"}))
+	data, _ = store.Get(ctx, "users", id)
+	assert.Equal(t, "Alice", data["name"])
+	assert.NotContains(t, data, "age")
+
+	results, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, resultIDs(results))
+
+	require.NoError(t, store.Delete(ctx, "users", id))
+	assert.False(t, store.Exists(ctx, "users", id))
+	assert.True(t, store.Exists(ctx, "users", id2))
+
+	_, err = store.Get(ctx, "users", id)
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Update(ctx, "users", id, testUserData("x")), storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Patch(ctx, "users", id, testUserData("x")), storage.ErrNotFound))
+	assert.True(t, errors.Is(store.Delete(ctx, "users", id), storage.ErrNotFound))
+}
+
+func TestPostgresStore_SaveUpdatesSequence(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "users", 10, testUserData("ten")))
+	assert.True(t, errors.Is(store.Save(ctx, "users", 10, testUserData("again")), storage.ErrAlreadyExists))
+
+	id, err := store.Create(ctx, "users", testUserData("next"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, id)
+
+	// Saving below the sequence leaves it alone
+	require.NoError(t, store.Save(ctx, "users", 5, testUserData("five")))
+	next, err := store.(storage.IDGenerator).NextID(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, 12, next)
+}
+
+func TestPostgresStore_Migrate(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	migrator, ok := store.(storage.Migrator)
+	require.True(t, ok, "PostgresStore should implement Migrator interface")
+	require.NoError(t, migrator.Migrate(ctx), "Migrate should be idempotent")
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, version)
+
+	info := store.(storage.InfoProvider).Info()
+	assert.Equal(t, "postgres", info.Type)
+	assert.True(t, info.SupportsSearch)
+	assert.True(t, info.SupportsBatch)
+	assert.True(t, info.SupportsTransaction)
+	assert.False(t, info.SupportsFullText)
+}
+
+// =============================================================================
+// Search and Query Tests
+// =============================================================================
+
+func TestPostgresStore_Search(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	users := []map[string]interface{}{
+		{"name": "Alice Smith", "age": 34, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "alan Jones", "age": 25, "address": map[string]interface{}{"city": "Porto"}},
+		{"name": "Bob_Smith", "age": 41, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "Carol", "age": 30},
+	}
+	for _, user := range users {
+		_, err := store.Create(ctx, "users", user)
+		require.NoError(t, err)
+	}
+
+	cases := []struct {
+		name     string
+		field    string
+		query    string
+		match    string
+		expected []float64
+	}{
+		{"contains ignores case", "name", "AL", storage.MatchContains, []float64{1, 2}},
+		{"exact ignores case", "name", "ALICE SMITH", storage.MatchExact, []float64{1}},
+		{"wildcards match literally", "name", "_", storage.MatchContains, []float64{3}},
+		{"numbers match their text", "age", "3", storage.MatchStarts, []float64{1, 4}},
+		{"nested path", "address.city", "bon", storage.MatchEnds, []float64{1, 3}},
+	}
+
+	searcher, ok := store.(storage.Searcher)
+	require.True(t, ok, "PostgresStore should implement Searcher interface")
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := searcher.Search(ctx, "users", tc.field, tc.query, tc.match)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resultIDs(results))
+		})
+	}
+
+	t.Run("Compound query", func(t *testing.T) {
+		query := storage.SearchQuery{And: []storage.SearchQuery{
+			{Field: "address.city", Op: storage.OpEq, Value: "Lisbon"},
+			{Or: []storage.SearchQuery{
+				{Field: "name", Op: storage.OpStarts, Value: "alice"},
+				{Field: "age", Op: storage.OpGt, Value: 40},
+			}},
+		}}
+
+		results, err := storage.SearchWithQuery(ctx, store, "users", query)
+		require.NoError(t, err)
+		assert.Equal(t, []float64{1, 3}, resultIDs(results))
+	})
+
+	_, err := searcher.Search(ctx, "users", "name", "x", "fuzzy")
+	assert.Error(t, err)
+}
+
+func TestPostgresStore_Query(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	users := []map[string]interface{}{
+		{"name": "Alice", "age": 34, "active": true, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "bob", "age": 25, "active": false, "address": map[string]interface{}{"city": "Porto"}},
+		{"name": "Carol", "age": "unknown"},
+		{"name": "Dave", "age": 41, "active": true},
+		{"name": "Eve", "age": nil},
+	}
+	for _, user := range users {
+		_, err := store.Create(ctx, "users", user)
+		require.NoError(t, err)
+	}
+
+	// The same cases as TestStoreQuery, so results match the other stores
+	cases := []struct {
+		name     string
+		opts     storage.ListOptions
+		expected []float64
+	}{
+		{"gte", storage.ListOptions{Filters: []models.FilterParam{{Field: "age", Operator: "gte", Value: "30"}}}, []float64{1, 3, 4}},
+		{"lt", storage.ListOptions{Filters: []models.FilterParam{{Field: "age", Operator: "lt", Value: "30"}}}, []float64{2}},
+		{"ne skips missing", storage.ListOptions{Filters: []models.FilterParam{{Field: "active", Operator: "ne", Value: "true"}}}, []float64{2}},
+		{"nested path", storage.ListOptions{Filters: []models.FilterParam{{Field: "address.city", Operator: "eq", Value: "Porto"}}}, []float64{2}},
+		{"in", storage.ListOptions{Filters: []models.FilterParam{{Field: "age", Operator: "in", Value: "25,41"}}}, []float64{2, 4}},
+		{"contains is case-insensitive", storage.ListOptions{Filters: []models.FilterParam{{Field: "name", Operator: "contains", Value: "B"}}}, []float64{2}},
+		{"exists", storage.ListOptions{Filters: []models.FilterParam{{Field: "address", Operator: "exists", Value: "true"}}}, []float64{1, 2}},
+		{"sort numbers before strings", storage.ListOptions{Sort: []models.SortParam{{Field: "age", Order: "asc"}}}, []float64{5, 2, 1, 4, 3}},
+		{"sort desc", storage.ListOptions{Sort: []models.SortParam{{Field: "name", Order: "desc"}}}, []float64{2, 5, 4, 3, 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := storage.ListWithOptions(ctx, store, "users", tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resultIDs(results))
+		})
+	}
+
+	t.Run("Projection", func(t *testing.T) {
+		results, err := storage.ListWithOptions(ctx, store, "users", storage.ListOptions{Fields: []string{"name"}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "Alice"}, results[0])
+	})
+}
+
+// =============================================================================
+// Graph Tests
+// =============================================================================
+
+func TestPostgresStore_GraphSync(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	manager1, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager1"})
+	manager2, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager2"})
+	employee, _ := store.Create(ctx, "users", map[string]interface{}{
+		"name":    "Employee",
+		"manager": map[string]interface{}{"type": "REF", "entity": "users", "id": manager1},
+		"mentors": []interface{}{
+			map[string]interface{}{"type": "REF", "entity": "users", "id": manager2},
+		},
+	})
+
+	gn, ok := store.(storage.GraphNeighbors)
+	require.True(t, ok, "PostgresStore should implement GraphNeighbors interface")
+
+	neighbors, err := gn.GetNeighbors(ctx, "users", employee, "out")
+	require.NoError(t, err)
+	require.Len(t, neighbors, 2)
+	assert.Equal(t, "manager", neighbors[0]["_relationship"])
+	assert.Equal(t, "Manager1", neighbors[0]["name"])
+	assert.Equal(t, "mentors[0]", neighbors[1]["_relationship"])
+	assert.Equal(t, "out", neighbors[1]["_direction"])
+	assert.Equal(t, "users", neighbors[1]["_neighbor_type"])
+
+	// Updating replaces the old edges
+	require.NoError(t, store.Patch(ctx, "users", employee, map[string]interface{}{
+		"manager": map[string]interface{}{"type": "REF", "entity": "users", "id": manager2},
+		"mentors": nil,
+	}))
+	incoming, _ := gn.GetNeighbors(ctx, "users", manager1, "in")
+	assert.Len(t, incoming, 0)
+	incoming, _ = gn.GetNeighbors(ctx, "users", manager2, "in")
+	assert.Len(t, incoming, 1)
+
+	// Deleting removes edges in both directions
+	require.NoError(t, store.Delete(ctx, "users", employee))
+	incoming, _ = gn.GetNeighbors(ctx, "users", manager2, "in")
+	assert.Len(t, incoming, 0)
+
+	_, err = gn.GetNeighbors(ctx, "users", manager2, "both")
+	assert.Error(t, err)
+}
+
+func TestPostgresStore_GraphIntegrity(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	managerID, _ := store.Create(ctx, "users", map[string]interface{}{"name": "Manager"})
+	employeeID, _ := store.Create(ctx, "users", map[string]interface{}{
+		"name":    "Employee",
+		"manager": map[string]interface{}{"type": "REF", "entity": "users", "id": managerID},
+	})
+	store.Create(ctx, "posts", map[string]interface{}{
+		"author": map[string]interface{}{"type": "REF", "entity": "users", "id": employeeID},
+	})
+
+	gi, ok := store.(storage.GraphIntegrity)
+	require.True(t, ok, "PostgresStore should implement GraphIntegrity interface")
+	require.NoError(t, gi.VerifyGraphIntegrity(ctx))
+
+	// Deleting a target drops its incoming edges, leaving a dangling reference
+	require.NoError(t, store.Delete(ctx, "users", managerID))
+	assert.Error(t, gi.VerifyGraphIntegrity(ctx))
+
+	require.NoError(t, gi.RebuildGraph(ctx))
+	require.NoError(t, gi.VerifyGraphIntegrity(ctx))
+}
+
+// =============================================================================
+// Transaction and Concurrency Tests
+// =============================================================================
+
+func TestPostgresStore_Transactions(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	ts, ok := store.(storage.Transactional)
+	require.True(t, ok, "PostgresStore should implement Transactional interface")
+
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := ts.Begin(ctx)
+		require.NoError(t, err)
+
+		id, err := tx.Create(ctx, "users", testUserData("alice"))
+		require.NoError(t, err)
+		assert.True(t, tx.Exists(ctx, "users", id))
+		assert.False(t, store.Exists(ctx, "users", id), "Uncommitted writes should not be visible")
+
+		// A conflicting save fails without aborting the transaction
+		assert.True(t, errors.Is(tx.Save(ctx, "users", id, testUserData("dup")), storage.ErrAlreadyExists))
+		_, err = tx.Create(ctx, "users", testUserData("bob"))
+		require.NoError(t, err)
+
+		require.NoError(t, tx.Commit())
+		assert.Error(t, tx.Rollback(), "Rollback after Commit should fail")
+
+		results, _ := store.List(ctx, "users")
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		tx, err := ts.Begin(ctx)
+		require.NoError(t, err)
+
+		id, err := tx.Create(ctx, "users", testUserData("ghost"))
+		require.NoError(t, err)
+		require.NoError(t, tx.Delete(ctx, "users", 1))
+		require.NoError(t, tx.Rollback())
+
+		assert.False(t, store.Exists(ctx, "users", id))
+		assert.True(t, store.Exists(ctx, "users", 1))
+
+		next, _ := store.Create(ctx, "users", testUserData("next"))
+		assert.Equal(t, id, next, "A rolled back ID should be reused")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		batcher, ok := store.(storage.Batcher)
+		require.True(t, ok, "PostgresStore should implement Batcher interface")
+
+		ids, err := batcher.BatchCreate(ctx, "tags", []map[string]interface{}{{"name": "a"}, {"name": "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, ids)
+
+		err = batcher.BatchDelete(ctx, "tags", []int{1, 99})
+		assert.Error(t, err)
+		assert.True(t, store.Exists(ctx, "tags", 1), "A failed batch delete should delete nothing")
+	})
+}
+
+func TestPostgresStore_ConcurrentCreates(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	ctx := context.Background()
+
+	count := 20
+	var wg sync.WaitGroup
+	ids := make(chan int, count)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			id, err := store.Create(ctx, "users", testUserData(fmt.Sprintf("user%d", n)))
+			if err != nil {
+				t.Errorf("Concurrent create error: %v", err)
+				return
+			}
+			ids <- id
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "Duplicate ID: %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, count)
+}
+
+// =============================================================================
+// Full-Text Search Tests
+// =============================================================================
+
+func TestPostgresStore_Schemas(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	testSchemaStore(t, store)
+}
+
+func TestPostgresStore_Revisions(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	testRevisioner(t, store)
+}
+
+func TestPostgresStore_History(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	testHistorian(t, store)
+}
+
+func TestPostgresStore_SoftDelete(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	testSoftDeleter(t, store)
+	testTxSoftDeleter(t, store)
+}
+
+func TestPostgresStore_ChangeLog(t *testing.T) {
+	store := setupPostgresTest(t, nil)
+	testChangeLog(t, store)
+	testTxChangeLog(t, store)
+}
+
+func TestPostgresStore_FullText(t *testing.T) {
+	store := setupPostgresTest(t, map[string]interface{}{"fulltext": true})
+	ctx := context.Background()
+
+	store.Create(ctx, "articles", map[string]interface{}{"title": "Café culture", "body": "Coffee, coffee and more coffee"})
+	store.Create(ctx, "articles", map[string]interface{}{"title": "Tea", "body": "Not coffee"})
+	store.Create(ctx, "notes", map[string]interface{}{"text": "Buy coffee beans", "author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1}})
+
+	searcher, ok := store.(storage.FullTextSearcher)
+	require.True(t, ok, "PostgresStore should implement FullTextSearcher interface")
+
+	hits, err := searcher.SearchText(ctx, "Coffee", nil, 10)
+	require.NoError(t, err)
+	require.Len(t, hits, 3)
+	assert.Equal(t, "articles", hits[0].Entity)
+	assert.Equal(t, 1, hits[0].ID)
+	assert.Contains(t, hits[0].Snippet, "<mark>")
+	assert.Equal(t, "Café culture", hits[0].Data["title"])
+
+	hits, _ = searcher.SearchText(ctx, "café", []string{"articles"}, 10)
+	assert.Len(t, hits, 1)
+
+	hits, _ = searcher.SearchText(ctx, "users", nil, 10)
+	assert.Len(t, hits, 0, "References should not be indexed")
+
+	hits, _ = searcher.SearchText(ctx, "coffee", nil, 1)
+	assert.Len(t, hits, 1)
+
+	require.NoError(t, store.Delete(ctx, "notes", 1))
+	hits, _ = searcher.SearchText(ctx, "beans", nil, 10)
+	assert.Len(t, hits, 0)
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (s *PostgresStore) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.trash(ctx, tx, entity, id, expected)
+	})
+}
+
+// trash moves an entity to the trash within a transaction
+func (s *PostgresStore) trash(ctx context.Context, tx *sql.Tx, entity string, id int, expected int) error {
+	if _, err := s.revision(ctx, tx, entity, id, expected); err != nil {
+		return err
+	}
+	
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO trash (entity_type, id, data)
+		SELECT entity_type, id, data FROM entities
+		WHERE entity_type = $1 AND id = $2
+		ON CONFLICT (entity_type, id) DO UPDATE
+		SET data = excluded.data, deleted_at = excluded.deleted_at
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to trash entity: %w", err)
+	}
+	
+	// Keep the edges other entities hold to it, so restoring it can bring
+	// them back
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO dormant_edges (source_entity, source_id, target_entity, target_id, relationship_name)
+		SELECT source_entity, source_id, target_entity, target_id, relationship_name FROM graph_edges
+		WHERE target_entity = $1 AND target_id = $2
+		  AND NOT (source_entity = $1 AND source_id = $2)
+		ON CONFLICT DO NOTHING
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to keep graph edges: %w", err)
+	}
+	
+	return s.delete(ctx, tx, entity, id)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (s *PostgresStore) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		data, err = s.restore(ctx, tx, entity, id)
+		return err
+	})
+	return data, err
+}
+
+// restore brings a trashed entity back within a transaction
+func (s *PostgresStore) restore(ctx context.Context, tx *sql.Tx, entity string, id int) (map[string]interface{}, error) {
+	var jsonData []byte
+	err := tx.QueryRowContext(ctx, `
+		DELETE FROM trash
+		WHERE entity_type = $1 AND id = $2
+		RETURNING data
+	`, entity, id).Scan(&jsonData)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s with id %d in the trash", ErrNotFound, entity, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	
+	// Saving it rebuilds its own edges from its references
+	if err := s.save(ctx, tx, entity, id, data); err != nil {
+		return nil, err
+	}
+	if err := s.wakeDormantEdges(ctx, tx, entity, id); err != nil {
+		return nil, err
+	}
+	return withID(data, id), nil
+}
+
+// wakeDormantEdges re-syncs the edges of every live entity that pointed at a
+// restored one, which brings back those that still do
+func (s *PostgresStore) wakeDormantEdges(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM dormant_edges
+		WHERE target_entity = $1 AND target_id = $2
+		RETURNING source_entity, source_id
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to query dormant edges: %w", err)
+	}
+	
+	type node struct {
+		entity string
+		id     int
+	}
+	seen := make(map[node]bool)
+	var sources []node
+	for rows.Next() {
+		var source node
+		if err := rows.Scan(&source.entity, &source.id); err != nil {
+			rows.Close()
+			return err
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	
+	for _, source := range sources {
+		data, err := s.get(ctx, tx, source.entity, source.id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.syncGraphEdges(ctx, tx, source.entity, source.id, data); err != nil {
+			return fmt.Errorf("failed to sync graph: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListTrash lists an entity type's trashed entities
+func (s *PostgresStore) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	return s.listTrash(ctx, s.db, entity)
+}
+
+// listTrash loads an entity type's trashed entities
+func (s *PostgresStore) listTrash(ctx context.Context, q sqlQuerier, entity string) ([]TrashedEntity, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT entity_type, id, data, deleted_at FROM trash
+		WHERE entity_type = $1
+		ORDER BY deleted_at DESC, id DESC
+	`, entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+	
+	return scanTrash(rows)
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (s *PostgresStore) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	var purged []TrashedEntity
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		purged, err = s.purgeTrash(ctx, tx, entity, before)
+		return err
+	})
+	return purged, err
+}
+
+// purgeTrash permanently removes the entities trashed before a cutoff within
+// a transaction
+func (s *PostgresStore) purgeTrash(ctx context.Context, tx *sql.Tx, entity string, before time.Time) ([]TrashedEntity, error) {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM trash
+		WHERE ($1::text = '' OR entity_type = $1) AND deleted_at < $2
+		RETURNING entity_type, id, data, deleted_at
+	`, entity, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	purged, err := scanTrash(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, item := range purged {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM dormant_edges
+			WHERE target_entity = $1 AND target_id = $2
+		`, item.Entity, item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to drop dormant edges: %w", err)
+		}
+	}
+	
+	sortTrash(purged)
+	return purged, nil
+}
+
+// scanTrash reads trash rows
+func scanTrash(rows *sql.Rows) ([]TrashedEntity, error) {
+	items := []TrashedEntity{}
+	for rows.Next() {
+		var item TrashedEntity
+		var jsonData []byte
+		if err := rows.Scan(&item.Entity, &item.ID, &jsonData, &item.DeletedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(jsonData, &item.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (t *postgresTransaction) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return t.store.trash(ctx, t.tx, entity, id, expected)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (t *postgresTransaction) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.restore(ctx, t.tx, entity, id)
+}
+
+// ListTrash lists an entity type's trashed entities, including uncommitted
+// changes
+func (t *postgresTransaction) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	return t.store.listTrash(ctx, t.tx, entity)
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (t *postgresTransaction) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	return t.store.purgeTrash(ctx, t.tx, entity, before)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// postgresTransaction runs Store operations inside a single PostgreSQL
+// transaction. Unlike sqliteTransaction it takes no store-wide lock: other
+// writers proceed concurrently and conflict only on the rows they share.
+type postgresTransaction struct {
+	store *PostgresStore
+	tx    *sql.Tx
+	done  bool
+}
+
+// Begin starts a transaction. Writes made through it become visible together
+// on Commit, or not at all on Rollback.
+func (s *PostgresStore) Begin(ctx context.Context) (Transaction, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	
+	return &postgresTransaction{store: s, tx: tx}, nil
+}
+
+// Create inserts a new entity with auto-generated ID
+func (t *postgresTransaction) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	return t.store.create(ctx, t.tx, entity, data)
+}
+
+// Get retrieves an entity by ID, including uncommitted changes
+func (t *postgresTransaction) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.get(ctx, t.tx, entity, id)
+}
+
+// Update replaces an entity completely
+func (t *postgresTransaction) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.update(ctx, t.tx, entity, id, data)
+}
+
+// Patch partially updates an entity
+func (t *postgresTransaction) Patch(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.patch(ctx, t.tx, entity, id, data)
+}
+
+// Delete removes an entity
+func (t *postgresTransaction) Delete(ctx context.Context, entity string, id int) error {
+	return t.store.delete(ctx, t.tx, entity, id)
+}
+
+// Save creates an entity with a specific ID (fails if exists)
+func (t *postgresTransaction) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.save(ctx, t.tx, entity, id, data)
+}
+
+// List returns all entities of a given type, including uncommitted changes
+func (t *postgresTransaction) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
+	return t.store.list(ctx, t.tx, entity)
+}
+
+// Exists checks if an entity exists, including uncommitted changes
+func (t *postgresTransaction) Exists(ctx context.Context, entity string, id int) bool {
+	exists, err := t.store.exists(ctx, t.tx, entity, id)
+	return err == nil && exists
+}
+
+// GetNeighbors returns graph neighbors for an entity, including uncommitted
+// changes
+func (t *postgresTransaction) GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error) {
+	return t.store.getNeighbors(ctx, t.tx, entity, id, direction)
+}
+
+// Close rolls back the transaction if it has not been committed
+func (t *postgresTransaction) Close() error {
+	if t.done {
+		return nil
+	}
+	return t.Rollback()
+}
+
+// Commit makes the transaction's writes visible
+func (t *postgresTransaction) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the transaction's writes
+func (t *postgresTransaction) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	
+	return t.tx.Rollback()
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// Filter operators supported by ListOptions
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpContains = "contains"
+	OpStarts   = "starts"
+	OpEnds     = "ends"
+	OpIn       = "in"
+	OpExists   = "exists"
+)
+
+var validOperators = map[string]bool{
+	OpEq: true, OpNe: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true,
+	OpContains: true, OpStarts: true, OpEnds: true, OpIn: true, OpExists: true,
+}
+
+// fieldPathPattern restricts field paths to dotted identifiers so they can be
+// safely turned into JSON paths
+var fieldPathPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+
+// ListOptions controls filtering, sorting and field projection of a listing
+type ListOptions struct {
+	Filters []models.FilterParam
+	Sort    []models.SortParam
+	Fields  []string
+}
+
+// Validate checks that every field path, operator and sort order is supported
+func (o ListOptions) Validate() error {
+	for _, f := range o.Filters {
+		if !fieldPathPattern.MatchString(f.Field) {
+			return fmt.Errorf("invalid filter field: %s", f.Field)
+		}
+		if !validOperators[f.Operator] {
+			return fmt.Errorf("invalid filter operator: %s", f.Operator)
+		}
+	}
+	for _, s := range o.Sort {
+		if !fieldPathPattern.MatchString(s.Field) {
+			return fmt.Errorf("invalid sort field: %s", s.Field)
+		}
+		if s.Order != "asc" && s.Order != "desc" {
+			return fmt.Errorf("invalid sort order: %s", s.Order)
+		}
+	}
+	for _, field := range o.Fields {
+		if !fieldPathPattern.MatchString(field) {
+			return fmt.Errorf("invalid field: %s", field)
+		}
+	}
+	return nil
+}
+
+// Querier defines optional filtered listing
+// Stores that can evaluate ListOptions natively should implement this interface
+type Querier interface {
+	Query(ctx context.Context, entity string, opts ListOptions) ([]map[string]interface{}, error)
+}
+
+// ListWithOptions lists entities, pushing the options down to the store when it
+// implements Querier and evaluating them in memory otherwise
+func ListWithOptions(ctx context.Context, store Store, entity string, opts ListOptions) ([]map[string]interface{}, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	
+	if q, ok := store.(Querier); ok {
+		return q.Query(ctx, entity, opts)
+	}
+	
+	items, err := store.List(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyListOptions(items, opts), nil
+}
+
+// ApplyListOptions filters, sorts and projects entities in memory.
+// Comparison semantics follow SQLite so that both backends return the same
+// results: missing and null values never match a predicate, numbers sort
+// before strings, and contains/starts/ends are case-insensitive for ASCII letters.
+func ApplyListOptions(items []map[string]interface{}, opts ListOptions) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if MatchesFilters(item, opts.Filters) {
+			results = append(results, item)
+		}
+	}
+	
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, s := range opts.Sort {
+			a, _ := LookupPath(results[i], s.Field)
+			b, _ := LookupPath(results[j], s.Field)
+			c := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			if s.Order == "desc" {
+				return c > 0
+			}
+			return c < 0
+		}
+		return entityID(results[i]) < entityID(results[j])
+	})
+	
+	if len(opts.Fields) > 0 {
+		for i, item := range results {
+			results[i] = ProjectFields(item, opts.Fields)
+		}
+	}
+	
+	return results
+}
+
+// LookupPath resolves a field path such as "address.city" or "tags[0]" in a document
+func LookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	return models.LookupPath(data, path)
+}
+
+// ProjectFields returns a copy of data containing only the given field paths.
+// The id is always kept.
+func ProjectFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields)+1)
+	if id, ok := data["id"]; ok {
+		result["id"] = id
+	}
+	
+	for _, field := range fields {
+		value, ok := LookupPath(data, field)
+		if !ok {
+			continue
+		}
+		
+		parts := strings.Split(field, ".")
+		target := result
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	
+	return result
+}
+
+// MatchesFilters reports whether a document satisfies every filter
+func MatchesFilters(item map[string]interface{}, filters []models.FilterParam) bool {
+	for _, f := range filters {
+		value, present := LookupPath(item, f.Field)
+		
+		if f.Operator == OpExists {
+			if present != parseBool(f.Value) {
+				return false
+			}
+			continue
+		}
+		
+		if !present || value == nil {
+			return false
+		}
+		
+		if !matchFilter(value, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(value interface{}, f models.FilterParam) bool {
+	switch f.Operator {
+	case OpContains, OpStarts, OpEnds:
+		text := foldCase(valueText(value))
+		query := foldCase(f.Value)
+		switch f.Operator {
+		case OpContains:
+			return strings.Contains(text, query)
+		case OpStarts:
+			return strings.HasPrefix(text, query)
+		default:
+			return strings.HasSuffix(text, query)
+		}
+	case OpIn:
+		for _, candidate := range strings.Split(f.Value, ",") {
+			if compareValues(value, parseFilterValue(candidate)) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	
+	c := compareValues(value, parseFilterValue(f.Value))
+	switch f.Operator {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// parseFilterValue converts a query-string value into a typed value:
+// "true"/"false" become booleans, numeric strings become float64 and
+// everything else stays a string
+func parseFilterValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// Storage classes in SQLite comparison order
+const (
+	classNull = iota
+	classNumeric
+	classText
+)
+
+// classify maps a JSON value onto the SQLite storage class json_extract
+// would produce for it. Booleans are numeric 1/0 and objects/arrays are text.
+func classify(v interface{}) (int, float64, string) {
+	switch val := v.(type) {
+	case nil:
+		return classNull, 0, ""
+	case bool:
+		if val {
+			return classNumeric, 1, ""
+		}
+		return classNumeric, 0, ""
+	case float64:
+		return classNumeric, val, ""
+	case int:
+		return classNumeric, float64(val), ""
+	case string:
+		return classText, 0, val
+	default:
+		data, _ := json.Marshal(val)
+		return classText, 0, string(data)
+	}
+}
+
+// compareValues orders two values the way SQLite orders json_extract results
+func compareValues(a, b interface{}) int {
+	classA, numA, textA := classify(a)
+	classB, numB, textB := classify(b)
+	
+	if classA != classB {
+		if classA < classB {
+			return -1
+		}
+		return 1
+	}
+	
+	switch classA {
+	case classNumeric:
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	case classText:
+		return strings.Compare(textA, textB)
+	}
+	return 0
+}
+
+// valueText renders a value the way SQLite casts it to text for LIKE
+func valueText(v interface{}) string {
+	class, num, text := classify(v)
+	if class != classNumeric {
+		return text
+	}
+	if num == float64(int64(num)) {
+		return strconv.FormatInt(int64(num), 10)
+	}
+	return strconv.FormatFloat(num, 'g', -1, 64)
+}
+
+func entityID(data map[string]interface{}) int {
+	switch id := data["id"].(type) {
+	case float64:
+		return int(id)
+	case int:
+		return id
+	}
+	return 0
+}
+
+func parseBool(val string) bool {
+	val = strings.ToLower(val)
+	return val == "true" || val == "1" || val == "yes"
+}
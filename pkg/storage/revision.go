@@ -0,0 +1,13 @@
+package storage
+
+import "fmt"
+
+// checkRevision fails with ErrRevisionMismatch unless an entity's current
+// revision is the expected one. An expected revision of 0 matches any.
+func checkRevision(entity string, id int, current, expected int) error {
+	if expected != 0 && current != expected {
+		return fmt.Errorf("%w: %s with id %d is at revision %d, not %d",
+			ErrRevisionMismatch, entity, id, current, expected)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ha1tch/olu/pkg/models"
+)
+
+// Search match types for Searcher.Search. Every match type compares text
+// case-insensitively, folding ASCII letters only, as SQLite's lower() and
+// LIKE do. Numbers and booleans match their text form ("30", "1", "0").
+const (
+	MatchContains = "contains"
+	MatchStarts   = "starts"
+	MatchEnds     = "ends"
+	MatchExact    = "exact"
+)
+
+// maxSearchDepth bounds the nesting of AND/OR groups in a SearchQuery
+const maxSearchDepth = 10
+
+// SearchQuery is a tree of field conditions. A node is either a group, with
+// And or Or children, or a condition on a field. Conditions use the filter
+// operators of ListOptions with the same semantics, so eq is case-sensitive
+// while contains, starts and ends are not.
+type SearchQuery struct {
+	And   []SearchQuery `json:"and,omitempty"`
+	Or    []SearchQuery `json:"or,omitempty"`
+	Field string        `json:"field,omitempty"`
+	Op    string        `json:"op,omitempty"`
+	Value interface{}   `json:"value,omitempty"`
+}
+
+// CompoundSearcher defines optional evaluation of compound search queries
+// Stores that can evaluate a SearchQuery natively should implement this interface
+type CompoundSearcher interface {
+	SearchQuery(ctx context.Context, entity string, query SearchQuery) ([]map[string]interface{}, error)
+}
+
+// Validate checks that the query is well formed
+func (q SearchQuery) Validate() error {
+	return q.validate(1)
+}
+
+func (q SearchQuery) validate(depth int) error {
+	if depth > maxSearchDepth {
+		return fmt.Errorf("search query nested deeper than %d levels", maxSearchDepth)
+	}
+	
+	isGroup := len(q.And) > 0 || len(q.Or) > 0
+	if isGroup {
+		if len(q.And) > 0 && len(q.Or) > 0 {
+			return fmt.Errorf("a group must use either and or or, not both")
+		}
+		if q.Field != "" || q.Op != "" {
+			return fmt.Errorf("a node must be either a group or a condition")
+		}
+		for _, child := range append(q.And, q.Or...) {
+			if err := child.validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	
+	if q.Field == "" {
+		return fmt.Errorf("a condition needs a field")
+	}
+	if !fieldPathPattern.MatchString(q.Field) {
+		return fmt.Errorf("invalid search field: %s", q.Field)
+	}
+	if !validOperators[q.Op] {
+		return fmt.Errorf("invalid search operator: %s", q.Op)
+	}
+	return nil
+}
+
+// filter converts a condition into the equivalent filter
+func (q SearchQuery) filter() models.FilterParam {
+	var value string
+	switch v := q.Value.(type) {
+	case nil:
+	case string:
+		value = v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = fmt.Sprint(part)
+		}
+		value = strings.Join(parts, ",")
+	default:
+		value = fmt.Sprint(v)
+	}
+	return models.FilterParam{Field: q.Field, Operator: q.Op, Value: value}
+}
+
+// Matches reports whether an entity satisfies the query
+func (q SearchQuery) Matches(item map[string]interface{}) bool {
+	switch {
+	case len(q.And) > 0:
+		for _, child := range q.And {
+			if !child.Matches(item) {
+				return false
+			}
+		}
+		return true
+	case len(q.Or) > 0:
+		for _, child := range q.Or {
+			if child.Matches(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return MatchesFilters(item, []models.FilterParam{q.filter()})
+}
+
+// SearchWithQuery evaluates a compound query, pushing it down to the store
+// when it implements CompoundSearcher and evaluating it in memory otherwise.
+// Results are ordered by id.
+func SearchWithQuery(ctx context.Context, store Store, entity string, query SearchQuery) ([]map[string]interface{}, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	
+	if cs, ok := store.(CompoundSearcher); ok {
+		return cs.SearchQuery(ctx, entity, query)
+	}
+	
+	items, err := store.List(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if query.Matches(item) {
+			results = append(results, item)
+		}
+	}
+	return ApplyListOptions(results, ListOptions{}), nil
+}
+
+// ValidateSearch checks the field path and match type of a single-field search
+func ValidateSearch(field string, matchType string) error {
+	if !fieldPathPattern.MatchString(field) {
+		return fmt.Errorf("invalid search field: %s", field)
+	}
+	switch matchType {
+	case MatchContains, MatchStarts, MatchEnds, MatchExact, "":
+		return nil
+	}
+	return fmt.Errorf("invalid match type: %s", matchType)
+}
+
+// SearchField runs a single-field text search, using the store's Searcher when
+// it has one and scanning every entity otherwise. Results are ordered by id.
+func SearchField(ctx context.Context, store Store, entity string, field string, query string, matchType string) ([]map[string]interface{}, error) {
+	if err := ValidateSearch(field, matchType); err != nil {
+		return nil, err
+	}
+	
+	var items []map[string]interface{}
+	var err error
+	if searcher, ok := store.(Searcher); ok {
+		items, err = searcher.Search(ctx, entity, field, query, matchType)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		all, err := store.List(ctx, entity)
+		if err != nil {
+			return nil, err
+		}
+		if items, err = searchItems(all, field, query, matchType); err != nil {
+			return nil, err
+		}
+	}
+	return ApplyListOptions(items, ListOptions{}), nil
+}
+
+// searchItems returns the items whose field matches a text search
+func searchItems(items []map[string]interface{}, field string, query string, matchType string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	for _, item := range items {
+		value, ok := LookupPath(item, field)
+		if !ok || value == nil {
+			continue
+		}
+		
+		matched, err := matchText(value, query, matchType)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			results = append(results, item)
+		}
+	}
+	return results, nil
+}
+
+// matchText reports whether a value's text form matches a search query
+func matchText(value interface{}, query string, matchType string) (bool, error) {
+	text := foldCase(valueText(value))
+	query = foldCase(query)
+	
+	switch matchType {
+	case MatchContains, "":
+		return strings.Contains(text, query), nil
+	case MatchStarts:
+		return strings.HasPrefix(text, query), nil
+	case MatchEnds:
+		return strings.HasSuffix(text, query), nil
+	case MatchExact:
+		return text == query, nil
+	}
+	return false, fmt.Errorf("invalid match type: %s", matchType)
+}
+
+// foldCase lowercases ASCII letters only, like SQLite's lower() and LIKE
+func foldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/ha1tch/olu/pkg/models"
 	_ "modernc.org/sqlite" // Pure Go SQLite driver
 )
 
@@ -25,6 +27,8 @@ type SQLiteConfig struct {
 	EnableForeignKeys bool
 	CacheSize        int  // Page cache size in KB
 	BusyTimeout      int  // Milliseconds to wait on locked database
+	EnableFullText   bool // Maintain an FTS5 index over string fields
+	SkipMigrations   bool // Open without migrating, e.g. to report schema status
 }
 
 // NewSQLiteStore creates a new SQLite-based storage
@@ -58,7 +62,7 @@ func NewSQLiteStore(dbPath string, config SQLiteConfig) (*SQLiteStore, error) {
 	return store, nil
 }
 
-// initialize creates the necessary tables and triggers
+// initialize applies pragmas, schema migrations and the full-text index
 func (s *SQLiteStore) initialize(ctx context.Context) error {
 	// Apply pragmas for performance and consistency
 	pragmas := []string{
@@ -75,59 +79,13 @@ func (s *SQLiteStore) initialize(ctx context.Context) error {
 		}
 	}
 	
-	// Create schema
-	schema := `
-		-- Main entities table (JSON blob approach)
-		CREATE TABLE IF NOT EXISTS entities (
-			entity_type TEXT NOT NULL,
-			id INTEGER NOT NULL,
-			data TEXT NOT NULL, -- JSON stored as TEXT
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (entity_type, id)
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_entity_type ON entities(entity_type);
-		CREATE INDEX IF NOT EXISTS idx_updated_at ON entities(updated_at);
-		
-		-- Graph relationships table (auto-synced via triggers)
-		CREATE TABLE IF NOT EXISTS graph_edges (
-			source_entity TEXT NOT NULL,
-			source_id INTEGER NOT NULL,
-			target_entity TEXT NOT NULL,
-			target_id INTEGER NOT NULL,
-			relationship_name TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (source_entity, source_id, target_entity, target_id, relationship_name)
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_graph_source ON graph_edges(source_entity, source_id);
-		CREATE INDEX IF NOT EXISTS idx_graph_target ON graph_edges(target_entity, target_id);
-		CREATE INDEX IF NOT EXISTS idx_graph_relationship ON graph_edges(relationship_name);
-		
-		-- ID sequences table (replaces _next_id.json files)
-		CREATE TABLE IF NOT EXISTS entity_sequences (
-			entity_type TEXT PRIMARY KEY,
-			next_id INTEGER NOT NULL DEFAULT 1
-		);
-		
-		-- Schema metadata table (optional schema storage)
-		CREATE TABLE IF NOT EXISTS schemas (
-			entity_type TEXT PRIMARY KEY,
-			schema TEXT NOT NULL, -- JSON schema
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-		
-		-- Version tracking for migrations
-		CREATE TABLE IF NOT EXISTS schema_version (
-			version INTEGER PRIMARY KEY,
-			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-	`
+	// Opened only to inspect or migrate the schema
+	if s.config.SkipMigrations {
+		return nil
+	}
 	
-	if _, err := s.db.ExecContext(ctx, schema); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+	if err := s.Migrate(ctx); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
 	}
 	
 	// Create triggers for automatic graph synchronization
@@ -135,10 +93,9 @@ func (s *SQLiteStore) initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to create triggers: %w", err)
 	}
 	
-	// Mark current schema version
-	if _, err := s.db.ExecContext(ctx, 
-		"INSERT OR IGNORE INTO schema_version (version) VALUES (1)"); err != nil {
-		return fmt.Errorf("failed to set schema version: %w", err)
+	// Create or drop the full-text index
+	if err := s.initFullText(ctx); err != nil {
+		return fmt.Errorf("failed to initialize full-text index: %w", err)
 	}
 	
 	return nil
@@ -176,23 +133,245 @@ func (s *SQLiteStore) Info() StoreInfo {
 		SupportsSearch:      true,
 		SupportsBatch:       true,
 		SupportsTransaction: true,
+		SupportsFullText:    s.config.EnableFullText,
 	}
 }
 
 // Create inserts a new entity with auto-generated ID
 func (s *SQLiteStore) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	var id int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		id, err = s.create(ctx, tx, entity, data)
+		return err
+	})
+	return id, err
+}
+
+// Get retrieves an entity by ID
+func (s *SQLiteStore) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.get(ctx, s.db, entity, id)
+}
+
+// Update replaces an entity completely
+func (s *SQLiteStore) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, entity, id, data)
+	})
+}
+
+// Patch partially updates an entity
+func (s *SQLiteStore) Patch(ctx context.Context, entity string, id int, updates map[string]interface{}) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.patch(ctx, tx, entity, id, updates)
+	})
+}
+
+// Delete removes an entity
+func (s *SQLiteStore) Delete(ctx context.Context, entity string, id int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.delete(ctx, tx, entity, id)
+	})
+}
+
+// Save creates an entity with a specific ID (fails if exists)
+func (s *SQLiteStore) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.save(ctx, tx, entity, id, data)
+	})
+}
+
+// List returns all entities of a given type
+func (s *SQLiteStore) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.list(ctx, s.db, entity)
+}
+
+// Exists checks if an entity exists
+func (s *SQLiteStore) Exists(ctx context.Context, entity string, id int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	exists, err := s.exists(ctx, s.db, entity, id)
+	return err == nil && exists
+}
+
+// ListEntities returns every entity type that has at least one entity
+func (s *SQLiteStore) ListEntities(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT entity_type FROM entities ORDER BY entity_type")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entity types: %w", err)
+	}
+	defer rows.Close()
+	
+	entities := []string{}
+	for rows.Next() {
+		var entity string
+		if err := rows.Scan(&entity); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+// BatchCreate inserts several entities in a single transaction, returning
+// their IDs in order. Either every entity is created or none is.
+func (s *SQLiteStore) BatchCreate(ctx context.Context, entity string, items []map[string]interface{}) ([]int, error) {
+	ids := make([]int, 0, len(items))
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for i, item := range items {
+			id, err := s.create(ctx, tx, entity, item)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// BatchSave saves several entities under their own IDs in a single
+// transaction. If any of them already exists, nothing is saved.
+func (s *SQLiteStore) BatchSave(ctx context.Context, entity string, items []map[string]interface{}) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for i, item := range items {
+			id := entityID(item)
+			if id <= 0 {
+				return fmt.Errorf("item %d: %w", i, ErrInvalidID)
+			}
+			if err := s.save(ctx, tx, entity, id, item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// BatchDelete removes several entities in a single transaction. If any of
+// them does not exist, nothing is deleted.
+func (s *SQLiteStore) BatchDelete(ctx context.Context, entity string, ids []int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := s.delete(ctx, tx, entity, id); err != nil {
+				return fmt.Errorf("id %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSchema creates or replaces the JSON schema of an entity type
+func (s *SQLiteStore) SaveSchema(ctx context.Context, entity string, schema map[string]interface{}) error {
+	jsonData, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO schemas (entity_type, schema) VALUES (?, ?)
+			ON CONFLICT(entity_type) DO UPDATE
+			SET schema = excluded.schema, updated_at = CURRENT_TIMESTAMP
+		`, entity, string(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to save schema: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteSchema removes the JSON schema of an entity type
+func (s *SQLiteStore) DeleteSchema(ctx context.Context, entity string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM schemas WHERE entity_type = ?", entity)
+		if err != nil {
+			return fmt.Errorf("failed to delete schema: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return fmt.Errorf("%w: schema for %s", ErrNotFound, entity)
+		}
+		return nil
+	})
+}
+
+// ListSchemas returns every stored JSON schema keyed by entity type
+func (s *SQLiteStore) ListSchemas(ctx context.Context) (map[string]map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, "SELECT entity_type, schema FROM schemas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	defer rows.Close()
+	
+	schemas := make(map[string]map[string]interface{})
+	for rows.Next() {
+		var entity, jsonData string
+		if err := rows.Scan(&entity, &jsonData); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(jsonData), &schema); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schema for %s: %w", entity, err)
+		}
+		schemas[entity] = schema
+	}
+	return schemas, rows.Err()
+}
+
+// Close closes the database connection
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// sqlQuerier is implemented by both *sql.DB and *sql.Tx, so reads can run
+// inside or outside a transaction
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction while holding the write lock, committing if
+// it succeeds and rolling back otherwise
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer tx.Rollback()
 	
+	if err := fn(tx); err != nil {
+		return err
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// create inserts a new entity with the next ID in the entity's sequence
+func (s *SQLiteStore) create(ctx context.Context, tx *sql.Tx, entity string, data map[string]interface{}) (int, error) {
 	// Get next ID
 	var nextID int
-	err = tx.QueryRowContext(ctx, `
+	err := tx.QueryRowContext(ctx, `
 		INSERT INTO entity_sequences (entity_type, next_id) 
 		VALUES (?, 1)
 		ON CONFLICT(entity_type) DO UPDATE SET next_id = next_id + 1
@@ -223,20 +402,22 @@ func (s *SQLiteStore) Create(ctx context.Context, entity string, data map[string
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert entity: %w", err)
 	}
+	if err := s.recordVersion(ctx, tx, entity, nextID); err != nil {
+		return 0, err
+	}
 	
 	// Manually sync graph edges
 	if err := s.syncGraphEdges(ctx, tx, entity, nextID, dataCopy); err != nil {
 		return 0, fmt.Errorf("failed to sync graph: %w", err)
 	}
-	
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit: %w", err)
+	if err := s.syncFullText(ctx, tx, entity, nextID, dataCopy); err != nil {
+		return 0, err
 	}
 	
 	return nextID, nil
 }
 
-// syncGraphEdges extracts REF fields and creates graph edges
+// syncGraphEdges extracts references and creates graph edges named by their JSON path
 func (s *SQLiteStore) syncGraphEdges(ctx context.Context, tx *sql.Tx, sourceEntity string, sourceID int, data map[string]interface{}) error {
 	// First, delete old edges from this entity
 	_, err := tx.ExecContext(ctx, `
@@ -247,47 +428,16 @@ func (s *SQLiteStore) syncGraphEdges(ctx context.Context, tx *sql.Tx, sourceEnti
 		return err
 	}
 	
-	// Extract and insert REF fields
-	for key, value := range data {
-		if key == "id" {
-			continue
-		}
-		
-		valueMap, ok := value.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		
-		refType, _ := valueMap["type"].(string)
-		if refType != "REF" {
-			continue
-		}
-		
-		targetEntity, _ := valueMap["entity"].(string)
-		if targetEntity == "" {
-			continue
-		}
-		
-		// Handle both float64 (from JSON unmarshal) and int
-		var targetID int
-		switch v := valueMap["id"].(type) {
-		case float64:
-			targetID = int(v)
-		case int:
-			targetID = v
-		default:
-			continue
-		}
-		
-		if targetID == 0 {
+	// Extract and insert references, including those nested in objects and arrays
+	for _, ref := range models.FindReferences(data) {
+		if ref.Path == "id" || ref.ID == 0 {
 			continue
 		}
 		
-		// Insert edge
 		_, err := tx.ExecContext(ctx, `
 			INSERT INTO graph_edges (source_entity, source_id, target_entity, target_id, relationship_name)
 			VALUES (?, ?, ?, ?, ?)
-		`, sourceEntity, sourceID, targetEntity, targetID, key)
+		`, sourceEntity, sourceID, ref.Entity, ref.ID, ref.Path)
 		if err != nil {
 			return err
 		}
@@ -296,13 +446,10 @@ func (s *SQLiteStore) syncGraphEdges(ctx context.Context, tx *sql.Tx, sourceEnti
 	return nil
 }
 
-// Get retrieves an entity by ID
-func (s *SQLiteStore) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
+// get loads an entity
+func (s *SQLiteStore) get(ctx context.Context, q sqlQuerier, entity string, id int) (map[string]interface{}, error) {
 	var jsonData string
-	err := s.db.QueryRowContext(ctx, `
+	err := q.QueryRowContext(ctx, `
 		SELECT data FROM entities 
 		WHERE entity_type = ? AND id = ?
 	`, entity, id).Scan(&jsonData)
@@ -322,17 +469,8 @@ func (s *SQLiteStore) Get(ctx context.Context, entity string, id int) (map[strin
 	return result, nil
 }
 
-// Update replaces an entity completely
-func (s *SQLiteStore) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	
+// update replaces an entity's data and its graph edges
+func (s *SQLiteStore) update(ctx context.Context, tx *sql.Tx, entity string, id int, data map[string]interface{}) error {
 	// Create a copy to avoid mutating input
 	dataCopy := make(map[string]interface{}, len(data)+1)
 	for k, v := range data {
@@ -346,10 +484,15 @@ func (s *SQLiteStore) Update(ctx context.Context, entity string, id int, data ma
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 	
+	// Keep the version being replaced if it predates history
+	if err := s.archiveVersion(ctx, tx, entity, id); err != nil {
+		return err
+	}
+	
 	// Update entity
 	result, err := tx.ExecContext(ctx, `
 		UPDATE entities 
-		SET data = ?, updated_at = CURRENT_TIMESTAMP 
+		SET data = ?, revision = revision + 1, updated_at = CURRENT_TIMESTAMP 
 		WHERE entity_type = ? AND id = ?
 	`, string(jsonData), entity, id)
 	if err != nil {
@@ -363,44 +506,27 @@ func (s *SQLiteStore) Update(ctx context.Context, entity string, id int, data ma
 	if rows == 0 {
 		return ErrNotFound
 	}
+	if err := s.recordVersion(ctx, tx, entity, id); err != nil {
+		return err
+	}
 	
 	// Manually sync graph edges
 	if err := s.syncGraphEdges(ctx, tx, entity, id, dataCopy); err != nil {
 		return fmt.Errorf("failed to sync graph: %w", err)
 	}
+	if err := s.syncFullText(ctx, tx, entity, id, dataCopy); err != nil {
+		return err
+	}
 	
-	return tx.Commit()
+	return nil
 }
 
-// Patch partially updates an entity
-func (s *SQLiteStore) Patch(ctx context.Context, entity string, id int, updates map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	tx, err := s.db.BeginTx(ctx, nil)
+// patch merges updates into an entity. Null values remove fields.
+func (s *SQLiteStore) patch(ctx context.Context, tx *sql.Tx, entity string, id int, updates map[string]interface{}) error {
+	existing, err := s.get(ctx, tx, entity, id)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-	
-	// Get existing data directly (we already hold the lock)
-	var jsonData string
-	err = tx.QueryRowContext(ctx, `
-		SELECT data FROM entities 
-		WHERE entity_type = ? AND id = ?
-	`, entity, id).Scan(&jsonData)
-	
-	if err == sql.ErrNoRows {
-		return ErrNotFound
-	}
-	if err != nil {
-		return fmt.Errorf("failed to query entity: %w", err)
-	}
-	
-	var existing map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonData), &existing); err != nil {
-		return fmt.Errorf("failed to unmarshal data: %w", err)
-	}
 	
 	// Merge updates into existing data
 	for key, value := range updates {
@@ -413,51 +539,14 @@ func (s *SQLiteStore) Patch(ctx context.Context, entity string, id int, updates
 		}
 	}
 	
-	// Ensure ID is set
-	existing["id"] = id
-	
-	// Marshal back to JSON
-	updatedJSON, err := json.Marshal(existing)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
-	}
-	
-	// Update directly (we already hold the lock)
-	result, err := tx.ExecContext(ctx, `
-		UPDATE entities 
-		SET data = ?, updated_at = CURRENT_TIMESTAMP 
-		WHERE entity_type = ? AND id = ?
-	`, string(updatedJSON), entity, id)
-	if err != nil {
-		return fmt.Errorf("failed to update entity: %w", err)
-	}
-	
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return ErrNotFound
-	}
-	
-	// Manually sync graph edges
-	if err := s.syncGraphEdges(ctx, tx, entity, id, existing); err != nil {
-		return fmt.Errorf("failed to sync graph: %w", err)
-	}
-	
-	return tx.Commit()
+	return s.update(ctx, tx, entity, id, existing)
 }
 
-// Delete removes an entity
-func (s *SQLiteStore) Delete(ctx context.Context, entity string, id int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
+// delete removes an entity and every edge touching it
+func (s *SQLiteStore) delete(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	if err := s.recordDelete(ctx, tx, entity, id); err != nil {
 		return err
 	}
-	defer tx.Rollback()
 	
 	// Delete entity
 	result, err := tx.ExecContext(ctx, `
@@ -486,23 +575,15 @@ func (s *SQLiteStore) Delete(ctx context.Context, entity string, id int) error {
 		return fmt.Errorf("failed to delete graph edges: %w", err)
 	}
 	
-	return tx.Commit()
+	return s.removeFullText(ctx, tx, entity, id)
 }
 
-// Save creates an entity with a specific ID (fails if exists)
-func (s *SQLiteStore) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	// Check if exists directly (we already hold the lock)
-	var exists bool
-	err := s.db.QueryRowContext(ctx, `
-		SELECT EXISTS(SELECT 1 FROM entities WHERE entity_type = ? AND id = ?)
-	`, entity, id).Scan(&exists)
+// save inserts an entity with a specific ID and advances the sequence past it
+func (s *SQLiteStore) save(ctx context.Context, tx *sql.Tx, entity string, id int, data map[string]interface{}) error {
+	exists, err := s.exists(ctx, tx, entity, id)
 	if err != nil {
 		return fmt.Errorf("failed to check existence: %w", err)
 	}
-	
 	if exists {
 		return ErrAlreadyExists
 	}
@@ -520,12 +601,6 @@ func (s *SQLiteStore) Save(ctx context.Context, entity string, id int, data map[
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 	
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	
 	// Update sequence if needed
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO entity_sequences (entity_type, next_id) 
@@ -537,29 +612,37 @@ func (s *SQLiteStore) Save(ctx context.Context, entity string, id int, data map[
 		return fmt.Errorf("failed to update sequence: %w", err)
 	}
 	
-	// Insert entity
+	// Insert entity, continuing the revisions of any earlier entity with
+	// this ID
+	revision, err := s.lastRecordedRevision(ctx, tx, entity, id)
+	if err != nil {
+		return err
+	}
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO entities (entity_type, id, data) 
-		VALUES (?, ?, ?)
-	`, entity, id, string(jsonData))
+		INSERT INTO entities (entity_type, id, data, revision) 
+		VALUES (?, ?, ?, ?)
+	`, entity, id, string(jsonData), revision+1)
 	if err != nil {
 		return fmt.Errorf("failed to save entity: %w", err)
 	}
+	if err := s.recordVersion(ctx, tx, entity, id); err != nil {
+		return err
+	}
 	
 	// Manually sync graph edges
 	if err := s.syncGraphEdges(ctx, tx, entity, id, dataCopy); err != nil {
 		return fmt.Errorf("failed to sync graph: %w", err)
 	}
+	if err := s.syncFullText(ctx, tx, entity, id, dataCopy); err != nil {
+		return err
+	}
 	
-	return tx.Commit()
+	return nil
 }
 
-// List returns all entities of a given type
-func (s *SQLiteStore) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	rows, err := s.db.QueryContext(ctx, `
+// list loads every entity of a type in ID order
+func (s *SQLiteStore) list(ctx context.Context, q sqlQuerier, entity string) ([]map[string]interface{}, error) {
+	rows, err := q.QueryContext(ctx, `
 		SELECT data FROM entities 
 		WHERE entity_type = ?
 		ORDER BY id
@@ -587,102 +670,242 @@ func (s *SQLiteStore) List(ctx context.Context, entity string) ([]map[string]int
 	return results, rows.Err()
 }
 
-// Exists checks if an entity exists
-func (s *SQLiteStore) Exists(ctx context.Context, entity string, id int) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
+// exists reports whether an entity exists
+func (s *SQLiteStore) exists(ctx context.Context, q sqlQuerier, entity string, id int) (bool, error) {
 	var exists bool
-	err := s.db.QueryRowContext(ctx, `
+	err := q.QueryRowContext(ctx, `
 		SELECT EXISTS(SELECT 1 FROM entities WHERE entity_type = ? AND id = ?)
 	`, entity, id).Scan(&exists)
-	
-	return err == nil && exists
-}
-
-// Close closes the database connection
-func (s *SQLiteStore) Close() error {
-	return s.db.Close()
+	return exists, err
 }
 
-// Search implements field-based search using JSON extraction
+// Search implements field-based search using JSON extraction. Every match
+// type is case-insensitive for ASCII letters, like the JSONFile store.
 func (s *SQLiteStore) Search(ctx context.Context, entity string, field string, query string, matchType string) ([]map[string]interface{}, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
-	var sqlQuery string
-	var args []interface{}
+	var predicate string
+	var pattern string
 	
 	switch matchType {
-	case "exact":
-		sqlQuery = `
-			SELECT data FROM entities 
-			WHERE entity_type = ? 
-			  AND json_extract(data, '$.' || ?) = ?
-			ORDER BY id
-		`
-		args = []interface{}{entity, field, query}
-		
-	case "contains":
-		sqlQuery = `
-			SELECT data FROM entities 
-			WHERE entity_type = ? 
-			  AND json_extract(data, '$.' || ?) LIKE ?
-			ORDER BY id
-		`
-		args = []interface{}{entity, field, "%" + query + "%"}
-		
-	case "starts":
-		sqlQuery = `
-			SELECT data FROM entities 
-			WHERE entity_type = ? 
-			  AND json_extract(data, '$.' || ?) LIKE ?
-			ORDER BY id
-		`
-		args = []interface{}{entity, field, query + "%"}
-		
-	case "ends":
-		sqlQuery = `
-			SELECT data FROM entities 
-			WHERE entity_type = ? 
-			  AND json_extract(data, '$.' || ?) LIKE ?
-			ORDER BY id
-		`
-		args = []interface{}{entity, field, "%" + query}
-		
+	case MatchExact:
+		predicate = "lower(json_extract(data, ?)) = lower(?)"
+		pattern = query
+	case MatchContains, "":
+		predicate = `json_extract(data, ?) LIKE ? ESCAPE '\'`
+		pattern = "%" + escapeLike(query) + "%"
+	case MatchStarts:
+		predicate = `json_extract(data, ?) LIKE ? ESCAPE '\'`
+		pattern = escapeLike(query) + "%"
+	case MatchEnds:
+		predicate = `json_extract(data, ?) LIKE ? ESCAPE '\'`
+		pattern = "%" + escapeLike(query)
 	default:
 		return nil, fmt.Errorf("invalid match type: %s", matchType)
 	}
 	
-	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM entities 
+		WHERE entity_type = ? AND `+predicate+`
+		ORDER BY id
+	`, entity, "$."+field, pattern)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
 	defer rows.Close()
 	
-	var results []map[string]interface{}
+	return scanEntities(rows)
+}
+
+// SearchQuery evaluates a compound search query as a tree of json_extract
+// predicates
+func (s *SQLiteStore) SearchQuery(ctx context.Context, entity string, query SearchQuery) ([]map[string]interface{}, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	
+	clause, args := searchClause(query)
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, 
+		"SELECT data FROM entities WHERE entity_type = ? AND "+clause+" ORDER BY id",
+		append([]interface{}{entity}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	
+	return scanEntities(rows)
+}
+
+// searchClause translates a search query into a parenthesised SQL predicate
+func searchClause(q SearchQuery) (string, []interface{}) {
+	var children []SearchQuery
+	var joiner string
+	switch {
+	case len(q.And) > 0:
+		children, joiner = q.And, " AND "
+	case len(q.Or) > 0:
+		children, joiner = q.Or, " OR "
+	default:
+		clause, args := filterClause(q.filter())
+		return "(" + clause + ")", args
+	}
+	
+	parts := make([]string, len(children))
+	var args []interface{}
+	for i, child := range children {
+		clause, childArgs := searchClause(child)
+		parts[i] = clause
+		args = append(args, childArgs...)
+	}
+	return "(" + strings.Join(parts, joiner) + ")", args
+}
+
+// scanEntities decodes rows holding a single data column
+func scanEntities(rows *sql.Rows) ([]map[string]interface{}, error) {
+	results := []map[string]interface{}{}
 	for rows.Next() {
 		var jsonData string
 		if err := rows.Scan(&jsonData); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		
 		var data map[string]interface{}
 		if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		
+		results = append(results, data)
+	}
+	
+	return results, rows.Err()
+}
+
+// Query lists entities with filters and sorting pushed down as json_extract
+// predicates and ORDER BY clauses
+func (s *SQLiteStore) Query(ctx context.Context, entity string, opts ListOptions) ([]map[string]interface{}, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	
+	sqlQuery := "SELECT data FROM entities WHERE entity_type = ?"
+	args := []interface{}{entity}
+	
+	for _, f := range opts.Filters {
+		clause, clauseArgs := filterClause(f)
+		sqlQuery += " AND " + clause
+		args = append(args, clauseArgs...)
+	}
+	
+	sqlQuery += " ORDER BY "
+	for _, sp := range opts.Sort {
+		direction := "ASC"
+		if sp.Order == "desc" {
+			direction = "DESC"
+		}
+		sqlQuery += "json_extract(data, ?) " + direction + ", "
+		args = append(args, "$."+sp.Field)
+	}
+	sqlQuery += "id"
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entities: %w", err)
+	}
+	defer rows.Close()
+	
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		var jsonData string
+		if err := rows.Scan(&jsonData); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
 		}
 		
+		if len(opts.Fields) > 0 {
+			data = ProjectFields(data, opts.Fields)
+		}
 		results = append(results, data)
 	}
 	
 	return results, rows.Err()
 }
 
+// filterClause translates a filter into a SQL predicate over json_extract
+func filterClause(f models.FilterParam) (string, []interface{}) {
+	path := "$." + f.Field
+	
+	switch f.Operator {
+	case OpExists:
+		if parseBool(f.Value) {
+			return "json_type(data, ?) IS NOT NULL", []interface{}{path}
+		}
+		return "json_type(data, ?) IS NULL", []interface{}{path}
+	case OpContains:
+		return `json_extract(data, ?) LIKE ? ESCAPE '\'`, []interface{}{path, "%" + escapeLike(f.Value) + "%"}
+	case OpStarts:
+		return `json_extract(data, ?) LIKE ? ESCAPE '\'`, []interface{}{path, escapeLike(f.Value) + "%"}
+	case OpEnds:
+		return `json_extract(data, ?) LIKE ? ESCAPE '\'`, []interface{}{path, "%" + escapeLike(f.Value)}
+	case OpIn:
+		candidates := strings.Split(f.Value, ",")
+		placeholders := make([]string, len(candidates))
+		args := []interface{}{path}
+		for i, candidate := range candidates {
+			placeholders[i] = "?"
+			args = append(args, sqlFilterArg(candidate))
+		}
+		return "json_extract(data, ?) IN (" + strings.Join(placeholders, ", ") + ")", args
+	}
+	
+	operators := map[string]string{
+		OpEq: "=", OpNe: "<>", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<=",
+	}
+	return "json_extract(data, ?) " + operators[f.Operator] + " ?", []interface{}{path, sqlFilterArg(f.Value)}
+}
+
+// sqlFilterArg binds a filter value with the same typing json_extract uses,
+// so booleans compare as 1/0 and numeric strings as numbers
+func sqlFilterArg(value string) interface{} {
+	switch v := parseFilterValue(value).(type) {
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	default:
+		return v
+	}
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "%", `\%`)
+	return strings.ReplaceAll(value, "_", `\_`)
+}
+
 // GetNeighbors returns graph neighbors for an entity
 func (s *SQLiteStore) GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
+	return s.getNeighbors(ctx, s.db, entity, id, direction)
+}
+
+// getNeighbors reads a node's neighbors through the given querier
+func (s *SQLiteStore) getNeighbors(ctx context.Context, q sqlQuerier, entity string, id int, direction string) ([]map[string]interface{}, error) {
 	var query string
 	if direction == "out" {
 		query = `
@@ -702,7 +925,7 @@ func (s *SQLiteStore) GetNeighbors(ctx context.Context, entity string, id int, d
 		return nil, fmt.Errorf("invalid direction: %s (must be 'in' or 'out')", direction)
 	}
 	
-	rows, err := s.db.QueryContext(ctx, query, entity, id)
+	rows, err := q.QueryContext(ctx, query, entity, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get neighbors: %w", err)
 	}
@@ -762,18 +985,12 @@ func (s *SQLiteStore) VerifyGraphIntegrity(ctx context.Context) error {
 			continue
 		}
 		
-		// Extract REF fields
-		for key, value := range data {
-			if valueMap, ok := value.(map[string]interface{}); ok {
-				if valueMap["type"] == "REF" {
-					targetEntity, _ := valueMap["entity"].(string)
-					targetID, _ := valueMap["id"].(float64)
-					if targetEntity != "" && targetID > 0 {
-						edgeKey := fmt.Sprintf("%s:%d:%s:%d:%s", 
-							entity, id, targetEntity, int(targetID), key)
-						expectedEdges[edgeKey] = true
-					}
-				}
+		// Extract references, including nested ones
+		for _, ref := range models.FindReferences(data) {
+			if ref.Path != "id" && ref.ID > 0 {
+				edgeKey := fmt.Sprintf("%s:%d:%s:%d:%s", 
+					entity, id, ref.Entity, ref.ID, ref.Path)
+				expectedEdges[edgeKey] = true
 			}
 		}
 	}
@@ -817,64 +1034,48 @@ func (s *SQLiteStore) VerifyGraphIntegrity(ctx context.Context) error {
 
 // RebuildGraph rebuilds the graph_edges table from JSON data
 func (s *SQLiteStore) RebuildGraph(ctx context.Context) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	
-	// Clear graph_edges
-	if _, err := tx.ExecContext(ctx, "DELETE FROM graph_edges"); err != nil {
-		return err
-	}
-	
-	// Rebuild from entities
-	rows, err := tx.QueryContext(ctx, "SELECT entity_type, id, data FROM entities")
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-	
-	for rows.Next() {
-		var entity string
-		var id int
-		var jsonData string
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		// Clear graph_edges
+		if _, err := tx.ExecContext(ctx, "DELETE FROM graph_edges"); err != nil {
+			return err
+		}
 		
-		if err := rows.Scan(&entity, &id, &jsonData); err != nil {
+		// Load every entity before writing, so reads and writes don't interleave
+		rows, err := tx.QueryContext(ctx, "SELECT entity_type, id, data FROM entities")
+		if err != nil {
 			return err
 		}
 		
-		var data map[string]interface{}
-		if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
-			continue
+		type document struct {
+			entity string
+			id     int
+			data   map[string]interface{}
+		}
+		var documents []document
+		for rows.Next() {
+			var doc document
+			var jsonData string
+			
+			if err := rows.Scan(&doc.entity, &doc.id, &jsonData); err != nil {
+				rows.Close()
+				return err
+			}
+			
+			if err := json.Unmarshal([]byte(jsonData), &doc.data); err != nil {
+				continue
+			}
+			documents = append(documents, doc)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
 		}
 		
-		// Extract and insert REF fields
-		for key, value := range data {
-			if valueMap, ok := value.(map[string]interface{}); ok {
-				if valueMap["type"] == "REF" {
-					targetEntity, _ := valueMap["entity"].(string)
-					targetIDFloat, _ := valueMap["id"].(float64)
-					targetID := int(targetIDFloat)
-					
-					if targetEntity != "" && targetID > 0 {
-						_, err := tx.ExecContext(ctx, `
-							INSERT INTO graph_edges 
-							(source_entity, source_id, target_entity, target_id, relationship_name)
-							VALUES (?, ?, ?, ?, ?)
-						`, entity, id, targetEntity, targetID, key)
-						
-						if err != nil {
-							return fmt.Errorf("failed to insert edge: %w", err)
-						}
-					}
-				}
+		for _, doc := range documents {
+			if err := s.syncGraphEdges(ctx, tx, doc.entity, doc.id, doc.data); err != nil {
+				return fmt.Errorf("failed to insert edge: %w", err)
 			}
 		}
-	}
-	
-	return tx.Commit()
+		return nil
+	})
 }
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// AppendChange records a change in the change log
+func (s *SQLiteStore) AppendChange(ctx context.Context, change *Change) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.appendChange(ctx, tx, change)
+	})
+}
+
+func (s *SQLiteStore) appendChange(ctx context.Context, tx *sql.Tx, change *Change) error {
+	// The sequence number is only known once the row is in, so it is left
+	// out of the stored JSON and added back on read
+	change.Seq = 0
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO changes (data)
+		VALUES (?)
+	`, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	change.Seq, err = result.LastInsertId()
+	return err
+}
+
+// AppendChange records a change in the change log, as part of the transaction
+func (t *sqliteTransaction) AppendChange(ctx context.Context, change *Change) error {
+	return t.store.appendChange(ctx, t.tx, change)
+}
+
+// LastChangeSeq returns the sequence number of the last change recorded
+func (s *SQLiteStore) LastChangeSeq(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM changes`).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query changes: %w", err)
+	}
+	return seq, nil
+}
+
+// Changes returns up to limit changes recorded after a sequence number
+func (s *SQLiteStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, data FROM changes
+		WHERE seq > ?
+		ORDER BY seq
+		LIMIT ?
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+	
+	changes := []Change{}
+	for rows.Next() {
+		var seq int64
+		var data string
+		if err := rows.Scan(&seq, &data); err != nil {
+			return nil, err
+		}
+		var change Change
+		if err := json.Unmarshal([]byte(data), &change); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal change: %w", err)
+		}
+		change.Seq = seq
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// initFullText creates the FTS5 index when full-text search is enabled and
+// rebuilds it if it has fallen out of step with the entities table. When
+// disabled the index is dropped, since writes no longer keep it in sync.
+//
+// fulltext_docs gives every entity a stable doc_id that serves as its FTS5
+// rowid; the rowid of entities itself may change on VACUUM.
+func (s *SQLiteStore) initFullText(ctx context.Context) error {
+	if !s.config.EnableFullText {
+		_, err := s.db.ExecContext(ctx, `
+			DROP TABLE IF EXISTS entities_fts;
+			DROP TABLE IF EXISTS fulltext_docs;
+		`)
+		return err
+	}
+	
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS fulltext_docs (
+			doc_id INTEGER PRIMARY KEY,
+			entity_type TEXT NOT NULL,
+			id INTEGER NOT NULL,
+			UNIQUE (entity_type, id)
+		);
+		
+		CREATE VIRTUAL TABLE IF NOT EXISTS entities_fts USING fts5(
+			content,
+			tokenize = 'unicode61 remove_diacritics 0'
+		);
+	`)
+	if err != nil {
+		return err
+	}
+	
+	var indexed, stored int
+	err = s.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM fulltext_docs), (SELECT COUNT(*) FROM entities)
+	`).Scan(&indexed, &stored)
+	if err != nil {
+		return err
+	}
+	if indexed == stored {
+		return nil
+	}
+	
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.rebuildFullText(ctx, tx)
+	})
+}
+
+// rebuildFullText reindexes every entity
+func (s *SQLiteStore) rebuildFullText(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM entities_fts; DELETE FROM fulltext_docs"); err != nil {
+		return fmt.Errorf("failed to clear full-text index: %w", err)
+	}
+	
+	rows, err := tx.QueryContext(ctx, "SELECT entity_type, id, data FROM entities")
+	if err != nil {
+		return fmt.Errorf("failed to list entities: %w", err)
+	}
+	
+	type doc struct {
+		entity string
+		id     int
+		data   map[string]interface{}
+	}
+	var docs []doc
+	for rows.Next() {
+		var d doc
+		var jsonData string
+		if err := rows.Scan(&d.entity, &d.id, &jsonData); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(jsonData), &d.data); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	
+	for _, d := range docs {
+		if err := s.syncFullText(ctx, tx, d.entity, d.id, d.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncFullText replaces an entity's entry in the full-text index
+func (s *SQLiteStore) syncFullText(ctx context.Context, tx *sql.Tx, entity string, id int, data map[string]interface{}) error {
+	if !s.config.EnableFullText {
+		return nil
+	}
+	
+	var docID int64
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO fulltext_docs (entity_type, id) VALUES (?, ?)
+		ON CONFLICT(entity_type, id) DO UPDATE SET id = excluded.id
+		RETURNING doc_id
+	`, entity, id).Scan(&docID)
+	if err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	
+	if _, err := tx.ExecContext(ctx, "DELETE FROM entities_fts WHERE rowid = ?", docID); err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	
+	content := textContent(data)
+	if content == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, 
+		"INSERT INTO entities_fts (rowid, content) VALUES (?, ?)", docID, content); err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	return nil
+}
+
+// removeFullText drops an entity from the full-text index
+func (s *SQLiteStore) removeFullText(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	if !s.config.EnableFullText {
+		return nil
+	}
+	
+	var docID int64
+	err := tx.QueryRowContext(ctx, `
+		DELETE FROM fulltext_docs WHERE entity_type = ? AND id = ?
+		RETURNING doc_id
+	`, entity, id).Scan(&docID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unindex entity: %w", err)
+	}
+	
+	if _, err := tx.ExecContext(ctx, "DELETE FROM entities_fts WHERE rowid = ?", docID); err != nil {
+		return fmt.Errorf("failed to unindex entity: %w", err)
+	}
+	return nil
+}
+
+// SearchText ranks entities with FTS5's bm25(), requiring every query term
+func (s *SQLiteStore) SearchText(ctx context.Context, query string, entities []string, limit int) ([]TextHit, error) {
+	if !s.config.EnableFullText {
+		return nil, ErrFullTextDisabled
+	}
+	
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []TextHit{}, nil
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	
+	args := []interface{}{snippetOpen, snippetClose, snippetTokens, ftsMatch(terms)}
+	entityClause := ""
+	if len(entities) > 0 {
+		placeholders := make([]string, len(entities))
+		for i, entity := range entities {
+			placeholders[i] = "?"
+			args = append(args, entity)
+		}
+		entityClause = "AND d.entity_type IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	args = append(args, limit)
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.entity_type, d.id, e.data, -bm25(entities_fts),
+		       snippet(entities_fts, 0, ?, ?, '…', ?)
+		FROM entities_fts
+		JOIN fulltext_docs d ON d.doc_id = entities_fts.rowid
+		JOIN entities e ON e.entity_type = d.entity_type AND e.id = d.id
+		WHERE entities_fts MATCH ? `+entityClause+`
+		ORDER BY bm25(entities_fts), d.entity_type, d.id
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	
+	hits := []TextHit{}
+	for rows.Next() {
+		var hit TextHit
+		var jsonData string
+		if err := rows.Scan(&hit.Entity, &hit.ID, &jsonData, &hit.Score, &hit.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(jsonData), &hit.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	
+	return hits, rows.Err()
+}
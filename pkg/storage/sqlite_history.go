@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// History lists an entity's versions oldest first, ending with the current
+// one. A deleted entity's history ends with the delete.
+func (s *SQLiteStore) History(ctx context.Context, entity string, id int) ([]EntityVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT revision, data, changed_at FROM entity_history
+		WHERE entity_type = ? AND id = ?
+		ORDER BY revision
+	`, entity, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+	
+	var versions []EntityVersion
+	for rows.Next() {
+		var version EntityVersion
+		var data, changedAt sql.NullString
+		if err := rows.Scan(&version.Revision, &data, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		if data.Valid {
+			if err := json.Unmarshal([]byte(data.String), &version.Data); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal version: %w", err)
+			}
+		} else {
+			version.Deleted = true
+		}
+		if changedAt.Valid {
+			version.ChangedAt = parseHistoryTime(changedAt.String)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	// An entity untouched since before history was kept has only its
+	// current version
+	data, revision, err := s.getRevision(ctx, s.db, entity, id)
+	if errors.Is(err, ErrNotFound) {
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("%w: %s with id %d", ErrNotFound, entity, id)
+		}
+		return versions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[len(versions)-1].Revision < revision {
+		versions = append(versions, EntityVersion{Revision: revision, Data: data})
+	}
+	return versions, nil
+}
+
+// copyToHistory copies an entity's current row into its history, unless that
+// revision is already recorded
+func (s *SQLiteStore) copyToHistory(ctx context.Context, tx *sql.Tx, entity string, id int, changedAt interface{}) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO entity_history (entity_type, id, revision, data, changed_at)
+		SELECT entity_type, id, revision, data, ? FROM entities
+		WHERE entity_type = ? AND id = ?
+	`, changedAt, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// recordVersion records the version a write has just produced
+func (s *SQLiteStore) recordVersion(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	return s.copyToHistory(ctx, tx, entity, id, historyTime(time.Now()))
+}
+
+// archiveVersion records the version a write is about to replace, which is
+// only missing from history if it was written before history was kept
+func (s *SQLiteStore) archiveVersion(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	return s.copyToHistory(ctx, tx, entity, id, nil)
+}
+
+// recordDelete archives the version being deleted and records the delete as
+// the revision after it
+func (s *SQLiteStore) recordDelete(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	if err := s.archiveVersion(ctx, tx, entity, id); err != nil {
+		return err
+	}
+	
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO entity_history (entity_type, id, revision, data, changed_at)
+		SELECT entity_type, id, revision + 1, NULL, ? FROM entities
+		WHERE entity_type = ? AND id = ?
+	`, historyTime(time.Now()), entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to record delete: %w", err)
+	}
+	return nil
+}
+
+// lastRecordedRevision returns the latest revision in an entity's history,
+// so an entity saved over a deleted one carries on from its revisions
+func (s *SQLiteStore) lastRecordedRevision(ctx context.Context, tx *sql.Tx, entity string, id int) (int, error) {
+	var revision int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(revision), 0) FROM entity_history
+		WHERE entity_type = ? AND id = ?
+	`, entity, id).Scan(&revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query history: %w", err)
+	}
+	return revision, nil
+}
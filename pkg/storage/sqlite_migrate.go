@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrationFiles embed.FS
+
+// sqliteMigrations is the ordered schema migration set of SQLiteStore. New
+// schema changes are added as the next numbered file; applied files must
+// never be edited, since their checksums are verified on every Migrate.
+var sqliteMigrations = mustLoadMigrations(sqliteMigrationFiles, "migrations/sqlite")
+
+// Migrate applies pending schema migrations in order. The whole run holds
+// SQLite's write lock (BEGIN IMMEDIATE), so concurrent migrators, in this
+// process or another, wait for each other and apply each migration once. If
+// a migration fails, everything applied in the run is rolled back.
+func (s *SQLiteStore) Migrate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", s.config.BusyTimeout)); err != nil {
+		return fmt.Errorf("failed to set pragma: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("failed to lock database for migration: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+	
+	if err := s.createMigrationTable(ctx, conn); err != nil {
+		return fmt.Errorf("failed to create migration table: %w", err)
+	}
+	
+	applied, err := s.appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := checkApplied(sqliteMigrations, applied); err != nil {
+		return err
+	}
+	
+	for _, m := range sqliteMigrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		if _, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_version (version, name, checksum) VALUES (?, ?, ?)",
+			m.version, m.name, m.checksum); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+	}
+	
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// createMigrationTable creates schema_version, upgrading the version-only
+// table of databases created before migrations were checksummed. Their
+// version 1 row was written by the DDL now kept as migration 1, so it is
+// adopted with that migration's checksum after rerunning it, which only
+// creates whatever is missing.
+func (s *SQLiteStore) createMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			checksum TEXT NOT NULL DEFAULT '',
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	
+	legacy, err := s.legacyMigrationTable(ctx, conn)
+	if err != nil || !legacy {
+		return err
+	}
+	
+	for _, column := range []string{"name", "checksum"} {
+		if _, err := conn.ExecContext(ctx,
+			"ALTER TABLE schema_version ADD COLUMN "+column+" TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	
+	initial := sqliteMigrations[0]
+	if _, err := conn.ExecContext(ctx, initial.sql); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", initial.version, initial.name, err)
+	}
+	_, err = conn.ExecContext(ctx,
+		"UPDATE schema_version SET name = ?, checksum = ? WHERE version = ?",
+		initial.name, initial.checksum, initial.version)
+	return err
+}
+
+// legacyMigrationTable reports whether schema_version lacks checksums
+func (s *SQLiteStore) legacyMigrationTable(ctx context.Context, q sqlQuerier) (bool, error) {
+	var columns int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('schema_version') WHERE name = 'checksum'").Scan(&columns)
+	return columns == 0, err
+}
+
+// appliedMigrations reads the migrations recorded in schema_version, which
+// may not exist yet
+func (s *SQLiteStore) appliedMigrations(ctx context.Context, q sqlQuerier) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+	
+	var tables int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&tables)
+	if err != nil || tables == 0 {
+		return applied, err
+	}
+	
+	query := "SELECT version, name, checksum, COALESCE(CAST(applied_at AS TEXT), '') FROM schema_version"
+	legacy, err := s.legacyMigrationTable(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if legacy {
+		query = "SELECT version, '', '', COALESCE(CAST(applied_at AS TEXT), '') FROM schema_version"
+	}
+	
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// Version returns the highest schema migration applied to the database
+func (s *SQLiteStore) Version(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	applied, err := s.appliedMigrations(ctx, s.db)
+	if err != nil {
+		return 0, err
+	}
+	
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Migrations lists every migration this build knows and any unknown ones
+// the database has applied
+func (s *SQLiteStore) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	applied, err := s.appliedMigrations(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatus(sqliteMigrations, applied), nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// GetRevision retrieves an entity together with its current revision
+func (s *SQLiteStore) GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.getRevision(ctx, s.db, entity, id)
+}
+
+// UpdateIf replaces an entity if it is still at the expected revision
+func (s *SQLiteStore) UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	var revision int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		revision, err = s.updateIf(ctx, tx, entity, id, data, expected)
+		return err
+	})
+	return revision, err
+}
+
+// PatchIf applies modify to an entity if it is still at the expected
+// revision. The write lock is held from the read to the write.
+func (s *SQLiteStore) PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	var data map[string]interface{}
+	var revision int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		data, revision, err = s.patchIf(ctx, tx, entity, id, expected, modify)
+		return err
+	})
+	return data, revision, err
+}
+
+// DeleteIf removes an entity if it is still at the expected revision
+func (s *SQLiteStore) DeleteIf(ctx context.Context, entity string, id int, expected int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.deleteIf(ctx, tx, entity, id, expected)
+	})
+}
+
+// getRevision loads an entity and its revision
+func (s *SQLiteStore) getRevision(ctx context.Context, q sqlQuerier, entity string, id int) (map[string]interface{}, int, error) {
+	var jsonData string
+	var revision int
+	err := q.QueryRowContext(ctx, `
+		SELECT data, revision FROM entities
+		WHERE entity_type = ? AND id = ?
+	`, entity, id).Scan(&jsonData, &revision)
+	
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query entity: %w", err)
+	}
+	
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonData), &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	return result, revision, nil
+}
+
+// revision reads an entity's revision and checks it against the expected one
+func (s *SQLiteStore) revision(ctx context.Context, tx *sql.Tx, entity string, id int, expected int) (int, error) {
+	var revision int
+	err := tx.QueryRowContext(ctx, `
+		SELECT revision FROM entities
+		WHERE entity_type = ? AND id = ?
+	`, entity, id).Scan(&revision)
+	
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query revision: %w", err)
+	}
+	return revision, checkRevision(entity, id, revision, expected)
+}
+
+// updateIf replaces an entity after checking its revision
+func (s *SQLiteStore) updateIf(ctx context.Context, tx *sql.Tx, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	revision, err := s.revision(ctx, tx, entity, id, expected)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.update(ctx, tx, entity, id, data); err != nil {
+		return 0, err
+	}
+	return revision + 1, nil
+}
+
+// patchIf reads, modifies and writes back an entity after checking its
+// revision
+func (s *SQLiteStore) patchIf(ctx context.Context, tx *sql.Tx, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	data, revision, err := s.getRevision(ctx, tx, entity, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkRevision(entity, id, revision, expected); err != nil {
+		return nil, 0, err
+	}
+	
+	if err := modify(data); err != nil {
+		return nil, 0, err
+	}
+	data["id"] = id
+	
+	if err := s.update(ctx, tx, entity, id, data); err != nil {
+		return nil, 0, err
+	}
+	return data, revision + 1, nil
+}
+
+// deleteIf removes an entity after checking its revision
+func (s *SQLiteStore) deleteIf(ctx context.Context, tx *sql.Tx, entity string, id int, expected int) error {
+	if _, err := s.revision(ctx, tx, entity, id, expected); err != nil {
+		return err
+	}
+	return s.delete(ctx, tx, entity, id)
+}
+
+// GetRevision retrieves an entity and its revision, including uncommitted
+// changes
+func (t *sqliteTransaction) GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error) {
+	return t.store.getRevision(ctx, t.tx, entity, id)
+}
+
+// UpdateIf replaces an entity if it is still at the expected revision
+func (t *sqliteTransaction) UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error) {
+	return t.store.updateIf(ctx, t.tx, entity, id, data, expected)
+}
+
+// PatchIf applies modify to an entity if it is still at the expected revision
+func (t *sqliteTransaction) PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error) {
+	return t.store.patchIf(ctx, t.tx, entity, id, expected, modify)
+}
+
+// DeleteIf removes an entity if it is still at the expected revision
+func (t *sqliteTransaction) DeleteIf(ctx context.Context, entity string, id int, expected int) error {
+	return t.store.deleteIf(ctx, t.tx, entity, id, expected)
+}
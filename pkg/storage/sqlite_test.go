@@ -2,11 +2,14 @@ package storage_test
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
+	"github.com/ha1tch/olu/pkg/models"
 	"github.com/ha1tch/olu/pkg/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -498,6 +501,103 @@ func TestSQLiteStore_SearchNoResults(t *testing.T) {
 	assert.Empty(t, results)
 }
 
+// =============================================================================
+// Query Tests
+// =============================================================================
+
+func TestSQLiteStore_QueryFilters(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	
+	store.Create(ctx, "users", map[string]interface{}{"name": "Alice", "age": 34, "active": true})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Bob", "age": 25, "active": false})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Carol", "age": 41, "active": true})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Dave_1"})
+	
+	querier, ok := store.(storage.Querier)
+	require.True(t, ok, "SQLiteStore should implement Querier interface")
+	
+	results, err := querier.Query(ctx, "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "age", Operator: "gte", Value: "30"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	
+	results, err = querier.Query(ctx, "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "active", Operator: "eq", Value: "true"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	
+	results, err = querier.Query(ctx, "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "name", Operator: "in", Value: "Bob,Carol"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	
+	results, err = querier.Query(ctx, "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "age", Operator: "exists", Value: "false"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Dave_1", results[0]["name"])
+	
+	// LIKE wildcards in the value are matched literally
+	results, err = querier.Query(ctx, "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "name", Operator: "contains", Value: "_"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestSQLiteStore_QuerySortAndProject(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	
+	store.Create(ctx, "users", map[string]interface{}{"name": "Alice", "age": 34, "email": "a@example.com"})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Bob", "age": 25, "email": "b@example.com"})
+	store.Create(ctx, "users", map[string]interface{}{"name": "Carol", "age": 34, "email": "c@example.com"})
+	
+	querier := store.(storage.Querier)
+	
+	results, err := querier.Query(ctx, "users", storage.ListOptions{
+		Sort:   []models.SortParam{{Field: "age", Order: "desc"}, {Field: "name", Order: "desc"}},
+		Fields: []string{"name"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	
+	assert.Equal(t, "Carol", results[0]["name"])
+	assert.Equal(t, "Alice", results[1]["name"])
+	assert.Equal(t, "Bob", results[2]["name"])
+	
+	// Projection keeps only the requested fields and the id
+	assert.Len(t, results[0], 2)
+	assert.NotNil(t, results[0]["id"])
+	assert.Nil(t, results[0]["email"])
+}
+
+func TestSQLiteStore_QueryInvalidOptions(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	querier := store.(storage.Querier)
+	
+	_, err := querier.Query(context.Background(), "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "name') OR 1=1 --", Operator: "eq", Value: "x"}},
+	})
+	assert.Error(t, err)
+	
+	_, err = querier.Query(context.Background(), "users", storage.ListOptions{
+		Filters: []models.FilterParam{{Field: "name", Operator: "like", Value: "x"}},
+	})
+	assert.Error(t, err)
+}
+
 // =============================================================================
 // Graph Synchronization Tests
 // =============================================================================
@@ -864,6 +964,400 @@ func TestSQLiteStore_ConcurrentReadWrite(t *testing.T) {
 	}
 }
 
+// =============================================================================
+// Transaction and Batch Tests
+// =============================================================================
+
+func TestSQLiteStore_TransactionCommit(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	
+	ts, ok := store.(storage.Transactional)
+	require.True(t, ok, "SQLiteStore should implement Transactional interface")
+	
+	tx, err := ts.Begin(ctx)
+	require.NoError(t, err)
+	
+	managerID, err := tx.Create(ctx, "users", testUserData("manager"))
+	require.NoError(t, err)
+	
+	data := testUserData("employee")
+	data["manager"] = map[string]interface{}{"type": "REF", "entity": "users", "id": managerID}
+	employeeID, err := tx.Create(ctx, "users", data)
+	require.NoError(t, err)
+	
+	// Reads inside the transaction see its own writes
+	assert.True(t, tx.Exists(ctx, "users", employeeID))
+	
+	require.NoError(t, tx.Commit())
+	assert.Error(t, tx.Rollback(), "Rollback after Commit should fail")
+	
+	assert.True(t, store.Exists(ctx, "users", managerID))
+	
+	gn := store.(storage.GraphNeighbors)
+	neighbors, err := gn.GetNeighbors(ctx, "users", employeeID, "out")
+	require.NoError(t, err)
+	assert.Len(t, neighbors, 1)
+}
+
+func TestSQLiteStore_TransactionRollback(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	
+	id, err := store.Create(ctx, "users", testUserData("alice"))
+	require.NoError(t, err)
+	
+	err = storage.WithTransaction(ctx, store, func(tx storage.Transaction) error {
+		if _, err := tx.Create(ctx, "users", testUserData("bob")); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, "users", id); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	require.EqualError(t, err, "abort")
+	
+	// Neither write is visible
+	assert.True(t, store.Exists(ctx, "users", id))
+	items, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	
+	// The store is usable again once the transaction is over
+	_, err = store.Create(ctx, "users", testUserData("carol"))
+	require.NoError(t, err)
+}
+
+func TestSQLiteStore_BatchCreate(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	
+	batcher, ok := store.(storage.Batcher)
+	require.True(t, ok, "SQLiteStore should implement Batcher interface")
+	
+	ids, err := batcher.BatchCreate(ctx, "users", []map[string]interface{}{
+		testUserData("alice"),
+		testUserData("bob"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, ids)
+	
+	// A batch with an item that cannot be stored creates nothing
+	_, err = batcher.BatchCreate(ctx, "users", []map[string]interface{}{
+		testUserData("carol"),
+		{"name": "invalid", "callback": func() {}},
+	})
+	require.Error(t, err)
+	
+	items, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestSQLiteStore_BatchDelete(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	batcher := store.(storage.Batcher)
+	
+	ids, err := batcher.BatchCreate(ctx, "users", []map[string]interface{}{
+		testUserData("alice"),
+		testUserData("bob"),
+		testUserData("carol"),
+	})
+	require.NoError(t, err)
+	
+	// A missing ID aborts the whole batch
+	err = batcher.BatchDelete(ctx, "users", []int{ids[0], 999})
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	assert.True(t, store.Exists(ctx, "users", ids[0]))
+	
+	require.NoError(t, batcher.BatchDelete(ctx, "users", ids[:2]))
+	items, err := store.List(ctx, "users")
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestSQLiteStore_BatchSave(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	batcher := store.(storage.Batcher)
+	
+	alice := testUserData("alice")
+	alice["id"] = 5
+	bob := testUserData("bob")
+	bob["id"] = float64(9)
+	require.NoError(t, batcher.BatchSave(ctx, "users", []map[string]interface{}{alice, bob}))
+	assert.True(t, store.Exists(ctx, "users", 5))
+	assert.True(t, store.Exists(ctx, "users", 9))
+	
+	// A batch with an existing or missing ID saves nothing
+	carol := testUserData("carol")
+	carol["id"] = 12
+	err := batcher.BatchSave(ctx, "users", []map[string]interface{}{carol, alice})
+	require.ErrorIs(t, err, storage.ErrAlreadyExists)
+	err = batcher.BatchSave(ctx, "users", []map[string]interface{}{carol, testUserData("dave")})
+	require.ErrorIs(t, err, storage.ErrInvalidID)
+	assert.False(t, store.Exists(ctx, "users", 12))
+	
+	id, err := store.Create(ctx, "users", testUserData("erin"))
+	require.NoError(t, err)
+	assert.Greater(t, id, 9)
+}
+
+func TestSQLiteStore_ListEntities(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	ctx := context.Background()
+	lister, ok := store.(storage.EntityLister)
+	require.True(t, ok, "SQLiteStore should implement EntityLister interface")
+	
+	entities, err := lister.ListEntities(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, entities)
+	
+	_, err = store.Create(ctx, "users", testUserData("alice"))
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "posts", map[string]interface{}{"title": "hello"})
+	require.NoError(t, err)
+	
+	entities, err = lister.ListEntities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"posts", "users"}, entities)
+}
+
+func TestSQLiteStore_Schemas(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	testSchemaStore(t, store)
+}
+
+func TestSQLiteStore_Revisions(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	testRevisioner(t, store)
+}
+
+func TestSQLiteStore_History(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	testHistorian(t, store)
+}
+
+func TestSQLiteStore_SoftDelete(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	testSoftDeleter(t, store)
+	testTxSoftDeleter(t, store)
+}
+
+func TestSQLiteStore_ChangeLog(t *testing.T) {
+	store, cleanup := setupSQLiteTest(t)
+	defer cleanup()
+	
+	testChangeLog(t, store)
+	testTxChangeLog(t, store)
+}
+
+// =============================================================================
+// Migration Tests
+// =============================================================================
+
+func openSQLitePath(t *testing.T, path string, skipMigrations bool) (storage.Store, error) {
+	t.Helper()
+	return storage.NewStore("sqlite", map[string]interface{}{
+		"db_path":         path,
+		"skip_migrations": skipMigrations,
+	})
+}
+
+func TestSQLiteStore_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.db")
+	ctx := context.Background()
+	
+	// Opened without migrating, every migration is pending
+	store, err := openSQLitePath(t, path, true)
+	require.NoError(t, err)
+	migrator, ok := store.(storage.Migrator)
+	require.True(t, ok, "SQLiteStore should implement Migrator interface")
+	lister := store.(storage.MigrationLister)
+	
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	pending, err := lister.Migrations(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, pending)
+	assert.Equal(t, "initial", pending[0].Name)
+	for _, m := range pending {
+		assert.False(t, m.Applied, "migration %d", m.Version)
+	}
+	
+	require.NoError(t, migrator.Migrate(ctx))
+	require.NoError(t, migrator.Migrate(ctx), "Migrating twice should be a no-op")
+	
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(pending), version)
+	applied, err := lister.Migrations(ctx)
+	require.NoError(t, err)
+	for _, m := range applied {
+		assert.True(t, m.Applied, "migration %d", m.Version)
+		assert.NotEmpty(t, m.AppliedAt)
+		assert.False(t, m.Modified)
+	}
+	
+	_, err = store.Create(ctx, "users", testUserData("alice"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestSQLiteStore_MigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.db")
+	ctx := context.Background()
+	
+	// The schema written before migrations were checksummed
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		CREATE TABLE entities (
+			entity_type TEXT NOT NULL,
+			id INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity_type, id)
+		);
+		CREATE TABLE entity_sequences (entity_type TEXT PRIMARY KEY, next_id INTEGER NOT NULL DEFAULT 1);
+		CREATE TABLE schema_version (version INTEGER PRIMARY KEY, applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+		INSERT INTO schema_version (version) VALUES (1);
+		INSERT INTO entities (entity_type, id, data) VALUES ('users', 1, '{"id":1,"name":"alice"}');
+		INSERT INTO entity_sequences VALUES ('users', 1);
+	`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	
+	store, err := openSQLitePath(t, path, false)
+	require.NoError(t, err)
+	defer store.Close()
+	
+	data, revision, err := store.(storage.Revisioner).GetRevision(ctx, "users", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", data["name"])
+	assert.Equal(t, 1, revision, "existing entities start at revision 1")
+	
+	// Versions from before history was kept are archived, undated, when
+	// they are replaced
+	require.NoError(t, store.Update(ctx, "users", 1, map[string]interface{}{"name": "alicia"}))
+	versions, err := store.(storage.Historian).History(ctx, "users", 1)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "alice", versions[0].Data["name"])
+	assert.True(t, versions[0].ChangedAt.IsZero())
+	assert.Equal(t, "alicia", versions[1].Data["name"])
+	assert.False(t, versions[1].ChangedAt.IsZero())
+	
+	migrations, err := store.(storage.MigrationLister).Migrations(ctx)
+	require.NoError(t, err)
+	for _, m := range migrations {
+		assert.True(t, m.Applied, "migration %d", m.Version)
+		assert.False(t, m.Modified, "migration %d", m.Version)
+	}
+	
+	id, err := store.Create(ctx, "users", testUserData("bob"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, id)
+}
+
+func TestSQLiteStore_MigrateDetectsDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.db")
+	ctx := context.Background()
+	
+	store, err := openSQLitePath(t, path, false)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+	
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	
+	// An applied migration whose SQL has since changed
+	_, err = db.Exec("UPDATE schema_version SET checksum = 'edited' WHERE version = 1")
+	require.NoError(t, err)
+	_, err = openSQLitePath(t, path, false)
+	require.ErrorIs(t, err, storage.ErrMigrationModified)
+	
+	// A migration from a newer build
+	_, err = db.Exec("INSERT INTO schema_version (version, name, checksum) VALUES (999, 'future', 'x')")
+	require.NoError(t, err)
+	
+	inspect, err := openSQLitePath(t, path, true)
+	require.NoError(t, err)
+	defer inspect.Close()
+	migrations, err := inspect.(storage.MigrationLister).Migrations(ctx)
+	require.NoError(t, err)
+	assert.True(t, migrations[0].Modified)
+	last := migrations[len(migrations)-1]
+	assert.Equal(t, 999, last.Version)
+	assert.Equal(t, "future", last.Name)
+	assert.True(t, last.Unknown)
+	
+	_, err = db.Exec("DELETE FROM schema_version WHERE version = 1")
+	require.NoError(t, err)
+	err = inspect.(storage.Migrator).Migrate(ctx)
+	require.ErrorIs(t, err, storage.ErrSchemaTooNew)
+}
+
+func TestSQLiteStore_MigrateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "olu.db")
+	ctx := context.Background()
+	
+	// Separate stores stand in for separate processes sharing the file
+	var stores []storage.Store
+	for i := 0; i < 4; i++ {
+		store, err := openSQLitePath(t, path, true)
+		require.NoError(t, err)
+		defer store.Close()
+		stores = append(stores, store)
+	}
+	
+	var wg sync.WaitGroup
+	errs := make([]error, len(stores))
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store storage.Store) {
+			defer wg.Done()
+			errs[i] = store.(storage.Migrator).Migrate(ctx)
+		}(i, store)
+	}
+	wg.Wait()
+	
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	migrations, err := stores[0].(storage.MigrationLister).Migrations(ctx)
+	require.NoError(t, err)
+	for _, m := range migrations {
+		assert.True(t, m.Applied)
+		assert.False(t, m.Unknown)
+	}
+}
+
 // =============================================================================
 // Info Tests
 // =============================================================================
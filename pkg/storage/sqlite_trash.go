@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (s *SQLiteStore) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.trash(ctx, tx, entity, id, expected)
+	})
+}
+
+// trash moves an entity to the trash within a transaction
+func (s *SQLiteStore) trash(ctx context.Context, tx *sql.Tx, entity string, id int, expected int) error {
+	if _, err := s.revision(ctx, tx, entity, id, expected); err != nil {
+		return err
+	}
+	
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO trash (entity_type, id, data, deleted_at)
+		SELECT entity_type, id, data, ? FROM entities
+		WHERE entity_type = ? AND id = ?
+	`, historyTime(time.Now()), entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to trash entity: %w", err)
+	}
+	
+	// Keep the edges other entities hold to it, so restoring it can bring
+	// them back
+	_, err = tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO dormant_edges (source_entity, source_id, target_entity, target_id, relationship_name)
+		SELECT source_entity, source_id, target_entity, target_id, relationship_name FROM graph_edges
+		WHERE target_entity = ? AND target_id = ?
+		  AND NOT (source_entity = ? AND source_id = ?)
+	`, entity, id, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to keep graph edges: %w", err)
+	}
+	
+	return s.delete(ctx, tx, entity, id)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (s *SQLiteStore) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		data, err = s.restore(ctx, tx, entity, id)
+		return err
+	})
+	return data, err
+}
+
+// restore brings a trashed entity back within a transaction
+func (s *SQLiteStore) restore(ctx context.Context, tx *sql.Tx, entity string, id int) (map[string]interface{}, error) {
+	var jsonData string
+	err := tx.QueryRowContext(ctx, `
+		SELECT data FROM trash
+		WHERE entity_type = ? AND id = ?
+	`, entity, id).Scan(&jsonData)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s with id %d in the trash", ErrNotFound, entity, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	
+	// Saving it rebuilds its own edges from its references
+	if err := s.save(ctx, tx, entity, id, data); err != nil {
+		return nil, err
+	}
+	if err := s.wakeDormantEdges(ctx, tx, entity, id); err != nil {
+		return nil, err
+	}
+	
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM trash
+		WHERE entity_type = ? AND id = ?
+	`, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	data["id"] = id
+	return data, nil
+}
+
+// wakeDormantEdges re-syncs the edges of every live entity that pointed at a
+// restored one, which brings back those that still do
+func (s *SQLiteStore) wakeDormantEdges(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT source_entity, source_id FROM dormant_edges
+		WHERE target_entity = ? AND target_id = ?
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to query dormant edges: %w", err)
+	}
+	
+	type node struct {
+		entity string
+		id     int
+	}
+	var sources []node
+	for rows.Next() {
+		var source node
+		if err := rows.Scan(&source.entity, &source.id); err != nil {
+			rows.Close()
+			return err
+		}
+		sources = append(sources, source)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	
+	for _, source := range sources {
+		data, err := s.get(ctx, tx, source.entity, source.id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.syncGraphEdges(ctx, tx, source.entity, source.id, data); err != nil {
+			return fmt.Errorf("failed to sync graph: %w", err)
+		}
+	}
+	
+	return s.dropDormantEdges(ctx, tx, entity, id)
+}
+
+// dropDormantEdges forgets the edges that pointed at a trashed entity
+func (s *SQLiteStore) dropDormantEdges(ctx context.Context, tx *sql.Tx, entity string, id int) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM dormant_edges
+		WHERE target_entity = ? AND target_id = ?
+	`, entity, id)
+	if err != nil {
+		return fmt.Errorf("failed to drop dormant edges: %w", err)
+	}
+	return nil
+}
+
+// ListTrash lists an entity type's trashed entities
+func (s *SQLiteStore) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	return s.listTrash(ctx, s.db, entity)
+}
+
+// listTrash loads the trash of one entity type, or of all when entity is
+// empty
+func (s *SQLiteStore) listTrash(ctx context.Context, q sqlQuerier, entity string) ([]TrashedEntity, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT entity_type, id, data, deleted_at FROM trash
+		WHERE ? = '' OR entity_type = ?
+	`, entity, entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+	
+	items := []TrashedEntity{}
+	for rows.Next() {
+		var item TrashedEntity
+		var jsonData, deletedAt string
+		if err := rows.Scan(&item.Entity, &item.ID, &jsonData, &deletedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(jsonData), &item.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		item.DeletedAt = parseHistoryTime(deletedAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	sortTrash(items)
+	return items, nil
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (s *SQLiteStore) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	var purged []TrashedEntity
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		purged, err = s.purgeTrash(ctx, tx, entity, before)
+		return err
+	})
+	return purged, err
+}
+
+// purgeTrash permanently removes the entities trashed before a cutoff within
+// a transaction
+func (s *SQLiteStore) purgeTrash(ctx context.Context, tx *sql.Tx, entity string, before time.Time) ([]TrashedEntity, error) {
+	items, err := s.listTrash(ctx, tx, entity)
+	if err != nil {
+		return nil, err
+	}
+	
+	purged := expiredTrash(items, before)
+	for _, item := range purged {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM trash
+			WHERE entity_type = ? AND id = ?
+		`, item.Entity, item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge entity: %w", err)
+		}
+		if err := s.dropDormantEdges(ctx, tx, item.Entity, item.ID); err != nil {
+			return nil, err
+		}
+	}
+	return purged, nil
+}
+
+// Trash moves an entity to the trash if it is still at the expected revision
+func (t *sqliteTransaction) Trash(ctx context.Context, entity string, id int, expected int) error {
+	return t.store.trash(ctx, t.tx, entity, id, expected)
+}
+
+// Restore brings a trashed entity back with the edges pointing at it
+func (t *sqliteTransaction) Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.restore(ctx, t.tx, entity, id)
+}
+
+// ListTrash lists an entity type's trashed entities, including uncommitted
+// changes
+func (t *sqliteTransaction) ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error) {
+	return t.store.listTrash(ctx, t.tx, entity)
+}
+
+// PurgeTrash permanently removes the entities trashed before a cutoff
+func (t *sqliteTransaction) PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error) {
+	return t.store.purgeTrash(ctx, t.tx, entity, before)
+}
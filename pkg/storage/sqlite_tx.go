@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqliteTransaction runs Store operations inside a single SQLite transaction.
+// It holds the store's write lock from Begin until Commit or Rollback, so it
+// must not be used concurrently and the store itself must not be called from
+// within it.
+type sqliteTransaction struct {
+	store *SQLiteStore
+	tx    *sql.Tx
+	done  bool
+}
+
+// Begin starts a transaction. Writes made through it become visible together
+// on Commit, or not at all on Rollback.
+func (s *SQLiteStore) Begin(ctx context.Context) (Transaction, error) {
+	s.mu.Lock()
+	
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	
+	return &sqliteTransaction{store: s, tx: tx}, nil
+}
+
+// Create inserts a new entity with auto-generated ID
+func (t *sqliteTransaction) Create(ctx context.Context, entity string, data map[string]interface{}) (int, error) {
+	return t.store.create(ctx, t.tx, entity, data)
+}
+
+// Get retrieves an entity by ID, including uncommitted changes
+func (t *sqliteTransaction) Get(ctx context.Context, entity string, id int) (map[string]interface{}, error) {
+	return t.store.get(ctx, t.tx, entity, id)
+}
+
+// Update replaces an entity completely
+func (t *sqliteTransaction) Update(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.update(ctx, t.tx, entity, id, data)
+}
+
+// Patch partially updates an entity
+func (t *sqliteTransaction) Patch(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.patch(ctx, t.tx, entity, id, data)
+}
+
+// Delete removes an entity
+func (t *sqliteTransaction) Delete(ctx context.Context, entity string, id int) error {
+	return t.store.delete(ctx, t.tx, entity, id)
+}
+
+// Save creates an entity with a specific ID (fails if exists)
+func (t *sqliteTransaction) Save(ctx context.Context, entity string, id int, data map[string]interface{}) error {
+	return t.store.save(ctx, t.tx, entity, id, data)
+}
+
+// List returns all entities of a given type, including uncommitted changes
+func (t *sqliteTransaction) List(ctx context.Context, entity string) ([]map[string]interface{}, error) {
+	return t.store.list(ctx, t.tx, entity)
+}
+
+// Exists checks if an entity exists, including uncommitted changes
+func (t *sqliteTransaction) Exists(ctx context.Context, entity string, id int) bool {
+	exists, err := t.store.exists(ctx, t.tx, entity, id)
+	return err == nil && exists
+}
+
+// GetNeighbors returns graph neighbors for an entity, including uncommitted
+// changes
+func (t *sqliteTransaction) GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error) {
+	return t.store.getNeighbors(ctx, t.tx, entity, id, direction)
+}
+
+// Close rolls back the transaction if it has not been committed
+func (t *sqliteTransaction) Close() error {
+	if t.done {
+		return nil
+	}
+	return t.Rollback()
+}
+
+// Commit makes the transaction's writes visible and releases the write lock
+func (t *sqliteTransaction) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	defer t.store.mu.Unlock()
+	
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the transaction's writes and releases the write lock
+func (t *sqliteTransaction) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	defer t.store.mu.Unlock()
+	
+	return t.tx.Rollback()
+}
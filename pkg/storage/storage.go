@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
@@ -14,6 +15,9 @@ var (
 	ErrInvalidEntity = errors.New("invalid entity name")
 	// ErrInvalidID is returned when ID is invalid
 	ErrInvalidID = errors.New("invalid ID")
+	// ErrRevisionMismatch is returned when a conditional write finds the
+	// entity at a different revision than the caller expected
+	ErrRevisionMismatch = errors.New("revision mismatch")
 )
 
 // Store defines the core interface for entity storage backends
@@ -60,17 +64,110 @@ type Migrator interface {
 	Version(ctx context.Context) (int, error)
 }
 
+// MigrationLister defines optional reporting of individual schema migrations
+type MigrationLister interface {
+	Migrations(ctx context.Context) ([]MigrationStatus, error)
+}
+
 // Searcher defines optional search capabilities
 type Searcher interface {
 	Search(ctx context.Context, entity string, field string, query string, matchType string) ([]map[string]interface{}, error)
 }
 
+// FullTextSearcher defines optional ranked full-text search across entity types
+type FullTextSearcher interface {
+	// SearchText returns up to limit entities containing every term of query,
+	// restricted to the given entity types when any are given
+	SearchText(ctx context.Context, query string, entities []string, limit int) ([]TextHit, error)
+}
+
 // Batcher defines optional batch operation support
 type Batcher interface {
 	BatchCreate(ctx context.Context, entity string, items []map[string]interface{}) ([]int, error)
+	// BatchSave saves several entities under the IDs in their "id" fields
+	BatchSave(ctx context.Context, entity string, items []map[string]interface{}) error
 	BatchDelete(ctx context.Context, entity string, ids []int) error
 }
 
+// EntityLister defines optional enumeration of the stored entity types
+type EntityLister interface {
+	ListEntities(ctx context.Context) ([]string, error)
+}
+
+// SchemaStore defines optional persistence of entity JSON schemas
+type SchemaStore interface {
+	SaveSchema(ctx context.Context, entity string, schema map[string]interface{}) error
+	DeleteSchema(ctx context.Context, entity string) error
+	ListSchemas(ctx context.Context) (map[string]map[string]interface{}, error)
+}
+
+// Revisioner defines optional optimistic concurrency control. Every entity
+// carries a revision that starts at 1 and increases with each write to it,
+// whichever method makes the write. The conditional writes take the revision
+// the caller last read, or 0 to skip the check, and fail with
+// ErrRevisionMismatch if the entity has moved on since.
+type Revisioner interface {
+	// GetRevision retrieves an entity together with its current revision
+	GetRevision(ctx context.Context, entity string, id int) (map[string]interface{}, int, error)
+	// UpdateIf replaces an entity and returns its new revision
+	UpdateIf(ctx context.Context, entity string, id int, data map[string]interface{}, expected int) (int, error)
+	// PatchIf passes the current data to modify and stores the result in one
+	// atomic step, so no other write can land between the read and the
+	// write. An error from modify aborts the patch and is returned as is.
+	PatchIf(ctx context.Context, entity string, id int, expected int, modify func(data map[string]interface{}) error) (map[string]interface{}, int, error)
+	// DeleteIf removes an entity
+	DeleteIf(ctx context.Context, entity string, id int, expected int) error
+}
+
+// Historian defines optional retention of every version of an entity. Each
+// write records the version it produces, and a delete records a version
+// marking it, so revisions keep counting up when a deleted ID is saved
+// again.
+type Historian interface {
+	// History lists an entity's versions oldest first, ending with the
+	// current one
+	History(ctx context.Context, entity string, id int) ([]EntityVersion, error)
+}
+
+// SoftDeleter defines optional soft deletes. A trashed entity is gone from
+// reads, searches and graph queries, but its data is kept, and the edges
+// pointing at it lie dormant, until it is restored or purged.
+type SoftDeleter interface {
+	// Trash moves an entity to the trash if it is still at the expected
+	// revision, 0 meaning any
+	Trash(ctx context.Context, entity string, id int, expected int) error
+	// Restore brings a trashed entity back with the edges pointing at it and
+	// returns its data. It fails with ErrAlreadyExists if the ID is in use.
+	Restore(ctx context.Context, entity string, id int) (map[string]interface{}, error)
+	// ListTrash lists an entity type's trashed entities, most recently
+	// trashed first
+	ListTrash(ctx context.Context, entity string) ([]TrashedEntity, error)
+	// PurgeTrash permanently removes the entities trashed before a cutoff,
+	// of one type or of every type when entity is empty, and returns them
+	PurgeTrash(ctx context.Context, entity string, before time.Time) ([]TrashedEntity, error)
+}
+
+// ChangeLog defines optional durable storage of the change feed, so clients
+// can resume it from where they left off, across restarts
+type ChangeLog interface {
+	// AppendChange records a change, setting its sequence number, which is
+	// higher than that of every change recorded before it
+	AppendChange(ctx context.Context, change *Change) error
+	// Changes returns up to limit changes recorded after a sequence number,
+	// oldest first
+	Changes(ctx context.Context, after int64, limit int) ([]Change, error)
+	// LastChangeSeq returns the sequence number of the last change recorded,
+	// or 0 if there are none
+	LastChangeSeq(ctx context.Context) (int64, error)
+}
+
+// ChangeAppender defines optional change logging within a transaction, so a
+// change is committed or rolled back together with the write it describes
+type ChangeAppender interface {
+	// AppendChange records a change, as ChangeLog.AppendChange does
+	AppendChange(ctx context.Context, change *Change) error
+}
+
 // GraphNeighbors defines optional graph neighbor queries
 type GraphNeighbors interface {
 	GetNeighbors(ctx context.Context, entity string, id int, direction string) ([]map[string]interface{}, error)
@@ -89,6 +186,7 @@ type StoreInfo struct {
 	SupportsSearch      bool
 	SupportsBatch       bool
 	SupportsTransaction bool
+	SupportsFullText    bool
 }
 
 // InfoProvider allows stores to provide metadata about their capabilities
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ha1tch/olu/pkg/models"
 	"github.com/ha1tch/olu/pkg/storage"
 )
 
@@ -448,71 +451,1063 @@ func TestStoreSearch(t *testing.T) {
 	})
 }
 
+func TestStoreSearchQuery(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	sqliteStore, err := storage.NewStore("sqlite", map[string]interface{}{
+		"db_path": filepath.Join(tmpDir, "parity.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqliteStore.Close()
+
+	ctx := context.Background()
+
+	users := []map[string]interface{}{
+		{"name": "Alice Smith", "age": 34, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "alan Jones", "age": 25, "address": map[string]interface{}{"city": "Porto"}},
+		{"name": "Bob_Smith", "age": 41, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "Carol", "age": 30},
+	}
+	for _, user := range users {
+		for _, s := range []storage.Store{store, sqliteStore} {
+			copied := make(map[string]interface{}, len(user))
+			for k, v := range user {
+				copied[k] = v
+			}
+			if _, err := s.Create(ctx, "users", copied); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+	}
+
+	fieldCases := []struct {
+		name     string
+		field    string
+		query    string
+		match    string
+		expected []float64
+	}{
+		{"contains ignores case", "name", "AL", storage.MatchContains, []float64{1, 2}},
+		{"exact ignores case", "name", "ALICE SMITH", storage.MatchExact, []float64{1}},
+		{"wildcards match literally", "name", "_", storage.MatchContains, []float64{3}},
+		{"numbers match their text", "age", "3", storage.MatchStarts, []float64{1, 4}},
+		{"nested path", "address.city", "bon", storage.MatchEnds, []float64{1, 3}},
+	}
+
+	for _, tc := range fieldCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, s := range []storage.Store{store, sqliteStore} {
+				results, err := storage.SearchField(ctx, s, "users", tc.field, tc.query, tc.match)
+				if err != nil {
+					t.Fatalf("SearchField failed: %v", err)
+				}
+
+				ids := make([]float64, len(results))
+				for i, r := range results {
+					ids[i] = r["id"].(float64)
+				}
+				if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+					t.Errorf("%T: expected ids %v, got %v", s, tc.expected, ids)
+				}
+			}
+		})
+	}
+
+	t.Run("Compound query", func(t *testing.T) {
+		query := storage.SearchQuery{And: []storage.SearchQuery{
+			{Field: "address.city", Op: storage.OpEq, Value: "Lisbon"},
+			{Or: []storage.SearchQuery{
+				{Field: "name", Op: storage.OpStarts, Value: "alice"},
+				{Field: "age", Op: storage.OpGt, Value: 40},
+			}},
+		}}
+
+		for _, s := range []storage.Store{store, sqliteStore} {
+			results, err := storage.SearchWithQuery(ctx, s, "users", query)
+			if err != nil {
+				t.Fatalf("SearchWithQuery failed: %v", err)
+			}
+
+			ids := make([]float64, len(results))
+			for i, r := range results {
+				ids[i] = r["id"].(float64)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint([]float64{1, 3}) {
+				t.Errorf("%T: expected ids [1 3], got %v", s, ids)
+			}
+		}
+	})
+
+	t.Run("Invalid queries", func(t *testing.T) {
+		invalid := []storage.SearchQuery{
+			{},
+			{Field: "name", Op: "like", Value: "a"},
+			{Field: "name;drop", Op: storage.OpEq, Value: "a"},
+			{And: []storage.SearchQuery{{Field: "name", Op: storage.OpEq}}, Or: []storage.SearchQuery{{Field: "age", Op: storage.OpEq}}},
+		}
+		for _, query := range invalid {
+			if _, err := storage.SearchWithQuery(ctx, store, "users", query); err == nil {
+				t.Errorf("Expected error for %+v", query)
+			}
+		}
+	})
+}
+
+func TestStoreFullText(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "olu-storage-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+
+	configs := map[string]map[string]interface{}{
+		"jsonfile": {"base_dir": tmpDir, "schema": "test"},
+		"sqlite":   {"db_path": filepath.Join(tmpDir, "fts.db")},
+	}
+
+	docs := []struct {
+		entity string
+		data   map[string]interface{}
+	}{
+		{"articles", map[string]interface{}{"title": "Café culture", "body": "Coffee, coffee and more coffee"}},
+		{"articles", map[string]interface{}{"title": "Tea", "body": "Not coffee"}},
+		{"notes", map[string]interface{}{"text": "Buy coffee beans", "author": map[string]interface{}{"type": "REF", "entity": "users", "id": 1}}},
+		{"notes", map[string]interface{}{"count": 3}},
+	}
+
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			// Write without the index, then enable it so it is built from existing data
+			store, err := storage.NewStore(name, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, doc := range docs {
+				if _, err := store.Create(ctx, doc.entity, doc.data); err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+			}
+			if _, err := store.(storage.FullTextSearcher).SearchText(ctx, "coffee", nil, 10); !errors.Is(err, storage.ErrFullTextDisabled) {
+				t.Errorf("Expected ErrFullTextDisabled, got %v", err)
+			}
+			store.Close()
+
+			cfg["fulltext"] = true
+			store, err = storage.NewStore(name, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			searcher := store.(storage.FullTextSearcher)
+
+			hits, err := searcher.SearchText(ctx, "Coffee", nil, 10)
+			if err != nil {
+				t.Fatalf("SearchText failed: %v", err)
+			}
+			got := make([]string, len(hits))
+			for i, hit := range hits {
+				got[i] = fmt.Sprintf("%s:%d", hit.Entity, hit.ID)
+			}
+			if fmt.Sprint(got) != "[articles:1 articles:2 notes:1]" {
+				t.Errorf("Expected articles:1 ranked first, got %v", got)
+			}
+			if hits[0].Snippet == "" || hits[0].Data["title"] != "Café culture" {
+				t.Errorf("Expected snippet and data, got %+v", hits[0])
+			}
+
+			hits, _ = searcher.SearchText(ctx, "café", []string{"articles"}, 10)
+			if len(hits) != 1 {
+				t.Errorf("Expected 1 hit for café, got %d", len(hits))
+			}
+
+			hits, _ = searcher.SearchText(ctx, "users", nil, 10)
+			if len(hits) != 0 {
+				t.Errorf("Expected references to be skipped, got %d hits", len(hits))
+			}
+
+			hits, _ = searcher.SearchText(ctx, "coffee", nil, 1)
+			if len(hits) != 1 {
+				t.Errorf("Expected limit to apply, got %d hits", len(hits))
+			}
+		})
+	}
+}
+
+func TestStoreQuery(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	sqliteStore, err := storage.NewStore("sqlite", map[string]interface{}{
+		"db_path": filepath.Join(tmpDir, "parity.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqliteStore.Close()
+
+	ctx := context.Background()
+
+	users := []map[string]interface{}{
+		{"name": "Alice", "age": 34, "active": true, "address": map[string]interface{}{"city": "Lisbon"}},
+		{"name": "bob", "age": 25, "active": false, "address": map[string]interface{}{"city": "Porto"}},
+		{"name": "Carol", "age": "unknown"},
+		{"name": "Dave", "age": 41, "active": true},
+		{"name": "Eve", "age": nil},
+	}
+	for _, user := range users {
+		for _, s := range []storage.Store{store, sqliteStore} {
+			copied := make(map[string]interface{}, len(user))
+			for k, v := range user {
+				copied[k] = v
+			}
+			if _, err := s.Create(ctx, "users", copied); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+	}
+
+	cases := []struct {
+		name     string
+		opts     storage.ListOptions
+		expected []float64
+	}{
+		{"gte", storage.ListOptions{Filters: []models.FilterParam{{Field: "age", Operator: "gte", Value: "30"}}}, []float64{1, 3, 4}},
+		{"lt", storage.ListOptions{Filters: []models.FilterParam{{Field: "age", Operator: "lt", Value: "30"}}}, []float64{2}},
+		{"ne skips missing", storage.ListOptions{Filters: []models.FilterParam{{Field: "active", Operator: "ne", Value: "true"}}}, []float64{2}},
+		{"nested path", storage.ListOptions{Filters: []models.FilterParam{{Field: "address.city", Operator: "eq", Value: "Porto"}}}, []float64{2}},
+		{"contains is case-insensitive", storage.ListOptions{Filters: []models.FilterParam{{Field: "name", Operator: "contains", Value: "B"}}}, []float64{2}},
+		{"exists", storage.ListOptions{Filters: []models.FilterParam{{Field: "address", Operator: "exists", Value: "true"}}}, []float64{1, 2}},
+		{"sort numbers before strings", storage.ListOptions{Sort: []models.SortParam{{Field: "age", Order: "asc"}}}, []float64{5, 2, 1, 4, 3}},
+		{"sort desc", storage.ListOptions{Sort: []models.SortParam{{Field: "name", Order: "desc"}}}, []float64{2, 5, 4, 3, 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, s := range []storage.Store{store, sqliteStore} {
+				results, err := storage.ListWithOptions(ctx, s, "users", tc.opts)
+				if err != nil {
+					t.Fatalf("ListWithOptions failed: %v", err)
+				}
+
+				ids := make([]float64, len(results))
+				for i, r := range results {
+					ids[i] = r["id"].(float64)
+				}
+				if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+					t.Errorf("%T: expected ids %v, got %v", s, tc.expected, ids)
+				}
+			}
+		})
+	}
+
+	t.Run("Projection", func(t *testing.T) {
+		opts := storage.ListOptions{Fields: []string{"name", "address.city"}}
+		results, err := storage.ListWithOptions(ctx, store, "users", opts)
+		if err != nil {
+			t.Fatalf("ListWithOptions failed: %v", err)
+		}
+
+		if _, ok := results[0]["age"]; ok {
+			t.Error("Expected age to be projected out")
+		}
+		address, ok := results[0]["address"].(map[string]interface{})
+		if !ok || address["city"] != "Lisbon" {
+			t.Errorf("Expected nested address.city, got %v", results[0]["address"])
+		}
+	})
+}
+
 func TestStoreConcurrency(t *testing.T) {
 	store, tmpDir := setupTestStore(t)
 	defer os.RemoveAll(tmpDir)
 	defer store.Close()
 
-	ctx := context.Background()
+	ctx := context.Background()
+
+	t.Run("Concurrent creates", func(t *testing.T) {
+		const numGoroutines = 10
+
+		done := make(chan int, numGoroutines)
+
+		for i := 0; i < numGoroutines; i++ {
+			go func(n int) {
+				data := map[string]interface{}{
+					"name": "Concurrent User",
+					"num":  n,
+				}
+				id, err := store.Create(ctx, "users", data)
+				if err != nil {
+					t.Errorf("Concurrent create failed: %v", err)
+				}
+				done <- id
+			}(i)
+		}
+
+		ids := make(map[int]bool)
+		for i := 0; i < numGoroutines; i++ {
+			id := <-done
+			if ids[id] {
+				t.Errorf("Duplicate ID generated: %d", id)
+			}
+			ids[id] = true
+		}
+
+		if len(ids) != numGoroutines {
+			t.Errorf("Expected %d unique IDs, got %d", numGoroutines, len(ids))
+		}
+	})
+}
+
+func TestStoreInfo(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	t.Run("Store provides info", func(t *testing.T) {
+		infoProvider, ok := store.(storage.InfoProvider)
+		if !ok {
+			t.Skip("Store does not provide info")
+		}
+
+		info := infoProvider.Info()
+
+		if info.Type == "" {
+			t.Error("Expected store type")
+		}
+		if info.Version == "" {
+			t.Error("Expected store version")
+		}
+
+		t.Logf("Store: %s v%s", info.Type, info.Version)
+		t.Logf("  Supports Search: %v", info.SupportsSearch)
+		t.Logf("  Supports Batch: %v", info.SupportsBatch)
+		t.Logf("  Supports Transaction: %v", info.SupportsTransaction)
+	})
+}
+
+// testSchemaStore exercises a store's SchemaStore implementation
+func testSchemaStore(t *testing.T, store storage.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	schemaStore, ok := store.(storage.SchemaStore)
+	if !ok {
+		t.Fatal("Store should implement SchemaStore")
+	}
+
+	schemas, err := schemaStore.ListSchemas(ctx)
+	if err != nil || len(schemas) != 0 {
+		t.Fatalf("Expected no schemas, got %v (%v)", schemas, err)
+	}
+
+	users := map[string]interface{}{"type": "object", "required": []interface{}{"name"}}
+	posts := map[string]interface{}{"type": "object"}
+	for entity, schema := range map[string]map[string]interface{}{"users": users, "posts": posts} {
+		if err := schemaStore.SaveSchema(ctx, entity, schema); err != nil {
+			t.Fatalf("Failed to save %s schema: %v", entity, err)
+		}
+	}
+
+	// Saving again replaces the schema
+	users["required"] = []interface{}{"name", "email"}
+	if err := schemaStore.SaveSchema(ctx, "users", users); err != nil {
+		t.Fatalf("Failed to replace schema: %v", err)
+	}
+
+	schemas, err = schemaStore.ListSchemas(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list schemas: %v", err)
+	}
+	if len(schemas) != 2 {
+		t.Fatalf("Expected 2 schemas, got %d", len(schemas))
+	}
+	if required, _ := schemas["users"]["required"].([]interface{}); len(required) != 2 {
+		t.Errorf("Expected the replaced users schema, got %v", schemas["users"])
+	}
+
+	if err := schemaStore.DeleteSchema(ctx, "posts"); err != nil {
+		t.Fatalf("Failed to delete schema: %v", err)
+	}
+	if err := schemaStore.DeleteSchema(ctx, "posts"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing schema, got %v", err)
+	}
+
+	schemas, _ = schemaStore.ListSchemas(ctx)
+	if _, ok := schemas["posts"]; ok || len(schemas) != 1 {
+		t.Errorf("Expected only the users schema, got %v", schemas)
+	}
+}
+
+// testRevisioner exercises a store's Revisioner implementation
+func testRevisioner(t *testing.T, store storage.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	rv, ok := store.(storage.Revisioner)
+	if !ok {
+		t.Fatal("Store should implement Revisioner")
+	}
+
+	revisionOf := func(id int) int {
+		t.Helper()
+		_, revision, err := rv.GetRevision(ctx, "docs", id)
+		if err != nil {
+			t.Fatalf("Failed to get revision: %v", err)
+		}
+		return revision
+	}
+
+	id, err := store.Create(ctx, "docs", map[string]interface{}{"title": "Draft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision := revisionOf(id); revision != 1 {
+		t.Errorf("Expected a new entity at revision 1, got %d", revision)
+	}
+
+	// Unconditional writes advance the revision too
+	if err := store.Update(ctx, "docs", id, map[string]interface{}{"title": "Second"}); err != nil {
+		t.Fatal(err)
+	}
+	if revision := revisionOf(id); revision != 2 {
+		t.Errorf("Expected revision 2 after an update, got %d", revision)
+	}
+
+	revision, err := rv.UpdateIf(ctx, "docs", id, map[string]interface{}{"title": "Third"}, 2)
+	if err != nil || revision != 3 {
+		t.Fatalf("Expected revision 3 from a matching update, got %d (%v)", revision, err)
+	}
+
+	if _, err := rv.UpdateIf(ctx, "docs", id, map[string]interface{}{"title": "Stale"}, 2); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch for a stale update, got %v", err)
+	}
+	data, _ := store.Get(ctx, "docs", id)
+	if data["title"] != "Third" {
+		t.Errorf("Expected a stale update to change nothing, got %v", data)
+	}
+
+	data, revision, err = rv.PatchIf(ctx, "docs", id, 3, func(data map[string]interface{}) error {
+		data["body"] = "Text"
+		return nil
+	})
+	if err != nil || revision != 4 {
+		t.Fatalf("Expected revision 4 from a matching patch, got %d (%v)", revision, err)
+	}
+	if data["title"] != "Third" || data["body"] != "Text" || fmt.Sprint(data["id"]) != fmt.Sprint(id) {
+		t.Errorf("Expected the merged entity, got %v", data)
+	}
+
+	// An error from modify aborts the patch and comes back unchanged
+	errAbort := errors.New("abort")
+	_, _, err = rv.PatchIf(ctx, "docs", id, 0, func(data map[string]interface{}) error {
+		data["title"] = "Aborted"
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("Expected the modify error, got %v", err)
+	}
+	data, revision, _ = rv.GetRevision(ctx, "docs", id)
+	if data["title"] != "Third" || revision != 4 {
+		t.Errorf("Expected an aborted patch to change nothing, got %v at revision %d", data, revision)
+	}
+
+	// Concurrent patches each see the others' fields
+	const writers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := rv.PatchIf(ctx, "docs", id, 0, func(data map[string]interface{}) error {
+				data[fmt.Sprintf("field%d", i)] = i
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Concurrent patch failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, revision, _ = rv.GetRevision(ctx, "docs", id)
+	for i := 0; i < writers; i++ {
+		if _, ok := data[fmt.Sprintf("field%d", i)]; !ok {
+			t.Errorf("Expected field%d to survive concurrent patches, got %v", i, data)
+		}
+	}
+	if revision != 4+writers {
+		t.Errorf("Expected revision %d, got %d", 4+writers, revision)
+	}
+
+	if err := rv.DeleteIf(ctx, "docs", id, 4); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch for a stale delete, got %v", err)
+	}
+	if !store.Exists(ctx, "docs", id) {
+		t.Fatal("Expected a stale delete to leave the entity")
+	}
+	if err := rv.DeleteIf(ctx, "docs", id, revision); err != nil {
+		t.Fatalf("Failed to delete at the current revision: %v", err)
+	}
+	if _, _, err := rv.GetRevision(ctx, "docs", id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := rv.UpdateIf(ctx, "docs", id, map[string]interface{}{}, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating a deleted entity, got %v", err)
+	}
+
+	// A saved entity carries on past the delete, so old ETags never match it
+	if err := store.Save(ctx, "docs", id, map[string]interface{}{"title": "Again"}); err != nil {
+		t.Fatal(err)
+	}
+	if saved := revisionOf(id); saved != revision+2 {
+		t.Errorf("Expected a saved entity at revision %d, got %d", revision+2, saved)
+	}
+}
+
+func TestStoreRevisions(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	testRevisioner(t, store)
+
+	// Revision files sit beside the entities without being listed as ones
+	items, err := store.List(context.Background(), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 entity, got %d", len(items))
+	}
+}
+
+// testHistorian exercises a store's Historian implementation
+func testHistorian(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	h, ok := store.(storage.Historian)
+	if !ok {
+		t.Fatal("Store should implement Historian")
+	}
+
+	if _, err := h.History(ctx, "notes", 99); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an entity that never existed, got %v", err)
+	}
+
+	id, err := store.Create(ctx, "notes", map[string]interface{}{"text": "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Update(ctx, "notes", id, map[string]interface{}{"text": "second"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Patch(ctx, "notes", id, map[string]interface{}{"tag": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "notes", id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, "notes", id, map[string]interface{}{"text": "third"}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := h.History(ctx, "notes", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 5 {
+		t.Fatalf("Expected 5 versions, got %d: %+v", len(versions), versions)
+	}
+
+	texts := []interface{}{"first", "second", "second", nil, "third"}
+	for i, version := range versions {
+		if version.Revision != i+1 {
+			t.Errorf("Expected version %d at revision %d, got %d", i, i+1, version.Revision)
+		}
+		if version.ChangedAt.IsZero() {
+			t.Errorf("Expected revision %d to have a timestamp", version.Revision)
+		}
+		if i > 0 && version.ChangedAt.Before(versions[i-1].ChangedAt) {
+			t.Errorf("Expected revision %d no earlier than the one before it", version.Revision)
+		}
+		if version.Deleted != (i == 3) {
+			t.Errorf("Expected revision %d deleted=%v, got %v", version.Revision, i == 3, version.Deleted)
+		}
+		if version.Deleted {
+			if version.Data != nil {
+				t.Errorf("Expected no data for a delete, got %v", version.Data)
+			}
+			continue
+		}
+		if version.Data["text"] != texts[i] || fmt.Sprint(version.Data["id"]) != fmt.Sprint(id) {
+			t.Errorf("Expected revision %d to hold %v, got %v", version.Revision, texts[i], version.Data)
+		}
+	}
+	if versions[2].Data["tag"] != "x" {
+		t.Errorf("Expected the patched field in revision 3, got %v", versions[2].Data)
+	}
+
+	// History stays readable once the entity is gone
+	if err := store.Delete(ctx, "notes", id); err != nil {
+		t.Fatal(err)
+	}
+	versions, err = h.History(ctx, "notes", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 6 || !versions[5].Deleted {
+		t.Errorf("Expected history to end with the delete, got %+v", versions)
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	testHistorian(t, store)
+
+	// History sits beside the entities without being listed as them
+	id, err := store.Create(context.Background(), "notes", map[string]interface{}{"text": "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := store.List(context.Background(), "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || fmt.Sprint(items[0]["id"]) != fmt.Sprint(id) {
+		t.Errorf("Expected only the live entity, got %v", items)
+	}
+}
+
+func testSoftDeleter(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	sd, ok := store.(storage.SoftDeleter)
+	if !ok {
+		t.Fatal("Store should implement SoftDeleter")
+	}
+
+	authorID, err := store.Create(ctx, "authors", map[string]interface{}{"name": "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]interface{}{"type": "REF", "entity": "authors", "id": authorID}
+	postID, err := store.Create(ctx, "posts", map[string]interface{}{"title": "Hello", "author": ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sd.Trash(ctx, "authors", authorID, 5); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("Expected ErrRevisionMismatch for a stale revision, got %v", err)
+	}
+	if err := sd.Trash(ctx, "authors", 99, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing entity, got %v", err)
+	}
+
+	before := time.Now()
+	if err := sd.Trash(ctx, "authors", authorID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// A trashed entity is gone from reads and traversals
+	if store.Exists(ctx, "authors", authorID) {
+		t.Error("Expected the trashed entity not to exist")
+	}
+	if items, _ := store.List(ctx, "authors"); len(items) != 0 {
+		t.Errorf("Expected no authors listed, got %v", items)
+	}
+	gn, hasGraph := store.(storage.GraphNeighbors)
+	if hasGraph {
+		if neighbors, _ := gn.GetNeighbors(ctx, "posts", postID, "out"); len(neighbors) != 0 {
+			t.Errorf("Expected no neighbors while the author is trashed, got %v", neighbors)
+		}
+	}
+
+	trashed, err := sd.ListTrash(ctx, "authors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != authorID || trashed[0].Data["name"] != "Ann" {
+		t.Fatalf("Expected the author in the trash, got %+v", trashed)
+	}
+	if trashed[0].DeletedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("Expected a deletion time, got %v", trashed[0].DeletedAt)
+	}
+	if items, _ := sd.ListTrash(ctx, "posts"); len(items) != 0 {
+		t.Errorf("Expected no posts in the trash, got %+v", items)
+	}
+
+	// Restoring brings back the entity and the edges pointing at it
+	if _, err := sd.Restore(ctx, "authors", 99); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring an entity not in the trash, got %v", err)
+	}
+	data, err := sd.Restore(ctx, "authors", authorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["name"] != "Ann" || fmt.Sprint(data["id"]) != fmt.Sprint(authorID) {
+		t.Errorf("Expected the restored data, got %v", data)
+	}
+	if got, err := store.Get(ctx, "authors", authorID); err != nil || got["name"] != "Ann" {
+		t.Errorf("Expected the restored author, got %v, %v", got, err)
+	}
+	if items, _ := sd.ListTrash(ctx, "authors"); len(items) != 0 {
+		t.Errorf("Expected the trash to be empty, got %+v", items)
+	}
+	if hasGraph {
+		if neighbors, _ := gn.GetNeighbors(ctx, "posts", postID, "out"); len(neighbors) != 1 {
+			t.Errorf("Expected the post's edge to the author back, got %v", neighbors)
+		}
+	}
+	if rv, ok := store.(storage.Revisioner); ok {
+		if _, revision, _ := rv.GetRevision(ctx, "authors", authorID); revision != 3 {
+			t.Errorf("Expected the restore to be revision 3, got %d", revision)
+		}
+	}
+
+	// Restoring over a live entity with the same ID fails
+	if err := sd.Trash(ctx, "authors", authorID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, "authors", authorID, map[string]interface{}{"name": "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sd.Restore(ctx, "authors", authorID); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists restoring over a live entity, got %v", err)
+	}
+
+	// Purging removes what was trashed before the cutoff
+	if err := sd.Trash(ctx, "posts", postID, 0); err != nil {
+		t.Fatal(err)
+	}
+	purged, err := sd.PurgeTrash(ctx, "authors", time.Now().Add(-time.Hour))
+	if err != nil || len(purged) != 0 {
+		t.Errorf("Expected nothing purged before the cutoff, got %+v, %v", purged, err)
+	}
+	purged, err = sd.PurgeTrash(ctx, "", time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 2 {
+		t.Fatalf("Expected both trashed entities purged, got %+v", purged)
+	}
+	for _, entity := range []string{"authors", "posts"} {
+		if items, _ := sd.ListTrash(ctx, entity); len(items) != 0 {
+			t.Errorf("Expected no %s left in the trash, got %+v", entity, items)
+		}
+	}
+	if _, err := sd.Restore(ctx, "posts", postID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring a purged entity, got %v", err)
+	}
+}
+
+// testTxSoftDeleter checks that trashing, restoring and purging through a
+// transaction only take effect when it commits
+func testTxSoftDeleter(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	id, err := store.Create(ctx, "notes", map[string]interface{}{"text": "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inTx := func(commit bool, fn func(sd storage.SoftDeleter) error) {
+		t.Helper()
+		tx, err := store.(storage.Transactional).Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd, ok := tx.(storage.SoftDeleter)
+		if !ok {
+			tx.Rollback()
+			t.Fatal("Transaction should implement SoftDeleter")
+		}
+		if err := fn(sd); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	trashed := func() int {
+		t.Helper()
+		items, err := store.(storage.SoftDeleter).ListTrash(ctx, "notes")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(items)
+	}
+
+	trash := func(sd storage.SoftDeleter) error { return sd.Trash(ctx, "notes", id, 0) }
+	inTx(false, trash)
+	if !store.Exists(ctx, "notes", id) || trashed() != 0 {
+		t.Error("Expected a rolled back trash to be dropped")
+	}
+	inTx(true, trash)
+	if store.Exists(ctx, "notes", id) || trashed() != 1 {
+		t.Error("Expected a committed trash to take effect")
+	}
+
+	inTx(false, func(sd storage.SoftDeleter) error {
+		_, err := sd.Restore(ctx, "notes", id)
+		return err
+	})
+	if store.Exists(ctx, "notes", id) || trashed() != 1 {
+		t.Error("Expected a rolled back restore to be dropped")
+	}
+
+	inTx(true, func(sd storage.SoftDeleter) error {
+		purged, err := sd.PurgeTrash(ctx, "notes", time.Now().Add(time.Second))
+		if err == nil && len(purged) != 1 {
+			err = fmt.Errorf("expected one entity purged, got %+v", purged)
+		}
+		return err
+	})
+	if trashed() != 0 {
+		t.Error("Expected a committed purge to take effect")
+	}
+}
+
+func TestStoreSoftDelete(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	testSoftDeleter(t, store)
 
-	t.Run("Concurrent creates", func(t *testing.T) {
-		const numGoroutines = 10
+	// The trash is not listed as an entity type
+	entities, err := store.(storage.EntityLister).ListEntities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 {
+		t.Errorf("Expected only authors and posts, got %v", entities)
+	}
+}
 
-		done := make(chan int, numGoroutines)
+// testChangeLog exercises a store's ChangeLog implementation
+func testChangeLog(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	log, ok := store.(storage.ChangeLog)
+	if !ok {
+		t.Fatal("Store should implement ChangeLog")
+	}
 
-		for i := 0; i < numGoroutines; i++ {
-			go func(n int) {
-				data := map[string]interface{}{
-					"name": "Concurrent User",
-					"num":  n,
-				}
-				id, err := store.Create(ctx, "users", data)
-				if err != nil {
-					t.Errorf("Concurrent create failed: %v", err)
-				}
-				done <- id
-			}(i)
+	if changes, err := log.Changes(ctx, 0, 10); err != nil || len(changes) != 0 {
+		t.Fatalf("Expected an empty change log, got %+v, %v", changes, err)
+	}
+	if seq, err := log.LastChangeSeq(ctx); err != nil || seq != 0 {
+		t.Fatalf("Expected no last change, got %d, %v", seq, err)
+	}
+
+	var seqs []int64
+	for i := 1; i <= 5; i++ {
+		change := &storage.Change{
+			Time:          time.Now(),
+			Entity:        "posts",
+			ID:            i,
+			Operation:     "create",
+			Revision:      1,
+			ChangedFields: []string{"title"},
+			EdgesAdded:    []models.GraphEdge{{From: fmt.Sprintf("posts:%d", i), To: "authors:1", Relationship: "author"}},
+		}
+		if err := log.AppendChange(ctx, change); err != nil {
+			t.Fatal(err)
 		}
+		if len(seqs) > 0 && change.Seq <= seqs[len(seqs)-1] {
+			t.Errorf("Expected sequence numbers to increase, got %d after %d", change.Seq, seqs[len(seqs)-1])
+		}
+		seqs = append(seqs, change.Seq)
+	}
 
-		ids := make(map[int]bool)
-		for i := 0; i < numGoroutines; i++ {
-			id := <-done
-			if ids[id] {
-				t.Errorf("Duplicate ID generated: %d", id)
-			}
-			ids[id] = true
+	changes, err := log.Changes(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 5 {
+		t.Fatalf("Expected 5 changes, got %d", len(changes))
+	}
+	for i, change := range changes {
+		if change.Seq != seqs[i] || change.ID != i+1 || change.Operation != "create" {
+			t.Errorf("Change %d came back as %+v", i, change)
+		}
+		if len(change.EdgesAdded) != 1 || change.EdgesAdded[0].To != "authors:1" {
+			t.Errorf("Expected change %d to keep its edges, got %+v", i, change.EdgesAdded)
 		}
+	}
 
-		if len(ids) != numGoroutines {
-			t.Errorf("Expected %d unique IDs, got %d", numGoroutines, len(ids))
+	// Resuming returns only later changes, up to the limit
+	changes, err = log.Changes(ctx, seqs[1], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Seq != seqs[2] || changes[1].Seq != seqs[3] {
+		t.Errorf("Expected changes %d and %d, got %+v", seqs[2], seqs[3], changes)
+	}
+	if changes, _ := log.Changes(ctx, seqs[4], 10); len(changes) != 0 {
+		t.Errorf("Expected no changes after the last one, got %+v", changes)
+	}
+	if seq, err := log.LastChangeSeq(ctx); err != nil || seq != seqs[4] {
+		t.Errorf("Expected the last change to be %d, got %d, %v", seqs[4], seq, err)
+	}
+}
+
+// testTxChangeLog checks that changes appended in a transaction are logged
+// when it commits and dropped when it rolls back
+func testTxChangeLog(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	log := store.(storage.ChangeLog)
+	before, err := log.Changes(ctx, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendInTx := func(id int, commit bool) {
+		t.Helper()
+		tx, err := store.(storage.Transactional).Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
 		}
-	})
+		appender, ok := tx.(storage.ChangeAppender)
+		if !ok {
+			tx.Rollback()
+			t.Fatal("Transaction should implement ChangeAppender")
+		}
+		if err := tx.Save(ctx, "posts", id, map[string]interface{}{"title": "logged"}); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := appender.AppendChange(ctx, &storage.Change{Entity: "posts", ID: id, Operation: "save"}); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendInTx(100, false)
+	if changes, _ := log.Changes(ctx, 0, 1000); len(changes) != len(before) {
+		t.Errorf("Expected a rolled back change to be dropped, got %d changes instead of %d", len(changes), len(before))
+	}
+	if store.Exists(ctx, "posts", 100) {
+		t.Error("Expected the rolled back save to be dropped")
+	}
+
+	appendInTx(101, true)
+	changes, err := log.Changes(ctx, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != len(before)+1 || changes[len(changes)-1].ID != 101 {
+		t.Errorf("Expected the committed change to be logged, got %+v", changes)
+	}
+	if !store.Exists(ctx, "posts", 101) {
+		t.Error("Expected the committed save to be stored")
+	}
 }
 
-func TestStoreInfo(t *testing.T) {
+func TestStoreChangeLog(t *testing.T) {
 	store, tmpDir := setupTestStore(t)
 	defer os.RemoveAll(tmpDir)
 	defer store.Close()
 
-	t.Run("Store provides info", func(t *testing.T) {
-		infoProvider, ok := store.(storage.InfoProvider)
-		if !ok {
-			t.Skip("Store does not provide info")
-		}
+	testChangeLog(t, store)
 
-		info := infoProvider.Info()
+	// The change log is not listed as an entity type, and survives a restart
+	entities, err := store.(storage.EntityLister).ListEntities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 0 {
+		t.Errorf("Expected no entity types, got %v", entities)
+	}
 
-		if info.Type == "" {
-			t.Error("Expected store type")
-		}
-		if info.Version == "" {
-			t.Error("Expected store version")
+	reopened, err := storage.NewJSONFileStore(tmpDir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := &storage.Change{Entity: "posts", ID: 6, Operation: "delete"}
+	if err := reopened.AppendChange(context.Background(), change); err != nil {
+		t.Fatal(err)
+	}
+	if change.Seq != 6 {
+		t.Errorf("Expected the reopened log to carry on at 6, got %d", change.Seq)
+	}
+
+	// A line torn by a crash mid-append is dropped by the next append
+	path := filepath.Join(tmpDir, "test", "_changes", "changes.log")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":7,"entity":"po`)
+	f.Close()
+
+	change = &storage.Change{Entity: "posts", ID: 7, Operation: "create"}
+	if err := reopened.AppendChange(context.Background(), change); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := reopened.Changes(context.Background(), 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[1].Seq != 7 || changes[1].ID != 7 {
+		t.Errorf("Expected changes 6 and 7 after the torn line, got %+v", changes)
+	}
+}
+
+func TestStoreSchemas(t *testing.T) {
+	store, tmpDir := setupTestStore(t)
+	defer os.RemoveAll(tmpDir)
+	defer store.Close()
+
+	testSchemaStore(t, store)
+
+	// Schema files live apart from entity directories
+	entities, err := store.(storage.EntityLister).ListEntities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 0 {
+		t.Errorf("Expected schemas not to be listed as entities, got %v", entities)
+	}
+
+	t.Run("Schema directory", func(t *testing.T) {
+		schemaDir := filepath.Join(tmpDir, "schema")
+		store, err := storage.NewStore("jsonfile", map[string]interface{}{
+			"base_dir":   tmpDir,
+			"schema":     "other",
+			"schema_dir": schemaDir,
+		})
+		if err != nil {
+			t.Fatal(err)
 		}
+		defer store.Close()
 
-		t.Logf("Store: %s v%s", info.Type, info.Version)
-		t.Logf("  Supports Search: %v", info.SupportsSearch)
-		t.Logf("  Supports Batch: %v", info.SupportsBatch)
-		t.Logf("  Supports Transaction: %v", info.SupportsTransaction)
+		if err := store.(storage.SchemaStore).SaveSchema(context.Background(), "users", map[string]interface{}{"type": "object"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(schemaDir, "users.json")); err != nil {
+			t.Errorf("Expected schema file in the schema directory: %v", err)
+		}
 	})
 }
 
@@ -596,3 +1591,151 @@ func TestStoreFileStructure(t *testing.T) {
 		}
 	})
 }
+
+func TestStoreCrashRecovery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "olu-storage-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	storeConfig := map[string]interface{}{
+		"base_dir": tmpDir,
+		"schema":   "test",
+	}
+
+	store, err := storage.NewStore("jsonfile", storeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := store.Create(ctx, "users", map[string]interface{}{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// Simulate a crash: a half-written temp file and a stale, then a corrupt, ID file
+	usersDir := filepath.Join(tmpDir, "test", "users")
+	strayPath := filepath.Join(usersDir, "4.json.123456.tmp")
+	if err := os.WriteFile(strayPath, []byte(`{"id": 4, "na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nextIDPath := filepath.Join(usersDir, "_next_id.json")
+
+	idFiles := []struct {
+		name     string
+		contents string
+	}{
+		{"Stale next ID", `{"next_id": 2}`},
+		{"Corrupt next ID", `{"next_`},
+	}
+	for _, tc := range idFiles {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(nextIDPath, []byte(tc.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := storage.NewStore("jsonfile", storeConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			if _, err := os.Stat(strayPath); !os.IsNotExist(err) {
+				t.Error("Expected stray temp file to be removed")
+			}
+
+			id, err := store.Create(ctx, "users", map[string]interface{}{"n": tc.name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != 4 {
+				t.Errorf("Expected ID 4 after the highest existing ID, got %d", id)
+			}
+			if err := store.Delete(ctx, "users", id); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("Save advances next ID", func(t *testing.T) {
+		store, err := storage.NewStore("jsonfile", storeConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		if err := store.Save(ctx, "users", 50, map[string]interface{}{"n": 50}); err != nil {
+			t.Fatal(err)
+		}
+		id, err := store.Create(ctx, "users", map[string]interface{}{"n": 51})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 51 {
+			t.Errorf("Expected ID 51 after saving 50, got %d", id)
+		}
+	})
+}
+
+func TestStoreSharedDirectory(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "olu-storage-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	storeConfig := map[string]interface{}{
+		"base_dir": tmpDir,
+		"schema":   "test",
+	}
+
+	// Separate store instances share nothing in memory, like separate
+	// processes, so only the file locks keep their IDs apart
+	const numStores, perStore = 4, 10
+	stores := make([]storage.Store, numStores)
+	for i := range stores {
+		if stores[i], err = storage.NewStore("jsonfile", storeConfig); err != nil {
+			t.Fatal(err)
+		}
+		defer stores[i].Close()
+	}
+
+	ids := make(chan int, numStores*perStore)
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		wg.Add(1)
+		go func(store storage.Store) {
+			defer wg.Done()
+			for i := 0; i < perStore; i++ {
+				id, err := store.Create(ctx, "users", map[string]interface{}{"n": i})
+				if err != nil {
+					t.Errorf("Create failed: %v", err)
+					return
+				}
+				ids <- id
+			}
+		}(store)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("Duplicate ID generated: %d", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != numStores*perStore {
+		t.Errorf("Expected %d unique IDs, got %d", numStores*perStore, len(seen))
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(tmpDir, "test", "users", "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("Expected no temp files left behind, got %v", matches)
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// TrashedEntity is an entity moved to the trash by a soft delete
+type TrashedEntity struct {
+	Entity    string
+	ID        int
+	Data      map[string]interface{}
+	DeletedAt time.Time
+}
+
+// trashRecord is a trashed entity as the bolt and JSON file stores keep it
+type trashRecord struct {
+	Data      map[string]interface{} `json:"data"`
+	DeletedAt string                 `json:"deleted_at"`
+}
+
+// sortTrash orders trashed entities most recently trashed first
+func sortTrash(items []TrashedEntity) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+}
+
+// expiredTrash returns the trashed entities deleted before a cutoff
+func expiredTrash(items []TrashedEntity, before time.Time) []TrashedEntity {
+	var expired []TrashedEntity
+	for _, item := range items {
+		if item.DeletedAt.Before(before) {
+			expired = append(expired, item)
+		}
+	}
+	return expired
+}
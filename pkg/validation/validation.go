@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/ha1tch/olu/pkg/models"
 )
 
 // Validator interface defines validation operations
@@ -14,12 +16,43 @@ type Validator interface {
 	LoadSchema(entity string, schemaData map[string]interface{}) error
 	HasSchema(entity string) bool
 	GetSchema(entity string) (map[string]interface{}, error)
+	RemoveSchema(entity string) bool
+	ListSchemas() map[string]map[string]interface{}
+}
+
+// Referential actions for REF properties, declared with "onDelete"
+const (
+	OnDeleteCascade  = "cascade"
+	OnDeleteRestrict = "restrict"
+	OnDeleteSetNull  = "set_null"
+	OnDeleteIgnore   = "ignore"
+)
+
+// ReferenceRule describes a REF property declared in a schema
+type ReferenceRule struct {
+	Field        string
+	TargetEntity string // empty means any entity type
+	OnDelete     string // empty means the server default
+}
+
+// ReferenceResolver reports whether a referenced entity exists
+type ReferenceResolver func(entity string, id int) bool
+
+// ReferenceValidator defines optional referential integrity support
+// Validators that understand REF annotations should implement this interface
+type ReferenceValidator interface {
+	SetReferenceResolver(resolver ReferenceResolver)
+	ReferenceRule(entity string, field string) (ReferenceRule, bool)
+	// ValidateWithResolver validates data, checking REF targets with the given
+	// resolver instead of the one set with SetReferenceResolver
+	ValidateWithResolver(entity string, data map[string]interface{}, resolver ReferenceResolver) (bool, []string)
 }
 
 // JSONSchemaValidator implements JSON schema validation
 type JSONSchemaValidator struct {
 	schemas    map[string]map[string]interface{}
 	schemaDir  string
+	resolver   ReferenceResolver
 	mu         sync.RWMutex
 }
 
@@ -31,8 +64,17 @@ func NewJSONSchemaValidator(schemaDir string) *JSONSchemaValidator {
 	}
 }
 
+// CheckSchema reports whether a schema would be accepted by LoadSchema
+func CheckSchema(schemaData map[string]interface{}) error {
+	return checkReferenceAnnotations(schemaData)
+}
+
 // LoadSchema loads a schema for an entity
 func (v *JSONSchemaValidator) LoadSchema(entity string, schemaData map[string]interface{}) error {
+	if err := CheckSchema(schemaData); err != nil {
+		return err
+	}
+	
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
@@ -40,6 +82,100 @@ func (v *JSONSchemaValidator) LoadSchema(entity string, schemaData map[string]in
 	return nil
 }
 
+// checkReferenceAnnotations rejects schemas with malformed onDelete/targetEntity
+func checkReferenceAnnotations(schemaData map[string]interface{}) error {
+	properties, ok := schemaData["properties"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	
+	for key, propSchema := range properties {
+		propMap, ok := propSchema.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		
+		if onDelete, exists := propMap["onDelete"]; exists {
+			switch onDelete {
+			case OnDeleteCascade, OnDeleteRestrict, OnDeleteSetNull, OnDeleteIgnore:
+			default:
+				return fmt.Errorf("property %s: invalid onDelete %v (must be cascade, restrict, set_null or ignore)", key, onDelete)
+			}
+		}
+		if target, exists := propMap["targetEntity"]; exists {
+			if name, ok := target.(string); !ok || name == "" {
+				return fmt.Errorf("property %s: targetEntity must be a non-empty string", key)
+			}
+		}
+	}
+	
+	return nil
+}
+
+// SetReferenceResolver sets the function used to check that REF targets exist
+func (v *JSONSchemaValidator) SetReferenceResolver(resolver ReferenceResolver) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	
+	v.resolver = resolver
+}
+
+// ReferenceRule returns the REF annotations of a schema property, if any. The
+// field may be a JSON path such as "address.city" or "tags[2]".
+func (v *JSONSchemaValidator) ReferenceRule(entity string, field string) (ReferenceRule, bool) {
+	v.mu.RLock()
+	schema, exists := v.schemas[entity]
+	v.mu.RUnlock()
+	
+	if !exists {
+		return ReferenceRule{}, false
+	}
+	
+	segments, err := models.ParsePath(field)
+	if err != nil {
+		return ReferenceRule{}, false
+	}
+	
+	return schemaReferenceRule(schema, field, segments)
+}
+
+// schemaReferenceRule finds the REF annotations of the property a parsed path
+// leads to in a schema
+func schemaReferenceRule(schema map[string]interface{}, field string, segments []models.PathSegment) (ReferenceRule, bool) {
+	// Follow the path through nested properties and array items
+	propMap := schema
+	for _, segment := range segments {
+		var next map[string]interface{}
+		if segment.Index >= 0 {
+			next, _ = propMap["items"].(map[string]interface{})
+		} else {
+			properties, _ := propMap["properties"].(map[string]interface{})
+			next, _ = properties[segment.Key].(map[string]interface{})
+		}
+		if next == nil {
+			return ReferenceRule{}, false
+		}
+		propMap = next
+	}
+	
+	return referenceRule(field, propMap)
+}
+
+// referenceRule extracts REF annotations from a property schema
+func referenceRule(field string, propMap map[string]interface{}) (ReferenceRule, bool) {
+	target, hasTarget := propMap["targetEntity"].(string)
+	onDelete, hasOnDelete := propMap["onDelete"].(string)
+	if !hasTarget && !hasOnDelete {
+		return ReferenceRule{}, false
+	}
+	
+	return ReferenceRule{
+		Field:        field,
+		TargetEntity: target,
+		OnDelete:     onDelete,
+	}, true
+}
+
 // LoadSchemaFromFile loads a schema from a file
 func (v *JSONSchemaValidator) LoadSchemaFromFile(entity string) error {
 	schemaFile := filepath.Join(v.schemaDir, entity+".json")
@@ -81,8 +217,40 @@ func (v *JSONSchemaValidator) GetSchema(entity string) (map[string]interface{},
 	return schema, nil
 }
 
+// RemoveSchema unloads an entity's schema, reporting whether it had one
+func (v *JSONSchemaValidator) RemoveSchema(entity string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	
+	_, exists := v.schemas[entity]
+	delete(v.schemas, entity)
+	return exists
+}
+
+// ListSchemas returns every loaded schema keyed by entity
+func (v *JSONSchemaValidator) ListSchemas() map[string]map[string]interface{} {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	
+	schemas := make(map[string]map[string]interface{}, len(v.schemas))
+	for entity, schema := range v.schemas {
+		schemas[entity] = schema
+	}
+	return schemas
+}
+
 // Validate validates data against a schema
 func (v *JSONSchemaValidator) Validate(entity string, data map[string]interface{}) (bool, []string) {
+	v.mu.RLock()
+	resolver := v.resolver
+	v.mu.RUnlock()
+	
+	return v.ValidateWithResolver(entity, data, resolver)
+}
+
+// ValidateWithResolver validates data against a schema, checking that REF
+// targets exist with the given resolver (nil skips the check)
+func (v *JSONSchemaValidator) ValidateWithResolver(entity string, data map[string]interface{}, resolver ReferenceResolver) (bool, []string) {
 	v.mu.RLock()
 	schema, exists := v.schemas[entity]
 	v.mu.RUnlock()
@@ -123,6 +291,18 @@ func (v *JSONSchemaValidator) Validate(entity string, data map[string]interface{
 				continue
 			}
 			
+			// REF properties hold a reference or null, e.g. after
+			// onDelete set_null; the references are checked below
+			if _, isRefProp := referenceRule(key, propMap); isRefProp {
+				if value == nil {
+					continue
+				}
+				if _, isRef := models.IsReference(value); !isRef {
+					errors = append(errors, fmt.Sprintf("field %s: expected a reference", key))
+					continue
+				}
+			}
+			
 			// Validate type
 			if expectedType, ok := propMap["type"].(string); ok {
 				actualType := getJSONType(value)
@@ -185,6 +365,30 @@ func (v *JSONSchemaValidator) Validate(entity string, data map[string]interface{
 		}
 	}
 	
+	// Check every reference, however deeply nested, against the
+	// annotations of the property that holds it, and that its target exists
+	for _, field := range models.FindReferences(data) {
+		if field.Path == "id" {
+			continue
+		}
+		segments, err := models.ParsePath(field.Path)
+		if err != nil {
+			continue
+		}
+		if rule, ok := schemaReferenceRule(schema, field.Path, segments); ok {
+			if rule.TargetEntity != "" && field.Entity != rule.TargetEntity {
+				errors = append(errors, 
+					fmt.Sprintf("field %s: reference must point to %s, got %s", 
+						field.Path, rule.TargetEntity, field.Entity))
+				continue
+			}
+		}
+		if resolver != nil && !resolver(field.Entity, field.ID) {
+			errors = append(errors, 
+				fmt.Sprintf("field %s: referenced %s:%d does not exist", field.Path, field.Entity, field.ID))
+		}
+	}
+	
 	return len(errors) == 0, errors
 }
 
@@ -285,3 +489,13 @@ func (n *NoOpValidator) HasSchema(entity string) bool {
 func (n *NoOpValidator) GetSchema(entity string) (map[string]interface{}, error) {
 	return nil, fmt.Errorf("no-op validator has no schemas")
 }
+
+// RemoveSchema is a no-op
+func (n *NoOpValidator) RemoveSchema(entity string) bool {
+	return false
+}
+
+// ListSchemas always returns no schemas
+func (n *NoOpValidator) ListSchemas() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{}
+}
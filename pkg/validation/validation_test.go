@@ -0,0 +1,119 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/olu/pkg/validation"
+)
+
+func ref(entity string, id int) map[string]interface{} {
+	return map[string]interface{}{"type": "REF", "entity": entity, "id": float64(id)}
+}
+
+// setupValidator loads a posts schema with REF properties at the top level,
+// inside an object and as array items. Users 1 and 2 exist.
+func setupValidator(t *testing.T) *validation.JSONSchemaValidator {
+	t.Helper()
+
+	v := validation.NewJSONSchemaValidator("")
+	err := v.LoadSchema("posts", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"author": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "cascade"},
+			"meta": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"editor": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "set_null"},
+				},
+			},
+			"reviewers": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "object", "targetEntity": "users", "onDelete": "ignore"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.SetReferenceResolver(func(entity string, id int) bool {
+		return entity == "users" && (id == 1 || id == 2)
+	})
+	return v
+}
+
+func expectValid(t *testing.T, v *validation.JSONSchemaValidator, data map[string]interface{}) {
+	t.Helper()
+	if valid, errs := v.Validate("posts", data); !valid {
+		t.Errorf("Expected %v to be valid, got %v", data, errs)
+	}
+}
+
+func expectInvalid(t *testing.T, v *validation.JSONSchemaValidator, data map[string]interface{}, want string) {
+	t.Helper()
+	valid, errs := v.Validate("posts", data)
+	if valid {
+		t.Fatalf("Expected %v to be invalid", data)
+	}
+	if !strings.Contains(strings.Join(errs, "; "), want) {
+		t.Errorf("Expected an error mentioning %q, got %v", want, errs)
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	v := setupValidator(t)
+
+	expectValid(t, v, map[string]interface{}{"author": ref("users", 1)})
+	expectValid(t, v, map[string]interface{}{"author": nil})
+	expectInvalid(t, v, map[string]interface{}{"author": "users:1"}, "field author: expected a reference")
+	expectInvalid(t, v, map[string]interface{}{"author": ref("teams", 1)}, "field author: reference must point to users")
+	expectInvalid(t, v, map[string]interface{}{"author": ref("users", 9)}, "field author: referenced users:9 does not exist")
+}
+
+func TestValidateNestedReferences(t *testing.T) {
+	v := setupValidator(t)
+
+	expectValid(t, v, map[string]interface{}{
+		"meta": map[string]interface{}{"editor": ref("users", 2)},
+	})
+	expectInvalid(t, v, map[string]interface{}{
+		"meta": map[string]interface{}{"editor": ref("users", 9)},
+	}, "field meta.editor: referenced users:9 does not exist")
+	expectInvalid(t, v, map[string]interface{}{
+		"meta": map[string]interface{}{"editor": ref("teams", 1)},
+	}, "field meta.editor: reference must point to users")
+
+	// References outside any annotated property must still exist
+	expectInvalid(t, v, map[string]interface{}{
+		"extra": map[string]interface{}{"owner": ref("users", 7)},
+	}, "field extra.owner: referenced users:7 does not exist")
+}
+
+func TestValidateReferenceArrays(t *testing.T) {
+	v := setupValidator(t)
+
+	expectValid(t, v, map[string]interface{}{
+		"reviewers": []interface{}{ref("users", 1), ref("users", 2)},
+	})
+	expectInvalid(t, v, map[string]interface{}{
+		"reviewers": []interface{}{ref("users", 1), ref("users", 9)},
+	}, "field reviewers[1]: referenced users:9 does not exist")
+	expectInvalid(t, v, map[string]interface{}{
+		"reviewers": []interface{}{ref("teams", 1)},
+	}, "field reviewers[0]: reference must point to users")
+}
+
+func TestValidateWithoutResolver(t *testing.T) {
+	v := setupValidator(t)
+
+	data := map[string]interface{}{
+		"reviewers": []interface{}{ref("users", 9)},
+	}
+	if valid, errs := v.ValidateWithResolver("posts", data, nil); !valid {
+		t.Errorf("Expected existence checks to be skipped without a resolver, got %v", errs)
+	}
+	expectInvalid(t, v, map[string]interface{}{
+		"reviewers": []interface{}{ref("teams", 1)},
+	}, "reference must point to users")
+}